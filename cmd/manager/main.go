@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 
@@ -8,13 +9,20 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/controllers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/metrics"
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/webhooks"
 )
 
 var (
@@ -25,6 +33,7 @@ var (
 func init() {
 	// Register our types with the scheme
 	//_ = scheme.New(scheme)
+	_ = clientgoscheme.AddToScheme(scheme)
 	_ = clusterv1alpha1.AddToScheme(scheme)
 }
 
@@ -33,12 +42,38 @@ func main() {
 		metricsAddr          string
 		enableLeaderElection bool
 		probeAddr            string
+		traceOpts            tracing.Options
+		maxNodeOperations    int
+		nodeImageRepository  string
+		versionCatalogFile   string
+		versionCatalogCM     string
+		enableWebhooks       bool
+		etcdSnapshotDir      string
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&maxNodeOperations, "max-concurrent-node-operations", providers.DefaultMaxConcurrentNodeOperations,
+		"How many nodes of a cluster are created or removed at the same time.")
+	flag.StringVar(&nodeImageRepository, "node-image-repository", providers.DefaultNodeImageRepository,
+		"The repository node images are pulled from unless a cluster names another one.")
+	flag.StringVar(&etcdSnapshotDir, "etcd-snapshot-dir", providers.DefaultEtcdSnapshotDirectory(),
+		"The directory etcd snapshots are stored in, before upgrades and for backups without a location.")
+	flag.StringVar(&versionCatalogFile, "version-catalog-file", "",
+		"A file with Kubernetes versions that override or extend the built-in version catalog.")
+	flag.StringVar(&versionCatalogCM, "version-catalog-configmap", "",
+		"The namespace/name of a ConfigMap with Kubernetes versions that override or extend the built-in version catalog.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the Cluster validating webhook. Requires a serving certificate in the webhook server's certificate directory.")
+	flag.StringVar(&traceOpts.Exporter, "trace-exporter", tracing.ExporterNone,
+		"Where to export traces: none, otlp (OTLP over HTTP) or file.")
+	flag.StringVar(&traceOpts.Endpoint, "trace-otlp-endpoint", "",
+		"The host:port of the OTLP/HTTP collector. Defaults to the OTEL_EXPORTER_OTLP_* environment variables.")
+	flag.BoolVar(&traceOpts.Insecure, "trace-otlp-insecure", false, "Connect to the OTLP collector without TLS.")
+	flag.StringVar(&traceOpts.FilePath, "trace-file", "traces.json", "The file the file trace exporter writes spans to.")
+	flag.Float64Var(&traceOpts.SampleRatio, "trace-sample-ratio", 1, "The fraction of reconciles that are traced.")
 
 	opts := zap.Options{
 		Development: true,
@@ -48,10 +83,24 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	ctx := ctrl.SetupSignalHandler()
+
+	shutdownTracing, err := tracing.Setup(ctx, traceOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "problem flushing traces")
+		}
+	}()
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
-		//MetricsBindAddress:     metricsAddr,
-		//Port:                   9443,
+		Metrics: metricsserver.Options{
+			BindAddress: metricsAddr,
+		},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "mini-k8s-manager.mini-k8s.io",
@@ -61,6 +110,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Load the Kubernetes version catalog. The cache is not running yet, so
+	// the ConfigMap is read directly from the API server.
+	catalog, err := versions.Load(ctx, mgr.GetAPIReader(), versionCatalogFile, versionCatalogCM)
+	if err != nil {
+		setupLog.Error(err, "unable to load version catalog")
+		os.Exit(1)
+	}
+
 	// Create Docker provider
 	provider, err := providers.NewDockerProvider(&clusterv1alpha1.DockerProviderConfig{
 		Spec: clusterv1alpha1.DockerProviderConfigSpec{
@@ -71,8 +128,11 @@ func main() {
 				EnableIPv6:    false,
 				DNSNameserver: "8.8.8.8",
 			},
+			MaxConcurrentNodeOperations: int32(maxNodeOperations),
+			NodeImageRepository:         nodeImageRepository,
+			EtcdSnapshotDirectory:       etcdSnapshotDir,
 		},
-	})
+	}, providers.WithVersionCatalog(catalog))
 	if err != nil {
 		setupLog.Error(err, "unable to create Docker provider")
 		os.Exit(1)
@@ -80,11 +140,47 @@ func main() {
 
 	// Set up the cluster controller
 	if err = (&controllers.ClusterReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Provider:  provider,
+		Recorder:  mgr.GetEventRecorderFor("cluster-controller"),
+		Versions:  catalog,
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
+		os.Exit(1)
+	}
+
+	// Set up the etcd backup and restore controllers
+	if err = (&controllers.ClusterBackupReconciler{
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		Provider: provider,
+		Recorder: mgr.GetEventRecorderFor("clusterbackup-controller"),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.ClusterRestoreReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Provider: provider,
+		Recorder: mgr.GetEventRecorderFor("clusterrestore-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterRestore")
+		os.Exit(1)
+	}
+
+	if enableWebhooks {
+		if err := (&webhooks.ClusterValidator{Versions: catalog}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Cluster")
+			os.Exit(1)
+		}
+	}
+
+	// Report cluster phases and node counts on the metrics endpoint
+	if err := ctrlmetrics.Registry.Register(metrics.NewClusterCollector(mgr.GetClient())); err != nil {
+		setupLog.Error(err, "unable to register cluster metrics")
 		os.Exit(1)
 	}
 
@@ -99,8 +195,9 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
+		shutdownTracing(context.Background())
 		os.Exit(1)
 	}
 }
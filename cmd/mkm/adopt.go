@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// newAdoptCommand returns the command that brings an existing kind cluster under management
+func newAdoptCommand(opts *globalOptions) *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "adopt KIND_CLUSTER",
+		Short: "Bring an existing kind cluster under management",
+		Long: `Create a Cluster resource that adopts an existing kind cluster.
+
+The manager inspects the kind cluster's containers and derives the Cluster
+spec from them. The containers are left as they are; the manager finds them
+through kind's labels from then on.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source := args[0]
+			if name == "" {
+				name = source
+			}
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			cluster := &clusterv1alpha1.Cluster{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: clusterv1alpha1.GroupVersion.String(),
+					Kind:       "Cluster",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Annotations: map[string]string{
+						clusterv1alpha1.AdoptAnnotation: source,
+					},
+				},
+			}
+			if err := c.Create(cmd.Context(), cluster); err != nil {
+				return fmt.Errorf("failed to create cluster %s: %w", name, err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s created, adopting kind cluster %s\n", namespace, name, source)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "Name of the Cluster resource (defaults to the kind cluster name)")
+	return cmd
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// newBackupCommand returns the command that backs up the etcd of a cluster
+func newBackupCommand(opts *globalOptions) *cobra.Command {
+	var (
+		name      string
+		hostPath  string
+		volume    string
+		interval  time.Duration
+		retention int32
+	)
+
+	cmd := &cobra.Command{
+		Use:   "backup CLUSTER",
+		Short: "Back up the etcd of a cluster, once or on a schedule",
+		Long: `Create a ClusterBackup resource for a cluster.
+
+The manager runs etcdctl snapshot save in the cluster's control plane node and
+copies the snapshot to a directory on the manager's host or to a Docker volume.
+With --interval a snapshot is taken every interval and only the newest
+--retention snapshots are kept.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if hostPath != "" && volume != "" {
+				return fmt.Errorf("--host-path and --volume cannot be used together")
+			}
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			backup := &clusterv1alpha1.ClusterBackup{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: clusterv1alpha1.GroupVersion.String(),
+					Kind:       "ClusterBackup",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: clusterv1alpha1.ClusterBackupSpec{
+					ClusterName: args[0],
+					Location:    clusterv1alpha1.BackupLocation{HostPath: hostPath, Volume: volume},
+					Retention:   retention,
+				},
+			}
+			if name == "" {
+				backup.GenerateName = args[0] + "-backup-"
+			}
+			if interval > 0 {
+				backup.Spec.Interval = &metav1.Duration{Duration: interval}
+			}
+			if err := c.Create(cmd.Context(), backup); err != nil {
+				return fmt.Errorf("failed to create cluster backup: %w", err)
+			}
+
+			if interval > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "cluster backup %s/%s created, backing up cluster %s to %s every %s\n",
+					namespace, backup.Name, args[0], backup.Spec.Location, interval)
+				return nil
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster backup %s/%s created, backing up cluster %s to %s\n",
+				namespace, backup.Name, args[0], backup.Spec.Location)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "Name of the ClusterBackup resource (defaults to a generated name)")
+	cmd.Flags().StringVar(&hostPath, "host-path", "", "Directory on the manager's host to store snapshots in")
+	cmd.Flags().StringVar(&volume, "volume", "", "Docker volume to store snapshots in")
+	cmd.Flags().DurationVar(&interval, "interval", 0, "Take a snapshot every interval instead of once")
+	cmd.Flags().Int32Var(&retention, "retention", 0,
+		fmt.Sprintf("Number of scheduled snapshots to keep (defaults to %d)", clusterv1alpha1.DefaultBackupRetention))
+	return cmd
+}
+
+// newRestoreCommand returns the command that restores a cluster from a backup
+func newRestoreCommand(opts *globalOptions) *cobra.Command {
+	var (
+		name     string
+		backup   string
+		snapshot string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore CLUSTER --backup BACKUP",
+		Short: "Restore the etcd of a cluster from a backup",
+		Long: `Create a ClusterRestore resource for a cluster.
+
+The manager replaces the etcd data of the cluster's control plane nodes with a
+snapshot of the ClusterBackup, the newest one unless --snapshot names another.
+The cluster may be a new one standing in for the cluster that was backed up.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			restore := &clusterv1alpha1.ClusterRestore{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: clusterv1alpha1.GroupVersion.String(),
+					Kind:       "ClusterRestore",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: clusterv1alpha1.ClusterRestoreSpec{
+					ClusterName: args[0],
+					BackupName:  backup,
+					Snapshot:    snapshot,
+				},
+			}
+			if name == "" {
+				restore.GenerateName = args[0] + "-restore-"
+			}
+			if err := c.Create(cmd.Context(), restore); err != nil {
+				return fmt.Errorf("failed to create cluster restore: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster restore %s/%s created, restoring cluster %s from backup %s\n",
+				namespace, restore.Name, args[0], backup)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "Name of the ClusterRestore resource (defaults to a generated name)")
+	cmd.Flags().StringVar(&backup, "backup", "", "Name of the ClusterBackup holding the snapshot")
+	cmd.Flags().StringVar(&snapshot, "snapshot", "", "Name of the snapshot to restore (defaults to the newest)")
+	_ = cmd.MarkFlagRequired("backup")
+	return cmd
+}
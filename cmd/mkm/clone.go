@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// newCloneCommand returns the command that forks a running cluster into a new one
+func newCloneCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clone SOURCE NAME",
+		Short: "Create a copy of a running cluster, including its state",
+		Long: `Create a Cluster resource that is a copy of the running cluster SOURCE.
+
+The manager snapshots the source's etcd, copies its nodes and their volumes
+into new nodes on a network of their own, and gives the copies their new
+names, addresses and certificates. The source keeps running; each of its
+nodes is paused while it is copied.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, name := args[0], args[1]
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			cluster := &clusterv1alpha1.Cluster{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: clusterv1alpha1.GroupVersion.String(),
+					Kind:       "Cluster",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: clusterv1alpha1.ClusterSpec{
+					CloneFrom: &corev1.LocalObjectReference{Name: source},
+				},
+			}
+			if err := c.Create(cmd.Context(), cluster); err != nil {
+				return fmt.Errorf("failed to create cluster %s: %w", name, err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s created, cloning cluster %s\n", namespace, name, source)
+			return nil
+		},
+	}
+	return cmd
+}
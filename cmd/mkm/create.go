@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+)
+
+// newCreateCommand returns the command that creates clusters
+func newCreateCommand(opts *globalOptions) *cobra.Command {
+	var (
+		file          string
+		version       string
+		controlPlanes int32
+		workers       int32
+		memory        string
+		cpus          int32
+		waitOpts      waitOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create [NAME]",
+		Short: "Create a cluster",
+		Long: `Create a Cluster resource, from the flags or from the manifests in a file.
+
+With -f every Cluster in the file, or in stdin for -, is created; Clusters
+without a namespace get the one of the command. Otherwise a cluster NAME is
+created with the given version, node counts and node resources.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (file == "") == (len(args) == 0) {
+				return fmt.Errorf("either a cluster name or -f is required")
+			}
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+
+			var clusters []*clusterv1alpha1.Cluster
+			if file != "" {
+				if clusters, err = readClusterManifests(file, cmd.InOrStdin()); err != nil {
+					return err
+				}
+				for _, cluster := range clusters {
+					if cluster.Namespace == "" {
+						cluster.Namespace = namespace
+					}
+				}
+			} else {
+				if version == "" {
+					return fmt.Errorf("--kubernetes-version is required")
+				}
+				machine := clusterv1alpha1.MachineConfig{Memory: memory, CPUCount: cpus}
+				clusters = append(clusters, &clusterv1alpha1.Cluster{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: clusterv1alpha1.GroupVersion.String(),
+						Kind:       "Cluster",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      args[0],
+						Namespace: namespace,
+					},
+					Spec: clusterv1alpha1.ClusterSpec{
+						KubernetesVersion: version,
+						ControlPlane:      clusterv1alpha1.ControlPlaneConfig{Count: controlPlanes, MachineConfig: machine},
+						Workers:           clusterv1alpha1.WorkerConfig{Count: workers, MachineConfig: machine},
+					},
+				})
+			}
+
+			// Catch what the provider would reject before anything is created.
+			// Clones may leave their spec to their source.
+			for _, cluster := range clusters {
+				if cluster.Spec.CloneFrom != nil && cluster.Spec.KubernetesVersion == "" {
+					continue
+				}
+				if err := clusterops.ValidateSpec(&cluster.Spec, nil); err != nil {
+					return fmt.Errorf("cluster %s: %w", cluster.Name, err)
+				}
+			}
+
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+			for _, cluster := range clusters {
+				if err := c.Create(cmd.Context(), cluster); err != nil {
+					return fmt.Errorf("failed to create cluster %s: %w", cluster.Name, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s created, running Kubernetes %s with %d control plane nodes and %d workers\n",
+					cluster.Namespace, cluster.Name, cluster.Spec.KubernetesVersion, cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count)
+			}
+
+			if !waitOpts.wait {
+				return nil
+			}
+			for _, cluster := range clusters {
+				if err := waitForReady(cmd.Context(), c, cluster, waitOpts.timeout, cmd.ErrOrStderr()); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s is ready\n", cluster.Namespace, cluster.Name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "File with the Cluster manifests to create, or - for stdin")
+	cmd.Flags().StringVar(&version, "kubernetes-version", "", "Kubernetes version of the cluster, e.g. v1.31.0")
+	cmd.Flags().Int32Var(&controlPlanes, "control-planes", 1, "Number of control plane nodes")
+	cmd.Flags().Int32Var(&workers, "workers", 1, "Number of worker nodes")
+	cmd.Flags().StringVar(&memory, "memory", "2Gi", "Memory of each node")
+	cmd.Flags().Int32Var(&cpus, "cpus", 2, "CPUs of each node")
+	waitOpts.addFlags(cmd, "the clusters are ready")
+	return cmd
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/controllers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/standalone"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+// newDaemonCommand returns the command that runs the controllers of
+// standalone mode
+func newDaemonCommand(opts *globalOptions) *cobra.Command {
+	var (
+		maxNodeOperations   int
+		nodeImageRepository string
+		etcdSnapshotDir     string
+		versionCatalogFile  string
+		versionCatalogCM    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the cluster controllers against the local state store",
+		Long: `Run the cluster, backup and restore controllers against the local state
+store in --state-dir, with the Docker provider, until interrupted.
+
+In standalone mode the other commands keep Cluster resources in the state
+store instead of an API server. The daemon reconciles them as the manager
+does, with the same phases, finalizers and status, so it must be running
+for clusters to be created, changed or deleted, and for --wait to finish.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.stateDir == "" {
+				return errors.New("the daemon needs a state store, set --state-dir or " + stateDirEnv)
+			}
+			log.SetLogger(zap.New(zap.UseDevMode(true)))
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			scheme, err := newScheme()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newStandaloneClient(scheme)
+			if err != nil {
+				return err
+			}
+
+			catalog, err := versions.Load(ctx, c, versionCatalogFile, versionCatalogCM)
+			if err != nil {
+				return err
+			}
+			provider, err := providers.NewDockerProvider(&clusterv1alpha1.DockerProviderConfig{
+				Spec: clusterv1alpha1.DockerProviderConfigSpec{
+					Network: clusterv1alpha1.NetworkConfig{
+						CIDR:          "10.10.0.0/16",
+						SubnetMask:    24,
+						ExposedPorts:  []int32{6443},
+						EnableIPv6:    false,
+						DNSNameserver: "8.8.8.8",
+					},
+					MaxConcurrentNodeOperations: int32(maxNodeOperations),
+					NodeImageRepository:         nodeImageRepository,
+					EtcdSnapshotDirectory:       etcdSnapshotDir,
+				},
+			}, providers.WithVersionCatalog(catalog))
+			if err != nil {
+				return fmt.Errorf("failed to create Docker provider: %w", err)
+			}
+
+			runner := standalone.NewRunner(c,
+				standalone.Controller{
+					Name:    "cluster",
+					NewList: func() client.ObjectList { return &clusterv1alpha1.ClusterList{} },
+					Reconciler: &controllers.ClusterReconciler{
+						Client:   c,
+						Scheme:   scheme,
+						Provider: provider,
+						Recorder: standalone.NewEventRecorder(c, "cluster-controller"),
+						Versions: catalog,
+					},
+				},
+				standalone.Controller{
+					Name:    "clusterbackup",
+					NewList: func() client.ObjectList { return &clusterv1alpha1.ClusterBackupList{} },
+					Reconciler: &controllers.ClusterBackupReconciler{
+						Client:   c,
+						Scheme:   scheme,
+						Provider: provider,
+						Recorder: standalone.NewEventRecorder(c, "clusterbackup-controller"),
+					},
+				},
+				standalone.Controller{
+					Name:    "clusterrestore",
+					NewList: func() client.ObjectList { return &clusterv1alpha1.ClusterRestoreList{} },
+					Reconciler: &controllers.ClusterRestoreReconciler{
+						Client:   c,
+						Scheme:   scheme,
+						Provider: provider,
+						Recorder: standalone.NewEventRecorder(c, "clusterrestore-controller"),
+					},
+				},
+			)
+			fmt.Fprintf(cmd.OutOrStdout(), "running controllers against the state store in %s\n", opts.stateDir)
+			return runner.Run(ctx)
+		},
+	}
+	cmd.Flags().IntVar(&maxNodeOperations, "max-concurrent-node-operations", providers.DefaultMaxConcurrentNodeOperations,
+		"How many nodes of a cluster are created or removed at the same time")
+	cmd.Flags().StringVar(&nodeImageRepository, "node-image-repository", providers.DefaultNodeImageRepository,
+		"The repository node images are pulled from unless a cluster names another one")
+	cmd.Flags().StringVar(&etcdSnapshotDir, "etcd-snapshot-dir", providers.DefaultEtcdSnapshotDirectory(),
+		"The directory etcd snapshots are stored in, before upgrades and for backups without a location")
+	cmd.Flags().StringVar(&versionCatalogFile, "version-catalog-file", "",
+		"A file with Kubernetes versions that override or extend the built-in version catalog")
+	cmd.Flags().StringVar(&versionCatalogCM, "version-catalog-configmap", "",
+		"The namespace/name of a ConfigMap in the state store with Kubernetes versions that override or extend the built-in catalog")
+	return cmd
+}
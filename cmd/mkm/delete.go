@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// newDeleteCommand returns the command that deletes clusters
+func newDeleteCommand(opts *globalOptions) *cobra.Command {
+	var (
+		file     string
+		waitOpts waitOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "delete [NAME...]",
+		Short: "Delete clusters",
+		Long: `Delete Cluster resources, named on the command line or in the manifests in a file.
+
+The manager removes the nodes, networks and volumes of a deleted cluster
+before the Cluster resource goes away.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (file == "") == (len(args) == 0) {
+				return fmt.Errorf("either cluster names or -f is required")
+			}
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+
+			var keys []client.ObjectKey
+			if file != "" {
+				clusters, err := readClusterManifests(file, cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				for _, cluster := range clusters {
+					if cluster.Namespace == "" {
+						cluster.Namespace = namespace
+					}
+					keys = append(keys, client.ObjectKeyFromObject(cluster))
+				}
+			} else {
+				for _, name := range args {
+					keys = append(keys, client.ObjectKey{Namespace: namespace, Name: name})
+				}
+			}
+
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+				if err := c.Delete(cmd.Context(), cluster); err != nil {
+					if apierrors.IsNotFound(err) {
+						return fmt.Errorf("cluster %s/%s not found", key.Namespace, key.Name)
+					}
+					return fmt.Errorf("failed to delete cluster %s: %w", key.Name, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s deleted, removing its nodes\n", key.Namespace, key.Name)
+			}
+
+			if !waitOpts.wait {
+				return nil
+			}
+			for _, key := range keys {
+				if err := waitForCluster(cmd.Context(), c, key, waitOpts.timeout, cmd.ErrOrStderr(), clusterDeleted); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s is gone\n", key.Namespace, key.Name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "File with the Cluster manifests to delete, or - for stdin")
+	waitOpts.addFlags(cmd, "the clusters and their nodes are gone")
+	return cmd
+}
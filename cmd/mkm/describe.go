@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// describedEvents is how many of the latest events describe shows
+const describedEvents = 10
+
+// newDescribeCommand returns the command that shows the details of a cluster
+func newDescribeCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe NAME",
+		Short: "Show the details of a cluster",
+		Long: `Show the spec and status of a Cluster resource, its conditions, upgrade
+and node remediations, and the latest events the manager recorded for it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			var cluster clusterv1alpha1.Cluster
+			if err := c.Get(cmd.Context(), client.ObjectKey{Namespace: namespace, Name: name}, &cluster); err != nil {
+				return fmt.Errorf("failed to get cluster %s: %w", name, err)
+			}
+			var events corev1.EventList
+			if err := c.List(cmd.Context(), &events, client.InNamespace(namespace)); err != nil {
+				return fmt.Errorf("failed to list events of cluster %s: %w", name, err)
+			}
+			return describeCluster(cmd.OutOrStdout(), &cluster, events.Items)
+		},
+	}
+	return cmd
+}
+
+// describeCluster writes the description of cluster, with those of events
+// that are about it
+func describeCluster(out io.Writer, cluster *clusterv1alpha1.Cluster, events []corev1.Event) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	spec, status := cluster.Spec, cluster.Status
+
+	fmt.Fprintf(w, "Name:\t%s\n", cluster.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", cluster.Namespace)
+	fmt.Fprintf(w, "Created:\t%s\n", cluster.CreationTimestamp.Format(time.RFC3339))
+	fmt.Fprintf(w, "Kubernetes Version:\t%s\n", spec.KubernetesVersion)
+	if status.KubernetesVersion != "" && status.KubernetesVersion != spec.KubernetesVersion {
+		fmt.Fprintf(w, "Running Version:\t%s\n", status.KubernetesVersion)
+	}
+	fmt.Fprintf(w, "Phase:\t%s\n", orDash(string(status.Phase)))
+	if status.Message != "" {
+		fmt.Fprintf(w, "Message:\t%s\n", status.Message)
+	}
+	if spec.Suspended {
+		fmt.Fprintf(w, "Suspended:\ttrue\n")
+	}
+	if spec.CloneFrom != nil {
+		fmt.Fprintf(w, "Cloned From:\t%s\n", spec.CloneFrom.Name)
+	}
+	fmt.Fprintf(w, "Control Plane:\t%d nodes, %s memory, %d CPUs, ready: %t\n",
+		spec.ControlPlane.Count, orDash(spec.ControlPlane.MachineConfig.Memory), spec.ControlPlane.MachineConfig.CPUCount, status.ControlPlaneReady)
+	fmt.Fprintf(w, "Workers:\t%d nodes, %s memory, %d CPUs, %d ready\n",
+		spec.Workers.Count, orDash(spec.Workers.MachineConfig.Memory), spec.Workers.MachineConfig.CPUCount, status.WorkersReady)
+	if upgrade := status.Upgrade; upgrade != nil {
+		fmt.Fprintf(w, "Upgrade:\t%s to %s, %s\n", upgrade.FromVersion, upgrade.ToVersion, upgrade.Phase)
+		if upgrade.Message != "" {
+			fmt.Fprintf(w, "\t%s\n", upgrade.Message)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nConditions:")
+	if len(status.Conditions) == 0 {
+		fmt.Fprintln(out, "  <none>")
+	} else {
+		w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+		for _, condition := range status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason,
+				since(condition.LastTransitionTime.Time), condition.Message)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(status.Remediations) > 0 {
+		fmt.Fprintln(out, "\nRemediations:")
+		w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  NODE\tCONDITION\tACTION\tRESULT\tAGE\tMESSAGE")
+		for _, r := range status.Remediations {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", r.Node, r.Condition, r.Action, r.Result, since(r.Time.Time), orDash(r.Message))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var own []corev1.Event
+	for _, event := range events {
+		if event.InvolvedObject.Kind == "Cluster" && event.InvolvedObject.Name == cluster.Name &&
+			(event.InvolvedObject.UID == "" || event.InvolvedObject.UID == cluster.UID) {
+			own = append(own, event)
+		}
+	}
+	sort.SliceStable(own, func(i, j int) bool { return eventTime(own[i]).Before(eventTime(own[j])) })
+	if len(own) > describedEvents {
+		own = own[len(own)-describedEvents:]
+	}
+	fmt.Fprintln(out, "\nEvents:")
+	if len(own) == 0 {
+		fmt.Fprintln(out, "  <none>")
+		return nil
+	}
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  TYPE\tREASON\tAGE\tMESSAGE")
+	for _, event := range own {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", event.Type, event.Reason, since(eventTime(event)), event.Message)
+	}
+	return w.Flush()
+}
+
+// eventTime returns when an event last happened
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// since returns how long ago t was, or "-" if it is not set
+func since(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return duration.HumanDuration(time.Since(t))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// newGetCommand returns the command that lists clusters
+func newGetCommand(opts *globalOptions) *cobra.Command {
+	var allNamespaces bool
+
+	cmd := &cobra.Command{
+		Use:   "get [NAME...]",
+		Short: "List clusters and their state",
+		Long: `List the named Cluster resources, or all of them, with their phase and
+how many of their nodes are ready.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			var clusters []clusterv1alpha1.Cluster
+			if len(args) == 0 {
+				var list clusterv1alpha1.ClusterList
+				var listOpts []client.ListOption
+				if !allNamespaces {
+					listOpts = append(listOpts, client.InNamespace(namespace))
+				}
+				if err := c.List(cmd.Context(), &list, listOpts...); err != nil {
+					return fmt.Errorf("failed to list clusters: %w", err)
+				}
+				clusters = list.Items
+			}
+			for _, name := range args {
+				var cluster clusterv1alpha1.Cluster
+				if err := c.Get(cmd.Context(), client.ObjectKey{Namespace: namespace, Name: name}, &cluster); err != nil {
+					return fmt.Errorf("failed to get cluster %s: %w", name, err)
+				}
+				clusters = append(clusters, cluster)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			if allNamespaces {
+				fmt.Fprint(w, "NAMESPACE\t")
+			}
+			fmt.Fprintln(w, "NAME\tVERSION\tPHASE\tREADY\tCONTROL PLANE\tWORKERS\tAGE")
+			for _, cluster := range clusters {
+				if allNamespaces {
+					fmt.Fprintf(w, "%s\t", cluster.Namespace)
+				}
+				controlPlane := "0"
+				if cluster.Status.ControlPlaneReady {
+					controlPlane = fmt.Sprint(cluster.Spec.ControlPlane.Count)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s/%d\t%d/%d\t%s\n",
+					cluster.Name, cluster.Spec.KubernetesVersion, orDash(string(cluster.Status.Phase)), readiness(&cluster),
+					controlPlane, cluster.Spec.ControlPlane.Count, cluster.Status.WorkersReady, cluster.Spec.Workers.Count,
+					since(cluster.CreationTimestamp.Time))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List the clusters of all namespaces")
+	return cmd
+}
+
+// readiness returns the READY column for a cluster: the status of its Ready condition
+func readiness(cluster *clusterv1alpha1.Cluster) string {
+	ready := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1alpha1.ClusterConditionReady)
+	if ready == nil {
+		return "-"
+	}
+	return string(ready.Status)
+}
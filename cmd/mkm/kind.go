@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/kindconfig"
+)
+
+// newKindCommand returns the commands that convert kind cluster configs
+func newKindCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kind",
+		Short: "Import and export kind cluster configs",
+		Long: `Convert between kind cluster configs (kind.x-k8s.io/v1alpha4 Cluster) and
+Cluster resources. The nodes and their roles, the node image, extra port
+mappings and mounts, networking and kubeadm config patches are converted;
+what the other side cannot express is reported as warnings on stderr.
+Networking and kubeadm config patches are kept in imported Clusters for
+exporting them again, but the manager does not apply them to the nodes.`,
+	}
+	cmd.AddCommand(newKindImportCommand(opts))
+	cmd.AddCommand(newKindExportCommand(opts))
+	return cmd
+}
+
+// newKindImportCommand returns the command that turns a kind config into a Cluster
+func newKindImportCommand(opts *globalOptions) *cobra.Command {
+	var (
+		file    string
+		name    string
+		version string
+		create  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import -f FILE",
+		Short: "Convert a kind cluster config to a Cluster",
+		Long: `Convert the kind cluster config in a file, or in stdin for -, to a Cluster
+resource and print its manifest, or create it with --create.
+
+Cluster resources give all nodes of a role the same extra port mappings and
+mounts, those of the first node of the role in the kind config. The
+Kubernetes version is taken from the node image unless --kubernetes-version
+is given.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := readKindConfig(file, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			cluster, warnings, err := kindconfig.ToCluster(config, version)
+			if err != nil {
+				return fmt.Errorf("failed to convert kind config: %w", err)
+			}
+			printWarnings(cmd.ErrOrStderr(), warnings)
+			if name != "" {
+				cluster.Name = name
+			}
+
+			if !create {
+				data, err := yaml.Marshal(cluster)
+				if err != nil {
+					return err
+				}
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+
+			if err := clusterops.ValidateSpec(&cluster.Spec, nil); err != nil {
+				return fmt.Errorf("cluster %s: %w", cluster.Name, err)
+			}
+			if cluster.Namespace, err = opts.resolveNamespace(); err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+			if err := c.Create(cmd.Context(), cluster); err != nil {
+				return fmt.Errorf("failed to create cluster %s: %w", cluster.Name, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s created, running Kubernetes %s with %d control plane nodes and %d workers\n",
+				cluster.Namespace, cluster.Name, cluster.Spec.KubernetesVersion, cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "File with the kind cluster config, or - for stdin")
+	cmd.Flags().StringVar(&name, "name", "", "Name of the cluster, instead of the name in the kind config")
+	cmd.Flags().StringVar(&version, "kubernetes-version", "", "Kubernetes version of the cluster, instead of the tag of the node image")
+	cmd.Flags().BoolVar(&create, "create", false, "Create the cluster instead of printing its manifest")
+	_ = cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+// newKindExportCommand returns the command that turns a Cluster into a kind config
+func newKindExportCommand(opts *globalOptions) *cobra.Command {
+	var (
+		file   string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [NAME]",
+		Short: "Convert a Cluster to a kind cluster config",
+		Long: `Convert the Cluster NAME, or the one in the manifest given with -f, to a kind
+cluster config with a node for each of its nodes, and print it or write it to
+the file given with -o. The result can be passed to kind create cluster --config.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (file == "") == (len(args) == 0) {
+				return fmt.Errorf("either a cluster name or -f is required")
+			}
+
+			var cluster *clusterv1alpha1.Cluster
+			if file != "" {
+				clusters, err := readClusterManifests(file, cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				if len(clusters) > 1 {
+					return fmt.Errorf("%s holds %d Cluster resources, export one at a time", file, len(clusters))
+				}
+				cluster = clusters[0]
+			} else {
+				namespace, err := opts.resolveNamespace()
+				if err != nil {
+					return err
+				}
+				c, err := opts.newClient()
+				if err != nil {
+					return err
+				}
+				cluster = &clusterv1alpha1.Cluster{}
+				if err := c.Get(cmd.Context(), client.ObjectKey{Namespace: namespace, Name: args[0]}, cluster); err != nil {
+					return fmt.Errorf("failed to get cluster %s: %w", args[0], err)
+				}
+			}
+
+			config, warnings, err := kindconfig.FromCluster(cluster)
+			if err != nil {
+				return fmt.Errorf("failed to convert cluster: %w", err)
+			}
+			printWarnings(cmd.ErrOrStderr(), warnings)
+			data, err := kindconfig.Marshal(config)
+			if err != nil {
+				return err
+			}
+			if output != "" {
+				if err := os.WriteFile(output, data, 0o644); err != nil {
+					return fmt.Errorf("failed to write kind config: %w", err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "kind config of cluster %s written to %s\n", cluster.Name, output)
+				return nil
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "File with the Cluster manifest to export, or - for stdin")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the kind config to instead of stdout")
+	return cmd
+}
+
+// readKindConfig reads the kind cluster config in a file, or in stdin if the
+// file is "-"
+func readKindConfig(file string, stdin io.Reader) (*kindconfig.Config, error) {
+	if file != "-" {
+		return kindconfig.LoadFile(file)
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kind config: %w", err)
+	}
+	return kindconfig.Parse(data)
+}
+
+// printWarnings writes what a conversion could not carry over
+func printWarnings(out io.Writer, warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(out, "warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// newKubeconfigCommand returns the command that prints the kubeconfig of a cluster
+func newKubeconfigCommand(opts *globalOptions) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "kubeconfig NAME",
+		Short: "Print the admin kubeconfig of a cluster",
+		Long: `Print the admin kubeconfig of a running cluster, or write it to a file.
+
+The manager publishes the kubeconfig to the Secret NAME-kubeconfig once the
+cluster runs. Its server is the cluster's address on its Docker network,
+which can be reached from the host running the manager.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			var secret corev1.Secret
+			key := client.ObjectKey{Namespace: namespace, Name: clusterv1alpha1.KubeconfigSecretName(name)}
+			if err := c.Get(cmd.Context(), key, &secret); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return fmt.Errorf("failed to get kubeconfig of cluster %s: %w", name, err)
+				}
+				var cluster clusterv1alpha1.Cluster
+				if err := c.Get(cmd.Context(), client.ObjectKey{Namespace: namespace, Name: name}, &cluster); err != nil {
+					return fmt.Errorf("failed to get cluster %s: %w", name, err)
+				}
+				available := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1alpha1.ClusterConditionKubeconfigAvailable)
+				if available != nil && available.Message != "" {
+					return fmt.Errorf("kubeconfig of cluster %s is not available yet: %s", name, available.Message)
+				}
+				return fmt.Errorf("kubeconfig of cluster %s is not available yet, the cluster is %s", name, orDash(string(cluster.Status.Phase)))
+			}
+			kubeconfig, ok := secret.Data[clusterv1alpha1.KubeconfigSecretKey]
+			if !ok {
+				return fmt.Errorf("kubeconfig Secret %s has no %s key", key.Name, clusterv1alpha1.KubeconfigSecretKey)
+			}
+
+			if output == "" {
+				_, err := cmd.OutOrStdout().Write(kubeconfig)
+				return err
+			}
+			if err := os.WriteFile(output, kubeconfig, 0o600); err != nil {
+				return fmt.Errorf("failed to write kubeconfig: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "kubeconfig of cluster %s/%s written to %s\n", namespace, name, output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the kubeconfig to instead of stdout")
+	return cmd
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/standalone"
+)
+
+// stateDirEnv is the environment variable --state-dir defaults to
+const stateDirEnv = "MKM_STATE_DIR"
+
+// globalOptions holds the flags shared by all commands
+type globalOptions struct {
+	kubeconfig string
+	context    string
+	namespace  string
+
+	// stateDir is the state store of standalone mode, used instead of
+	// an API server if set
+	stateDir string
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCommand builds the mkm command tree
+func newRootCommand() *cobra.Command {
+	opts := &globalOptions{}
+
+	cmd := &cobra.Command{
+		Use:           "mkm",
+		Short:         "Manage Kubernetes clusters run by mini-k8s-manager",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+	}
+	cmd.PersistentFlags().StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig of the cluster hosting the Cluster resources")
+	cmd.PersistentFlags().StringVar(&opts.context, "context", "", "Kubeconfig context to use")
+	cmd.PersistentFlags().StringVarP(&opts.namespace, "namespace", "n", "", "Namespace of the Cluster resources")
+	cmd.PersistentFlags().StringVar(&opts.stateDir, "state-dir", os.Getenv(stateDirEnv),
+		"Directory of the local state store to keep Cluster resources in instead of an API server (standalone mode, see mkm daemon)")
+
+	cmd.AddCommand(newCreateCommand(opts))
+	cmd.AddCommand(newDeleteCommand(opts))
+	cmd.AddCommand(newGetCommand(opts))
+	cmd.AddCommand(newDescribeCommand(opts))
+	cmd.AddCommand(newScaleCommand(opts))
+	cmd.AddCommand(newUpgradeCommand(opts))
+	cmd.AddCommand(newKubeconfigCommand(opts))
+	cmd.AddCommand(newAdoptCommand(opts))
+	cmd.AddCommand(newCloneCommand(opts))
+	cmd.AddCommand(newVersionsCommand(opts))
+	cmd.AddCommand(newBackupCommand(opts))
+	cmd.AddCommand(newRestoreCommand(opts))
+	cmd.AddCommand(newKindCommand(opts))
+	cmd.AddCommand(newDaemonCommand(opts))
+	return cmd
+}
+
+// clientConfig returns the kubeconfig loader for the global flags
+func (o *globalOptions) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: o.context})
+}
+
+// newClient returns a client for the cluster hosting the Cluster resources,
+// or for the state store in standalone mode
+func (o *globalOptions) newClient() (client.WithWatch, error) {
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	if o.stateDir != "" {
+		return o.newStandaloneClient(scheme)
+	}
+
+	config, err := o.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return client.NewWithWatch(config, client.Options{Scheme: scheme})
+}
+
+// newStandaloneClient returns a client for the state store in --state-dir
+func (o *globalOptions) newStandaloneClient(scheme *runtime.Scheme) (*standalone.Client, error) {
+	store, err := standalone.NewFileStore(o.stateDir)
+	if err != nil {
+		return nil, err
+	}
+	return standalone.NewClient(store, scheme), nil
+}
+
+// newScheme returns the scheme of the resources mkm works with
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := clusterv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// resolveNamespace returns the --namespace flag, falling back to the kubeconfig
+// context, or to the default namespace in standalone mode
+func (o *globalOptions) resolveNamespace() (string, error) {
+	if o.namespace != "" {
+		return o.namespace, nil
+	}
+	if o.stateDir != "" {
+		return metav1.NamespaceDefault, nil
+	}
+	namespace, _, err := o.clientConfig().Namespace()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine namespace: %w", err)
+	}
+	return namespace, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// readClusterManifests reads the Cluster resources in the YAML or JSON
+// documents of a file, or of stdin if the file is "-". Documents must be
+// Clusters of this API version; empty documents are skipped.
+func readClusterManifests(file string, stdin io.Reader) ([]*clusterv1alpha1.Cluster, error) {
+	reader := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	var clusters []*clusterv1alpha1.Cluster
+	decoder := yaml.NewYAMLOrJSONDecoder(reader, 4096)
+	for document := 1; ; document++ {
+		cluster := &clusterv1alpha1.Cluster{}
+		if err := decoder.Decode(cluster); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse document %d of %s: %w", document, file, err)
+		}
+		if cluster.APIVersion == "" && cluster.Kind == "" && cluster.Name == "" {
+			continue
+		}
+		if cluster.APIVersion != clusterv1alpha1.GroupVersion.String() || cluster.Kind != "Cluster" {
+			return nil, fmt.Errorf("document %d of %s is a %s %s, not a %s Cluster",
+				document, file, cluster.APIVersion, cluster.Kind, clusterv1alpha1.GroupVersion)
+		}
+		if cluster.Name == "" {
+			return nil, fmt.Errorf("document %d of %s has no name", document, file)
+		}
+		clusters = append(clusters, cluster)
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("%s holds no Cluster resources", file)
+	}
+	return clusters, nil
+}
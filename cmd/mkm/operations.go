@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// pollInterval is how often the CLI reads a cluster it waits for
+const pollInterval = time.Second
+
+// clusterResources is the provider the CLI runs the cluster operations
+// against. It changes Cluster resources and reports the status the manager
+// records on them; the manager acts on the nodes.
+type clusterResources struct {
+	client client.Client
+
+	// progress receives the phase and the Ready condition of the clusters
+	// whose status is read, as they change; nil discards them
+	progress io.Writer
+	last     map[client.ObjectKey]string
+}
+
+var _ providers.Provider = &clusterResources{}
+
+// newOperations returns the cluster operations on the Cluster resources of c
+func newOperations(c client.Client, progress io.Writer, opts ...clusterops.Option) *clusterops.Operations {
+	resources := &clusterResources{client: c, progress: progress, last: map[client.ObjectKey]string{}}
+	return clusterops.NewOperations(resources, append([]clusterops.Option{clusterops.WithPollInterval(pollInterval)}, opts...)...)
+}
+
+// waitForReady waits up to timeout for the manager to report cluster ready
+// at its current spec, writing its phase and Ready condition to progress as
+// they change
+func waitForReady(ctx context.Context, c client.Client, cluster *clusterv1alpha1.Cluster, timeout time.Duration, progress io.Writer) error {
+	_, err := newOperations(c, progress).WaitForReady(ctx, cluster, timeout)
+	return err
+}
+
+// CreateCluster creates the Cluster resource
+func (r *clusterResources) CreateCluster(ctx context.Context, cluster *clusterv1alpha1.Cluster) error {
+	if err := r.client.Create(ctx, cluster); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return providers.ErrClusterExists
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteCluster deletes the Cluster resource
+func (r *clusterResources) DeleteCluster(ctx context.Context, cluster *clusterv1alpha1.Cluster) error {
+	if err := r.client.Delete(ctx, cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return providers.ErrClusterNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateCluster sets the spec of the Cluster resource to that of cluster
+func (r *clusterResources) UpdateCluster(ctx context.Context, cluster *clusterv1alpha1.Cluster) error {
+	var current clusterv1alpha1.Cluster
+	if err := r.client.Get(ctx, client.ObjectKeyFromObject(cluster), &current); err != nil {
+		if apierrors.IsNotFound(err) {
+			return providers.ErrClusterNotFound
+		}
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	current.Spec = cluster.Spec
+	return r.client.Patch(ctx, &current, patch)
+}
+
+// GetClusterStatus returns the status the manager recorded on the Cluster
+// resource. The cluster is only reported Running once the manager has
+// rolled out its current spec and found it ready, and as Failed once an
+// upgrade of that spec has failed.
+func (r *clusterResources) GetClusterStatus(ctx context.Context, cluster *clusterv1alpha1.Cluster) (*clusterv1alpha1.ClusterStatus, error) {
+	key := client.ObjectKeyFromObject(cluster)
+	var current clusterv1alpha1.Cluster
+	if err := r.client.Get(ctx, key, &current); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("cluster %s was deleted: %w", key.Name, providers.ErrClusterNotFound)
+		}
+		return nil, err
+	}
+	if summary := clusterSummary(&current); r.progress != nil && summary != r.last[key] {
+		fmt.Fprintf(r.progress, "cluster %s/%s: %s\n", key.Namespace, key.Name, summary)
+		r.last[key] = summary
+	}
+
+	status := &clusterv1alpha1.ClusterStatus{}
+	current.Status.DeepCopyInto(status)
+	done, err := clusterReady(&current)
+	switch {
+	case err != nil:
+		if status.Phase != clusterv1alpha1.ClusterPhaseFailed {
+			status.Message = err.Error()
+		}
+		status.Phase = clusterv1alpha1.ClusterPhaseFailed
+	case !done && status.Phase == clusterv1alpha1.ClusterPhaseRunning:
+		status.Phase = clusterv1alpha1.ClusterPhaseUpdating
+	}
+	return status, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+func TestClusterResourceOperations(t *testing.T) {
+	s := runtime.NewScheme()
+	clusterv1alpha1.AddToScheme(s)
+	ctx := context.Background()
+
+	c := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&clusterv1alpha1.Cluster{}).Build()
+	cluster := &clusterv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops", Namespace: "default", Generation: 1},
+		Spec: clusterv1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.30.4",
+			ControlPlane:      clusterv1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           clusterv1alpha1.WorkerConfig{Count: 1},
+		},
+	}
+	if err := c.Create(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	ready := func(generation int64, workers int32) clusterv1alpha1.ClusterStatus {
+		return clusterv1alpha1.ClusterStatus{
+			Phase:              clusterv1alpha1.ClusterPhaseRunning,
+			ControlPlaneReady:  true,
+			WorkersReady:       workers,
+			ObservedGeneration: generation,
+			KubernetesVersion:  "v1.30.4",
+			Conditions: []metav1.Condition{{
+				Type:               clusterv1alpha1.ClusterConditionReady,
+				Status:             metav1.ConditionTrue,
+				ObservedGeneration: generation,
+			}},
+		}
+	}
+	cluster.Status = ready(1, 1)
+	if err := c.Status().Update(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update cluster status: %v", err)
+	}
+
+	// Scaling changes the spec of the resource
+	if err := newOperations(c, nil).Scale(ctx, cluster, 3, 2); err != nil {
+		t.Fatalf("Failed to scale cluster: %v", err)
+	}
+	current := &clusterv1alpha1.Cluster{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cluster), current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if current.Spec.ControlPlane.Count != 3 || current.Spec.Workers.Count != 2 {
+		t.Errorf("Expected 3 control plane nodes and 2 workers, got %+v", current.Spec)
+	}
+
+	// Upgrades are checked against the catalog before the spec is changed
+	err := newOperations(c, nil, clusterops.WithVersionCatalog(versions.Builtin())).Upgrade(ctx, cluster, "v1.32.99")
+	if !errors.Is(err, clusterops.ErrInvalidSpec) {
+		t.Errorf("Expected an upgrade to an unknown version to be rejected, got %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cluster), current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if current.Spec.KubernetesVersion != "v1.30.4" {
+		t.Errorf("Expected the rejected upgrade to leave the version alone, got %s", current.Spec.KubernetesVersion)
+	}
+
+	// The cluster is not ready until the manager has rolled out the new spec
+	current.Generation = 2
+	if err := c.Update(ctx, current); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		current.Status = ready(current.Generation, 2)
+		if err := c.Status().Update(ctx, current); err != nil {
+			t.Errorf("Failed to update cluster status: %v", err)
+		}
+	}()
+	var progress bytes.Buffer
+	if err := waitForReady(ctx, c, cluster, 5*time.Second, &progress); err != nil {
+		t.Fatalf("Expected the cluster to become ready, got %v", err)
+	}
+	if lines := strings.Count(progress.String(), "cluster default/ops: Running, Ready=True\n"); lines != 1 {
+		t.Errorf("Expected the state to be reported once, got %q", progress.String())
+	}
+
+	// A failed upgrade of the current spec ends the wait
+	current.Status.Upgrade = &clusterv1alpha1.UpgradeStatus{Generation: current.Generation, Phase: clusterv1alpha1.UpgradePhaseFailed, ToVersion: "v1.31.0", Message: "etcd unhealthy"}
+	if err := c.Status().Update(ctx, current); err != nil {
+		t.Fatalf("Failed to update cluster status: %v", err)
+	}
+	err = waitForReady(ctx, c, cluster, 5*time.Second, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "etcd unhealthy") {
+		t.Errorf("Expected the upgrade failure, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+)
+
+// newScaleCommand returns the command that changes the node counts of a cluster
+func newScaleCommand(opts *globalOptions) *cobra.Command {
+	var (
+		controlPlanes int32
+		workers       int32
+		waitOpts      waitOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scale NAME",
+		Short: "Change the number of nodes of a cluster",
+		Long: `Change the number of control plane nodes or workers of a cluster.
+
+Workers are drained before they are removed. Control planes must have an odd
+number of nodes so that etcd keeps its majority.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			scaleControlPlane, scaleWorkers := cmd.Flags().Changed("control-planes"), cmd.Flags().Changed("workers")
+			if !scaleControlPlane && !scaleWorkers {
+				return fmt.Errorf("--control-planes or --workers is required")
+			}
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			var cluster clusterv1alpha1.Cluster
+			if err := c.Get(cmd.Context(), client.ObjectKey{Namespace: namespace, Name: name}, &cluster); err != nil {
+				return fmt.Errorf("failed to get cluster %s: %w", name, err)
+			}
+			controlPlaneCount, workerCount := cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count
+			if scaleControlPlane {
+				controlPlaneCount = controlPlanes
+			}
+			if scaleWorkers {
+				workerCount = workers
+			}
+			if err := newOperations(c, nil).Scale(cmd.Context(), &cluster, controlPlaneCount, workerCount); err != nil {
+				if errors.Is(err, clusterops.ErrInvalidSpec) {
+					return fmt.Errorf("cannot scale cluster %s: %w", name, err)
+				}
+				return fmt.Errorf("failed to scale cluster %s: %w", name, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s scaled to %d control plane nodes and %d workers\n",
+				namespace, name, cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count)
+
+			if !waitOpts.wait {
+				return nil
+			}
+			if err := waitForReady(cmd.Context(), c, &cluster, waitOpts.timeout, cmd.ErrOrStderr()); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s is ready\n", namespace, name)
+			return nil
+		},
+	}
+	cmd.Flags().Int32Var(&controlPlanes, "control-planes", 0, "Number of control plane nodes")
+	cmd.Flags().Int32Var(&workers, "workers", 0, "Number of worker nodes")
+	waitOpts.addFlags(cmd, "the cluster is ready at its new size")
+	return cmd
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+// newUpgradeCommand returns the command that changes the Kubernetes version of a cluster
+func newUpgradeCommand(opts *globalOptions) *cobra.Command {
+	var (
+		catalogFile, catalogConfigMap string
+		waitOpts                      waitOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upgrade NAME VERSION",
+		Short: "Upgrade a cluster to another Kubernetes version",
+		Long: `Set the Kubernetes version of a cluster.
+
+The upgrade is checked against the version catalog, which --catalog-file and
+--catalog-configmap override as for mkm versions, before the manager
+snapshots etcd and replaces the nodes one at a time, control plane first.
+See mkm versions for the versions a cluster can be upgraded to. With --wait
+the command fails as soon as the upgrade does.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, version := args[0], args[1]
+
+			namespace, err := opts.resolveNamespace()
+			if err != nil {
+				return err
+			}
+			c, err := opts.newClient()
+			if err != nil {
+				return err
+			}
+
+			var cluster clusterv1alpha1.Cluster
+			if err := c.Get(cmd.Context(), client.ObjectKey{Namespace: namespace, Name: name}, &cluster); err != nil {
+				return fmt.Errorf("failed to get cluster %s: %w", name, err)
+			}
+			from := cluster.Spec.KubernetesVersion
+			if from == version {
+				return fmt.Errorf("cluster %s already runs Kubernetes %s", name, version)
+			}
+			catalog, err := versions.Load(cmd.Context(), c, catalogFile, catalogConfigMap)
+			if err != nil {
+				return err
+			}
+			if err := newOperations(c, nil, clusterops.WithVersionCatalog(catalog)).Upgrade(cmd.Context(), &cluster, version); err != nil {
+				if errors.Is(err, clusterops.ErrInvalidSpec) {
+					return fmt.Errorf("cannot upgrade cluster %s: %w", name, err)
+				}
+				return fmt.Errorf("failed to upgrade cluster %s: %w", name, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s upgrading from Kubernetes %s to %s\n", namespace, name, from, version)
+
+			if !waitOpts.wait {
+				return nil
+			}
+			if err := waitForReady(cmd.Context(), c, &cluster, waitOpts.timeout, cmd.ErrOrStderr()); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "cluster %s/%s is ready, running Kubernetes %s\n", namespace, name, version)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&catalogFile, "catalog-file", "", "File with versions that override or extend the built-in catalog")
+	cmd.Flags().StringVar(&catalogConfigMap, "catalog-configmap", "", "ConfigMap (namespace/name) with versions that override or extend the built-in catalog")
+	waitOpts.addFlags(cmd, "every node runs the new version")
+	return cmd
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+// newVersionsCommand returns the command that lists the supported Kubernetes versions
+func newVersionsCommand(opts *globalOptions) *cobra.Command {
+	var catalogFile, catalogConfigMap string
+
+	cmd := &cobra.Command{
+		Use:   "versions",
+		Short: "List the Kubernetes versions clusters can run",
+		Long: `List the Kubernetes versions in the version catalog, newest first.
+
+The built-in catalog can be overridden the same way as the manager's, from a
+file or from a ConfigMap in the cluster hosting the Cluster resources. Empty
+upgrade bounds mean the default: one minor version at a time.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var reader client.Reader
+			if catalogConfigMap != "" {
+				c, err := opts.newClient()
+				if err != nil {
+					return err
+				}
+				reader = c
+			}
+			catalog, err := versions.Load(cmd.Context(), reader, catalogFile, catalogConfigMap)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tDIGEST\tUPGRADE FROM\tUPGRADE TO\tDEPRECATED")
+			for _, v := range catalog.List() {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Version, orDash(v.Digest), orDash(v.MinUpgradeFrom), orDash(v.MaxUpgradeTo), deprecation(v))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&catalogFile, "catalog-file", "", "File with versions that override or extend the built-in catalog")
+	cmd.Flags().StringVar(&catalogConfigMap, "catalog-configmap", "", "ConfigMap (namespace/name) with versions that override or extend the built-in catalog")
+	return cmd
+}
+
+// deprecation returns the DEPRECATED column for v
+func deprecation(v versions.Version) string {
+	switch {
+	case !v.Deprecated:
+		return "no"
+	case v.DeprecationMessage != "":
+		return "yes: " + v.DeprecationMessage
+	default:
+		return "yes"
+	}
+}
+
+// orDash returns s, or "-" if it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
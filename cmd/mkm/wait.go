@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// waitOptions holds the flags of commands that can wait for the manager to
+// act on the clusters they change
+type waitOptions struct {
+	wait    bool
+	timeout time.Duration
+}
+
+// addFlags adds the --wait and --timeout flags to cmd; what describes what
+// is waited for
+func (o *waitOptions) addFlags(cmd *cobra.Command, what string) {
+	cmd.Flags().BoolVar(&o.wait, "wait", false, "Wait until "+what)
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 15*time.Minute, "How long --wait waits before giving up")
+}
+
+// clusterCheck reports whether a cluster is where a command waits for it to
+// be. The cluster is nil once it has been deleted. An error ends the wait.
+type clusterCheck func(cluster *clusterv1alpha1.Cluster) (bool, error)
+
+// waitForCluster watches the cluster key until check is satisfied, timeout
+// passes or check fails, writing the phase and the Ready condition to
+// progress as they change
+func waitForCluster(ctx context.Context, c client.WithWatch, key client.ObjectKey, timeout time.Duration, progress io.Writer, check clusterCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	last := ""
+	observe := func(cluster *clusterv1alpha1.Cluster) (bool, error) {
+		if cluster != nil {
+			if summary := clusterSummary(cluster); summary != last {
+				fmt.Fprintf(progress, "cluster %s/%s: %s\n", key.Namespace, key.Name, summary)
+				last = summary
+			}
+		}
+		return check(cluster)
+	}
+
+	for {
+		// Watch before getting the cluster so that no change in between is missed
+		w, err := c.Watch(ctx, &clusterv1alpha1.ClusterList{}, client.InNamespace(key.Namespace), client.MatchingFields{"metadata.name": key.Name})
+		if err != nil {
+			return waitError(ctx, key, timeout, last, fmt.Errorf("failed to watch cluster %s: %w", key.Name, err))
+		}
+		cluster := &clusterv1alpha1.Cluster{}
+		if err := c.Get(ctx, key, cluster); apierrors.IsNotFound(err) {
+			cluster = nil
+		} else if err != nil {
+			w.Stop()
+			return waitError(ctx, key, timeout, last, fmt.Errorf("failed to get cluster %s: %w", key.Name, err))
+		}
+		if done, err := observe(cluster); done || err != nil {
+			w.Stop()
+			return err
+		}
+
+		done, err := watchCluster(ctx, w, key, observe)
+		w.Stop()
+		if done || err != nil {
+			return waitError(ctx, key, timeout, last, err)
+		}
+		// The server closed the watch; start another one
+	}
+}
+
+// watchCluster feeds the events of w about the cluster key to observe until
+// it is satisfied or fails, the watch ends or ctx is done
+func watchCluster(ctx context.Context, w watch.Interface, key client.ObjectKey, observe clusterCheck) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			cluster, isCluster := event.Object.(*clusterv1alpha1.Cluster)
+			switch {
+			case event.Type == watch.Error:
+				return false, nil
+			case !isCluster || cluster.Name != key.Name:
+				continue
+			case event.Type == watch.Deleted:
+				cluster = nil
+			}
+			if done, err := observe(cluster); done || err != nil {
+				return done, err
+			}
+		}
+	}
+}
+
+// waitError explains why a wait ended early, naming the last state seen if
+// it timed out
+func waitError(ctx context.Context, key client.ObjectKey, timeout time.Duration, last string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		if last == "" {
+			return fmt.Errorf("timed out after %s waiting for cluster %s", timeout, key.Name)
+		}
+		return fmt.Errorf("timed out after %s waiting for cluster %s, which is %s", timeout, key.Name, last)
+	}
+	return err
+}
+
+// clusterSummary describes the phase and Ready condition of a cluster in a line
+func clusterSummary(cluster *clusterv1alpha1.Cluster) string {
+	summary := string(cluster.Status.Phase)
+	if summary == "" {
+		summary = "Pending"
+	}
+	if ready := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1alpha1.ClusterConditionReady); ready != nil {
+		summary += fmt.Sprintf(", Ready=%s", ready.Status)
+		if ready.Status != metav1.ConditionTrue && ready.Message != "" {
+			summary += ": " + ready.Message
+		}
+	} else if cluster.Status.Message != "" {
+		summary += ": " + cluster.Status.Message
+	}
+	return summary
+}
+
+// clusterReady is satisfied once the cluster runs its current spec with all
+// its nodes ready, as its Ready condition says. It fails once the cluster or
+// an upgrade of its current spec has failed, or the cluster is gone.
+func clusterReady(cluster *clusterv1alpha1.Cluster) (bool, error) {
+	if cluster == nil {
+		return false, fmt.Errorf("cluster was deleted")
+	}
+	if cluster.Status.Phase == clusterv1alpha1.ClusterPhaseFailed {
+		return false, fmt.Errorf("cluster %s failed: %s", cluster.Name, cluster.Status.Message)
+	}
+	if upgrade := cluster.Status.Upgrade; upgrade != nil && upgrade.Generation == cluster.Generation && upgrade.Phase == clusterv1alpha1.UpgradePhaseFailed {
+		return false, fmt.Errorf("upgrade of cluster %s to %s failed: %s", cluster.Name, upgrade.ToVersion, upgrade.Message)
+	}
+	if cluster.Status.ObservedGeneration != cluster.Generation {
+		return false, nil
+	}
+	ready := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1alpha1.ClusterConditionReady)
+	return ready != nil && ready.Status == metav1.ConditionTrue && ready.ObservedGeneration == cluster.Generation, nil
+}
+
+// clusterDeleted is satisfied once the cluster is gone
+func clusterDeleted(cluster *clusterv1alpha1.Cluster) (bool, error) {
+	return cluster == nil, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+func TestReadClusterManifests(t *testing.T) {
+	manifest := `apiVersion: cluster.mini-k8s.io/v1alpha1
+kind: Cluster
+metadata:
+  name: one
+spec:
+  kubernetesVersion: v1.31.0
+  controlPlane:
+    count: 1
+  workers:
+    count: 2
+---
+---
+apiVersion: cluster.mini-k8s.io/v1alpha1
+kind: Cluster
+metadata:
+  name: two
+  namespace: other
+spec:
+  kubernetesVersion: v1.30.4
+`
+	file := filepath.Join(t.TempDir(), "clusters.yaml")
+	if err := os.WriteFile(file, []byte(manifest), 0o644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		file  string
+		stdin string
+	}{
+		{name: "file", file: file},
+		{name: "stdin", file: "-", stdin: manifest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters, err := readClusterManifests(tt.file, strings.NewReader(tt.stdin))
+			if err != nil {
+				t.Fatalf("Failed to read manifests: %v", err)
+			}
+			if len(clusters) != 2 {
+				t.Fatalf("Expected 2 clusters, got %d", len(clusters))
+			}
+			if c := clusters[0]; c.Name != "one" || c.Namespace != "" || c.Spec.Workers.Count != 2 {
+				t.Errorf("Expected cluster one with 2 workers, got %+v", c)
+			}
+			if c := clusters[1]; c.Name != "two" || c.Namespace != "other" || c.Spec.KubernetesVersion != "v1.30.4" {
+				t.Errorf("Expected cluster two in namespace other, got %+v", c)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name     string
+		manifest string
+		err      string
+	}{
+		{name: "other kind", manifest: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n", err: "not a cluster.mini-k8s.io/v1alpha1 Cluster"},
+		{name: "no name", manifest: "apiVersion: cluster.mini-k8s.io/v1alpha1\nkind: Cluster\n", err: "has no name"},
+		{name: "empty", manifest: "---\n", err: "holds no Cluster resources"},
+		{name: "invalid", manifest: "kind: [", err: "failed to parse document 1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readClusterManifests("-", strings.NewReader(tt.manifest))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestClusterReady(t *testing.T) {
+	ready := func(status metav1.ConditionStatus, generation int64) []metav1.Condition {
+		return []metav1.Condition{{Type: clusterv1alpha1.ClusterConditionReady, Status: status, ObservedGeneration: generation}}
+	}
+	tests := []struct {
+		name    string
+		status  clusterv1alpha1.ClusterStatus
+		ready   bool
+		wantErr bool
+	}{
+		{name: "pending", status: clusterv1alpha1.ClusterStatus{Phase: clusterv1alpha1.ClusterPhasePending}},
+		{name: "ready", status: clusterv1alpha1.ClusterStatus{Phase: clusterv1alpha1.ClusterPhaseRunning, ObservedGeneration: 2, Conditions: ready(metav1.ConditionTrue, 2)}, ready: true},
+		{name: "not ready", status: clusterv1alpha1.ClusterStatus{Phase: clusterv1alpha1.ClusterPhaseRunning, ObservedGeneration: 2, Conditions: ready(metav1.ConditionFalse, 2)}},
+		{name: "ready for an older spec", status: clusterv1alpha1.ClusterStatus{Phase: clusterv1alpha1.ClusterPhaseRunning, ObservedGeneration: 1, Conditions: ready(metav1.ConditionTrue, 1)}},
+		{name: "condition of an older spec", status: clusterv1alpha1.ClusterStatus{Phase: clusterv1alpha1.ClusterPhaseRunning, ObservedGeneration: 2, Conditions: ready(metav1.ConditionTrue, 1)}},
+		{name: "failed", status: clusterv1alpha1.ClusterStatus{Phase: clusterv1alpha1.ClusterPhaseFailed, Message: "no image"}, wantErr: true},
+		{name: "failed upgrade", status: clusterv1alpha1.ClusterStatus{
+			Phase:   clusterv1alpha1.ClusterPhaseUpdating,
+			Upgrade: &clusterv1alpha1.UpgradeStatus{Generation: 2, Phase: clusterv1alpha1.UpgradePhaseFailed, ToVersion: "v1.31.0"},
+		}, wantErr: true},
+		{name: "failed upgrade of an older spec", status: clusterv1alpha1.ClusterStatus{
+			Phase:   clusterv1alpha1.ClusterPhaseUpdating,
+			Upgrade: &clusterv1alpha1.UpgradeStatus{Generation: 1, Phase: clusterv1alpha1.UpgradePhaseFailed},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Generation: 2}, Status: tt.status}
+			got, err := clusterReady(cluster)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.ready {
+				t.Errorf("Expected ready %v, got %v", tt.ready, got)
+			}
+		})
+	}
+	if _, err := clusterReady(nil); err == nil {
+		t.Error("Expected a deleted cluster never to become ready")
+	}
+}
+
+func TestWaitForCluster(t *testing.T) {
+	s := runtime.NewScheme()
+	clusterv1alpha1.AddToScheme(s)
+
+	newCluster := func(t *testing.T) (client.WithWatch, *clusterv1alpha1.Cluster) {
+		c := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&clusterv1alpha1.Cluster{}).Build()
+		cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "wait", Namespace: "default"}}
+		if err := c.Create(context.Background(), cluster); err != nil {
+			t.Fatalf("Failed to create cluster: %v", err)
+		}
+		return c, cluster
+	}
+	// setStatus changes the status of cluster once the wait has started
+	setStatus := func(t *testing.T, c client.Client, cluster *clusterv1alpha1.Cluster, status clusterv1alpha1.ClusterStatus) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cluster.Status = status
+			if err := c.Status().Update(context.Background(), cluster); err != nil {
+				t.Errorf("Failed to update cluster status: %v", err)
+			}
+		}()
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		c, cluster := newCluster(t)
+		setStatus(t, c, cluster, clusterv1alpha1.ClusterStatus{
+			Phase:              clusterv1alpha1.ClusterPhaseRunning,
+			ObservedGeneration: cluster.Generation,
+			Conditions: []metav1.Condition{{
+				Type:               clusterv1alpha1.ClusterConditionReady,
+				Status:             metav1.ConditionTrue,
+				ObservedGeneration: cluster.Generation,
+			}},
+		})
+		var progress bytes.Buffer
+		if err := waitForCluster(context.Background(), c, client.ObjectKeyFromObject(cluster), 5*time.Second, &progress, clusterReady); err != nil {
+			t.Fatalf("Expected the cluster to become ready, got %v", err)
+		}
+		if expected := "cluster default/wait: Pending\ncluster default/wait: Running, Ready=True\n"; progress.String() != expected {
+			t.Errorf("Expected progress %q, got %q", expected, progress.String())
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		c, cluster := newCluster(t)
+		setStatus(t, c, cluster, clusterv1alpha1.ClusterStatus{Phase: clusterv1alpha1.ClusterPhaseFailed, Message: "no image"})
+		err := waitForCluster(context.Background(), c, client.ObjectKeyFromObject(cluster), 5*time.Second, &bytes.Buffer{}, clusterReady)
+		if err == nil || !strings.Contains(err.Error(), "no image") {
+			t.Errorf("Expected the failure of the cluster, got %v", err)
+		}
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		c, cluster := newCluster(t)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			if err := c.Delete(context.Background(), cluster); err != nil {
+				t.Errorf("Failed to delete cluster: %v", err)
+			}
+		}()
+		if err := waitForCluster(context.Background(), c, client.ObjectKeyFromObject(cluster), 5*time.Second, &bytes.Buffer{}, clusterDeleted); err != nil {
+			t.Errorf("Expected the cluster to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		c, cluster := newCluster(t)
+		err := waitForCluster(context.Background(), c, client.ObjectKeyFromObject(cluster), 100*time.Millisecond, &bytes.Buffer{}, clusterReady)
+		if err == nil || !strings.Contains(err.Error(), "timed out after 100ms waiting for cluster wait, which is Pending") {
+			t.Errorf("Expected a timeout naming the last state, got %v", err)
+		}
+	})
+}
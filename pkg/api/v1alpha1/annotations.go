@@ -0,0 +1,15 @@
+package v1alpha1
+
+const (
+	// AdoptAnnotation asks the controller to take over an existing kind cluster
+	// instead of provisioning a new one. The value is the kind cluster name.
+	// The spec is derived from the adopted nodes, so it can be left empty.
+	// The adopted containers keep kind's labels and are found through the
+	// annotation, so it stays on the Cluster.
+	AdoptAnnotation = "cluster.mini-k8s.io/adopt"
+
+	// RollbackAnnotation asks the controller to roll back a failed upgrade of a
+	// cluster whose RollbackPolicy is Manual. The controller removes it once
+	// the upgrade has been rolled back.
+	RollbackAnnotation = "cluster.mini-k8s.io/rollback"
+)
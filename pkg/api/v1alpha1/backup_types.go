@@ -0,0 +1,359 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultBackupRetention is how many snapshots a scheduled ClusterBackup keeps
+// unless its spec says otherwise
+const DefaultBackupRetention = 5
+
+// BackupLocation says where etcd snapshots are stored. At most one of HostPath
+// and Volume may be set; without either the provider's snapshot directory is used.
+type BackupLocation struct {
+	// HostPath is a directory on the manager's host
+	// +optional
+	HostPath string `json:"hostPath,omitempty"`
+
+	// Volume is the name of a Docker volume. It is created if it does not exist.
+	// +optional
+	Volume string `json:"volume,omitempty"`
+}
+
+// String describes the location for events and command output
+func (l BackupLocation) String() string {
+	switch {
+	case l.Volume != "":
+		return "volume " + l.Volume
+	case l.HostPath != "":
+		return l.HostPath
+	default:
+		return "the snapshot directory"
+	}
+}
+
+// ClusterBackupPhase is the phase of a ClusterBackup
+type ClusterBackupPhase string
+
+const (
+	// ClusterBackupPhaseCompleted means the single snapshot of an unscheduled backup was taken
+	ClusterBackupPhaseCompleted ClusterBackupPhase = "Completed"
+	// ClusterBackupPhaseScheduled means the last scheduled snapshot was taken and the next one is due later
+	ClusterBackupPhaseScheduled ClusterBackupPhase = "Scheduled"
+	// ClusterBackupPhaseFailed means the last snapshot could not be taken; it is retried
+	ClusterBackupPhaseFailed ClusterBackupPhase = "Failed"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterBackup saves snapshots of a cluster's etcd, once or on a schedule
+type ClusterBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterBackupSpec   `json:"spec,omitempty"`
+	Status ClusterBackupStatus `json:"status,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (b *ClusterBackup) DeepCopyObject() runtime.Object {
+	copy := &ClusterBackup{}
+	b.DeepCopyInto(copy)
+	return copy
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (b *ClusterBackup) DeepCopyInto(out *ClusterBackup) {
+	*out = *b
+	out.TypeMeta = b.TypeMeta
+	b.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	b.Spec.DeepCopyInto(&out.Spec)
+	b.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy creates a deep copy of ClusterBackup
+func (b *ClusterBackup) DeepCopy() *ClusterBackup {
+	if b == nil {
+		return nil
+	}
+	out := new(ClusterBackup)
+	b.DeepCopyInto(out)
+	return out
+}
+
+// ClusterBackupSpec defines the desired state of ClusterBackup
+type ClusterBackupSpec struct {
+	// ClusterName is the name of the Cluster in the backup's namespace to back up
+	// +kubebuilder:validation:Required
+	ClusterName string `json:"clusterName"`
+
+	// Location is where the snapshots are stored
+	// +optional
+	Location BackupLocation `json:"location,omitempty"`
+
+	// Interval schedules a snapshot every interval, e.g. 24h. Without it a
+	// single snapshot is taken.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Retention is how many snapshots of a scheduled backup are kept; older
+	// ones are deleted. Defaults to DefaultBackupRetention.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Retention int32 `json:"retention,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *ClusterBackupSpec) DeepCopyInto(out *ClusterBackupSpec) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// ClusterBackupStatus defines the observed state of ClusterBackup
+type ClusterBackupStatus struct {
+	// Phase is the phase of the backup
+	// +kubebuilder:validation:Enum=Completed;Scheduled;Failed
+	// +optional
+	Phase ClusterBackupPhase `json:"phase,omitempty"`
+
+	// Snapshots are the snapshots kept, oldest first
+	// +optional
+	Snapshots []BackupSnapshot `json:"snapshots,omitempty"`
+
+	// LastBackupTime is when the last snapshot was taken
+	// +optional
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+
+	// NextBackupTime is when the next scheduled snapshot is due
+	// +optional
+	NextBackupTime *metav1.Time `json:"nextBackupTime,omitempty"`
+
+	// Message describes the last failure, if any
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *ClusterBackupStatus) DeepCopyInto(out *ClusterBackupStatus) {
+	*out = *in
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]BackupSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastBackupTime != nil {
+		in, out := &in.LastBackupTime, &out.LastBackupTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextBackupTime != nil {
+		in, out := &in.NextBackupTime, &out.NextBackupTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// BackupSnapshot records an etcd snapshot taken by a ClusterBackup
+type BackupSnapshot struct {
+	// Name identifies the snapshot within its location
+	Name string `json:"name"`
+
+	// Location is where the snapshot is stored. It is recorded per snapshot
+	// so changing the backup's location does not lose earlier snapshots.
+	Location BackupLocation `json:"location"`
+
+	// KubernetesVersion is the version the cluster ran when the snapshot was taken
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// Time is when the snapshot was taken
+	Time metav1.Time `json:"time"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *BackupSnapshot) DeepCopyInto(out *BackupSnapshot) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterBackupList contains a list of ClusterBackup
+type ClusterBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterBackup `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (l *ClusterBackupList) DeepCopyObject() runtime.Object {
+	copy := &ClusterBackupList{}
+	l.DeepCopyInto(copy)
+	return copy
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (l *ClusterBackupList) DeepCopyInto(out *ClusterBackupList) {
+	*out = *l
+	out.TypeMeta = l.TypeMeta
+	l.ListMeta.DeepCopyInto(&out.ListMeta)
+	if l.Items != nil {
+		in, out := &l.Items, &out.Items
+		*out = make([]ClusterBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// ClusterRestorePhase is the phase of a ClusterRestore
+type ClusterRestorePhase string
+
+const (
+	// ClusterRestorePhasePending means the restore waits for its backup or cluster
+	ClusterRestorePhasePending ClusterRestorePhase = "Pending"
+	// ClusterRestorePhaseRestoring means etcd is being replaced with the snapshot
+	ClusterRestorePhaseRestoring ClusterRestorePhase = "Restoring"
+	// ClusterRestorePhaseCompleted means the cluster runs on the restored data
+	ClusterRestorePhaseCompleted ClusterRestorePhase = "Completed"
+	// ClusterRestorePhaseFailed means the restore did not complete
+	ClusterRestorePhaseFailed ClusterRestorePhase = "Failed"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterRestore replaces the etcd data of a cluster with a snapshot taken by
+// a ClusterBackup. Each ClusterRestore runs once.
+type ClusterRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterRestoreSpec   `json:"spec,omitempty"`
+	Status ClusterRestoreStatus `json:"status,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (r *ClusterRestore) DeepCopyObject() runtime.Object {
+	copy := &ClusterRestore{}
+	r.DeepCopyInto(copy)
+	return copy
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (r *ClusterRestore) DeepCopyInto(out *ClusterRestore) {
+	*out = *r
+	out.TypeMeta = r.TypeMeta
+	r.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = r.Spec
+	r.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy creates a deep copy of ClusterRestore
+func (r *ClusterRestore) DeepCopy() *ClusterRestore {
+	if r == nil {
+		return nil
+	}
+	out := new(ClusterRestore)
+	r.DeepCopyInto(out)
+	return out
+}
+
+// ClusterRestoreSpec defines the desired state of ClusterRestore
+type ClusterRestoreSpec struct {
+	// ClusterName is the name of the Cluster in the restore's namespace to
+	// restore into. The cluster may be a new one replacing the backed up cluster.
+	// +kubebuilder:validation:Required
+	ClusterName string `json:"clusterName"`
+
+	// BackupName is the name of the ClusterBackup in the restore's namespace
+	// holding the snapshot
+	// +kubebuilder:validation:Required
+	BackupName string `json:"backupName"`
+
+	// Snapshot is the name of the snapshot to restore. Defaults to the newest
+	// snapshot of the backup.
+	// +optional
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// ClusterRestoreStatus defines the observed state of ClusterRestore
+type ClusterRestoreStatus struct {
+	// Phase is the phase of the restore
+	// +kubebuilder:validation:Enum=Pending;Restoring;Completed;Failed
+	// +optional
+	Phase ClusterRestorePhase `json:"phase,omitempty"`
+
+	// Snapshot is the snapshot being restored
+	// +optional
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// Message describes what the restore waits for or why it failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartTime is when the restore started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the restore finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *ClusterRestoreStatus) DeepCopyInto(out *ClusterRestoreStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterRestoreList contains a list of ClusterRestore
+type ClusterRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterRestore `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (l *ClusterRestoreList) DeepCopyObject() runtime.Object {
+	copy := &ClusterRestoreList{}
+	l.DeepCopyInto(copy)
+	return copy
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (l *ClusterRestoreList) DeepCopyInto(out *ClusterRestoreList) {
+	*out = *l
+	out.TypeMeta = l.TypeMeta
+	l.ListMeta.DeepCopyInto(&out.ListMeta)
+	if l.Items != nil {
+		in, out := &l.Items, &out.Items
+		*out = make([]ClusterRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterBackup{}, &ClusterBackupList{}, &ClusterRestore{}, &ClusterRestoreList{})
+}
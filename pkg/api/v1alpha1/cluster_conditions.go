@@ -0,0 +1,20 @@
+package v1alpha1
+
+const (
+	// ClusterConditionReady is True while the cluster runs its current spec
+	// with all its nodes ready
+	ClusterConditionReady = "Ready"
+
+	// ClusterConditionKubeconfigAvailable is True once the admin kubeconfig
+	// of the cluster has been published to its kubeconfig Secret
+	ClusterConditionKubeconfigAvailable = "KubeconfigAvailable"
+)
+
+// KubeconfigSecretKey is the key of the kubeconfig in a cluster's kubeconfig Secret
+const KubeconfigSecretKey = "value"
+
+// KubeconfigSecretName returns the name of the Secret, in the namespace of
+// the cluster, that holds the admin kubeconfig of the cluster
+func KubeconfigSecretName(clusterName string) string {
+	return clusterName + "-kubeconfig"
+}
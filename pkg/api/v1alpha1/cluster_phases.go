@@ -16,6 +16,9 @@ const (
 	// ClusterPhaseUpdating indicates the cluster is being updated
 	ClusterPhaseUpdating ClusterPhase = "Updating"
 
+	// ClusterPhaseSuspended indicates the cluster's nodes are stopped on request
+	ClusterPhaseSuspended ClusterPhase = "Suspended"
+
 	// ClusterPhaseFailed indicates the cluster operation has failed
 	ClusterPhaseFailed ClusterPhase = "Failed"
 
@@ -3,6 +3,9 @@
 package v1alpha1
 
 import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -59,6 +62,52 @@ type ClusterSpec struct {
 	// Workers defines the desired state of the worker nodes
 	// +kubebuilder:validation:Required
 	Workers WorkerConfig `json:"workers"`
+
+	// ImagePullPolicy determines when the node image is pulled: Always,
+	// IfNotPresent or Never. Defaults to IfNotPresent.
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// NodeImage overrides where the node image comes from
+	// +optional
+	NodeImage *NodeImageSpec `json:"nodeImage,omitempty"`
+
+	// RollbackPolicy determines what happens when an upgrade fails part way:
+	// Automatic recreates the upgraded nodes from their previous image right
+	// away, Manual waits for the RollbackAnnotation. Defaults to Automatic.
+	// +kubebuilder:validation:Enum=Automatic;Manual
+	// +optional
+	RollbackPolicy RollbackPolicy `json:"rollbackPolicy,omitempty"`
+
+	// Suspended stops all nodes of the cluster while keeping their network,
+	// volumes and state. Clearing it starts the nodes again.
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+
+	// CloneFrom names a running cluster in the same namespace to create this
+	// cluster as a copy of, with the source's nodes, volumes and etcd data.
+	// The rest of the spec is taken from the source when the cluster is
+	// created, so it can be left empty. It has no effect afterwards.
+	// +optional
+	CloneFrom *corev1.LocalObjectReference `json:"cloneFrom,omitempty"`
+
+	// HealthCheck makes the controller repair nodes that stay unhealthy while
+	// the cluster is running. Without it unhealthy nodes are only reported.
+	// +optional
+	HealthCheck *MachineHealthCheckSpec `json:"healthCheck,omitempty"`
+
+	// Networking configures the Kubernetes networking of the cluster. It is
+	// carried over from and to kind configs; the manager does not apply it
+	// to the nodes.
+	// +optional
+	Networking *NetworkingSpec `json:"networking,omitempty"`
+
+	// KubeadmConfigPatches are patches to the kubeadm configuration of all
+	// nodes, as in kind configs. Like Networking, they are carried over from
+	// and to kind configs but not applied to the nodes.
+	// +optional
+	KubeadmConfigPatches []string `json:"kubeadmConfigPatches,omitempty"`
 }
 
 // DeepCopyInto copies all properties of this object into another object of the same type
@@ -66,22 +115,79 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 	*out = *in
 	in.ControlPlane.DeepCopyInto(&out.ControlPlane)
 	in.Workers.DeepCopyInto(&out.Workers)
+	if in.NodeImage != nil {
+		in, out := &in.NodeImage, &out.NodeImage
+		*out = new(NodeImageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CloneFrom != nil {
+		in, out := &in.CloneFrom, &out.CloneFrom
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(MachineHealthCheckSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Networking != nil {
+		in, out := &in.Networking, &out.Networking
+		*out = new(NetworkingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubeadmConfigPatches != nil {
+		out.KubeadmConfigPatches = append([]string(nil), in.KubeadmConfigPatches...)
+	}
+}
+
+// NodeImageSpec describes the image the cluster's nodes run. The image tag is
+// always the cluster's KubernetesVersion.
+type NodeImageSpec struct {
+	// Repository is the node image repository, e.g. registry.example.com/kind/node.
+	// Defaults to the provider's repository, kindest/node unless configured otherwise.
+	// +optional
+	Repository string `json:"repository,omitempty"`
+
+	// Digest pins the node image to an exact build, e.g. sha256:0123...
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// PullSecretRef names a Secret of type kubernetes.io/dockerconfigjson in
+	// the cluster's namespace holding the credentials for the image registry
+	// +optional
+	PullSecretRef *corev1.LocalObjectReference `json:"pullSecretRef,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *NodeImageSpec) DeepCopyInto(out *NodeImageSpec) {
+	*out = *in
+	if in.PullSecretRef != nil {
+		in, out := &in.PullSecretRef, &out.PullSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // ControlPlaneConfig defines the configuration for control plane nodes
 type ControlPlaneConfig struct {
-	// Count is the number of control plane nodes
+	// Count is the number of control plane nodes. It has to be odd, so etcd
+	// keeps a majority when a member fails. Control plane nodes are added and
+	// removed one at a time.
 	// +kubebuilder:validation:Minimum=1
 	Count int32 `json:"count"`
 
 	// MachineConfig defines the hardware configuration for the nodes
 	MachineConfig MachineConfig `json:"machineConfig"`
+
+	NodeExtras `json:",inline"`
 }
 
 // DeepCopyInto copies all properties of this object into another object of the same type
 func (in *ControlPlaneConfig) DeepCopyInto(out *ControlPlaneConfig) {
 	*out = *in
 	in.MachineConfig.DeepCopyInto(&out.MachineConfig)
+	in.NodeExtras.DeepCopyInto(&out.NodeExtras)
 }
 
 // WorkerConfig defines the configuration for worker nodes
@@ -92,15 +198,46 @@ type WorkerConfig struct {
 
 	// MachineConfig defines the hardware configuration for the nodes
 	MachineConfig MachineConfig `json:"machineConfig"`
+
+	// DrainTimeout bounds how long a worker removed by scaling down is
+	// drained for, evicting its pods within their PodDisruptionBudgets. The
+	// worker is removed once it is drained or the timeout passes.
+	// Defaults to DefaultDrainTimeout.
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
+
+	// RolloutStrategy controls how workers are replaced when a change of
+	// their MachineConfig cannot be applied to the running containers
+	// +optional
+	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy,omitempty"`
+
+	NodeExtras `json:",inline"`
 }
 
+// DefaultDrainTimeout is how long workers are drained for unless their
+// WorkerConfig says otherwise
+const DefaultDrainTimeout = 5 * time.Minute
+
 // DeepCopyInto copies all properties of this object into another object of the same type
 func (in *WorkerConfig) DeepCopyInto(out *WorkerConfig) {
 	*out = *in
 	in.MachineConfig.DeepCopyInto(&out.MachineConfig)
+	in.NodeExtras.DeepCopyInto(&out.NodeExtras)
+	if in.DrainTimeout != nil {
+		in, out := &in.DrainTimeout, &out.DrainTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RolloutStrategy != nil {
+		in, out := &in.RolloutStrategy, &out.RolloutStrategy
+		*out = new(RolloutStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// MachineConfig defines the hardware configuration for a node
+// MachineConfig defines the hardware configuration for a node. Changes are
+// applied to existing nodes in place where Docker can change the resources of
+// a running container; otherwise the nodes are replaced.
 type MachineConfig struct {
 	// Memory is the amount of memory to allocate to the node (e.g., "2Gi")
 	Memory string `json:"memory"`
@@ -118,7 +255,7 @@ func (in *MachineConfig) DeepCopyInto(out *MachineConfig) {
 // ClusterStatus defines the observed state of Cluster
 type ClusterStatus struct {
 	// Phase represents the current phase of cluster actuation
-	// +kubebuilder:validation:Enum=Pending;Provisioning;Running;Updating;Failed;Deleting
+	// +kubebuilder:validation:Enum=Pending;Provisioning;Running;Updating;Suspended;Failed;Deleting
 	Phase ClusterPhase `json:"phase,omitempty"`
 
 	Message string `json:"message,omitempty"`
@@ -131,6 +268,27 @@ type ClusterStatus struct {
 
 	// WorkersReady indicates the number of workers that are ready
 	WorkersReady int32 `json:"workersReady"`
+
+	// ObservedGeneration is the most recent spec generation applied to the cluster
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// KubernetesVersion is the Kubernetes version the nodes are running
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// ImagePull reports the progress of the most recent node image pull
+	// +optional
+	ImagePull *ImagePullStatus `json:"imagePull,omitempty"`
+
+	// Upgrade records the most recent Kubernetes upgrade
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// Remediations records the most recent remediations of unhealthy nodes,
+	// oldest first
+	// +optional
+	Remediations []NodeRemediation `json:"remediations,omitempty"`
 }
 
 // DeepCopyInto copies all properties of this object into another object of the same type
@@ -143,6 +301,67 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ImagePull != nil {
+		in, out := &in.ImagePull, &out.ImagePull
+		*out = new(ImagePullStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Remediations != nil {
+		in, out := &in.Remediations, &out.Remediations
+		*out = make([]NodeRemediation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// ImagePullState is the state of a node image pull
+type ImagePullState string
+
+const (
+	// ImagePullStatePulling means the image is being downloaded
+	ImagePullStatePulling ImagePullState = "Pulling"
+	// ImagePullStatePulled means the image is available locally
+	ImagePullStatePulled ImagePullState = "Pulled"
+	// ImagePullStateFailed means the image could not be pulled
+	ImagePullStateFailed ImagePullState = "Failed"
+)
+
+// ImagePullStatus describes the progress of a node image pull
+type ImagePullStatus struct {
+	// Image is the reference of the image being pulled
+	Image string `json:"image"`
+
+	// State is the state of the pull
+	// +kubebuilder:validation:Enum=Pulling;Pulled;Failed
+	State ImagePullState `json:"state"`
+
+	// CurrentBytes is the number of bytes downloaded so far
+	// +optional
+	CurrentBytes int64 `json:"currentBytes,omitempty"`
+
+	// TotalBytes is the size of the layers being downloaded, as far as known
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+
+	// Message describes the pull step in progress or the pull error
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastUpdateTime is when the progress was last reported
+	// +optional
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *ImagePullStatus) DeepCopyInto(out *ImagePullStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
 }
 
 // +kubebuilder:object:root=true
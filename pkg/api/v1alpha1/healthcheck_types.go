@@ -0,0 +1,122 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UnhealthyConditionType names a way in which a node can be unhealthy
+type UnhealthyConditionType string
+
+const (
+	// UnhealthyConditionContainerNotRunning matches nodes whose container has
+	// exited or was stopped by something other than the manager
+	UnhealthyConditionContainerNotRunning UnhealthyConditionType = "ContainerNotRunning"
+	// UnhealthyConditionNodeNotReady matches nodes whose kubelet does not
+	// report the Node as Ready. Only clusters bootstrapped with kubeadm have
+	// an API to report it through.
+	UnhealthyConditionNodeNotReady UnhealthyConditionType = "NodeNotReady"
+)
+
+// RemediationAction is how an unhealthy node is repaired
+type RemediationAction string
+
+const (
+	// RemediationActionRestart restarts the node's container, keeping its state
+	RemediationActionRestart RemediationAction = "Restart"
+	// RemediationActionRecreate replaces the node's container with a new one of
+	// the same name and joins it to the cluster again. Control plane nodes of
+	// clusters bootstrapped with kubeadm hold etcd data and are restarted instead.
+	RemediationActionRecreate RemediationAction = "Recreate"
+)
+
+// RemediationResult is the outcome of a node remediation
+type RemediationResult string
+
+const (
+	// RemediationResultSucceeded means the node was restarted or recreated
+	RemediationResultSucceeded RemediationResult = "Succeeded"
+	// RemediationResultFailed means the remediation ran into an error
+	RemediationResultFailed RemediationResult = "Failed"
+)
+
+const (
+	// DefaultMaxRemediating is how many nodes are remediated at once unless
+	// the health check says otherwise
+	DefaultMaxRemediating = 1
+
+	// MaxRemediationHistory is how many remediations a cluster's status keeps
+	MaxRemediationHistory = 10
+)
+
+// MachineHealthCheckSpec describes when the nodes of a cluster count as
+// unhealthy and how they are repaired
+type MachineHealthCheckSpec struct {
+	// UnhealthyConditions are the conditions under which a node is remediated.
+	// A node that matches any of them is unhealthy.
+	// +kubebuilder:validation:MinItems=1
+	UnhealthyConditions []UnhealthyCondition `json:"unhealthyConditions"`
+
+	// MaxRemediating is the maximum number of nodes remediated at once. Further
+	// unhealthy nodes wait for the next check. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRemediating int32 `json:"maxRemediating,omitempty"`
+
+	// Remediation is how unhealthy nodes are repaired: Restart or Recreate.
+	// Defaults to Restart.
+	// +kubebuilder:validation:Enum=Restart;Recreate
+	// +optional
+	Remediation RemediationAction `json:"remediation,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *MachineHealthCheckSpec) DeepCopyInto(out *MachineHealthCheckSpec) {
+	*out = *in
+	if in.UnhealthyConditions != nil {
+		in, out := &in.UnhealthyConditions, &out.UnhealthyConditions
+		*out = make([]UnhealthyCondition, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// UnhealthyCondition is a condition that makes a node unhealthy once it has
+// held for Timeout
+type UnhealthyCondition struct {
+	// Type is the condition: ContainerNotRunning or NodeNotReady
+	// +kubebuilder:validation:Enum=ContainerNotRunning;NodeNotReady
+	Type UnhealthyConditionType `json:"type"`
+
+	// Timeout is how long the condition has to hold before the node is
+	// remediated. It also keeps a remediated node from being remediated
+	// again before it had the time to recover.
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// NodeRemediation records the remediation of an unhealthy node
+type NodeRemediation struct {
+	// Node is the name of the node
+	Node string `json:"node"`
+
+	// Condition is the unhealthy condition the node matched
+	Condition UnhealthyConditionType `json:"condition"`
+
+	// Action is how the node was repaired
+	Action RemediationAction `json:"action"`
+
+	// Result is the outcome of the remediation
+	// +kubebuilder:validation:Enum=Succeeded;Failed
+	Result RemediationResult `json:"result"`
+
+	// Message describes why the remediation failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Time is when the remediation started
+	Time metav1.Time `json:"time"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *NodeRemediation) DeepCopyInto(out *NodeRemediation) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
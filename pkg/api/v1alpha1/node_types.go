@@ -0,0 +1,145 @@
+package v1alpha1
+
+// PortProtocol is the protocol of a port mapping
+type PortProtocol string
+
+// Protocols of port mappings
+const (
+	PortProtocolTCP  PortProtocol = "TCP"
+	PortProtocolUDP  PortProtocol = "UDP"
+	PortProtocolSCTP PortProtocol = "SCTP"
+)
+
+// PortMapping publishes a port of a node container on the host, like the
+// extraPortMappings of kind nodes
+type PortMapping struct {
+	// ContainerPort is the port in the node container
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// HostPort is the port on the host. Docker picks a free one if it is not
+	// set. A host port can only be bound by one node, so pools with more
+	// than one node should leave it unset.
+	// +optional
+	HostPort int32 `json:"hostPort,omitempty"`
+
+	// ListenAddress is the host address the port is bound to. Defaults to
+	// all addresses.
+	// +optional
+	ListenAddress string `json:"listenAddress,omitempty"`
+
+	// Protocol defaults to TCP
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +optional
+	Protocol PortProtocol `json:"protocol,omitempty"`
+}
+
+// MountPropagation is how mounts below a mount are shared between the host
+// and a node container
+type MountPropagation string
+
+const (
+	// MountPropagationNone shares no mounts, Docker's rprivate
+	MountPropagationNone MountPropagation = "None"
+	// MountPropagationHostToContainer shares mounts made on the host with the
+	// node, Docker's rslave
+	MountPropagationHostToContainer MountPropagation = "HostToContainer"
+	// MountPropagationBidirectional shares mounts both ways, Docker's rshared
+	MountPropagationBidirectional MountPropagation = "Bidirectional"
+)
+
+// Mount mounts a host path into a node container, like the extraMounts of
+// kind nodes
+type Mount struct {
+	// HostPath is the path on the host
+	HostPath string `json:"hostPath"`
+
+	// ContainerPath is the path in the node container
+	ContainerPath string `json:"containerPath"`
+
+	// ReadOnly mounts the path read-only
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// SelinuxRelabel relabels the host path for use by the node container
+	// +optional
+	SelinuxRelabel bool `json:"selinuxRelabel,omitempty"`
+
+	// Propagation defaults to None
+	// +kubebuilder:validation:Enum=None;HostToContainer;Bidirectional
+	// +optional
+	Propagation MountPropagation `json:"propagation,omitempty"`
+}
+
+// NodeExtras are the host resources given to each node of a node pool.
+// Changes apply to nodes created afterwards.
+type NodeExtras struct {
+	// ExtraPortMappings publishes ports of each node on the host
+	// +optional
+	ExtraPortMappings []PortMapping `json:"extraPortMappings,omitempty"`
+
+	// ExtraMounts mounts host paths into each node
+	// +optional
+	ExtraMounts []Mount `json:"extraMounts,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *NodeExtras) DeepCopyInto(out *NodeExtras) {
+	*out = *in
+	if in.ExtraPortMappings != nil {
+		out.ExtraPortMappings = append([]PortMapping(nil), in.ExtraPortMappings...)
+	}
+	if in.ExtraMounts != nil {
+		out.ExtraMounts = append([]Mount(nil), in.ExtraMounts...)
+	}
+}
+
+// IPFamily is the IP family of a cluster network
+type IPFamily string
+
+// IP families of cluster networks
+const (
+	IPFamilyIPv4      IPFamily = "ipv4"
+	IPFamilyIPv6      IPFamily = "ipv6"
+	IPFamilyDualStack IPFamily = "dual"
+)
+
+// NetworkingSpec configures the Kubernetes networking of a cluster, as the
+// networking section of kind configs does. Node containers are always
+// attached to the cluster's Docker network.
+type NetworkingSpec struct {
+	// IPFamily is the IP family of the cluster, ipv4, ipv6 or dual
+	// +kubebuilder:validation:Enum=ipv4;ipv6;dual
+	// +optional
+	IPFamily IPFamily `json:"ipFamily,omitempty"`
+
+	// APIServerAddress is the host address the API server is published on
+	// +optional
+	APIServerAddress string `json:"apiServerAddress,omitempty"`
+
+	// APIServerPort is the host port the API server is published on
+	// +optional
+	APIServerPort int32 `json:"apiServerPort,omitempty"`
+
+	// PodSubnet is the CIDR pod addresses are allocated from
+	// +optional
+	PodSubnet string `json:"podSubnet,omitempty"`
+
+	// ServiceSubnet is the CIDR service addresses are allocated from
+	// +optional
+	ServiceSubnet string `json:"serviceSubnet,omitempty"`
+
+	// DisableDefaultCNI leaves installing a CNI plugin to the user
+	// +optional
+	DisableDefaultCNI bool `json:"disableDefaultCNI,omitempty"`
+
+	// KubeProxyMode is the mode of kube-proxy, e.g. iptables, ipvs or none
+	// +optional
+	KubeProxyMode string `json:"kubeProxyMode,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *NetworkingSpec) DeepCopyInto(out *NetworkingSpec) {
+	*out = *in
+}
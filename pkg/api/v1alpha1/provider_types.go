@@ -23,6 +23,23 @@ type DockerProviderConfigSpec struct {
 
 	// ResourceLimits defines resource constraints for Docker containers
 	ResourceLimits ResourceLimitsConfig `json:"resourceLimits"`
+
+	// MaxConcurrentNodeOperations limits how many nodes are created or removed
+	// at the same time. Defaults to 4.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentNodeOperations int32 `json:"maxConcurrentNodeOperations,omitempty"`
+
+	// NodeImageRepository is the repository node images are pulled from unless
+	// a cluster says otherwise. Defaults to kindest/node.
+	// +optional
+	NodeImageRepository string `json:"nodeImageRepository,omitempty"`
+
+	// EtcdSnapshotDirectory is the directory on the manager's host where etcd
+	// snapshots are stored. Defaults to mini-k8s-manager/snapshots in the
+	// user's cache directory.
+	// +optional
+	EtcdSnapshotDirectory string `json:"etcdSnapshotDirectory,omitempty"`
 }
 
 // NetworkConfig defines the network configuration for Docker provider
@@ -123,6 +140,16 @@ func (d *DockerProviderConfig) MergeWith(other *DockerProviderConfig) *DockerPro
 		result.Spec.ResourceLimits.Storage = other.Spec.ResourceLimits.Storage
 	}
 
+	if other.Spec.MaxConcurrentNodeOperations != 0 {
+		result.Spec.MaxConcurrentNodeOperations = other.Spec.MaxConcurrentNodeOperations
+	}
+	if other.Spec.NodeImageRepository != "" {
+		result.Spec.NodeImageRepository = other.Spec.NodeImageRepository
+	}
+	if other.Spec.EtcdSnapshotDirectory != "" {
+		result.Spec.EtcdSnapshotDirectory = other.Spec.EtcdSnapshotDirectory
+	}
+
 	return result
 }
 
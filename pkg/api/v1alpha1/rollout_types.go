@@ -0,0 +1,47 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// DefaultMaxSurge is how many workers a rollout creates above the desired
+	// count unless its RolloutStrategy says otherwise
+	DefaultMaxSurge = 1
+
+	// DefaultMaxUnavailable is how many workers a rollout takes down below the
+	// desired count unless its RolloutStrategy says otherwise
+	DefaultMaxUnavailable = 0
+)
+
+// RolloutStrategy controls how workers are replaced when their MachineConfig
+// changes in a way Docker cannot apply to running containers
+type RolloutStrategy struct {
+	// MaxSurge is how many workers may be created above the desired count
+	// while workers are replaced, as a number or a percentage of the desired
+	// count rounded up. Defaults to DefaultMaxSurge.
+	// +optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// MaxUnavailable is how many workers may be unavailable below the desired
+	// count while workers are replaced, as a number or a percentage of the
+	// desired count rounded down. Defaults to DefaultMaxUnavailable. It
+	// cannot be 0 when MaxSurge is 0.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *RolloutStrategy) DeepCopyInto(out *RolloutStrategy) {
+	*out = *in
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
@@ -0,0 +1,134 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RollbackPolicy determines how failed upgrades are rolled back
+type RollbackPolicy string
+
+const (
+	// RollbackPolicyAutomatic rolls a failed upgrade back as soon as it fails
+	RollbackPolicyAutomatic RollbackPolicy = "Automatic"
+	// RollbackPolicyManual leaves a failed upgrade until the RollbackAnnotation is set
+	RollbackPolicyManual RollbackPolicy = "Manual"
+)
+
+// UpgradePhase is the phase of a Kubernetes upgrade
+type UpgradePhase string
+
+const (
+	// UpgradePhaseInProgress means nodes are being replaced
+	UpgradePhaseInProgress UpgradePhase = "InProgress"
+	// UpgradePhaseSucceeded means all nodes run the new version
+	UpgradePhaseSucceeded UpgradePhase = "Succeeded"
+	// UpgradePhaseFailed means the upgrade stopped part way
+	UpgradePhaseFailed UpgradePhase = "Failed"
+	// UpgradePhaseRollingBack means upgraded nodes are being recreated from their previous image
+	UpgradePhaseRollingBack UpgradePhase = "RollingBack"
+	// UpgradePhaseRolledBack means all nodes run the previous version again
+	UpgradePhaseRolledBack UpgradePhase = "RolledBack"
+)
+
+// NodeUpgradeState is the state of a single node during an upgrade
+type NodeUpgradeState string
+
+const (
+	// NodeUpgradeStateUpgrading means the node is being replaced
+	NodeUpgradeStateUpgrading NodeUpgradeState = "Upgrading"
+	// NodeUpgradeStateUpgraded means the node runs the new image
+	NodeUpgradeStateUpgraded NodeUpgradeState = "Upgraded"
+	// NodeUpgradeStateFailed means replacing the node failed
+	NodeUpgradeStateFailed NodeUpgradeState = "Failed"
+	// NodeUpgradeStateRolledBack means the node runs its previous image again
+	NodeUpgradeStateRolledBack NodeUpgradeState = "RolledBack"
+)
+
+// UpgradeStatus records a Kubernetes upgrade: what the cluster looked like
+// before, what happened to each node and where etcd was saved
+type UpgradeStatus struct {
+	// FromVersion is the version the nodes ran before the upgrade
+	FromVersion string `json:"fromVersion"`
+
+	// ToVersion is the version being upgraded to
+	ToVersion string `json:"toVersion"`
+
+	// Generation is the spec generation being rolled out
+	Generation int64 `json:"generation"`
+
+	// Phase is the phase of the upgrade
+	// +kubebuilder:validation:Enum=InProgress;Succeeded;Failed;RollingBack;RolledBack
+	Phase UpgradePhase `json:"phase"`
+
+	// PreviousSpec is the spec the cluster ran before the upgrade
+	PreviousSpec ClusterSpec `json:"previousSpec"`
+
+	// Nodes records the nodes the upgrade has touched so far
+	// +optional
+	Nodes []NodeUpgradeStatus `json:"nodes,omitempty"`
+
+	// EtcdSnapshot is the path of the etcd snapshot taken before the upgrade
+	// on the host running the manager
+	// +optional
+	EtcdSnapshot string `json:"etcdSnapshot,omitempty"`
+
+	// Message describes why the upgrade failed or how it ended
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartTime is when the upgrade started
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the upgrade succeeded or was rolled back
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (in *UpgradeStatus) DeepCopyInto(out *UpgradeStatus) {
+	*out = *in
+	in.PreviousSpec.DeepCopyInto(&out.PreviousSpec)
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeUpgradeStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy creates a deep copy of UpgradeStatus
+func (in *UpgradeStatus) DeepCopy() *UpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// NodeUpgradeStatus is the state of a node during an upgrade
+type NodeUpgradeStatus struct {
+	// Name is the name of the node
+	Name string `json:"name"`
+
+	// Role is the role of the node (control-plane or worker)
+	Role string `json:"role"`
+
+	// PreviousImage is the image the node ran before the upgrade
+	PreviousImage string `json:"previousImage"`
+
+	// Image is the image the node is being upgraded to
+	Image string `json:"image"`
+
+	// State is the state of the node
+	// +kubebuilder:validation:Enum=Upgrading;Upgraded;Failed;RolledBack
+	State NodeUpgradeState `json:"state"`
+
+	// Message describes why replacing the node failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
@@ -1,58 +1,282 @@
+// Package cluster holds the operations on clusters shared by the controller
+// and the CLI: validating cluster specs and creating, updating, deleting,
+// scaling, upgrading and observing clusters through a provider.
 package cluster
 
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
+
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-// CreateCluster creates a new cluster based on the provided specifications.
-func CreateCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
-	// Validate the cluster specification
+// DefaultPollInterval is how often WaitForReady asks the provider for the
+// status of a cluster
+const DefaultPollInterval = 5 * time.Second
+
+// ErrInvalidSpec is returned for cluster specs and changes that are rejected
+// before the provider is called
+var ErrInvalidSpec = errors.New("invalid cluster spec")
+
+// Operations creates, updates, deletes, scales, upgrades and observes
+// clusters through a provider. Its operations are idempotent: creating a
+// cluster that exists with the nodes of its spec, deleting one that does not,
+// and scaling or upgrading a cluster to what it already runs all succeed
+// without changing anything.
+type Operations struct {
+	provider     providers.Provider
+	versions     *versions.Catalog
+	pollInterval time.Duration
+}
+
+// Option configures Operations
+type Option func(*Operations)
+
+// WithVersionCatalog checks Kubernetes versions and upgrades against catalog.
+// Without a catalog they are left to the provider.
+func WithVersionCatalog(catalog *versions.Catalog) Option {
+	return func(o *Operations) {
+		o.versions = catalog
+	}
+}
+
+// WithPollInterval sets how often WaitForReady polls the provider
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *Operations) {
+		o.pollInterval = interval
+	}
+}
+
+// NewOperations returns the operations on the clusters of provider
+func NewOperations(provider providers.Provider, opts ...Option) *Operations {
+	o := &Operations{provider: provider, pollInterval: DefaultPollInterval}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Create provisions the nodes of cluster. A cluster that exists already is
+// left as it is if the provider reports the nodes of its spec ready, and
+// updated to its spec otherwise, which creates the nodes it is missing.
+func (o *Operations) Create(ctx context.Context, cluster *v1alpha1.Cluster) error {
 	if cluster == nil {
-		return errors.New("cluster specification cannot be nil")
+		return fmt.Errorf("%w: cluster cannot be nil", ErrInvalidSpec)
+	}
+	if err := ValidateSpec(&cluster.Spec, o.versions); err != nil {
+		return err
 	}
+	log := log.FromContext(ctx).WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
 
-	// Create Docker provider configuration
-	config := &v1alpha1.DockerProviderConfig{
-		Spec: v1alpha1.DockerProviderConfigSpec{
-			Network: v1alpha1.NetworkConfig{
-				CIDR: "10.10.0.0/24", // Valid CIDR block
-			},
-		},
+	log.V(1).Info("Creating cluster", "controlPlanes", cluster.Spec.ControlPlane.Count, "workers", cluster.Spec.Workers.Count)
+	if err := o.provider.CreateCluster(ctx, cluster); err != nil {
+		if !errors.Is(err, providers.ErrClusterExists) {
+			return err
+		}
+		status, err := o.provider.GetClusterStatus(ctx, cluster)
+		if err != nil {
+			return fmt.Errorf("cluster %s exists but its status is unknown: %w", cluster.Name, err)
+		}
+		if ready(cluster, status) {
+			log.Info("Cluster already exists")
+			return nil
+		}
+		log.Info("Cluster already exists without the nodes of its spec, updating it", "phase", status.Phase,
+			"controlPlaneReady", status.ControlPlaneReady, "workersReady", status.WorkersReady)
+		return o.Update(ctx, cluster)
 	}
+	return nil
+}
 
-	// Call the Docker provider to create the cluster resources
-	provider, err := providers.NewDockerProvider(config)
-	if err != nil {
+// Delete removes the nodes of cluster. Clusters that are already gone are
+// not an error.
+func (o *Operations) Delete(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	if err := o.provider.DeleteCluster(ctx, cluster); err != nil && !errors.Is(err, providers.ErrClusterNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Update applies the spec of cluster to its nodes: their counts, machine
+// configuration and Kubernetes version. Node counts are checked first, and a
+// version that differs from the one the cluster runs is checked as an upgrade.
+func (o *Operations) Update(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	if cluster == nil {
+		return fmt.Errorf("%w: cluster cannot be nil", ErrInvalidSpec)
+	}
+	if err := ValidateScale(cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count); err != nil {
+		return err
+	}
+	if from, to := cluster.Status.KubernetesVersion, cluster.Spec.KubernetesVersion; from != "" && from != to {
+		if err := ValidateUpgrade(from, to, o.versions); err != nil {
+			return err
+		}
+	}
+	log.FromContext(ctx).V(1).Info("Updating cluster", "cluster", cluster.Name, "namespace", cluster.Namespace,
+		"controlPlanes", cluster.Spec.ControlPlane.Count, "workers", cluster.Spec.Workers.Count, "version", cluster.Spec.KubernetesVersion)
+	return o.provider.UpdateCluster(ctx, cluster)
+}
+
+// Scale brings cluster to controlPlanes control plane nodes and workers
+// worker nodes, and sets its spec to them. Nothing is done if the spec and
+// the nodes the provider reports already have those counts.
+func (o *Operations) Scale(ctx context.Context, cluster *v1alpha1.Cluster, controlPlanes, workers int32) error {
+	if err := ValidateScale(controlPlanes, workers); err != nil {
 		return err
 	}
+	if cluster.Spec.ControlPlane.Count == controlPlanes && cluster.Spec.Workers.Count == workers {
+		status, err := o.provider.GetClusterStatus(ctx, cluster)
+		if err != nil {
+			return err
+		}
+		if ready(cluster, status) {
+			return nil
+		}
+	}
+
+	updated := cluster.DeepCopy()
+	updated.Spec.ControlPlane.Count = controlPlanes
+	updated.Spec.Workers.Count = workers
+	log.FromContext(ctx).V(1).Info("Scaling cluster", "cluster", cluster.Name, "namespace", cluster.Namespace,
+		"controlPlanes", controlPlanes, "workers", workers)
+	if err := o.provider.UpdateCluster(ctx, updated); err != nil {
+		return err
+	}
+	cluster.Spec = updated.Spec
+	return nil
+}
 
-	if err := provider.CreateCluster(ctx, cluster); err != nil {
+// Upgrade moves cluster to Kubernetes version and sets its spec to it.
+// Clusters already running version are left alone.
+func (o *Operations) Upgrade(ctx context.Context, cluster *v1alpha1.Cluster, version string) error {
+	from := runningVersion(cluster)
+	if from == version {
+		return nil
+	}
+	if err := ValidateUpgrade(from, version, o.versions); err != nil {
 		return err
 	}
 
-	// Update the cluster status to indicate success
-	cluster.Status = v1alpha1.ClusterStatus{
-		Phase:             v1alpha1.ClusterPhaseRunning,
-		ControlPlaneReady: true,
-		WorkersReady:      cluster.Spec.Workers.Count,
-		Conditions:        []metav1.Condition{},
-		Message:           "Cluster created successfully",
+	updated := cluster.DeepCopy()
+	updated.Spec.KubernetesVersion = version
+	log.FromContext(ctx).V(1).Info("Upgrading cluster", "cluster", cluster.Name, "namespace", cluster.Namespace,
+		"from", from, "to", version)
+	if err := o.provider.UpdateCluster(ctx, updated); err != nil {
+		return err
 	}
+	cluster.Spec = updated.Spec
+	cluster.Status.KubernetesVersion = version
 	return nil
 }
 
-// DeleteCluster deletes the specified cluster and cleans up resources.
-func DeleteCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
-	// Implement cluster deletion logic here
+// Status returns what the provider observes of cluster
+func (o *Operations) Status(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+	return o.provider.GetClusterStatus(ctx, cluster)
+}
+
+// WaitForReady waits up to timeout for the provider to report cluster
+// running with the nodes of its spec ready, and returns that status. It
+// fails early if the cluster fails or is gone.
+func (o *Operations) WaitForReady(ctx context.Context, cluster *v1alpha1.Cluster, timeout time.Duration) (*v1alpha1.ClusterStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var last *v1alpha1.ClusterStatus
+	for {
+		status, err := o.provider.GetClusterStatus(ctx, cluster)
+		if err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+		if status != nil {
+			last = status
+			if ready(cluster, status) {
+				return status, nil
+			}
+			if status.Phase == v1alpha1.ClusterPhaseFailed {
+				return status, fmt.Errorf("cluster %s failed: %s", cluster.Name, status.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			phase := "unknown"
+			if last != nil && last.Phase != "" {
+				phase = string(last.Phase)
+			}
+			return last, fmt.Errorf("timed out after %s waiting for cluster %s to be ready, it is %s", timeout, cluster.Name, phase)
+		case <-time.After(o.pollInterval):
+		}
+	}
+}
+
+// ValidateSpec checks what the provider needs of a cluster spec: a Kubernetes
+// version, in catalog if it is not nil, an odd number of control plane
+// nodes, so that etcd keeps a majority when a member fails, and no negative
+// worker count.
+func ValidateSpec(spec *v1alpha1.ClusterSpec, catalog *versions.Catalog) error {
+	if spec == nil {
+		return fmt.Errorf("%w: spec cannot be nil", ErrInvalidSpec)
+	}
+	if spec.KubernetesVersion == "" {
+		return fmt.Errorf("%w: kubernetes version is required", ErrInvalidSpec)
+	}
+	if catalog != nil {
+		if _, err := catalog.Validate(spec.KubernetesVersion); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+		}
+	}
+	return ValidateScale(spec.ControlPlane.Count, spec.Workers.Count)
+}
+
+// ValidateScale checks node counts for a cluster
+func ValidateScale(controlPlanes, workers int32) error {
+	if controlPlanes < 1 || controlPlanes%2 == 0 {
+		return fmt.Errorf("%w: the control plane needs an odd number of nodes for etcd to keep a majority, not %d", ErrInvalidSpec, controlPlanes)
+	}
+	if workers < 0 {
+		return fmt.Errorf("%w: worker count cannot be negative, got %d", ErrInvalidSpec, workers)
+	}
 	return nil
 }
 
-// ScaleCluster scales the number of worker nodes in the specified cluster.
-func ScaleCluster(ctx context.Context, cluster *v1alpha1.Cluster, newCount int) error {
-	// Implement scaling logic here
+// ValidateUpgrade checks an upgrade from Kubernetes version from to to
+// against catalog; without a version to upgrade from, to only needs to be in it.
+// Without a catalog only a target version is required.
+func ValidateUpgrade(from, to string, catalog *versions.Catalog) error {
+	if to == "" {
+		return fmt.Errorf("%w: kubernetes version is required", ErrInvalidSpec)
+	}
+	if catalog == nil {
+		return nil
+	}
+	var err error
+	if from == "" {
+		_, err = catalog.Validate(to)
+	} else {
+		err = catalog.ValidateUpgrade(from, to)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
 	return nil
 }
+
+// runningVersion returns the Kubernetes version the nodes of cluster run
+func runningVersion(cluster *v1alpha1.Cluster) string {
+	if cluster.Status.KubernetesVersion != "" {
+		return cluster.Status.KubernetesVersion
+	}
+	return cluster.Spec.KubernetesVersion
+}
+
+// ready returns whether status shows cluster running with the nodes of its
+// spec, all of them ready
+func ready(cluster *v1alpha1.Cluster, status *v1alpha1.ClusterStatus) bool {
+	return status != nil && status.Phase == v1alpha1.ClusterPhaseRunning && status.ControlPlaneReady &&
+		status.WorkersReady == cluster.Spec.Workers.Count
+}
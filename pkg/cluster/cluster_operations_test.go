@@ -2,28 +2,327 @@ package cluster
 
 import (
 	"context"
+	"errors"
+	"testing"
+	"time"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
-	"testing"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
 )
 
-// TestCreateCluster tests the CreateCluster function.
-func TestCreateCluster(t *testing.T) {
-	cluster := &v1alpha1.Cluster{
+func newTestCluster() *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{
 		Spec: v1alpha1.ClusterSpec{
 			KubernetesVersion: v1alpha1.TestKubernetesVersion,
 			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
 			Workers:           v1alpha1.WorkerConfig{Count: 1},
 		},
 	}
+}
+
+// TestCreateCluster tests creating a cluster and observing it.
+func TestCreateCluster(t *testing.T) {
+	created := 0
+	provider := &providers.MockProvider{
+		CreateClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			created++
+			if created > 1 {
+				return providers.ErrClusterExists
+			}
+			return nil
+		},
+	}
+	ops := NewOperations(provider, WithVersionCatalog(versions.Builtin()))
+	cluster := newTestCluster()
 
-	// Call CreateCluster
-	err := CreateCluster(context.Background(), cluster)
+	// Call Create twice; the second finds the cluster there
+	assert.NoError(t, ops.Create(context.Background(), cluster))
+	assert.NoError(t, ops.Create(context.Background(), cluster))
+	assert.Equal(t, 2, created)
 
-	// Assert no error
+	// Assert the cluster is reported ready
+	status, err := ops.Status(context.Background(), cluster)
 	assert.NoError(t, err)
-	// Assert cluster status is updated
-	assert.Equal(t, v1alpha1.ClusterPhaseRunning, cluster.Status.Phase)
-	assert.True(t, cluster.Status.ControlPlaneReady)
-	assert.Equal(t, int32(1), cluster.Status.WorkersReady)
+	assert.Equal(t, v1alpha1.ClusterPhaseRunning, status.Phase)
+	assert.True(t, status.ControlPlaneReady)
+	assert.Equal(t, int32(1), status.WorkersReady)
+}
+
+func TestCreateExistingCluster(t *testing.T) {
+	var updates []v1alpha1.ClusterSpec
+	provider := &providers.MockProvider{
+		CreateClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			return providers.ErrClusterExists
+		},
+		UpdateClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			updates = append(updates, cluster.Spec)
+			return nil
+		},
+	}
+	ops := NewOperations(provider)
+	cluster := newTestCluster()
+	cluster.Spec.Workers.Count = 2
+
+	// A cluster with the nodes of its spec is left alone
+	assert.NoError(t, ops.Create(context.Background(), cluster))
+	assert.Empty(t, updates)
+
+	// A half-created cluster is updated to its spec
+	provider.GetClusterStatusFunc = func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+		return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhasePending, ControlPlaneReady: true, WorkersReady: 1}, nil
+	}
+	assert.NoError(t, ops.Create(context.Background(), cluster))
+	if assert.Len(t, updates, 1) {
+		assert.Equal(t, int32(2), updates[0].Workers.Count)
+	}
+
+	// A cluster whose nodes cannot be observed is not reported as created
+	provider.GetClusterStatusFunc = func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+		return nil, errors.New("docker unavailable")
+	}
+	assert.ErrorContains(t, ops.Create(context.Background(), cluster), "docker unavailable")
+	assert.Len(t, updates, 1)
+}
+
+func TestCreateClusterValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cluster *v1alpha1.Cluster)
+	}{
+		{name: "no version", modify: func(c *v1alpha1.Cluster) { c.Spec.KubernetesVersion = "" }},
+		{name: "unknown version", modify: func(c *v1alpha1.Cluster) { c.Spec.KubernetesVersion = "v1.12.0" }},
+		{name: "even control plane", modify: func(c *v1alpha1.Cluster) { c.Spec.ControlPlane.Count = 2 }},
+		{name: "no control plane", modify: func(c *v1alpha1.Cluster) { c.Spec.ControlPlane.Count = 0 }},
+		{name: "negative workers", modify: func(c *v1alpha1.Cluster) { c.Spec.Workers.Count = -1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &providers.MockProvider{
+				CreateClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+					t.Error("Expected the provider not to be called")
+					return nil
+				},
+			}
+			cluster := newTestCluster()
+			tt.modify(cluster)
+			err := NewOperations(provider, WithVersionCatalog(versions.Builtin())).Create(context.Background(), cluster)
+			assert.ErrorIs(t, err, ErrInvalidSpec)
+		})
+	}
+
+	err := NewOperations(&providers.MockProvider{}).Create(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrInvalidSpec)
+}
+
+func TestDeleteCluster(t *testing.T) {
+	deleted := 0
+	provider := &providers.MockProvider{
+		DeleteClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			deleted++
+			if deleted > 1 {
+				return providers.ErrClusterNotFound
+			}
+			return nil
+		},
+	}
+	ops := NewOperations(provider)
+	cluster := newTestCluster()
+
+	// Deleting a cluster that is gone succeeds
+	assert.NoError(t, ops.Delete(context.Background(), cluster))
+	assert.NoError(t, ops.Delete(context.Background(), cluster))
+	assert.Equal(t, 2, deleted)
+
+	provider.DeleteClusterFunc = func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+		return errors.New("docker unavailable")
+	}
+	assert.EqualError(t, ops.Delete(context.Background(), cluster), "docker unavailable")
+}
+
+func TestUpdateCluster(t *testing.T) {
+	var updates []v1alpha1.ClusterSpec
+	provider := &providers.MockProvider{
+		UpdateClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			updates = append(updates, cluster.Spec)
+			return nil
+		},
+	}
+	ops := NewOperations(provider, WithVersionCatalog(versions.Builtin()))
+	cluster := newTestCluster()
+	cluster.Spec.KubernetesVersion = "v1.30.4"
+	cluster.Status.KubernetesVersion = "v1.30.4"
+
+	// Scaling and upgrading are passed on to the provider
+	cluster.Spec.ControlPlane.Count = 3
+	cluster.Spec.Workers.Count = 4
+	cluster.Spec.KubernetesVersion = "v1.31.0"
+	assert.NoError(t, ops.Update(context.Background(), cluster))
+	if assert.Len(t, updates, 1) {
+		assert.Equal(t, int32(3), updates[0].ControlPlane.Count)
+		assert.Equal(t, int32(4), updates[0].Workers.Count)
+		assert.Equal(t, "v1.31.0", updates[0].KubernetesVersion)
+	}
+
+	// Invalid sizes, downgrades and unknown versions are rejected before the
+	// provider is called
+	tests := []struct {
+		name   string
+		modify func(cluster *v1alpha1.Cluster)
+	}{
+		{name: "even control plane", modify: func(c *v1alpha1.Cluster) { c.Spec.ControlPlane.Count = 2 }},
+		{name: "negative workers", modify: func(c *v1alpha1.Cluster) { c.Spec.Workers.Count = -1 }},
+		{name: "downgrade", modify: func(c *v1alpha1.Cluster) {
+			c.Status.KubernetesVersion = "v1.31.0"
+			c.Spec.KubernetesVersion = "v1.30.4"
+		}},
+		{name: "unknown version", modify: func(c *v1alpha1.Cluster) { c.Spec.KubernetesVersion = "v1.32.99" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			cluster.Spec.KubernetesVersion = "v1.30.4"
+			cluster.Status.KubernetesVersion = "v1.30.4"
+			tt.modify(cluster)
+			assert.ErrorIs(t, ops.Update(context.Background(), cluster), ErrInvalidSpec)
+		})
+	}
+	assert.Len(t, updates, 1)
+
+	// The version a cluster already runs is not checked again
+	cluster = newTestCluster()
+	cluster.Spec.KubernetesVersion = "v1.12.0"
+	cluster.Status.KubernetesVersion = "v1.12.0"
+	assert.NoError(t, ops.Update(context.Background(), cluster))
+	assert.Len(t, updates, 2)
+
+	provider.UpdateClusterFunc = func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+		return providers.ErrClusterNotFound
+	}
+	assert.ErrorIs(t, ops.Update(context.Background(), cluster), providers.ErrClusterNotFound)
+}
+
+func TestScaleCluster(t *testing.T) {
+	var updates []v1alpha1.ClusterSpec
+	provider := &providers.MockProvider{
+		UpdateClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			updates = append(updates, cluster.Spec)
+			return nil
+		},
+	}
+	ops := NewOperations(provider)
+	cluster := newTestCluster()
+
+	assert.NoError(t, ops.Scale(context.Background(), cluster, 3, 4))
+	assert.Equal(t, int32(3), cluster.Spec.ControlPlane.Count)
+	assert.Equal(t, int32(4), cluster.Spec.Workers.Count)
+	if assert.Len(t, updates, 1) {
+		assert.Equal(t, int32(3), updates[0].ControlPlane.Count)
+		assert.Equal(t, int32(4), updates[0].Workers.Count)
+	}
+
+	// Scaling to the size the cluster has and runs changes nothing
+	assert.NoError(t, ops.Scale(context.Background(), cluster, 3, 4))
+	assert.Len(t, updates, 1)
+
+	// Scaling to the size in the spec rolls out nodes that are missing
+	provider.GetClusterStatusFunc = func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+		return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseRunning, ControlPlaneReady: true, WorkersReady: 2}, nil
+	}
+	assert.NoError(t, ops.Scale(context.Background(), cluster, 3, 4))
+	assert.Len(t, updates, 2)
+
+	// Invalid sizes are rejected before the provider is called
+	assert.ErrorIs(t, ops.Scale(context.Background(), cluster, 2, 4), ErrInvalidSpec)
+	assert.ErrorIs(t, ops.Scale(context.Background(), cluster, 1, -1), ErrInvalidSpec)
+	assert.Len(t, updates, 2)
+	assert.Equal(t, int32(3), cluster.Spec.ControlPlane.Count)
+
+	// A failed update leaves the spec as it was
+	provider.UpdateClusterFunc = func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+		return providers.ErrClusterNotFound
+	}
+	assert.ErrorIs(t, ops.Scale(context.Background(), cluster, 1, 1), providers.ErrClusterNotFound)
+	assert.Equal(t, int32(3), cluster.Spec.ControlPlane.Count)
+}
+
+func TestUpgradeCluster(t *testing.T) {
+	var upgrades []string
+	provider := &providers.MockProvider{
+		UpdateClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			upgrades = append(upgrades, cluster.Spec.KubernetesVersion)
+			return nil
+		},
+	}
+	ops := NewOperations(provider, WithVersionCatalog(versions.Builtin()))
+	cluster := newTestCluster()
+	cluster.Spec.KubernetesVersion = "v1.30.4"
+	cluster.Status.KubernetesVersion = "v1.30.4"
+
+	assert.NoError(t, ops.Upgrade(context.Background(), cluster, "v1.31.0"))
+	assert.Equal(t, "v1.31.0", cluster.Spec.KubernetesVersion)
+	assert.Equal(t, []string{"v1.31.0"}, upgrades)
+
+	// Upgrading to the running version changes nothing
+	assert.NoError(t, ops.Upgrade(context.Background(), cluster, "v1.31.0"))
+	assert.Len(t, upgrades, 1)
+
+	// Downgrades and unknown versions are rejected
+	assert.ErrorIs(t, ops.Upgrade(context.Background(), cluster, "v1.30.4"), ErrInvalidSpec)
+	assert.ErrorIs(t, ops.Upgrade(context.Background(), cluster, "v1.32.99"), ErrInvalidSpec)
+	assert.Len(t, upgrades, 1)
+}
+
+func TestWaitForReady(t *testing.T) {
+	cluster := newTestCluster()
+	cluster.Name = "wait"
+
+	t.Run("becomes ready", func(t *testing.T) {
+		polls := 0
+		provider := &providers.MockProvider{
+			GetClusterStatusFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+				polls++
+				if polls < 3 {
+					return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseProvisioning}, nil
+				}
+				return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseRunning, ControlPlaneReady: true, WorkersReady: 1}, nil
+			},
+		}
+		status, err := NewOperations(provider, WithPollInterval(time.Millisecond)).WaitForReady(context.Background(), cluster, time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, v1alpha1.ClusterPhaseRunning, status.Phase)
+		assert.Equal(t, 3, polls)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		provider := &providers.MockProvider{
+			GetClusterStatusFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+				return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseFailed, Message: "no image"}, nil
+			},
+		}
+		_, err := NewOperations(provider).WaitForReady(context.Background(), cluster, time.Second)
+		assert.EqualError(t, err, "cluster wait failed: no image")
+	})
+
+	t.Run("gone", func(t *testing.T) {
+		provider := &providers.MockProvider{
+			GetClusterStatusFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+				return nil, providers.ErrClusterNotFound
+			},
+		}
+		_, err := NewOperations(provider).WaitForReady(context.Background(), cluster, time.Second)
+		assert.ErrorIs(t, err, providers.ErrClusterNotFound)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		provider := &providers.MockProvider{
+			GetClusterStatusFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+				return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseProvisioning}, nil
+			},
+		}
+		status, err := NewOperations(provider, WithPollInterval(10*time.Millisecond)).WaitForReady(context.Background(), cluster, 50*time.Millisecond)
+		assert.EqualError(t, err, "timed out after 50ms waiting for cluster wait to be ready, it is Provisioning")
+		assert.Equal(t, v1alpha1.ClusterPhaseProvisioning, status.Phase)
+	})
 }
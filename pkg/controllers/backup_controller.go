@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+// clusterWaitInterval is how often backups, restores and clones check on a
+// cluster that is not Running yet
+const clusterWaitInterval = 30 * time.Second
+
+// ClusterBackupReconciler takes the etcd snapshots ClusterBackups ask for and
+// deletes the ones that fall out of their retention
+type ClusterBackupReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Provider providers.Provider
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=cluster.mini-k8s.io,resources=clusterbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cluster.mini-k8s.io,resources=clusterbackups/status,verbs=get;update;patch
+
+func (r *ClusterBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "ClusterBackupReconciler.Reconcile",
+		tracing.ClusterNamespaceKey.String(req.Namespace),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	log := log.FromContext(ctx)
+	if r.Recorder != nil {
+		ctx = providers.WithEventRecorder(ctx, r.Recorder)
+	}
+
+	var backup clusterv1alpha1.ClusterBackup
+	if err := r.Get(ctx, req.NamespacedName, &backup); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// An unscheduled backup takes a single snapshot
+	interval := backup.Spec.Interval
+	if interval == nil && backup.Status.Phase == clusterv1alpha1.ClusterBackupPhaseCompleted {
+		return ctrl.Result{}, nil
+	}
+	if interval != nil && backup.Status.LastBackupTime != nil {
+		if wait := time.Until(backup.Status.LastBackupTime.Add(interval.Duration)); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	backupper, ok := r.Provider.(providers.EtcdBackupper)
+	if !ok {
+		log.Info("Provider cannot back up etcd")
+		r.event(&backup, corev1.EventTypeWarning, ReasonBackupFailed, "The provider cannot back up etcd")
+		backup.Status.Phase = clusterv1alpha1.ClusterBackupPhaseFailed
+		backup.Status.Message = "The provider cannot back up etcd"
+		return ctrl.Result{}, r.Status().Update(ctx, &backup)
+	}
+
+	cluster, err := runningCluster(ctx, r.Client, backup.Namespace, backup.Spec.ClusterName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cluster == nil {
+		message := fmt.Sprintf("Waiting for cluster %s to be Running", backup.Spec.ClusterName)
+		if backup.Status.Message != message {
+			backup.Status.Message = message
+			if err := r.Status().Update(ctx, &backup); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: clusterWaitInterval}, nil
+	}
+
+	// Take the snapshot. Failures are retried with the controller's backoff.
+	now := metav1.Now()
+	name, err := backupper.BackupEtcd(ctx, cluster, backup.Spec.Location)
+	if err != nil {
+		log.Error(err, "Failed to back up etcd", "cluster", cluster.Name)
+		r.event(&backup, corev1.EventTypeWarning, ReasonBackupFailed, "Failed to back up etcd of cluster %s: %v", cluster.Name, err)
+		backup.Status.Phase = clusterv1alpha1.ClusterBackupPhaseFailed
+		backup.Status.Message = fmt.Sprintf("Failed to back up etcd: %v", err)
+		if updateErr := r.Status().Update(ctx, &backup); updateErr != nil {
+			log.Error(updateErr, "Failed to update status")
+		}
+		return ctrl.Result{}, err
+	}
+	r.event(&backup, corev1.EventTypeNormal, ReasonBackedUp, "Saved etcd snapshot %s of cluster %s to %s", name, cluster.Name, backup.Spec.Location)
+	backup.Status.Snapshots = append(backup.Status.Snapshots, clusterv1alpha1.BackupSnapshot{
+		Name:              name,
+		Location:          backup.Spec.Location,
+		KubernetesVersion: cluster.Status.KubernetesVersion,
+		Time:              now,
+	})
+	backup.Status.LastBackupTime = &now
+	backup.Status.Message = ""
+
+	if interval == nil {
+		backup.Status.Phase = clusterv1alpha1.ClusterBackupPhaseCompleted
+		return ctrl.Result{}, r.Status().Update(ctx, &backup)
+	}
+
+	r.pruneSnapshots(ctx, &backup, cluster, backupper)
+	next := metav1.NewTime(now.Add(interval.Duration))
+	backup.Status.NextBackupTime = &next
+	backup.Status.Phase = clusterv1alpha1.ClusterBackupPhaseScheduled
+	if err := r.Status().Update(ctx, &backup); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval.Duration}, nil
+}
+
+// pruneSnapshots deletes the oldest snapshots of a scheduled backup until no
+// more than its retention are left. A snapshot that cannot be deleted stays
+// in the status and is tried again after the next snapshot.
+func (r *ClusterBackupReconciler) pruneSnapshots(ctx context.Context, backup *clusterv1alpha1.ClusterBackup,
+	cluster *clusterv1alpha1.Cluster, backupper providers.EtcdBackupper) {
+	retention := int(backup.Spec.Retention)
+	if retention <= 0 {
+		retention = clusterv1alpha1.DefaultBackupRetention
+	}
+
+	for len(backup.Status.Snapshots) > retention {
+		oldest := backup.Status.Snapshots[0]
+		if err := backupper.DeleteEtcdBackup(ctx, cluster, oldest.Location, oldest.Name); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to delete etcd snapshot", "snapshot", oldest.Name)
+			r.event(backup, corev1.EventTypeWarning, ReasonSnapshotDeletionFailed, "Failed to delete etcd snapshot %s: %v", oldest.Name, err)
+			return
+		}
+		r.event(backup, corev1.EventTypeNormal, ReasonSnapshotDeleted, "Deleted etcd snapshot %s, keeping %d", oldest.Name, retention)
+		backup.Status.Snapshots = backup.Status.Snapshots[1:]
+	}
+}
+
+// event emits an event about the backup if the reconciler has a recorder
+func (r *ClusterBackupReconciler) event(backup *clusterv1alpha1.ClusterBackup, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(backup, eventType, reason, messageFmt, args...)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&clusterv1alpha1.ClusterBackup{}).
+		Complete(r)
+}
+
+// runningCluster returns the named cluster if it is Running, and nil
+// if it does not exist or is in another phase
+func runningCluster(ctx context.Context, c client.Client, namespace, name string) (*clusterv1alpha1.Cluster, error) {
+	var cluster clusterv1alpha1.Cluster
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &cluster); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if cluster.Status.Phase != clusterv1alpha1.ClusterPhaseRunning || !cluster.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return &cluster, nil
+}
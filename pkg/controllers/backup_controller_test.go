@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newBackupTestClient returns a fake client holding a Running cluster named dev
+func newBackupTestClient(t *testing.T) client.Client {
+	t.Helper()
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.28.13",
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+		},
+		Status: v1alpha1.ClusterStatus{
+			Phase:             v1alpha1.ClusterPhaseRunning,
+			KubernetesVersion: "v1.28.13",
+		},
+	}
+	return fake.NewClientBuilder().WithScheme(s).
+		WithStatusSubresource(&v1alpha1.Cluster{}, &v1alpha1.ClusterBackup{}, &v1alpha1.ClusterRestore{}).
+		WithObjects(cluster).
+		Build()
+}
+
+func TestClusterBackup(t *testing.T) {
+	client := newBackupTestClient(t)
+
+	var taken []v1alpha1.BackupLocation
+	mockProvider := &providers.MockProvider{
+		BackupEtcdFunc: func(ctx context.Context, c *v1alpha1.Cluster, location v1alpha1.BackupLocation) (string, error) {
+			taken = append(taken, location)
+			return "etcd-1.db", nil
+		},
+	}
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterBackupReconciler{
+		Client:   client,
+		Scheme:   client.Scheme(),
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	backup := &v1alpha1.ClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-once", Namespace: "default"},
+		Spec: v1alpha1.ClusterBackupSpec{
+			ClusterName: "dev",
+			Location:    v1alpha1.BackupLocation{Volume: "dev-backups"},
+		},
+	}
+	if err := client.Create(context.Background(), backup); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: backup.Name, Namespace: backup.Namespace}}
+	for i := 0; i < 2; i++ {
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile backup: %v", err)
+		}
+	}
+
+	if len(taken) != 1 || taken[0].Volume != "dev-backups" {
+		t.Fatalf("Expected a single snapshot in volume dev-backups, got %v", taken)
+	}
+	if err := client.Get(context.Background(), req.NamespacedName, backup); err != nil {
+		t.Fatalf("Failed to get backup: %v", err)
+	}
+	if backup.Status.Phase != v1alpha1.ClusterBackupPhaseCompleted {
+		t.Errorf("Expected phase Completed, got %s", backup.Status.Phase)
+	}
+	if len(backup.Status.Snapshots) != 1 || backup.Status.Snapshots[0].Name != "etcd-1.db" ||
+		backup.Status.Snapshots[0].KubernetesVersion != "v1.28.13" {
+		t.Errorf("Expected snapshot etcd-1.db of v1.28.13 to be recorded, got %+v", backup.Status.Snapshots)
+	}
+	expectEvents(t, recorder, ReasonBackedUp)
+}
+
+func TestScheduledBackupRetention(t *testing.T) {
+	client := newBackupTestClient(t)
+
+	count := 0
+	var deleted []string
+	mockProvider := &providers.MockProvider{
+		BackupEtcdFunc: func(ctx context.Context, c *v1alpha1.Cluster, location v1alpha1.BackupLocation) (string, error) {
+			count++
+			return fmt.Sprintf("etcd-%d.db", count), nil
+		},
+		DeleteEtcdBackupFunc: func(ctx context.Context, c *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error {
+			deleted = append(deleted, name)
+			return nil
+		},
+	}
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterBackupReconciler{
+		Client:   client,
+		Scheme:   client.Scheme(),
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	backup := &v1alpha1.ClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-daily", Namespace: "default"},
+		Spec: v1alpha1.ClusterBackupSpec{
+			ClusterName: "dev",
+			Interval:    &metav1.Duration{Duration: time.Hour},
+			Retention:   2,
+		},
+	}
+	if err := client.Create(context.Background(), backup); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: backup.Name, Namespace: backup.Namespace}}
+
+	for i := 0; i < 3; i++ {
+		result, err := reconciler.Reconcile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed to reconcile backup: %v", err)
+		}
+		if result.RequeueAfter != time.Hour {
+			t.Errorf("Expected the next backup in an hour, got %v", result.RequeueAfter)
+		}
+
+		// A backup that is not due yet takes no snapshot
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile backup: %v", err)
+		}
+		if count != i+1 {
+			t.Fatalf("Expected %d snapshots, got %d", i+1, count)
+		}
+
+		// Make the next backup due
+		if err := client.Get(context.Background(), req.NamespacedName, backup); err != nil {
+			t.Fatalf("Failed to get backup: %v", err)
+		}
+		earlier := metav1.NewTime(backup.Status.LastBackupTime.Add(-time.Hour))
+		backup.Status.LastBackupTime = &earlier
+		if err := client.Status().Update(context.Background(), backup); err != nil {
+			t.Fatalf("Failed to update backup: %v", err)
+		}
+	}
+
+	if backup.Status.Phase != v1alpha1.ClusterBackupPhaseScheduled || backup.Status.NextBackupTime == nil {
+		t.Errorf("Expected a scheduled backup with a next backup time, got phase %s", backup.Status.Phase)
+	}
+	if len(deleted) != 1 || deleted[0] != "etcd-1.db" {
+		t.Errorf("Expected the oldest snapshot to be deleted, got %v", deleted)
+	}
+	if len(backup.Status.Snapshots) != 2 || backup.Status.Snapshots[0].Name != "etcd-2.db" {
+		t.Errorf("Expected the two newest snapshots to be kept, got %+v", backup.Status.Snapshots)
+	}
+	expectEvents(t, recorder, ReasonBackedUp, ReasonSnapshotDeleted)
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/metrics"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// handleClonePending takes the spec of a clone from its source cluster once
+// the source is running, and moves the clone on to be provisioned
+func (r *ClusterReconciler) handleClonePending(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	sourceName := cluster.Spec.CloneFrom.Name
+
+	if _, ok := r.Provider.(providers.Cloner); !ok {
+		return r.failClone(ctx, cluster, fmt.Errorf("provider does not support cloning clusters"))
+	}
+	source, err := runningCluster(ctx, r.Client, cluster.Namespace, sourceName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if source == nil {
+		message := fmt.Sprintf("Waiting for source cluster %s to be running", sourceName)
+		if cluster.Status.Message != message {
+			cluster.Status.Message = message
+			if err := r.Status().Update(ctx, cluster); err != nil {
+				log.Error(err, "Failed to update status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: clusterWaitInterval}, nil
+	}
+
+	// Record the source's spec; the clone is provisioned from it
+	status := cluster.Status
+	var spec clusterv1alpha1.ClusterSpec
+	source.Spec.DeepCopyInto(&spec)
+	spec.CloneFrom = cluster.Spec.CloneFrom
+	spec.Suspended = cluster.Spec.Suspended
+	cluster.Spec = spec
+	if err := r.Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update clone spec")
+		return ctrl.Result{}, err
+	}
+
+	cluster.Status = status
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseProvisioning
+	cluster.Status.Message = ""
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update status to Provisioning")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// handleCloning provisions a clone by copying the nodes and state of its
+// source cluster
+func (r *ClusterReconciler) handleCloning(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	sourceName := cluster.Spec.CloneFrom.Name
+
+	cloner, ok := r.Provider.(providers.Cloner)
+	if !ok {
+		return r.failClone(ctx, cluster, fmt.Errorf("provider does not support cloning clusters"))
+	}
+	source, err := runningCluster(ctx, r.Client, cluster.Namespace, sourceName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if source == nil {
+		return r.failClone(ctx, cluster, fmt.Errorf("source cluster %s is not running", sourceName))
+	}
+
+	r.event(cluster, corev1.EventTypeNormal, ReasonCloning, "Cloning cluster %s", sourceName)
+	start := time.Now()
+	if err := cloner.CloneCluster(ctx, cluster, source); err != nil {
+		log.Error(err, "Failed to clone cluster", "source", sourceName)
+		return r.failClone(ctx, cluster, err)
+	}
+
+	metrics.ProvisioningDuration.Observe(time.Since(start).Seconds())
+	r.event(cluster, corev1.EventTypeNormal, ReasonCloned, "Cloned cluster %s", sourceName)
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseRunning
+	cluster.Status.ObservedGeneration = cluster.Generation
+	cluster.Status.KubernetesVersion = cluster.Spec.KubernetesVersion
+	cluster.Status.Message = fmt.Sprintf("Cloned from cluster %s", sourceName)
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update status to Running")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *ClusterReconciler) failClone(ctx context.Context, cluster *clusterv1alpha1.Cluster, err error) (ctrl.Result, error) {
+	r.event(cluster, corev1.EventTypeWarning, ReasonCloneFailed, "Failed to clone cluster %s: %v", cluster.Spec.CloneFrom.Name, err)
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseFailed
+	cluster.Status.Message = fmt.Sprintf("Failed to clone cluster %s: %v", cluster.Spec.CloneFrom.Name, err)
+	if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+		log.FromContext(ctx).Error(updateErr, "Failed to update status")
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{}, err
+}
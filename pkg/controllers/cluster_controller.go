@@ -3,14 +3,24 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/metrics"
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
 )
 
 // ClusterReconciler reconciles a Cluster object
@@ -18,16 +28,39 @@ type ClusterReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Provider providers.Provider
+	Recorder record.EventRecorder
+
+	// Versions is the catalog Kubernetes upgrades are checked against.
+	// Upgrades are not checked if it is nil.
+	Versions *versions.Catalog
+
+	// APIReader reads Secrets from the API server rather than the cache, so
+	// the manager neither lists nor watches Secrets. The client is used if
+	// it is nil.
+	APIReader client.Reader
 }
 
 // +kubebuilder:rbac:groups=cluster.mini-k8s.io,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cluster.mini-k8s.io,resources=clusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.mini-k8s.io,resources=clusters/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update
+
+func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "ClusterReconciler.Reconcile",
+		tracing.ClusterNamespaceKey.String(req.Namespace),
+		tracing.ClusterNameKey.String(req.Name),
+	)
+	defer func() { tracing.End(span, err) }()
 
-func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.Info("Reconciling Cluster", "name", req.Name, "namespace", req.Namespace)
 
+	// Let the provider report the steps it performs as events
+	if r.Recorder != nil {
+		ctx = providers.WithEventRecorder(ctx, r.Recorder)
+	}
+
 	// Get the Cluster resource
 	var cluster clusterv1alpha1.Cluster
 	if err := r.Get(ctx, req.NamespacedName, &cluster); err != nil {
@@ -39,6 +72,13 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, nil
 	}
 
+	// Record node image pull progress in the status
+	ctx = providers.WithImagePullReporter(ctx, r.imagePullReporter(ctx, &cluster))
+
+	// Report every phase transition made below
+	previousPhase := cluster.Status.Phase
+	defer func() { r.recordPhaseChange(&cluster, previousPhase) }()
+
 	// Initialize status if not set
 	if cluster.Status.Phase == "" {
 		cluster.Status.Phase = clusterv1alpha1.ClusterPhasePending
@@ -59,19 +99,28 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	// Handle deletion
 	if !cluster.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.handleDeletion(ctx, &cluster)
+		return r.tracePhase(ctx, &cluster, "handleDeletion", r.handleDeletion)
+	}
+
+	// Suspend the cluster before anything else is rolled out
+	if cluster.Spec.Suspended && suspendable(&cluster) {
+		return r.tracePhase(ctx, &cluster, "handleSuspension", r.handleSuspension)
 	}
 
 	// Handle cluster lifecycle based on current phase
 	switch cluster.Status.Phase {
 	case clusterv1alpha1.ClusterPhasePending:
-		return r.handlePendingPhase(ctx, &cluster)
+		return r.tracePhase(ctx, &cluster, "handlePendingPhase", r.handlePendingPhase)
 	case clusterv1alpha1.ClusterPhaseProvisioning:
-		return r.handleProvisioningPhase(ctx, &cluster)
+		return r.tracePhase(ctx, &cluster, "handleProvisioningPhase", r.handleProvisioningPhase)
 	case clusterv1alpha1.ClusterPhaseRunning:
-		return r.handleRunningPhase(ctx, &cluster)
+		return r.tracePhase(ctx, &cluster, "handleRunningPhase", r.handleRunningPhase)
+	case clusterv1alpha1.ClusterPhaseUpdating:
+		return r.tracePhase(ctx, &cluster, "handleUpdatingPhase", r.handleUpdatingPhase)
+	case clusterv1alpha1.ClusterPhaseSuspended:
+		return r.tracePhase(ctx, &cluster, "handleSuspendedPhase", r.handleSuspendedPhase)
 	case clusterv1alpha1.ClusterPhaseFailed:
-		return r.handleFailedPhase(ctx, &cluster)
+		return r.tracePhase(ctx, &cluster, "handleFailedPhase", r.handleFailedPhase)
 	default:
 		log.Info("Unknown cluster phase", "phase", cluster.Status.Phase)
 		return ctrl.Result{}, nil
@@ -86,10 +135,15 @@ func (r *ClusterReconciler) handleDeletion(ctx context.Context, cluster *cluster
 
 	if containsString(cluster.ObjectMeta.Finalizers, clusterFinalizer) {
 		// Delete the cluster using provider
-		if err := r.Provider.DeleteCluster(ctx, cluster); err != nil {
+		r.event(cluster, corev1.EventTypeNormal, ReasonDeleting, "Deleting cluster resources")
+		start := time.Now()
+		if err := r.operations().Delete(ctx, cluster); err != nil {
 			log.Error(err, "Failed to delete cluster")
+			r.event(cluster, corev1.EventTypeWarning, ReasonDeletionFailed, "Failed to delete cluster: %v", err)
 			return ctrl.Result{}, err
 		}
+		metrics.DeletionDuration.Observe(time.Since(start).Seconds())
+		r.event(cluster, corev1.EventTypeNormal, ReasonDeleted, "Deleted cluster resources")
 
 		// Remove finalizer
 		cluster.ObjectMeta.Finalizers = removeString(cluster.ObjectMeta.Finalizers, clusterFinalizer)
@@ -106,6 +160,16 @@ func (r *ClusterReconciler) handlePendingPhase(ctx context.Context, cluster *clu
 	log := log.FromContext(ctx)
 	log.Info("Handling pending phase", "name", cluster.Name)
 
+	// Existing clusters are adopted rather than provisioned
+	if source, ok := cluster.Annotations[clusterv1alpha1.AdoptAnnotation]; ok {
+		return r.handleAdoption(ctx, cluster, source)
+	}
+
+	// Clones take their spec from the source cluster
+	if cluster.Spec.CloneFrom != nil {
+		return r.handleClonePending(ctx, cluster)
+	}
+
 	// Update status to Provisioning
 	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseProvisioning
 	if err := r.Status().Update(ctx, cluster); err != nil {
@@ -116,13 +180,88 @@ func (r *ClusterReconciler) handlePendingPhase(ctx context.Context, cluster *clu
 	return ctrl.Result{Requeue: true}, nil
 }
 
+func (r *ClusterReconciler) handleAdoption(ctx context.Context, cluster *clusterv1alpha1.Cluster, source string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Adopting existing cluster", "name", cluster.Name, "source", source)
+
+	adopter, ok := r.Provider.(providers.Adopter)
+	if !ok {
+		return r.failAdoption(ctx, cluster, fmt.Errorf("provider does not support adopting existing clusters"))
+	}
+
+	spec, err := adopter.AdoptCluster(ctx, cluster, source)
+	if err != nil {
+		log.Error(err, "Failed to adopt cluster")
+		return r.failAdoption(ctx, cluster, err)
+	}
+
+	// Record the spec derived from the adopted nodes
+	status := cluster.Status
+	cluster.Spec = *spec
+	if err := r.Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update adopted cluster spec")
+		return ctrl.Result{}, err
+	}
+
+	// Adopted clusters are already running; reconcile them from here on
+	cluster.Status = status
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseRunning
+	cluster.Status.ObservedGeneration = cluster.Generation
+	cluster.Status.KubernetesVersion = cluster.Spec.KubernetesVersion
+	cluster.Status.Message = fmt.Sprintf("Adopted kind cluster %s", source)
+	r.event(cluster, corev1.EventTypeNormal, ReasonAdopted, "Adopted kind cluster %s", source)
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update status to Running")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+func (r *ClusterReconciler) failAdoption(ctx context.Context, cluster *clusterv1alpha1.Cluster, err error) (ctrl.Result, error) {
+	r.event(cluster, corev1.EventTypeWarning, ReasonAdoptionFailed, "Failed to adopt cluster: %v", err)
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseFailed
+	cluster.Status.Message = fmt.Sprintf("Failed to adopt cluster: %v", err)
+	if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+		log.FromContext(ctx).Error(updateErr, "Failed to update status")
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{}, err
+}
+
 func (r *ClusterReconciler) handleProvisioningPhase(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.Info("Handling provisioning phase", "name", cluster.Name)
 
+	ctx, err := r.withRegistryCredentials(ctx, cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Clones are copied from their source rather than created
+	if cluster.Spec.CloneFrom != nil {
+		return r.handleCloning(ctx, cluster)
+	}
+
 	// Create the cluster using provider
-	if err := r.Provider.CreateCluster(ctx, cluster); err != nil {
+	r.event(cluster, corev1.EventTypeNormal, ReasonProvisioning, "Provisioning %d control plane and %d worker nodes",
+		cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count)
+	start := time.Now()
+	if err := r.operations().Create(ctx, cluster); err != nil {
+		if providers.IsPartialFailure(err) {
+			// Keep the nodes that came up; the update phase creates the rest
+			log.Error(err, "Some worker nodes could not be created")
+			r.event(cluster, corev1.EventTypeWarning, ReasonProvisioningFailed, "Cluster partially provisioned: %v", err)
+			cluster.Status.Phase = clusterv1alpha1.ClusterPhaseUpdating
+			cluster.Status.Message = fmt.Sprintf("Cluster partially provisioned: %v", err)
+			if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+				log.Error(updateErr, "Failed to update status")
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, err
+		}
 		log.Error(err, "Failed to create cluster")
+		r.event(cluster, corev1.EventTypeWarning, ReasonProvisioningFailed, "Failed to create cluster: %v", err)
 		cluster.Status.Phase = clusterv1alpha1.ClusterPhaseFailed
 		cluster.Status.Message = fmt.Sprintf("Failed to create cluster: %v", err)
 		if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
@@ -133,7 +272,11 @@ func (r *ClusterReconciler) handleProvisioningPhase(ctx context.Context, cluster
 	}
 
 	// Update status to Running
+	metrics.ProvisioningDuration.Observe(time.Since(start).Seconds())
+	r.event(cluster, corev1.EventTypeNormal, ReasonProvisioned, "Cluster provisioned")
 	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseRunning
+	cluster.Status.ObservedGeneration = cluster.Generation
+	cluster.Status.KubernetesVersion = cluster.Spec.KubernetesVersion
 	if err := r.Status().Update(ctx, cluster); err != nil {
 		log.Error(err, "Failed to update status to Running")
 		return ctrl.Result{}, err
@@ -146,21 +289,84 @@ func (r *ClusterReconciler) handleRunningPhase(ctx context.Context, cluster *clu
 	log := log.FromContext(ctx)
 	log.Info("Handling running phase", "name", cluster.Name)
 
+	// Bring resources created by earlier releases under the current labels
+	if migrator, ok := r.Provider.(providers.Migrator); ok {
+		if err := migrator.MigrateCluster(ctx, cluster); err != nil {
+			log.Error(err, "Failed to migrate legacy cluster resources")
+			r.event(cluster, corev1.EventTypeWarning, ReasonMigrationFailed, "Failed to migrate legacy cluster resources: %v", err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Roll out spec changes that have not been applied yet
+	if rolloutPending(cluster) {
+		return r.startRollout(ctx, cluster)
+	}
+
 	// Get cluster status from provider
-	status, err := r.Provider.GetClusterStatus(ctx, cluster)
+	status, err := r.operations().Status(ctx, cluster)
 	if err != nil {
 		log.Error(err, "Failed to get cluster status")
+		r.event(cluster, corev1.EventTypeWarning, ReasonStatusCheckFailed, "Failed to get cluster status: %v", err)
 		return ctrl.Result{}, err
 	}
 
-	// Update cluster status
-	cluster.Status = *status
+	// Update cluster status with what the provider observes; the rest of
+	// the status is the controller's own record
+	cluster.Status.Phase = status.Phase
+	cluster.Status.Message = status.Message
+	for _, condition := range status.Conditions {
+		meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+	}
+	cluster.Status.ControlPlaneReady = status.ControlPlaneReady
+	cluster.Status.WorkersReady = status.WorkersReady
+	meta.SetStatusCondition(&cluster.Status.Conditions, readyCondition(cluster))
+	r.publishKubeconfig(ctx, cluster)
+	r.remediateUnhealthyNodes(ctx, cluster)
 	if err := r.Status().Update(ctx, cluster); err != nil {
 		log.Error(err, "Failed to update cluster status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{RequeueAfter: 30}, nil
+	return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+}
+
+// operations returns the operations on clusters through the reconciler's provider
+func (r *ClusterReconciler) operations() *clusterops.Operations {
+	return clusterops.NewOperations(r.Provider, clusterops.WithVersionCatalog(r.Versions))
+}
+
+// readyCondition returns the Ready condition of a running cluster from what
+// its provider observes
+func readyCondition(cluster *clusterv1alpha1.Cluster) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               clusterv1alpha1.ClusterConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NodesReady",
+		Message:            "All nodes are ready",
+		ObservedGeneration: cluster.Generation,
+	}
+	switch {
+	case cluster.Status.Phase != clusterv1alpha1.ClusterPhaseRunning:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = string(cluster.Status.Phase)
+		condition.Message = cluster.Status.Message
+	case !cluster.Status.ControlPlaneReady:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ControlPlaneNotReady"
+		condition.Message = "The control plane is not ready"
+	case cluster.Status.WorkersReady < cluster.Spec.Workers.Count:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "WorkersNotReady"
+		condition.Message = fmt.Sprintf("%d of %d workers are ready", cluster.Status.WorkersReady, cluster.Spec.Workers.Count)
+	}
+	if condition.Status == metav1.ConditionFalse && cluster.Status.Message != "" {
+		condition.Message = cluster.Status.Message
+	}
+	if condition.Reason == "" {
+		condition.Reason = "Unknown"
+	}
+	return condition
 }
 
 func (r *ClusterReconciler) handleFailedPhase(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
@@ -168,10 +374,109 @@ func (r *ClusterReconciler) handleFailedPhase(ctx context.Context, cluster *clus
 	log.Info("Handling failed phase", "name", cluster.Name)
 
 	// For now, just requeue after some time
-	return ctrl.Result{RequeueAfter: 300}, nil
+	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+// withRegistryCredentials adds the credentials from the cluster's node image
+// pull Secret, if it has one, to the context passed to the provider
+func (r *ClusterReconciler) withRegistryCredentials(ctx context.Context, cluster *clusterv1alpha1.Cluster) (context.Context, error) {
+	if cluster.Spec.NodeImage == nil || cluster.Spec.NodeImage.PullSecretRef == nil {
+		return ctx, nil
+	}
+	name := cluster.Spec.NodeImage.PullSecretRef.Name
+
+	var secret corev1.Secret
+	if err := r.secretReader().Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: name}, &secret); err != nil {
+		r.event(cluster, corev1.EventTypeWarning, ReasonPullSecretFailed, "Failed to get pull Secret %s: %v", name, err)
+		return ctx, fmt.Errorf("failed to get pull secret %s: %w", name, err)
+	}
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		r.event(cluster, corev1.EventTypeWarning, ReasonPullSecretFailed, "Pull Secret %s has no %s key", name, corev1.DockerConfigJsonKey)
+		return ctx, fmt.Errorf("pull secret %s has no %s key", name, corev1.DockerConfigJsonKey)
+	}
+	credentials, err := providers.ParseDockerConfigJSON(data)
+	if err != nil {
+		r.event(cluster, corev1.EventTypeWarning, ReasonPullSecretFailed, "Invalid pull Secret %s: %v", name, err)
+		return ctx, fmt.Errorf("invalid pull secret %s: %w", name, err)
+	}
+	return providers.WithRegistryCredentials(ctx, credentials), nil
+}
+
+// secretReader returns the reader Secrets are read through
+func (r *ClusterReconciler) secretReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// imagePullStatusInterval is how often image pull progress is written to the
+// status while a pull is running
+const imagePullStatusInterval = 5 * time.Second
+
+// imagePullReporter returns a reporter that records image pull progress in the
+// status of cluster. Progress updates are rate limited; the start and end of a
+// pull are always recorded.
+func (r *ClusterReconciler) imagePullReporter(ctx context.Context, cluster *clusterv1alpha1.Cluster) providers.ImagePullReporter {
+	var (
+		mu         sync.Mutex
+		lastUpdate time.Time
+	)
+	return func(progress clusterv1alpha1.ImagePullStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		previous := cluster.Status.ImagePull
+		if progress.State == clusterv1alpha1.ImagePullStatePulling && previous != nil &&
+			previous.State == clusterv1alpha1.ImagePullStatePulling && time.Since(lastUpdate) < imagePullStatusInterval {
+			return
+		}
+		lastUpdate = time.Now()
+
+		patch := client.MergeFrom(cluster.DeepCopy())
+		cluster.Status.ImagePull = &progress
+		if err := r.Status().Patch(ctx, cluster, patch); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to record image pull progress", "image", progress.Image)
+		}
+	}
+}
+
+// tracePhase runs a phase handler in its own span below the reconcile span
+func (r *ClusterReconciler) tracePhase(ctx context.Context, cluster *clusterv1alpha1.Cluster, name string,
+	handler func(context.Context, *clusterv1alpha1.Cluster) (ctrl.Result, error)) (ctrl.Result, error) {
+	ctx, span := tracing.Start(ctx, "ClusterReconciler."+name, tracing.PhaseKey.String(string(cluster.Status.Phase)))
+	result, err := handler(ctx, cluster)
+	tracing.End(span, err)
+	return result, err
+}
+
+// event emits an event about the cluster if the reconciler has a recorder
+func (r *ClusterReconciler) event(cluster *clusterv1alpha1.Cluster, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(cluster, eventType, reason, messageFmt, args...)
+}
+
+// recordPhaseChange emits an event if the cluster moved to a different phase
+func (r *ClusterReconciler) recordPhaseChange(cluster *clusterv1alpha1.Cluster, previous clusterv1alpha1.ClusterPhase) {
+	if cluster.Status.Phase == previous {
+		return
+	}
+	eventType := corev1.EventTypeNormal
+	if cluster.Status.Phase == clusterv1alpha1.ClusterPhaseFailed {
+		eventType = corev1.EventTypeWarning
+	}
+	if previous == "" {
+		r.event(cluster, eventType, ReasonPhaseChanged, "Cluster phase set to %s", cluster.Status.Phase)
+		return
+	}
+	r.event(cluster, eventType, ReasonPhaseChanged, "Cluster phase changed from %s to %s", previous, cluster.Status.Phase)
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Secrets are not
+// watched: a deleted kubeconfig Secret is published again by the periodic
+// reconcile of running clusters.
 func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&clusterv1alpha1.Cluster{}).
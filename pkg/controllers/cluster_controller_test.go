@@ -2,15 +2,23 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -218,3 +226,1155 @@ func TestClusterPhaseTransitions(t *testing.T) {
 		time.Sleep(100 * time.Millisecond)
 	}
 }
+
+func TestClusterAdoption(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	// Create a fake client
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	// Create a mock provider that reports the spec of the adopted kind cluster
+	var createCalled bool
+	var adoptedSource string
+	mockProvider := &providers.MockProvider{
+		CreateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			createCalled = true
+			return nil
+		},
+		AdoptClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster, source string) (*v1alpha1.ClusterSpec, error) {
+			adoptedSource = source
+			return &v1alpha1.ClusterSpec{
+				KubernetesVersion: v1alpha1.TestKubernetesVersion,
+				ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+				Workers:           v1alpha1.WorkerConfig{Count: 2},
+			}, nil
+		},
+	}
+
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "adopted",
+			Namespace: "default",
+			Annotations: map[string]string{
+				v1alpha1.AdoptAnnotation: "kind",
+			},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	for i := 0; i < 2; i++ {
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile cluster: %v", err)
+		}
+	}
+
+	adopted := &v1alpha1.Cluster{}
+	if err := client.Get(context.Background(), req.NamespacedName, adopted); err != nil {
+		t.Fatalf("Failed to get adopted cluster: %v", err)
+	}
+
+	if adoptedSource != "kind" {
+		t.Errorf("Expected kind cluster %q to be adopted, got %q", "kind", adoptedSource)
+	}
+	if createCalled {
+		t.Error("Adopted cluster should not be provisioned")
+	}
+	if adopted.Status.Phase != v1alpha1.ClusterPhaseRunning {
+		t.Errorf("Expected phase Running, got %s", adopted.Status.Phase)
+	}
+	if adopted.Spec.KubernetesVersion != v1alpha1.TestKubernetesVersion || adopted.Spec.Workers.Count != 2 {
+		t.Errorf("Expected derived spec to be recorded, got %+v", adopted.Spec)
+	}
+}
+
+func TestLegacyRunningCluster(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	var updateCalled bool
+	mockProvider := &providers.MockProvider{
+		UpdateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			updateCalled = true
+			return nil
+		},
+	}
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+	}
+
+	// A cluster that reached Running before the applied generation was recorded
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "legacy",
+			Namespace:  "default",
+			Generation: 3,
+			Finalizers: []string{clusterFinalizer},
+		},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: v1alpha1.TestKubernetesVersion,
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+	cluster.Status.Phase = v1alpha1.ClusterPhaseRunning
+	if err := client.Status().Update(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to update test cluster status: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Failed to reconcile cluster: %v", err)
+	}
+
+	current := &v1alpha1.Cluster{}
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if updateCalled || current.Status.Phase != v1alpha1.ClusterPhaseRunning {
+		t.Errorf("Expected the cluster to stay Running without an update, got phase %s, update called %v", current.Status.Phase, updateCalled)
+	}
+	if current.Status.ObservedGeneration != current.Generation {
+		t.Errorf("Expected generation %d to be recorded as applied, got %d", current.Generation, current.Status.ObservedGeneration)
+	}
+}
+
+func TestPartialProvisioning(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	// Create a fake client
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	// Create a mock provider that fails to create one of the workers
+	var updateCalled bool
+	mockProvider := &providers.MockProvider{
+		CreateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			return &providers.NodeOperationError{
+				Operation: "create",
+				Succeeded: []string{"worker-0"},
+				Failed:    map[string]error{"worker-1": errors.New("pull failed")},
+			}
+		},
+		UpdateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			updateCalled = true
+			return nil
+		},
+	}
+
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "partial", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: v1alpha1.TestKubernetesVersion,
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           v1alpha1.WorkerConfig{Count: 2},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Failed to reconcile cluster: %v", err)
+	}
+	if _, err := reconciler.Reconcile(context.Background(), req); err == nil {
+		t.Fatal("Expected the provisioning error to be returned")
+	}
+
+	current := &v1alpha1.Cluster{}
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if current.Status.Phase != v1alpha1.ClusterPhaseUpdating {
+		t.Fatalf("Expected a partially provisioned cluster to move to Updating, got %s", current.Status.Phase)
+	}
+	if !strings.Contains(current.Status.Message, "worker-1") {
+		t.Errorf("Expected the failed node in the status message, got %q", current.Status.Message)
+	}
+
+	// The update phase creates the missing workers
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Failed to reconcile cluster: %v", err)
+	}
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if !updateCalled {
+		t.Error("Expected the missing workers to be created through UpdateCluster")
+	}
+	if current.Status.Phase != v1alpha1.ClusterPhaseRunning {
+		t.Errorf("Expected phase Running, got %s", current.Status.Phase)
+	}
+}
+
+func TestClusterLifecycleEvents(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	// Create a fake client
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	// Create a mock provider that records scaling requests and reports the
+	// workers it runs
+	var updatedWorkers int32 = -1
+	var runningWorkers int32 = 1
+	mockProvider := &providers.MockProvider{
+		UpdateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			updatedWorkers = c.Spec.Workers.Count
+			runningWorkers = c.Spec.Workers.Count
+			return nil
+		},
+		GetClusterStatusFunc: func(ctx context.Context, c *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+			return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseRunning, ControlPlaneReady: true, WorkersReady: runningWorkers}, nil
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "events",
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: v1alpha1.TestKubernetesVersion,
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           v1alpha1.WorkerConfig{Count: 1},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile cluster: %v", err)
+		}
+	}
+
+	// Provision the cluster
+	for i := 0; i < 3; i++ {
+		reconcile()
+	}
+	expectEvents(t, recorder, ReasonPhaseChanged, ReasonProvisioning, ReasonProvisioned)
+
+	// Scale the workers up
+	current := &v1alpha1.Cluster{}
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if current.Status.Phase != v1alpha1.ClusterPhaseRunning {
+		t.Fatalf("Expected phase Running, got %s", current.Status.Phase)
+	}
+	current.Spec.Workers.Count = 3
+	current.Generation = 2
+	if err := client.Update(context.Background(), current); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+
+	reconcile()
+	reconcile()
+
+	if updatedWorkers != 3 {
+		t.Errorf("Expected provider to scale to 3 workers, got %d", updatedWorkers)
+	}
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if current.Status.Phase != v1alpha1.ClusterPhaseRunning {
+		t.Errorf("Expected phase Running after scaling, got %s", current.Status.Phase)
+	}
+	if current.Status.ObservedGeneration != 2 {
+		t.Errorf("Expected observed generation 2, got %d", current.Status.ObservedGeneration)
+	}
+	expectEvents(t, recorder, ReasonScaling, ReasonScaled)
+}
+
+func TestUpgradeValidation(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	// Create a mock provider that records the versions it upgrades to
+	var upgradedTo []string
+	mockProvider := &providers.MockProvider{
+		UpdateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			upgradedTo = append(upgradedTo, c.Spec.KubernetesVersion)
+			return nil
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+		Recorder: recorder,
+		Versions: versions.Builtin(),
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "upgrade", Namespace: "default", Generation: 1},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.27.13",
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile cluster: %v", err)
+		}
+	}
+	setVersion := func(version string, generation int64) {
+		t.Helper()
+		current := &v1alpha1.Cluster{}
+		if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+			t.Fatalf("Failed to get cluster: %v", err)
+		}
+		current.Spec.KubernetesVersion = version
+		current.Generation = generation
+		if err := client.Update(context.Background(), current); err != nil {
+			t.Fatalf("Failed to update cluster: %v", err)
+		}
+	}
+
+	// Provision the cluster
+	for i := 0; i < 3; i++ {
+		reconcile()
+	}
+	current := &v1alpha1.Cluster{}
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if current.Status.KubernetesVersion != "v1.27.13" {
+		t.Fatalf("Expected the provisioned version in the status, got %q", current.Status.KubernetesVersion)
+	}
+
+	// Skipping a minor version is rejected without touching the nodes
+	setVersion("v1.29.8", 2)
+	reconcile()
+	reconcile()
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if len(upgradedTo) != 0 {
+		t.Errorf("Expected no upgrade, got upgrades to %v", upgradedTo)
+	}
+	if current.Status.Phase != v1alpha1.ClusterPhaseUpdating || !strings.Contains(current.Status.Message, "v1.29.8") {
+		t.Errorf("Expected the cluster to wait in Updating with the reason, got %s: %q", current.Status.Phase, current.Status.Message)
+	}
+	expectEvents(t, recorder, ReasonUpgradeRejected)
+
+	// A supported upgrade goes ahead
+	setVersion("v1.28.13", 3)
+	reconcile()
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if len(upgradedTo) != 1 || upgradedTo[0] != "v1.28.13" {
+		t.Errorf("Expected an upgrade to v1.28.13, got %v", upgradedTo)
+	}
+	if current.Status.Phase != v1alpha1.ClusterPhaseRunning || current.Status.KubernetesVersion != "v1.28.13" {
+		t.Errorf("Expected Running on v1.28.13, got %s on %s", current.Status.Phase, current.Status.KubernetesVersion)
+	}
+	events := recordedEvents(recorder)
+	for _, reason := range []string{ReasonUpgrading, ReasonUpgraded} {
+		if !hasEvent(events, reason) {
+			t.Errorf("Expected a %s event, got %v", reason, events)
+		}
+	}
+	if hasEvent(events, ReasonScaling) || hasEvent(events, ReasonScaled) {
+		t.Errorf("Expected no scaling events for an upgrade, got %v", events)
+	}
+}
+
+func TestUpgradeRollback(t *testing.T) {
+	for _, policy := range []v1alpha1.RollbackPolicy{v1alpha1.RollbackPolicyAutomatic, v1alpha1.RollbackPolicyManual} {
+		t.Run(string(policy), func(t *testing.T) {
+			// Register cluster types
+			s := runtime.NewScheme()
+			scheme.AddToScheme(s)
+			v1alpha1.AddToScheme(s)
+
+			client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+			// Create a mock provider whose upgrades fail
+			var rolledBack *v1alpha1.UpgradeStatus
+			mockProvider := &providers.MockProvider{
+				SnapshotEtcdFunc: func(ctx context.Context, c *v1alpha1.Cluster) (string, error) {
+					return "/snapshots/etcd.db", nil
+				},
+				UpdateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+					return errors.New("worker-0 did not start")
+				},
+				RollbackUpgradeFunc: func(ctx context.Context, c *v1alpha1.Cluster, upgrade *v1alpha1.UpgradeStatus) error {
+					rolledBack = upgrade
+					return nil
+				},
+			}
+
+			recorder := record.NewFakeRecorder(100)
+			reconciler := &ClusterReconciler{
+				Client:   client,
+				Scheme:   s,
+				Provider: mockProvider,
+				Recorder: recorder,
+				Versions: versions.Builtin(),
+			}
+
+			cluster := &v1alpha1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "rollback", Namespace: "default", Generation: 1},
+				Spec: v1alpha1.ClusterSpec{
+					KubernetesVersion: "v1.27.13",
+					ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+					RollbackPolicy:    policy,
+				},
+			}
+			if err := client.Create(context.Background(), cluster); err != nil {
+				t.Fatalf("Failed to create test cluster: %v", err)
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+			reconcile := func() {
+				t.Helper()
+				if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+					t.Fatalf("Failed to reconcile cluster: %v", err)
+				}
+			}
+			current := &v1alpha1.Cluster{}
+			get := func() {
+				t.Helper()
+				if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+					t.Fatalf("Failed to get cluster: %v", err)
+				}
+			}
+
+			// Provision the cluster, then upgrade it
+			for i := 0; i < 3; i++ {
+				reconcile()
+			}
+			get()
+			current.Spec.KubernetesVersion = "v1.28.13"
+			current.Generation = 2
+			if err := client.Update(context.Background(), current); err != nil {
+				t.Fatalf("Failed to update cluster: %v", err)
+			}
+			reconcile()
+			reconcile()
+
+			get()
+			upgrade := current.Status.Upgrade
+			if upgrade == nil {
+				t.Fatal("Expected the upgrade to be recorded")
+			}
+			if upgrade.EtcdSnapshot != "/snapshots/etcd.db" || upgrade.PreviousSpec.KubernetesVersion != "v1.27.13" {
+				t.Errorf("Expected the snapshot and previous spec to be recorded, got %q and %q", upgrade.EtcdSnapshot, upgrade.PreviousSpec.KubernetesVersion)
+			}
+
+			if policy == v1alpha1.RollbackPolicyManual {
+				if rolledBack != nil || upgrade.Phase != v1alpha1.UpgradePhaseFailed {
+					t.Fatalf("Expected the failed upgrade to wait for a rollback request, got phase %s", upgrade.Phase)
+				}
+				expectEvents(t, recorder, ReasonUpgradeFailed)
+
+				// Request the rollback
+				current.Annotations = map[string]string{v1alpha1.RollbackAnnotation: "true"}
+				if err := client.Update(context.Background(), current); err != nil {
+					t.Fatalf("Failed to update cluster: %v", err)
+				}
+				reconcile()
+				get()
+				upgrade = current.Status.Upgrade
+				if _, ok := current.Annotations[v1alpha1.RollbackAnnotation]; ok {
+					t.Error("Expected the rollback annotation to be removed")
+				}
+			}
+
+			if rolledBack == nil || rolledBack.ToVersion != "v1.28.13" {
+				t.Fatalf("Expected the upgrade to v1.28.13 to be rolled back, got %+v", rolledBack)
+			}
+			if upgrade.Phase != v1alpha1.UpgradePhaseRolledBack || upgrade.CompletionTime == nil {
+				t.Errorf("Expected the upgrade to be rolled back, got phase %s", upgrade.Phase)
+			}
+			if current.Status.Phase != v1alpha1.ClusterPhaseRunning || current.Status.KubernetesVersion != "v1.27.13" {
+				t.Errorf("Expected Running on v1.27.13, got %s on %s", current.Status.Phase, current.Status.KubernetesVersion)
+			}
+			if current.Status.ObservedGeneration != 2 {
+				t.Errorf("Expected the rolled back generation to be observed, got %d", current.Status.ObservedGeneration)
+			}
+			expectEvents(t, recorder, ReasonRollingBack, ReasonRolledBack)
+		})
+	}
+}
+
+func TestUpgradeOfUnrecordedVersion(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	// The provider tells which version the nodes run
+	var snapshots int
+	mockProvider := &providers.MockProvider{
+		GetClusterStatusFunc: func(ctx context.Context, c *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+			return &v1alpha1.ClusterStatus{KubernetesVersion: "v1.27.13"}, nil
+		},
+		SnapshotEtcdFunc: func(ctx context.Context, c *v1alpha1.Cluster) (string, error) {
+			snapshots++
+			return "/snapshots/etcd.db", nil
+		},
+	}
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+		Recorder: record.NewFakeRecorder(100),
+		Versions: versions.Builtin(),
+	}
+
+	// A cluster from before the version of its nodes was recorded, being upgraded
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "unrecorded",
+			Namespace:  "default",
+			Generation: 2,
+			Finalizers: []string{clusterFinalizer},
+		},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.28.13",
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+	cluster.Status.Phase = v1alpha1.ClusterPhaseUpdating
+	cluster.Status.ObservedGeneration = 1
+	if err := client.Status().Update(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to update test cluster status: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Failed to reconcile cluster: %v", err)
+	}
+
+	current := &v1alpha1.Cluster{}
+	if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	upgrade := current.Status.Upgrade
+	if upgrade == nil || upgrade.FromVersion != "v1.27.13" || upgrade.ToVersion != "v1.28.13" {
+		t.Fatalf("Expected the upgrade from v1.27.13 to v1.28.13 to be recorded, got %+v", upgrade)
+	}
+	if snapshots != 1 || upgrade.EtcdSnapshot != "/snapshots/etcd.db" {
+		t.Errorf("Expected one etcd snapshot to be recorded, got %d and %q", snapshots, upgrade.EtcdSnapshot)
+	}
+	if current.Status.KubernetesVersion != "v1.28.13" {
+		t.Errorf("Expected the cluster to run v1.28.13, got %q", current.Status.KubernetesVersion)
+	}
+}
+
+func TestSetNodeUpgrade(t *testing.T) {
+	upgrade := &v1alpha1.UpgradeStatus{}
+	setNodeUpgrade(upgrade, v1alpha1.NodeUpgradeStatus{Name: "worker-0", PreviousImage: "kindest/node:v1.27.13", State: v1alpha1.NodeUpgradeStateFailed})
+	setNodeUpgrade(upgrade, v1alpha1.NodeUpgradeStatus{Name: "worker-1", PreviousImage: "kindest/node:v1.27.13", State: v1alpha1.NodeUpgradeStateUpgraded})
+
+	// A later upgrade attempt replaces worker-0 again, from whatever it runs now
+	setNodeUpgrade(upgrade, v1alpha1.NodeUpgradeStatus{Name: "worker-0", PreviousImage: "kindest/node:v1.28.9", State: v1alpha1.NodeUpgradeStateUpgraded})
+
+	if len(upgrade.Nodes) != 2 {
+		t.Fatalf("Expected one entry per node, got %v", upgrade.Nodes)
+	}
+	if node := upgrade.Nodes[0]; node.State != v1alpha1.NodeUpgradeStateUpgraded || node.PreviousImage != "kindest/node:v1.27.13" {
+		t.Errorf("Expected worker-0 to be upgraded and keep its original image, got %+v", node)
+	}
+}
+
+func TestClusterSuspension(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	// Record the order of provider calls
+	var calls []string
+	mockProvider := &providers.MockProvider{
+		SuspendClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			calls = append(calls, "suspend")
+			return nil
+		},
+		ResumeClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			calls = append(calls, "resume")
+			return nil
+		},
+		UpdateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			calls = append(calls, "update")
+			return nil
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "suspend", Namespace: "default", Generation: 1},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.28.13",
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           v1alpha1.WorkerConfig{Count: 2},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile cluster: %v", err)
+		}
+	}
+	current := &v1alpha1.Cluster{}
+	get := func() {
+		t.Helper()
+		if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+			t.Fatalf("Failed to get cluster: %v", err)
+		}
+	}
+	setSuspended := func(suspended bool, generation int64) {
+		t.Helper()
+		get()
+		current.Spec.Suspended = suspended
+		current.Generation = generation
+		if err := client.Update(context.Background(), current); err != nil {
+			t.Fatalf("Failed to update cluster: %v", err)
+		}
+	}
+
+	// Provision the cluster, then suspend it
+	for i := 0; i < 3; i++ {
+		reconcile()
+	}
+	setSuspended(true, 2)
+	reconcile()
+	get()
+	if current.Status.Phase != v1alpha1.ClusterPhaseSuspended {
+		t.Fatalf("Expected phase Suspended, got %s", current.Status.Phase)
+	}
+	if current.Status.WorkersReady != 0 || current.Status.ControlPlaneReady {
+		t.Errorf("Expected no ready nodes while suspended, got %d workers", current.Status.WorkersReady)
+	}
+
+	// The nodes are kept stopped while the cluster stays suspended
+	reconcile()
+	get()
+	if current.Status.Phase != v1alpha1.ClusterPhaseSuspended {
+		t.Errorf("Expected the cluster to stay Suspended, got %s", current.Status.Phase)
+	}
+	expectEvents(t, recorder, ReasonSuspending, ReasonSuspended)
+
+	// Resume it; the spec change is rolled out afterwards
+	setSuspended(false, 3)
+	for i := 0; i < 3; i++ {
+		reconcile()
+	}
+	get()
+	if current.Status.Phase != v1alpha1.ClusterPhaseRunning || current.Status.ObservedGeneration != 3 {
+		t.Errorf("Expected Running with generation 3 observed, got %s with %d", current.Status.Phase, current.Status.ObservedGeneration)
+	}
+	expected := []string{"suspend", "suspend", "resume", "update"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected provider calls %v, got %v", expected, calls)
+	}
+	expectEvents(t, recorder, ReasonResuming, ReasonResumed)
+}
+
+func TestClusterClone(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	var clonedFrom string
+	mockProvider := &providers.MockProvider{
+		CreateClusterFunc: func(ctx context.Context, c *v1alpha1.Cluster) error {
+			t.Errorf("Expected the clone to be copied, not created")
+			return nil
+		},
+		CloneClusterFunc: func(ctx context.Context, c, source *v1alpha1.Cluster) error {
+			clonedFrom = source.Name
+			return nil
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	source := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.28.13",
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           v1alpha1.WorkerConfig{Count: 2},
+		},
+	}
+	clone := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "clone", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			CloneFrom: &corev1.LocalObjectReference{Name: source.Name},
+		},
+	}
+	for _, cluster := range []*v1alpha1.Cluster{source, clone} {
+		if err := client.Create(context.Background(), cluster); err != nil {
+			t.Fatalf("Failed to create test cluster: %v", err)
+		}
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: clone.Name, Namespace: clone.Namespace}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile cluster: %v", err)
+		}
+	}
+	current := &v1alpha1.Cluster{}
+	get := func() {
+		t.Helper()
+		if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+			t.Fatalf("Failed to get cluster: %v", err)
+		}
+	}
+
+	// The clone waits for its source to run
+	reconcile()
+	get()
+	if current.Status.Phase != v1alpha1.ClusterPhasePending {
+		t.Fatalf("Expected phase Pending, got %s", current.Status.Phase)
+	}
+	if !strings.Contains(current.Status.Message, "Waiting for source cluster source") {
+		t.Errorf("Expected a message about waiting for the source, got %q", current.Status.Message)
+	}
+
+	source.Status.Phase = v1alpha1.ClusterPhaseRunning
+	if err := client.Status().Update(context.Background(), source); err != nil {
+		t.Fatalf("Failed to update source cluster status: %v", err)
+	}
+	reconcile()
+	get()
+	if current.Status.Phase != v1alpha1.ClusterPhaseProvisioning {
+		t.Fatalf("Expected phase Provisioning, got %s", current.Status.Phase)
+	}
+	if current.Spec.KubernetesVersion != "v1.28.13" || current.Spec.Workers.Count != 2 {
+		t.Errorf("Expected the spec of the source, got %+v", current.Spec)
+	}
+	if current.Spec.CloneFrom == nil || current.Spec.CloneFrom.Name != source.Name {
+		t.Errorf("Expected the clone to keep its source, got %v", current.Spec.CloneFrom)
+	}
+
+	reconcile()
+	get()
+	if current.Status.Phase != v1alpha1.ClusterPhaseRunning {
+		t.Fatalf("Expected phase Running, got %s", current.Status.Phase)
+	}
+	if clonedFrom != source.Name {
+		t.Errorf("Expected the cluster to be cloned from %s, got %q", source.Name, clonedFrom)
+	}
+	expectEvents(t, recorder, ReasonCloning, ReasonCloned)
+}
+
+func TestNodeRemediation(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	notReady := false
+	var nodes []providers.NodeHealth
+	var remediated []string
+	mockProvider := &providers.MockProvider{
+		NodeHealthFunc: func(ctx context.Context, c *v1alpha1.Cluster) ([]providers.NodeHealth, error) {
+			return nodes, nil
+		},
+		RemediateNodeFunc: func(ctx context.Context, c *v1alpha1.Cluster, node string, action v1alpha1.RemediationAction) (v1alpha1.RemediationAction, error) {
+			remediated = append(remediated, node)
+			if node == "worker-1" {
+				return action, errors.New("node image is gone")
+			}
+			return action, nil
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "health", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.28.13",
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           v1alpha1.WorkerConfig{Count: 3},
+			HealthCheck: &v1alpha1.MachineHealthCheckSpec{
+				UnhealthyConditions: []v1alpha1.UnhealthyCondition{
+					{Type: v1alpha1.UnhealthyConditionContainerNotRunning, Timeout: metav1.Duration{Duration: time.Minute}},
+					{Type: v1alpha1.UnhealthyConditionNodeNotReady, Timeout: metav1.Duration{Duration: 5 * time.Minute}},
+				},
+				Remediation: v1alpha1.RemediationActionRecreate,
+			},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile cluster: %v", err)
+		}
+	}
+	current := &v1alpha1.Cluster{}
+	get := func() {
+		t.Helper()
+		if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+			t.Fatalf("Failed to get cluster: %v", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		reconcile()
+	}
+
+	// worker-0 stopped before worker-1 went NotReady; worker-2 has not been
+	// NotReady for long enough
+	now := time.Now()
+	nodes = []providers.NodeHealth{
+		{Name: "control-plane-0", Role: "control-plane", ContainerRunning: true},
+		{Name: "worker-0", Role: "worker", ContainerSince: now.Add(-20 * time.Minute)},
+		{Name: "worker-1", Role: "worker", ContainerRunning: true, Ready: &notReady, ReadySince: now.Add(-10 * time.Minute)},
+		{Name: "worker-2", Role: "worker", ContainerRunning: true, Ready: &notReady, ReadySince: now.Add(-time.Minute)},
+	}
+
+	// One node is remediated per check, the longest unhealthy first
+	reconcile()
+	get()
+	if len(current.Status.Remediations) != 1 {
+		t.Fatalf("Expected one remediation, got %+v", current.Status.Remediations)
+	}
+	if r := current.Status.Remediations[0]; r.Node != "worker-0" || r.Condition != v1alpha1.UnhealthyConditionContainerNotRunning ||
+		r.Action != v1alpha1.RemediationActionRecreate || r.Result != v1alpha1.RemediationResultSucceeded {
+		t.Errorf("Expected worker-0 to be recreated, got %+v", r)
+	}
+	expectEvents(t, recorder, ReasonNodeUnhealthy, ReasonNodeRemediated)
+
+	// worker-0 gets time to recover, so worker-1 is next
+	reconcile()
+	get()
+	if len(current.Status.Remediations) != 2 {
+		t.Fatalf("Expected two remediations, got %+v", current.Status.Remediations)
+	}
+	if r := current.Status.Remediations[1]; r.Node != "worker-1" || r.Result != v1alpha1.RemediationResultFailed || r.Message != "node image is gone" {
+		t.Errorf("Expected the remediation of worker-1 to fail, got %+v", r)
+	}
+	if current.Status.Phase != v1alpha1.ClusterPhaseRunning {
+		t.Errorf("Expected a failed remediation to leave the cluster Running, got %s", current.Status.Phase)
+	}
+	expectEvents(t, recorder, ReasonNodeUnhealthy, ReasonRemediationFailed)
+
+	// Nothing is due until the timeouts pass again
+	reconcile()
+	if expected := "worker-0,worker-1"; strings.Join(remediated, ",") != expected {
+		t.Errorf("Expected remediations of %s, got %v", expected, remediated)
+	}
+}
+
+func TestClusterReadinessAndKubeconfig(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+
+	workersReady := int32(1)
+	var kubeconfigErr error
+	mockProvider := &providers.MockProvider{
+		GetClusterStatusFunc: func(ctx context.Context, c *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error) {
+			return &v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseRunning, ControlPlaneReady: true, WorkersReady: workersReady}, nil
+		},
+		KubeconfigFunc: func(ctx context.Context, c *v1alpha1.Cluster) ([]byte, error) {
+			return []byte("kubeconfig of " + c.Name), kubeconfigErr
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.28.13",
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           v1alpha1.WorkerConfig{Count: 2},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile cluster: %v", err)
+		}
+	}
+	current := &v1alpha1.Cluster{}
+	get := func() {
+		t.Helper()
+		if err := client.Get(context.Background(), req.NamespacedName, current); err != nil {
+			t.Fatalf("Failed to get cluster: %v", err)
+		}
+	}
+	condition := func(conditionType string) metav1.Condition {
+		t.Helper()
+		for _, c := range current.Status.Conditions {
+			if c.Type == conditionType {
+				return c
+			}
+		}
+		t.Fatalf("Expected a %s condition, got %+v", conditionType, current.Status.Conditions)
+		return metav1.Condition{}
+	}
+
+	// A kubeconfig the provider cannot read yet is retried
+	kubeconfigErr = errors.New("no admin kubeconfig yet")
+	for i := 0; i < 3; i++ {
+		reconcile()
+	}
+	get()
+	if c := condition(v1alpha1.ClusterConditionReady); c.Status != metav1.ConditionFalse || c.Reason != "WorkersNotReady" {
+		t.Errorf("Expected the cluster not to be ready while a worker is not, got %+v", c)
+	}
+	if c := condition(v1alpha1.ClusterConditionKubeconfigAvailable); c.Status != metav1.ConditionFalse || c.Message != "no admin kubeconfig yet" {
+		t.Errorf("Expected the kubeconfig not to be available, got %+v", c)
+	}
+	expectEvents(t, recorder, ReasonKubeconfigFailed)
+
+	kubeconfigErr = nil
+	workersReady = 2
+	reconcile()
+	get()
+	if c := condition(v1alpha1.ClusterConditionReady); c.Status != metav1.ConditionTrue {
+		t.Errorf("Expected the cluster to be ready, got %+v", c)
+	}
+	if c := condition(v1alpha1.ClusterConditionKubeconfigAvailable); c.Status != metav1.ConditionTrue {
+		t.Errorf("Expected the kubeconfig to be available, got %+v", c)
+	}
+	expectEvents(t, recorder, ReasonKubeconfigPublished)
+
+	var secret corev1.Secret
+	key := types.NamespacedName{Name: v1alpha1.KubeconfigSecretName(cluster.Name), Namespace: cluster.Namespace}
+	if err := client.Get(context.Background(), key, &secret); err != nil {
+		t.Fatalf("Failed to get kubeconfig Secret: %v", err)
+	}
+	if got := string(secret.Data[v1alpha1.KubeconfigSecretKey]); got != "kubeconfig of ready" {
+		t.Errorf("Expected the provider's kubeconfig in the Secret, got %q", got)
+	}
+	if owners := secret.OwnerReferences; len(owners) != 1 || owners[0].Name != cluster.Name {
+		t.Errorf("Expected the Secret to be owned by the cluster, got %+v", owners)
+	}
+}
+
+func TestReconcileTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	defer otel.SetTracerProvider(previous)
+
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.Cluster{}).Build()
+	reconciler := &ClusterReconciler{
+		Client:   client,
+		Scheme:   s,
+		Provider: &providers.MockProvider{},
+	}
+
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "traced", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: v1alpha1.TestKubernetesVersion,
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+		},
+	}
+	if err := client.Create(context.Background(), cluster); err != nil {
+		t.Fatalf("Failed to create test cluster: %v", err)
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Failed to reconcile cluster: %v", err)
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("Expected a reconcile span and a phase span, got %d spans", len(ended))
+	}
+	phase, root := ended[0], ended[1]
+	if root.Name() != "ClusterReconciler.Reconcile" {
+		t.Errorf("Expected the root span to be ClusterReconciler.Reconcile, got %s", root.Name())
+	}
+	if phase.Name() != "ClusterReconciler.handlePendingPhase" {
+		t.Errorf("Expected a handlePendingPhase span, got %s", phase.Name())
+	}
+	if phase.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("Expected the phase span to be a child of the reconcile span")
+	}
+}
+
+// expectEvents drains the recorder and checks that every reason was emitted
+func expectEvents(t *testing.T, recorder *record.FakeRecorder, reasons ...string) {
+	t.Helper()
+
+	events := recordedEvents(recorder)
+	for _, reason := range reasons {
+		if !hasEvent(events, reason) {
+			t.Errorf("Expected a %s event, got %v", reason, events)
+		}
+	}
+}
+
+// recordedEvents drains the recorder
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	return events
+}
+
+// hasEvent reports whether one of the events has the reason
+func hasEvent(events []string, reason string) bool {
+	for _, event := range events {
+		if strings.Contains(event, " "+reason+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPullSecretFromAPIReader(t *testing.T) {
+	// Register cluster types
+	s := runtime.NewScheme()
+	scheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	// The pull Secret is only visible to the API reader, as the manager's
+	// cache holds no Secrets
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "default"},
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"}}}`)},
+	}
+	reconciler := &ClusterReconciler{
+		Client:    fake.NewClientBuilder().WithScheme(s).Build(),
+		Scheme:    s,
+		Provider:  &providers.MockProvider{},
+		APIReader: fake.NewClientBuilder().WithScheme(s).WithObjects(secret).Build(),
+	}
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "private", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			NodeImage: &v1alpha1.NodeImageSpec{PullSecretRef: &corev1.LocalObjectReference{Name: "registry"}},
+		},
+	}
+	if _, err := reconciler.withRegistryCredentials(context.Background(), cluster); err != nil {
+		t.Errorf("Expected the pull Secret to be read through the API reader, got %v", err)
+	}
+
+	reconciler.APIReader = nil
+	if _, err := reconciler.withRegistryCredentials(context.Background(), cluster); err == nil {
+		t.Error("Expected the pull Secret to be missing from the client")
+	}
+}
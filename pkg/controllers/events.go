@@ -0,0 +1,57 @@
+package controllers
+
+// Event reasons emitted by the cluster controller. Steps performed on
+// individual nodes and networks are reported by the provider, see the
+// Reason constants in the providers package.
+const (
+	ReasonPhaseChanged        = "PhaseChanged"
+	ReasonProvisioning        = "Provisioning"
+	ReasonProvisioned         = "Provisioned"
+	ReasonProvisioningFailed  = "ProvisioningFailed"
+	ReasonAdopted             = "Adopted"
+	ReasonAdoptionFailed      = "AdoptionFailed"
+	ReasonCloning             = "Cloning"
+	ReasonCloned              = "Cloned"
+	ReasonCloneFailed         = "CloneFailed"
+	ReasonMigrationFailed     = "MigrationFailed"
+	ReasonScaling             = "Scaling"
+	ReasonScaled              = "Scaled"
+	ReasonScalingFailed       = "ScalingFailed"
+	ReasonUpdateFailed        = "UpdateFailed"
+	ReasonUpgrading           = "Upgrading"
+	ReasonUpgraded            = "Upgraded"
+	ReasonUpgradeRejected     = "UpgradeRejected"
+	ReasonUpgradeFailed       = "UpgradeFailed"
+	ReasonEtcdSnapshotFailed  = "EtcdSnapshotFailed"
+	ReasonRollingBack         = "RollingBack"
+	ReasonRolledBack          = "RolledBack"
+	ReasonRollbackFailed      = "RollbackFailed"
+	ReasonSuspending          = "Suspending"
+	ReasonSuspended           = "Suspended"
+	ReasonSuspendFailed       = "SuspendFailed"
+	ReasonResuming            = "Resuming"
+	ReasonResumed             = "Resumed"
+	ReasonResumeFailed        = "ResumeFailed"
+	ReasonStatusCheckFailed   = "StatusCheckFailed"
+	ReasonHealthCheckFailed   = "HealthCheckFailed"
+	ReasonNodeUnhealthy       = "NodeUnhealthy"
+	ReasonNodeRemediated      = "NodeRemediated"
+	ReasonRemediationFailed   = "RemediationFailed"
+	ReasonDeleting            = "Deleting"
+	ReasonDeleted             = "Deleted"
+	ReasonDeletionFailed      = "DeletionFailed"
+	ReasonPullSecretFailed    = "PullSecretFailed"
+	ReasonKubeconfigPublished = "KubeconfigPublished"
+	ReasonKubeconfigFailed    = "KubeconfigFailed"
+)
+
+// Event reasons emitted by the backup and restore controllers
+const (
+	ReasonBackedUp               = "BackedUp"
+	ReasonBackupFailed           = "BackupFailed"
+	ReasonSnapshotDeleted        = "SnapshotDeleted"
+	ReasonSnapshotDeletionFailed = "SnapshotDeletionFailed"
+	ReasonRestoring              = "Restoring"
+	ReasonRestored               = "Restored"
+	ReasonRestoreFailed          = "RestoreFailed"
+)
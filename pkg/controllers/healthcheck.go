@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// unhealthyNode is a node that matches one of a health check's conditions
+type unhealthyNode struct {
+	name      string
+	condition clusterv1alpha1.UnhealthyConditionType
+	since     time.Time
+}
+
+// remediateUnhealthyNodes repairs the nodes the cluster's health check finds
+// unhealthy, at most MaxRemediating of them per check, and records each
+// remediation in the status. Failing to check or repair nodes does not fail
+// the reconcile; it is reported and tried again on the next check.
+func (r *ClusterReconciler) remediateUnhealthyNodes(ctx context.Context, cluster *clusterv1alpha1.Cluster) {
+	check := cluster.Spec.HealthCheck
+	if check == nil || len(check.UnhealthyConditions) == 0 {
+		return
+	}
+	log := log.FromContext(ctx)
+	checker, ok := r.Provider.(providers.NodeHealthChecker)
+	if !ok {
+		log.V(1).Info("Provider cannot check node health")
+		return
+	}
+
+	nodes, err := checker.NodeHealth(ctx, cluster)
+	if err != nil {
+		log.Error(err, "Failed to check node health")
+		r.event(cluster, corev1.EventTypeWarning, ReasonHealthCheckFailed, "Failed to check node health: %v", err)
+		return
+	}
+
+	now := time.Now()
+	unhealthy := unhealthyNodes(check, nodes, cluster.Status.Remediations, now)
+	if limit := maxRemediating(check); len(unhealthy) > limit {
+		log.Info("More nodes are unhealthy than are remediated at once", "unhealthy", len(unhealthy), "maxRemediating", limit)
+		unhealthy = unhealthy[:limit]
+	}
+
+	action := check.Remediation
+	if action == "" {
+		action = clusterv1alpha1.RemediationActionRestart
+	}
+	for _, node := range unhealthy {
+		log.Info("Remediating unhealthy node", "node", node.name, "condition", node.condition, "since", node.since, "action", action)
+		r.event(cluster, corev1.EventTypeWarning, ReasonNodeUnhealthy, "Node %s is unhealthy: %s since %s", node.name, node.condition, node.since.Format(time.RFC3339))
+
+		remediation := clusterv1alpha1.NodeRemediation{
+			Node:      node.name,
+			Condition: node.condition,
+			Time:      metav1.NewTime(now),
+		}
+		taken, err := checker.RemediateNode(ctx, cluster, node.name, action)
+		remediation.Action = taken
+		if err != nil {
+			log.Error(err, "Failed to remediate node", "node", node.name)
+			r.event(cluster, corev1.EventTypeWarning, ReasonRemediationFailed, "Failed to remediate node %s: %v", node.name, err)
+			remediation.Result = clusterv1alpha1.RemediationResultFailed
+			remediation.Message = err.Error()
+		} else {
+			r.event(cluster, corev1.EventTypeNormal, ReasonNodeRemediated, "Remediated node %s: %s", node.name, taken)
+			remediation.Result = clusterv1alpha1.RemediationResultSucceeded
+		}
+		recordRemediation(&cluster.Status, remediation)
+	}
+}
+
+// unhealthyNodes returns the nodes that have matched one of the health
+// check's conditions for longer than its timeout, the longest unhealthy
+// first. A remediation restarts the clock, so a node gets the timeout to
+// recover before it is remediated again.
+func unhealthyNodes(check *clusterv1alpha1.MachineHealthCheckSpec, nodes []providers.NodeHealth, history []clusterv1alpha1.NodeRemediation, now time.Time) []unhealthyNode {
+	var unhealthy []unhealthyNode
+	for _, node := range nodes {
+		for _, condition := range check.UnhealthyConditions {
+			since, holds := conditionHolds(condition.Type, node)
+			if !holds {
+				continue
+			}
+			if last := lastRemediation(history, node.Name); last.After(since) {
+				since = last
+			}
+			if now.Sub(since) >= condition.Timeout.Duration {
+				unhealthy = append(unhealthy, unhealthyNode{name: node.Name, condition: condition.Type, since: since})
+				break
+			}
+		}
+	}
+	sort.SliceStable(unhealthy, func(i, j int) bool {
+		return unhealthy[i].since.Before(unhealthy[j].since)
+	})
+	return unhealthy
+}
+
+// conditionHolds reports whether node matches an unhealthy condition and
+// since when. Nodes whose state the provider cannot tell do not match.
+func conditionHolds(condition clusterv1alpha1.UnhealthyConditionType, node providers.NodeHealth) (time.Time, bool) {
+	switch condition {
+	case clusterv1alpha1.UnhealthyConditionContainerNotRunning:
+		return node.ContainerSince, !node.ContainerRunning
+	case clusterv1alpha1.UnhealthyConditionNodeNotReady:
+		return node.ReadySince, node.Ready != nil && !*node.Ready
+	default:
+		return time.Time{}, false
+	}
+}
+
+// lastRemediation returns when node was last remediated, or the zero time
+func lastRemediation(history []clusterv1alpha1.NodeRemediation, node string) time.Time {
+	var last time.Time
+	for _, remediation := range history {
+		if remediation.Node == node && remediation.Time.After(last) {
+			last = remediation.Time.Time
+		}
+	}
+	return last
+}
+
+// maxRemediating returns how many nodes the health check remediates at once
+func maxRemediating(check *clusterv1alpha1.MachineHealthCheckSpec) int {
+	if check.MaxRemediating > 0 {
+		return int(check.MaxRemediating)
+	}
+	return clusterv1alpha1.DefaultMaxRemediating
+}
+
+// recordRemediation adds a remediation to the status, dropping the oldest
+// ones beyond MaxRemediationHistory
+func recordRemediation(status *clusterv1alpha1.ClusterStatus, remediation clusterv1alpha1.NodeRemediation) {
+	status.Remediations = append(status.Remediations, remediation)
+	if excess := len(status.Remediations) - clusterv1alpha1.MaxRemediationHistory; excess > 0 {
+		status.Remediations = status.Remediations[excess:]
+	}
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// publishKubeconfig stores the admin kubeconfig of a running cluster in its
+// kubeconfig Secret, owned by the cluster, and records the outcome in the
+// KubeconfigAvailable condition. A Secret that exists already is left alone.
+// Failures are retried on the next reconcile and do not fail this one.
+func (r *ClusterReconciler) publishKubeconfig(ctx context.Context, cluster *clusterv1alpha1.Cluster) {
+	log := log.FromContext(ctx)
+	reader, ok := r.Provider.(providers.KubeconfigReader)
+	if !ok {
+		return
+	}
+	name := clusterv1alpha1.KubeconfigSecretName(cluster.Name)
+
+	var secret corev1.Secret
+	err := r.secretReader().Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: name}, &secret)
+	if err == nil {
+		setKubeconfigCondition(cluster, metav1.ConditionTrue, "Published", "Kubeconfig is in Secret "+name)
+		return
+	}
+	if !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to get kubeconfig Secret", "secret", name)
+		return
+	}
+
+	kubeconfig, err := reader.Kubeconfig(ctx, cluster)
+	if err != nil {
+		log.Error(err, "Failed to get kubeconfig")
+		r.event(cluster, corev1.EventTypeWarning, ReasonKubeconfigFailed, "Failed to get kubeconfig: %v", err)
+		setKubeconfigCondition(cluster, metav1.ConditionFalse, ReasonKubeconfigFailed, err.Error())
+		return
+	}
+	secret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cluster.Namespace},
+		Data:       map[string][]byte{clusterv1alpha1.KubeconfigSecretKey: kubeconfig},
+	}
+	if err := controllerutil.SetControllerReference(cluster, &secret, r.Scheme); err != nil {
+		log.Error(err, "Failed to set owner of kubeconfig Secret")
+		return
+	}
+	if err := r.Create(ctx, &secret); err != nil {
+		log.Error(err, "Failed to create kubeconfig Secret", "secret", name)
+		r.event(cluster, corev1.EventTypeWarning, ReasonKubeconfigFailed, "Failed to create kubeconfig Secret %s: %v", name, err)
+		setKubeconfigCondition(cluster, metav1.ConditionFalse, ReasonKubeconfigFailed, err.Error())
+		return
+	}
+	r.event(cluster, corev1.EventTypeNormal, ReasonKubeconfigPublished, "Published kubeconfig to Secret %s", name)
+	setKubeconfigCondition(cluster, metav1.ConditionTrue, "Published", "Kubeconfig is in Secret "+name)
+}
+
+// setKubeconfigCondition sets the KubeconfigAvailable condition of cluster
+func setKubeconfigCondition(cluster *clusterv1alpha1.Cluster, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type:               clusterv1alpha1.ClusterConditionKubeconfigAvailable,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: cluster.Generation,
+	})
+}
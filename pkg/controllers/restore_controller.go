@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+// ClusterRestoreReconciler restores clusters from the snapshots of their
+// ClusterBackups. A restore replaces all of etcd and is never retried once
+// it failed; create a new ClusterRestore to try again.
+type ClusterRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Provider providers.Provider
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=cluster.mini-k8s.io,resources=clusterrestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cluster.mini-k8s.io,resources=clusterrestores/status,verbs=get;update;patch
+
+func (r *ClusterRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "ClusterRestoreReconciler.Reconcile",
+		tracing.ClusterNamespaceKey.String(req.Namespace),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	log := log.FromContext(ctx)
+	if r.Recorder != nil {
+		ctx = providers.WithEventRecorder(ctx, r.Recorder)
+	}
+
+	var restore clusterv1alpha1.ClusterRestore
+	if err := r.Get(ctx, req.NamespacedName, &restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	switch restore.Status.Phase {
+	case clusterv1alpha1.ClusterRestorePhaseCompleted, clusterv1alpha1.ClusterRestorePhaseFailed:
+		return ctrl.Result{}, nil
+	}
+
+	backupper, ok := r.Provider.(providers.EtcdBackupper)
+	if !ok {
+		return ctrl.Result{}, r.fail(ctx, &restore, fmt.Errorf("the provider cannot restore etcd"))
+	}
+
+	// Find the snapshot
+	var backup clusterv1alpha1.ClusterBackup
+	if err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: restore.Spec.BackupName}, &backup); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return r.wait(ctx, &restore, fmt.Sprintf("Waiting for ClusterBackup %s", restore.Spec.BackupName))
+	}
+	snapshot := findSnapshot(&backup, restore.Spec.Snapshot)
+	if snapshot == nil {
+		if restore.Spec.Snapshot != "" {
+			return ctrl.Result{}, r.fail(ctx, &restore, fmt.Errorf("ClusterBackup %s has no snapshot %s", backup.Name, restore.Spec.Snapshot))
+		}
+		return r.wait(ctx, &restore, fmt.Sprintf("Waiting for ClusterBackup %s to take a snapshot", backup.Name))
+	}
+
+	cluster, err := runningCluster(ctx, r.Client, restore.Namespace, restore.Spec.ClusterName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cluster == nil {
+		return r.wait(ctx, &restore, fmt.Sprintf("Waiting for cluster %s to be Running", restore.Spec.ClusterName))
+	}
+
+	// Record the start before touching the cluster
+	if restore.Status.Phase != clusterv1alpha1.ClusterRestorePhaseRestoring {
+		now := metav1.Now()
+		restore.Status.Phase = clusterv1alpha1.ClusterRestorePhaseRestoring
+		restore.Status.Snapshot = snapshot.Name
+		restore.Status.StartTime = &now
+		restore.Status.Message = ""
+		r.event(&restore, corev1.EventTypeNormal, ReasonRestoring, "Restoring cluster %s from etcd snapshot %s", cluster.Name, snapshot.Name)
+		if err := r.Status().Update(ctx, &restore); err != nil {
+			log.Error(err, "Failed to update status to Restoring")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := backupper.RestoreEtcd(ctx, cluster, snapshot.Location, snapshot.Name); err != nil {
+		log.Error(err, "Failed to restore etcd", "cluster", cluster.Name, "snapshot", snapshot.Name)
+		return ctrl.Result{}, r.fail(ctx, &restore, err)
+	}
+
+	now := metav1.Now()
+	restore.Status.Phase = clusterv1alpha1.ClusterRestorePhaseCompleted
+	restore.Status.CompletionTime = &now
+	r.event(&restore, corev1.EventTypeNormal, ReasonRestored, "Restored cluster %s from etcd snapshot %s", cluster.Name, snapshot.Name)
+	if err := r.Status().Update(ctx, &restore); err != nil {
+		log.Error(err, "Failed to update status to Completed")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// wait records what the restore waits for and checks again later
+func (r *ClusterRestoreReconciler) wait(ctx context.Context, restore *clusterv1alpha1.ClusterRestore, message string) (ctrl.Result, error) {
+	if restore.Status.Phase != clusterv1alpha1.ClusterRestorePhasePending || restore.Status.Message != message {
+		restore.Status.Phase = clusterv1alpha1.ClusterRestorePhasePending
+		restore.Status.Message = message
+		if err := r.Status().Update(ctx, restore); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: clusterWaitInterval}, nil
+}
+
+// fail records that the restore failed with err
+func (r *ClusterRestoreReconciler) fail(ctx context.Context, restore *clusterv1alpha1.ClusterRestore, err error) error {
+	now := metav1.Now()
+	r.event(restore, corev1.EventTypeWarning, ReasonRestoreFailed, "Failed to restore cluster %s: %v", restore.Spec.ClusterName, err)
+	restore.Status.Phase = clusterv1alpha1.ClusterRestorePhaseFailed
+	restore.Status.Message = fmt.Sprintf("Failed to restore: %v", err)
+	restore.Status.CompletionTime = &now
+	return r.Status().Update(ctx, restore)
+}
+
+// event emits an event about the restore if the reconciler has a recorder
+func (r *ClusterRestoreReconciler) event(restore *clusterv1alpha1.ClusterRestore, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(restore, eventType, reason, messageFmt, args...)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&clusterv1alpha1.ClusterRestore{}).
+		Complete(r)
+}
+
+// findSnapshot returns the named snapshot of the backup, or its newest one if
+// name is empty. It returns nil if there is no such snapshot.
+func findSnapshot(backup *clusterv1alpha1.ClusterBackup, name string) *clusterv1alpha1.BackupSnapshot {
+	snapshots := backup.Status.Snapshots
+	if name == "" {
+		if len(snapshots) == 0 {
+			return nil
+		}
+		return &snapshots[len(snapshots)-1]
+	}
+	for i := range snapshots {
+		if snapshots[i].Name == name {
+			return &snapshots[i]
+		}
+	}
+	return nil
+}
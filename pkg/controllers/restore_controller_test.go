@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestClusterRestore(t *testing.T) {
+	client := newBackupTestClient(t)
+
+	var restored []string
+	var restoreErr error
+	mockProvider := &providers.MockProvider{
+		RestoreEtcdFunc: func(ctx context.Context, c *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error {
+			restored = append(restored, location.HostPath+"/"+name)
+			return restoreErr
+		},
+	}
+	recorder := record.NewFakeRecorder(100)
+	reconciler := &ClusterRestoreReconciler{
+		Client:   client,
+		Scheme:   client.Scheme(),
+		Provider: mockProvider,
+		Recorder: recorder,
+	}
+
+	restore := &v1alpha1.ClusterRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-restore", Namespace: "default"},
+		Spec:       v1alpha1.ClusterRestoreSpec{ClusterName: "dev", BackupName: "dev-daily"},
+	}
+	if err := client.Create(context.Background(), restore); err != nil {
+		t.Fatalf("Failed to create restore: %v", err)
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Failed to reconcile restore: %v", err)
+		}
+		if err := client.Get(context.Background(), req.NamespacedName, restore); err != nil {
+			t.Fatalf("Failed to get restore: %v", err)
+		}
+	}
+
+	// The restore waits for its backup
+	reconcile()
+	if restore.Status.Phase != v1alpha1.ClusterRestorePhasePending || len(restored) != 0 {
+		t.Fatalf("Expected the restore to wait for the backup, got phase %s", restore.Status.Phase)
+	}
+
+	backup := &v1alpha1.ClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-daily", Namespace: "default"},
+		Spec:       v1alpha1.ClusterBackupSpec{ClusterName: "dev"},
+	}
+	if err := client.Create(context.Background(), backup); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	backup.Status.Snapshots = []v1alpha1.BackupSnapshot{
+		{Name: "etcd-1.db", Location: v1alpha1.BackupLocation{HostPath: "/old"}},
+		{Name: "etcd-2.db", Location: v1alpha1.BackupLocation{HostPath: "/backups"}},
+	}
+	if err := client.Status().Update(context.Background(), backup); err != nil {
+		t.Fatalf("Failed to update backup: %v", err)
+	}
+
+	// The newest snapshot is restored from where it was stored
+	reconcile()
+	if len(restored) != 1 || restored[0] != "/backups/etcd-2.db" {
+		t.Fatalf("Expected the newest snapshot to be restored, got %v", restored)
+	}
+	if restore.Status.Phase != v1alpha1.ClusterRestorePhaseCompleted || restore.Status.Snapshot != "etcd-2.db" ||
+		restore.Status.CompletionTime == nil {
+		t.Errorf("Expected the restore of etcd-2.db to be completed, got %+v", restore.Status)
+	}
+	expectEvents(t, recorder, ReasonRestoring, ReasonRestored)
+
+	// A completed restore is not run again
+	reconcile()
+	if len(restored) != 1 {
+		t.Errorf("Expected a single restore, got %v", restored)
+	}
+
+	// A failed restore of a named snapshot is not retried
+	restoreErr = errors.New("etcd did not start")
+	failing := &v1alpha1.ClusterRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-restore-old", Namespace: "default"},
+		Spec:       v1alpha1.ClusterRestoreSpec{ClusterName: "dev", BackupName: "dev-daily", Snapshot: "etcd-1.db"},
+	}
+	if err := client.Create(context.Background(), failing); err != nil {
+		t.Fatalf("Failed to create restore: %v", err)
+	}
+	req.Name = failing.Name
+	reconcile()
+	reconcile()
+	if len(restored) != 2 || restored[1] != "/old/etcd-1.db" {
+		t.Errorf("Expected a single attempt to restore etcd-1.db, got %v", restored)
+	}
+	if restore.Status.Phase != v1alpha1.ClusterRestorePhaseFailed {
+		t.Errorf("Expected phase Failed, got %s", restore.Status.Phase)
+	}
+	expectEvents(t, recorder, ReasonRestoreFailed)
+}
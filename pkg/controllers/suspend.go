@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// suspendedCheckInterval is how often the nodes of a suspended cluster are
+// checked to still be stopped
+const suspendedCheckInterval = 5 * time.Minute
+
+// suspendable reports whether the cluster can be suspended now. Clusters
+// are not suspended while nodes are being replaced for an upgrade or its
+// rollback; they are once it is over.
+func suspendable(cluster *clusterv1alpha1.Cluster) bool {
+	switch cluster.Status.Phase {
+	case clusterv1alpha1.ClusterPhaseRunning:
+		return true
+	case clusterv1alpha1.ClusterPhaseUpdating:
+		upgrade := cluster.Status.Upgrade
+		return upgrade == nil || (upgrade.Phase != clusterv1alpha1.UpgradePhaseInProgress &&
+			upgrade.Phase != clusterv1alpha1.UpgradePhaseRollingBack)
+	default:
+		return false
+	}
+}
+
+// handleSuspension stops the nodes of a cluster whose spec asks for it to be
+// suspended. Spec changes that have not been rolled out yet are rolled out
+// once the cluster resumes.
+func (r *ClusterReconciler) handleSuspension(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Suspending cluster", "name", cluster.Name)
+
+	suspender, ok := r.Provider.(providers.Suspender)
+	if !ok {
+		log.Info("Provider cannot suspend clusters")
+		if cluster.Status.Message != "The provider cannot suspend clusters" {
+			r.event(cluster, corev1.EventTypeWarning, ReasonSuspendFailed, "The provider cannot suspend clusters")
+			cluster.Status.Message = "The provider cannot suspend clusters"
+			if err := r.Status().Update(ctx, cluster); err != nil {
+				log.Error(err, "Failed to update status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: suspendedCheckInterval}, nil
+	}
+
+	r.event(cluster, corev1.EventTypeNormal, ReasonSuspending, "Stopping all nodes")
+	if err := suspender.SuspendCluster(ctx, cluster); err != nil {
+		log.Error(err, "Failed to suspend cluster")
+		r.event(cluster, corev1.EventTypeWarning, ReasonSuspendFailed, "Failed to suspend cluster: %v", err)
+		cluster.Status.Message = fmt.Sprintf("Failed to suspend cluster: %v", err)
+		if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+			log.Error(updateErr, "Failed to update status")
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	r.event(cluster, corev1.EventTypeNormal, ReasonSuspended, "Cluster suspended")
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseSuspended
+	cluster.Status.ControlPlaneReady = false
+	cluster.Status.WorkersReady = 0
+	cluster.Status.Message = "Cluster suspended"
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update status to Suspended")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: suspendedCheckInterval}, nil
+}
+
+// handleSuspendedPhase keeps a suspended cluster's nodes stopped, and starts
+// them again once the spec no longer asks for the cluster to be suspended
+func (r *ClusterReconciler) handleSuspendedPhase(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Handling suspended phase", "name", cluster.Name)
+
+	suspender, ok := r.Provider.(providers.Suspender)
+	if !ok {
+		log.Info("Provider cannot suspend or resume clusters")
+		return ctrl.Result{}, nil
+	}
+
+	// Stop nodes that were started behind the manager's back
+	if cluster.Spec.Suspended {
+		if err := suspender.SuspendCluster(ctx, cluster); err != nil {
+			log.Error(err, "Failed to keep cluster suspended")
+			r.event(cluster, corev1.EventTypeWarning, ReasonSuspendFailed, "Failed to keep cluster suspended: %v", err)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: suspendedCheckInterval}, nil
+	}
+
+	r.event(cluster, corev1.EventTypeNormal, ReasonResuming, "Starting control plane nodes, then workers")
+	if err := suspender.ResumeCluster(ctx, cluster); err != nil {
+		log.Error(err, "Failed to resume cluster")
+		r.event(cluster, corev1.EventTypeWarning, ReasonResumeFailed, "Failed to resume cluster: %v", err)
+		cluster.Status.Message = fmt.Sprintf("Failed to resume cluster: %v", err)
+		if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+			log.Error(updateErr, "Failed to update status")
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	// The running phase rolls out what changed while the cluster was suspended
+	r.event(cluster, corev1.EventTypeNormal, ReasonResumed, "Cluster resumed")
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseRunning
+	cluster.Status.Message = ""
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update status to Running")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/metrics"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// rolloutPending reports whether the spec of a running cluster changed since
+// it was last applied. Clusters that reached Running before the applied
+// generation was recorded were built from the spec they have: the current
+// generation is recorded as applied instead of rolling them out again.
+func rolloutPending(cluster *clusterv1alpha1.Cluster) bool {
+	if cluster.Status.ObservedGeneration == 0 {
+		cluster.Status.ObservedGeneration = cluster.Generation
+		return false
+	}
+	return cluster.Generation != cluster.Status.ObservedGeneration
+}
+
+// startRollout moves a running cluster whose spec changed to the Updating
+// phase, which applies the change
+func (r *ClusterReconciler) startRollout(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseUpdating
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type:               clusterv1alpha1.ClusterConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Updating",
+		Message:            "Rolling out spec changes",
+		ObservedGeneration: cluster.Generation,
+	})
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update status to Updating")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+func (r *ClusterReconciler) handleUpdatingPhase(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Handling updating phase", "name", cluster.Name)
+
+	// A failed upgrade of the current spec is rolled back before anything else
+	if upgrade := cluster.Status.Upgrade; upgrade != nil && upgrade.Generation == cluster.Generation {
+		switch {
+		case upgrade.Phase == clusterv1alpha1.UpgradePhaseRollingBack:
+			return r.rollbackUpgrade(ctx, cluster)
+		case upgrade.Phase == clusterv1alpha1.UpgradePhaseFailed && rollbackRequested(cluster):
+			return r.rollbackUpgrade(ctx, cluster)
+		case upgrade.Phase == clusterv1alpha1.UpgradePhaseFailed:
+			log.Info("Upgrade failed, waiting for a rollback to be requested", "annotation", clusterv1alpha1.RollbackAnnotation)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// Clusters that reached Running before the version of their nodes was
+	// recorded are asked for it, so their upgrades are recorded too
+	if cluster.Status.KubernetesVersion == "" {
+		cluster.Status.KubernetesVersion = r.runningVersion(ctx, cluster)
+	}
+
+	// Only start upgrades the version catalog supports. A rejected upgrade
+	// waits in the Updating phase for the spec to change again.
+	from, to := cluster.Status.KubernetesVersion, cluster.Spec.KubernetesVersion
+	upgrading := from != "" && from != to
+	if upgrading && r.Versions != nil {
+		if err := r.Versions.ValidateUpgrade(from, to); err != nil {
+			log.Error(err, "Rejected Kubernetes upgrade", "from", from, "to", to)
+			r.event(cluster, corev1.EventTypeWarning, ReasonUpgradeRejected, "Cannot upgrade from %s to %s: %v", from, to, err)
+			cluster.Status.Message = fmt.Sprintf("Cannot upgrade from %s to %s: %v", from, to, err)
+			if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+				log.Error(updateErr, "Failed to update status")
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+
+	ctx, err := r.withRegistryCredentials(ctx, cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Record the upgrade, and save etcd, before the first node is replaced
+	if upgrading {
+		if upgrade := cluster.Status.Upgrade; upgrade == nil || upgrade.Generation != cluster.Generation {
+			if err := r.startUpgrade(ctx, cluster); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		ctx = providers.WithNodeUpgradeReporter(ctx, r.nodeUpgradeReporter(ctx, cluster))
+	}
+
+	// Apply the changed spec through the cluster operations
+	scaling := r.scalingPending(ctx, cluster)
+	if upgrading {
+		r.event(cluster, corev1.EventTypeNormal, ReasonUpgrading, "Upgrading Kubernetes from %s to %s", from, to)
+	}
+	if scaling {
+		r.event(cluster, corev1.EventTypeNormal, ReasonScaling, "Scaling to %d control plane and %d worker nodes",
+			cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count)
+	}
+	start := time.Now()
+	if err := r.applySpec(ctx, cluster, upgrading, scaling); err != nil {
+		log.Error(err, "Failed to update cluster")
+		if upgrading {
+			return r.failUpgrade(ctx, cluster, err)
+		}
+		reason := ReasonUpdateFailed
+		if scaling {
+			reason = ReasonScalingFailed
+		}
+		r.event(cluster, corev1.EventTypeWarning, reason, "Failed to update cluster: %v", err)
+		cluster.Status.Message = fmt.Sprintf("Failed to update cluster: %v", err)
+		if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+			log.Error(updateErr, "Failed to update status")
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Update status to Running
+	if upgrading {
+		metrics.UpgradeDuration.Observe(time.Since(start).Seconds())
+		r.event(cluster, corev1.EventTypeNormal, ReasonUpgraded, "Upgraded Kubernetes to %s", to)
+		now := metav1.Now()
+		cluster.Status.Upgrade.Phase = clusterv1alpha1.UpgradePhaseSucceeded
+		cluster.Status.Upgrade.CompletionTime = &now
+	}
+	if scaling {
+		r.event(cluster, corev1.EventTypeNormal, ReasonScaled, "Cluster now has %d control plane and %d worker nodes",
+			cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count)
+	}
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseRunning
+	cluster.Status.ObservedGeneration = cluster.Generation
+	cluster.Status.KubernetesVersion = to
+	cluster.Status.Message = ""
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update status to Running")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// applySpec rolls the spec of cluster out through the cluster operations: as
+// an upgrade if it changes the Kubernetes version, as scaling if it changes
+// the node counts, and as an update of the nodes otherwise. Upgrades and
+// scaling apply the rest of the spec along with them.
+func (r *ClusterReconciler) applySpec(ctx context.Context, cluster *clusterv1alpha1.Cluster, upgrading, scaling bool) error {
+	ops := r.operations()
+	switch {
+	case upgrading:
+		return ops.Upgrade(ctx, cluster, cluster.Spec.KubernetesVersion)
+	case scaling:
+		return ops.Scale(ctx, cluster, cluster.Spec.ControlPlane.Count, cluster.Spec.Workers.Count)
+	default:
+		return ops.Update(ctx, cluster)
+	}
+}
+
+// scalingPending reports whether the node counts of the spec differ from
+// those the provider observes. A cluster whose nodes cannot be counted is
+// not reported as scaling.
+func (r *ClusterReconciler) scalingPending(ctx context.Context, cluster *clusterv1alpha1.Cluster) bool {
+	status, err := r.Provider.GetClusterStatus(ctx, cluster)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to get the nodes the cluster runs")
+		return false
+	}
+	if status == nil {
+		return false
+	}
+	return !status.ControlPlaneReady || status.WorkersReady != cluster.Spec.Workers.Count
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// startUpgrade records the upgrade of the cluster to the version in its spec.
+// If the provider can, etcd is saved first; no node is touched unless that
+// succeeds.
+func (r *ClusterReconciler) startUpgrade(ctx context.Context, cluster *clusterv1alpha1.Cluster) error {
+	log := log.FromContext(ctx)
+	from, to := cluster.Status.KubernetesVersion, cluster.Spec.KubernetesVersion
+
+	upgrade := &clusterv1alpha1.UpgradeStatus{
+		FromVersion: from,
+		ToVersion:   to,
+		Generation:  cluster.Generation,
+		Phase:       clusterv1alpha1.UpgradePhaseInProgress,
+		StartTime:   metav1.Now(),
+	}
+	cluster.Spec.DeepCopyInto(&upgrade.PreviousSpec)
+	upgrade.PreviousSpec.KubernetesVersion = from
+
+	// The nodes of a failed upgrade that was not rolled back are mixed; a
+	// rollback of this upgrade still has to return to where that one started
+	if failed := cluster.Status.Upgrade; failed != nil && failed.Phase == clusterv1alpha1.UpgradePhaseFailed {
+		failed.PreviousSpec.DeepCopyInto(&upgrade.PreviousSpec)
+		upgrade.Nodes = append(upgrade.Nodes, failed.Nodes...)
+		upgrade.EtcdSnapshot = failed.EtcdSnapshot
+	}
+
+	if snapshotter, ok := r.Provider.(providers.EtcdSnapshotter); ok && upgrade.EtcdSnapshot == "" {
+		path, err := snapshotter.SnapshotEtcd(ctx, cluster)
+		if err != nil {
+			log.Error(err, "Failed to take etcd snapshot before upgrade")
+			r.event(cluster, corev1.EventTypeWarning, ReasonEtcdSnapshotFailed, "Not upgrading, failed to take etcd snapshot: %v", err)
+			cluster.Status.Message = fmt.Sprintf("Not upgrading, failed to take etcd snapshot: %v", err)
+			if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+				log.Error(updateErr, "Failed to update status")
+			}
+			return err
+		}
+		if path == "" {
+			log.Info("Cluster has no etcd to snapshot before upgrade")
+		}
+		upgrade.EtcdSnapshot = path
+	}
+
+	cluster.Status.Upgrade = upgrade
+	cluster.Status.Message = fmt.Sprintf("Upgrading from %s to %s", from, to)
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to record upgrade")
+		return err
+	}
+	return nil
+}
+
+// runningVersion asks the provider which Kubernetes version the nodes of the
+// cluster run, returning "" if it cannot tell
+func (r *ClusterReconciler) runningVersion(ctx context.Context, cluster *clusterv1alpha1.Cluster) string {
+	status, err := r.Provider.GetClusterStatus(ctx, cluster)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to get the version the cluster runs")
+		return ""
+	}
+	if status == nil {
+		return ""
+	}
+	return status.KubernetesVersion
+}
+
+// failUpgrade records that the upgrade failed with err and rolls it back
+// unless the cluster's rollback policy is Manual
+func (r *ClusterReconciler) failUpgrade(ctx context.Context, cluster *clusterv1alpha1.Cluster, err error) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	upgrade := cluster.Status.Upgrade
+	upgrade.Phase = clusterv1alpha1.UpgradePhaseFailed
+	upgrade.Message = err.Error()
+	r.event(cluster, corev1.EventTypeWarning, ReasonUpgradeFailed, "Upgrade from %s to %s failed: %v", upgrade.FromVersion, upgrade.ToVersion, err)
+	cluster.Status.Message = fmt.Sprintf("Upgrade to %s failed: %v", upgrade.ToVersion, err)
+	if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+		log.Error(updateErr, "Failed to update status")
+		return ctrl.Result{}, updateErr
+	}
+
+	if !rollbackRequested(cluster) {
+		log.Info("Not rolling back failed upgrade until requested", "annotation", clusterv1alpha1.RollbackAnnotation)
+		return ctrl.Result{}, nil
+	}
+	return r.rollbackUpgrade(ctx, cluster)
+}
+
+// rollbackUpgrade recreates the nodes of a failed upgrade from their previous
+// image. Once done the cluster is Running again on the previous version, and
+// the spec counts as handled until it changes again.
+func (r *ClusterReconciler) rollbackUpgrade(ctx context.Context, cluster *clusterv1alpha1.Cluster) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	rollbacker, ok := r.Provider.(providers.Rollbacker)
+	if !ok {
+		log.Info("Provider cannot roll back upgrades")
+		r.event(cluster, corev1.EventTypeWarning, ReasonRollbackFailed, "The provider cannot roll back upgrades")
+		return ctrl.Result{}, nil
+	}
+
+	if cluster.Status.Upgrade.Phase != clusterv1alpha1.UpgradePhaseRollingBack {
+		cluster.Status.Upgrade.Phase = clusterv1alpha1.UpgradePhaseRollingBack
+		r.event(cluster, corev1.EventTypeNormal, ReasonRollingBack, "Rolling back upgrade from %s to %s",
+			cluster.Status.Upgrade.FromVersion, cluster.Status.Upgrade.ToVersion)
+		if err := r.Status().Update(ctx, cluster); err != nil {
+			log.Error(err, "Failed to update status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	ctx, err := r.withRegistryCredentials(ctx, cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	ctx = providers.WithNodeUpgradeReporter(ctx, r.nodeUpgradeReporter(ctx, cluster))
+	if err := rollbacker.RollbackUpgrade(ctx, cluster, cluster.Status.Upgrade.DeepCopy()); err != nil {
+		log.Error(err, "Failed to roll back upgrade")
+		r.event(cluster, corev1.EventTypeWarning, ReasonRollbackFailed, "Failed to roll back upgrade: %v", err)
+		cluster.Status.Upgrade.Message = fmt.Sprintf("Failed to roll back: %v", err)
+		if updateErr := r.Status().Update(ctx, cluster); updateErr != nil {
+			log.Error(updateErr, "Failed to update status")
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	// The request has been served
+	if _, ok := cluster.Annotations[clusterv1alpha1.RollbackAnnotation]; ok {
+		status := cluster.Status
+		delete(cluster.Annotations, clusterv1alpha1.RollbackAnnotation)
+		if err := r.Update(ctx, cluster); err != nil {
+			log.Error(err, "Failed to remove rollback annotation")
+			return ctrl.Result{}, err
+		}
+		cluster.Status = status
+	}
+
+	upgrade := cluster.Status.Upgrade
+	now := metav1.Now()
+	upgrade.Phase = clusterv1alpha1.UpgradePhaseRolledBack
+	upgrade.CompletionTime = &now
+	r.event(cluster, corev1.EventTypeNormal, ReasonRolledBack, "Rolled back upgrade to %s, the cluster runs %s again", upgrade.ToVersion, upgrade.FromVersion)
+	cluster.Status.Phase = clusterv1alpha1.ClusterPhaseRunning
+	cluster.Status.ObservedGeneration = cluster.Generation
+	cluster.Status.KubernetesVersion = upgrade.FromVersion
+	cluster.Status.Message = fmt.Sprintf("Upgrade to %s was rolled back, the cluster runs %s", upgrade.ToVersion, upgrade.FromVersion)
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		log.Error(err, "Failed to update status to Running")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// rollbackRequested reports whether a failed upgrade of the cluster is to be
+// rolled back now: always under the Automatic policy, on request under Manual
+func rollbackRequested(cluster *clusterv1alpha1.Cluster) bool {
+	if cluster.Spec.RollbackPolicy != clusterv1alpha1.RollbackPolicyManual {
+		return true
+	}
+	_, ok := cluster.Annotations[clusterv1alpha1.RollbackAnnotation]
+	return ok
+}
+
+// nodeUpgradeReporter returns a reporter that records the state of the nodes
+// the provider replaces in the upgrade status of cluster
+func (r *ClusterReconciler) nodeUpgradeReporter(ctx context.Context, cluster *clusterv1alpha1.Cluster) providers.NodeUpgradeReporter {
+	var mu sync.Mutex
+	return func(node clusterv1alpha1.NodeUpgradeStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cluster.Status.Upgrade == nil {
+			return
+		}
+
+		patch := client.MergeFrom(cluster.DeepCopy())
+		setNodeUpgrade(cluster.Status.Upgrade, node)
+		if err := r.Status().Patch(ctx, cluster, patch); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to record node upgrade state", "node", node.Name)
+		}
+	}
+}
+
+// setNodeUpgrade adds or replaces the entry for node in upgrade. A node
+// replaced more than once keeps the image it ran before the first time.
+func setNodeUpgrade(upgrade *clusterv1alpha1.UpgradeStatus, node clusterv1alpha1.NodeUpgradeStatus) {
+	for i := range upgrade.Nodes {
+		if upgrade.Nodes[i].Name != node.Name {
+			continue
+		}
+		if upgrade.Nodes[i].PreviousImage != "" {
+			node.PreviousImage = upgrade.Nodes[i].PreviousImage
+		}
+		upgrade.Nodes[i] = node
+		return
+	}
+	upgrade.Nodes = append(upgrade.Nodes, node)
+}
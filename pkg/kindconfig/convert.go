@@ -0,0 +1,359 @@
+package kindconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	clusterops "github.com/unmeshjoshi/mini-k8s-manager/pkg/cluster"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+// Parse reads a kind cluster configuration in YAML or JSON form. Fields kind
+// does not know are an error, as they are for kind.
+func Parse(data []byte) (*Config, error) {
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kind config: %w", err)
+	}
+	if config.APIVersion != APIVersion || config.Kind != Kind {
+		return nil, fmt.Errorf("kind config is a %s %s, not a %s %s", config.APIVersion, config.Kind, APIVersion, Kind)
+	}
+	return &config, nil
+}
+
+// LoadFile reads a kind cluster configuration from a file
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kind config: %w", err)
+	}
+	return Parse(data)
+}
+
+// Marshal writes a kind cluster configuration as YAML
+func Marshal(config *Config) ([]byte, error) {
+	out := *config
+	out.APIVersion, out.Kind = APIVersion, Kind
+	return yaml.Marshal(&out)
+}
+
+// ToCluster converts a kind cluster configuration to a Cluster named after
+// it, or DefaultName as kind does. The Kubernetes version and node image come from the image of the
+// nodes; kubernetesVersion overrides the version, and is required if the
+// nodes name no image tag. The extra port mappings and mounts of the first
+// node of each role apply to the whole node pool. What a Cluster cannot
+// express is returned as warnings.
+func ToCluster(config *Config, kubernetesVersion string) (*v1alpha1.Cluster, []string, error) {
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	cluster := &v1alpha1.Cluster{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.GroupVersion.String(),
+			Kind:       "Cluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: config.Name},
+	}
+	if cluster.Name == "" {
+		cluster.Name = DefaultName
+	}
+	spec := &cluster.Spec
+
+	// kind creates a single control plane node unless told otherwise
+	nodes := config.Nodes
+	if len(nodes) == 0 {
+		nodes = []Node{{Role: RoleControlPlane}}
+	}
+
+	var image string
+	seen := map[string]bool{}
+	for i, node := range nodes {
+		var extras *v1alpha1.NodeExtras
+		switch node.Role {
+		case RoleControlPlane, "":
+			spec.ControlPlane.Count++
+			extras = &spec.ControlPlane.NodeExtras
+		case RoleWorker:
+			spec.Workers.Count++
+			extras = &spec.Workers.NodeExtras
+		default:
+			return nil, nil, fmt.Errorf("node %d has unknown role %q", i, node.Role)
+		}
+		role := node.Role
+		if role == "" {
+			role = RoleControlPlane
+		}
+
+		if node.Image != "" {
+			if image == "" {
+				image = node.Image
+			} else if node.Image != image {
+				warn("node %d runs image %s, all nodes will run %s", i, node.Image, image)
+			}
+		}
+
+		nodeExtras := toNodeExtras(node)
+		if !seen[role] {
+			seen[role] = true
+			*extras = nodeExtras
+		} else if !reflect.DeepEqual(nodeExtras, *extras) {
+			warn("extraPortMappings and extraMounts of node %d differ from those of the first %s node, which apply to all %s nodes", i, role, role)
+		}
+
+		if len(node.Labels) > 0 {
+			warn("labels of node %d are not supported", i)
+		}
+		spec.KubeadmConfigPatches = appendMissing(spec.KubeadmConfigPatches, node.KubeadmConfigPatches...)
+		if len(node.KubeadmConfigPatchesJSON6902) > 0 {
+			warn("kubeadmConfigPatchesJSON6902 of node %d are not supported", i)
+		}
+	}
+
+	for _, pool := range []struct {
+		role   string
+		count  int32
+		extras v1alpha1.NodeExtras
+	}{
+		{RoleControlPlane, spec.ControlPlane.Count, spec.ControlPlane.NodeExtras},
+		{RoleWorker, spec.Workers.Count, spec.Workers.NodeExtras},
+	} {
+		if pool.count < 2 {
+			continue
+		}
+		for _, mapping := range pool.extras.ExtraPortMappings {
+			if mapping.HostPort > 0 {
+				warn("host port %d can only be bound by one of the %d %s nodes", mapping.HostPort, pool.count, pool.role)
+			}
+		}
+	}
+	if err := clusterops.ValidateScale(spec.ControlPlane.Count, spec.Workers.Count); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+
+	repository, tag, digest := splitImage(image)
+	switch {
+	case kubernetesVersion != "":
+		if tag != "" && tag != kubernetesVersion {
+			warn("node image %s is replaced by Kubernetes %s", image, kubernetesVersion)
+			digest = ""
+		}
+		spec.KubernetesVersion = kubernetesVersion
+	case tag != "":
+		spec.KubernetesVersion = tag
+	default:
+		return nil, nil, fmt.Errorf("nodes name no image tag, a Kubernetes version is required")
+	}
+	if (repository != "" && repository != providers.DefaultNodeImageRepository) || digest != "" {
+		spec.NodeImage = &v1alpha1.NodeImageSpec{Digest: digest}
+		if repository != providers.DefaultNodeImageRepository {
+			spec.NodeImage.Repository = repository
+		}
+	}
+
+	if config.Networking != nil {
+		if networking := toNetworking(*config.Networking); networking != (v1alpha1.NetworkingSpec{}) {
+			spec.Networking = &networking
+		}
+		if config.Networking.DNSSearch != nil {
+			warn("networking.dnsSearch is not supported")
+		}
+	}
+	spec.KubeadmConfigPatches = appendMissing(append([]string(nil), config.KubeadmConfigPatches...), spec.KubeadmConfigPatches...)
+	// The manager does not bootstrap nodes with kubeadm, so both are only
+	// kept for exporting the cluster to kind again
+	if spec.Networking != nil {
+		warn("networking is not supported, it is kept in the Cluster but not applied to its nodes")
+	}
+	if len(spec.KubeadmConfigPatches) > 0 {
+		warn("kubeadmConfigPatches are not supported, they are kept in the Cluster but not applied to its nodes")
+	}
+
+	if len(config.FeatureGates) > 0 {
+		warn("featureGates are not supported")
+	}
+	if len(config.RuntimeConfig) > 0 {
+		warn("runtimeConfig is not supported")
+	}
+	if len(config.KubeadmConfigPatchesJSON6902) > 0 {
+		warn("kubeadmConfigPatchesJSON6902 are not supported")
+	}
+	if len(config.ContainerdConfigPatches) > 0 || len(config.ContainerdConfigPatchesJSON6902) > 0 {
+		warn("containerdConfigPatches are not supported")
+	}
+	return cluster, warnings, nil
+}
+
+// FromCluster converts a Cluster to a kind cluster configuration with a node
+// for each of its nodes. What kind cannot express is returned as warnings.
+func FromCluster(cluster *v1alpha1.Cluster) (*Config, []string, error) {
+	spec := &cluster.Spec
+	if spec.KubernetesVersion == "" {
+		return nil, nil, fmt.Errorf("cluster %s has no Kubernetes version", cluster.Name)
+	}
+
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	repository := providers.DefaultNodeImageRepository
+	image := spec.KubernetesVersion
+	if spec.NodeImage != nil {
+		if spec.NodeImage.Repository != "" {
+			repository = spec.NodeImage.Repository
+		}
+		if spec.NodeImage.Digest != "" {
+			image += "@" + spec.NodeImage.Digest
+		}
+		if spec.NodeImage.PullSecretRef != nil {
+			warn("nodeImage.pullSecretRef is not supported, log in to the registry before creating the cluster")
+		}
+	}
+	image = repository + ":" + image
+
+	config := &Config{
+		APIVersion:           APIVersion,
+		Kind:                 Kind,
+		Name:                 cluster.Name,
+		KubeadmConfigPatches: append([]string(nil), spec.KubeadmConfigPatches...),
+	}
+	for i := int32(0); i < spec.ControlPlane.Count; i++ {
+		config.Nodes = append(config.Nodes, fromNodeExtras(RoleControlPlane, image, spec.ControlPlane.NodeExtras))
+	}
+	for i := int32(0); i < spec.Workers.Count; i++ {
+		config.Nodes = append(config.Nodes, fromNodeExtras(RoleWorker, image, spec.Workers.NodeExtras))
+	}
+	if spec.Networking != nil {
+		networking := fromNetworking(*spec.Networking)
+		config.Networking = &networking
+	}
+
+	if spec.ControlPlane.MachineConfig != (v1alpha1.MachineConfig{}) || spec.Workers.MachineConfig != (v1alpha1.MachineConfig{}) {
+		warn("machineConfig is not supported, kind nodes are not limited in memory and CPUs")
+	}
+	if spec.Workers.DrainTimeout != nil {
+		warn("workers.drainTimeout is not supported")
+	}
+	if spec.Workers.RolloutStrategy != nil {
+		warn("workers.rolloutStrategy is not supported")
+	}
+	if spec.ImagePullPolicy != "" {
+		warn("imagePullPolicy is not supported")
+	}
+	if spec.RollbackPolicy != "" {
+		warn("rollbackPolicy is not supported")
+	}
+	if spec.HealthCheck != nil {
+		warn("healthCheck is not supported")
+	}
+	if spec.Suspended {
+		warn("suspended is not supported, the kind cluster will run")
+	}
+	if spec.CloneFrom != nil {
+		warn("cloneFrom is not supported, the kind cluster will start empty")
+	}
+	return config, warnings, nil
+}
+
+// toNodeExtras returns the extra port mappings and mounts of a kind node
+func toNodeExtras(node Node) v1alpha1.NodeExtras {
+	var extras v1alpha1.NodeExtras
+	for _, m := range node.ExtraPortMappings {
+		extras.ExtraPortMappings = append(extras.ExtraPortMappings, v1alpha1.PortMapping{
+			ContainerPort: m.ContainerPort,
+			HostPort:      m.HostPort,
+			ListenAddress: m.ListenAddress,
+			Protocol:      v1alpha1.PortProtocol(m.Protocol),
+		})
+	}
+	for _, m := range node.ExtraMounts {
+		extras.ExtraMounts = append(extras.ExtraMounts, v1alpha1.Mount{
+			HostPath:       m.HostPath,
+			ContainerPath:  m.ContainerPath,
+			ReadOnly:       m.ReadOnly,
+			SelinuxRelabel: m.SelinuxRelabel,
+			Propagation:    v1alpha1.MountPropagation(m.Propagation),
+		})
+	}
+	return extras
+}
+
+// fromNodeExtras returns a kind node of role running image with extras
+func fromNodeExtras(role, image string, extras v1alpha1.NodeExtras) Node {
+	node := Node{Role: role, Image: image}
+	for _, m := range extras.ExtraPortMappings {
+		node.ExtraPortMappings = append(node.ExtraPortMappings, PortMapping{
+			ContainerPort: m.ContainerPort,
+			HostPort:      m.HostPort,
+			ListenAddress: m.ListenAddress,
+			Protocol:      string(m.Protocol),
+		})
+	}
+	for _, m := range extras.ExtraMounts {
+		node.ExtraMounts = append(node.ExtraMounts, Mount{
+			HostPath:       m.HostPath,
+			ContainerPath:  m.ContainerPath,
+			ReadOnly:       m.ReadOnly,
+			SelinuxRelabel: m.SelinuxRelabel,
+			Propagation:    string(m.Propagation),
+		})
+	}
+	return node
+}
+
+// toNetworking returns the networking of a Cluster for that of a kind config
+func toNetworking(n Networking) v1alpha1.NetworkingSpec {
+	return v1alpha1.NetworkingSpec{
+		IPFamily:          v1alpha1.IPFamily(n.IPFamily),
+		APIServerAddress:  n.APIServerAddress,
+		APIServerPort:     n.APIServerPort,
+		PodSubnet:         n.PodSubnet,
+		ServiceSubnet:     n.ServiceSubnet,
+		DisableDefaultCNI: n.DisableDefaultCNI,
+		KubeProxyMode:     n.KubeProxyMode,
+	}
+}
+
+// fromNetworking returns the networking of a kind config for that of a Cluster
+func fromNetworking(n v1alpha1.NetworkingSpec) Networking {
+	return Networking{
+		IPFamily:          string(n.IPFamily),
+		APIServerAddress:  n.APIServerAddress,
+		APIServerPort:     n.APIServerPort,
+		PodSubnet:         n.PodSubnet,
+		ServiceSubnet:     n.ServiceSubnet,
+		DisableDefaultCNI: n.DisableDefaultCNI,
+		KubeProxyMode:     n.KubeProxyMode,
+	}
+}
+
+// splitImage splits an image reference such as kindest/node:v1.31.0@sha256:...
+// into its repository, tag and digest
+func splitImage(ref string) (repository, tag, digest string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref, digest = ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		ref, tag = ref[:i], ref[i+1:]
+	}
+	return ref, tag, digest
+}
+
+// appendMissing appends the patches to list that it does not hold yet
+func appendMissing(list []string, patches ...string) []string {
+	for _, patch := range patches {
+		if !slices.Contains(list, patch) {
+			list = append(list, patch)
+		}
+	}
+	return list
+}
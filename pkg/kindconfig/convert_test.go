@@ -0,0 +1,252 @@
+package kindconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+const kindConfig = `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+name: dev
+networking:
+  podSubnet: 10.244.0.0/16
+  kubeProxyMode: ipvs
+kubeadmConfigPatches:
+- |
+  kind: ClusterConfiguration
+  apiServer:
+    extraArgs:
+      enable-admission-plugins: NodeRestriction
+nodes:
+- role: control-plane
+  image: kindest/node:v1.31.0@` + digest + `
+  extraPortMappings:
+  - containerPort: 80
+    hostPort: 8080
+    protocol: TCP
+  - containerPort: 443
+    listenAddress: 127.0.0.1
+  kubeadmConfigPatches:
+  - |
+    kind: InitConfiguration
+    nodeRegistration:
+      kubeletExtraArgs:
+        node-labels: ingress-ready=true
+- role: worker
+  image: kindest/node:v1.31.0@` + digest + `
+  extraMounts:
+  - hostPath: /srv/data
+    containerPath: /data
+    readOnly: true
+    propagation: HostToContainer
+- role: worker
+  image: kindest/node:v1.31.0@` + digest + `
+  extraMounts:
+  - hostPath: /srv/data
+    containerPath: /data
+    readOnly: true
+    propagation: HostToContainer
+`
+
+func TestToCluster(t *testing.T) {
+	config, err := Parse([]byte(kindConfig))
+	if err != nil {
+		t.Fatalf("Failed to parse kind config: %v", err)
+	}
+	cluster, warnings, err := ToCluster(config, "")
+	if err != nil {
+		t.Fatalf("Failed to convert kind config: %v", err)
+	}
+
+	spec := cluster.Spec
+	if cluster.Name != "dev" || cluster.Kind != "Cluster" || cluster.APIVersion != v1alpha1.GroupVersion.String() {
+		t.Errorf("Expected a Cluster named dev, got %s %s %s", cluster.APIVersion, cluster.Kind, cluster.Name)
+	}
+	if spec.KubernetesVersion != "v1.31.0" {
+		t.Errorf("Expected Kubernetes v1.31.0, got %s", spec.KubernetesVersion)
+	}
+	if spec.NodeImage == nil || spec.NodeImage.Digest != digest || spec.NodeImage.Repository != "" {
+		t.Errorf("Expected the node image to be pinned to its digest, got %+v", spec.NodeImage)
+	}
+	if spec.ControlPlane.Count != 1 || spec.Workers.Count != 2 {
+		t.Errorf("Expected 1 control plane node and 2 workers, got %d and %d", spec.ControlPlane.Count, spec.Workers.Count)
+	}
+	if got := spec.ControlPlane.ExtraPortMappings; len(got) != 2 || got[0].HostPort != 8080 || got[1].ListenAddress != "127.0.0.1" {
+		t.Errorf("Expected the port mappings of the control plane node, got %+v", got)
+	}
+	wantMount := v1alpha1.Mount{HostPath: "/srv/data", ContainerPath: "/data", ReadOnly: true, Propagation: v1alpha1.MountPropagationHostToContainer}
+	if got := spec.Workers.ExtraMounts; len(got) != 1 || got[0] != wantMount {
+		t.Errorf("Expected the mounts of the workers, got %+v", got)
+	}
+	if spec.Networking == nil || spec.Networking.PodSubnet != "10.244.0.0/16" || spec.Networking.KubeProxyMode != "ipvs" {
+		t.Errorf("Expected the networking of the kind config, got %+v", spec.Networking)
+	}
+	if len(spec.KubeadmConfigPatches) != 2 || !strings.Contains(spec.KubeadmConfigPatches[1], "InitConfiguration") {
+		t.Errorf("Expected the cluster and node kubeadm patches, got %q", spec.KubeadmConfigPatches)
+	}
+	want := []string{
+		"networking is not supported, it is kept in the Cluster but not applied to its nodes",
+		"kubeadmConfigPatches are not supported, they are kept in the Cluster but not applied to its nodes",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected warnings that networking and kubeadm patches are not applied, got %q", warnings)
+	}
+}
+
+func TestToClusterWarnings(t *testing.T) {
+	config := &Config{
+		Name:         "dev",
+		FeatureGates: map[string]bool{"SidecarContainers": true},
+		Nodes: []Node{
+			{Role: RoleControlPlane, Image: "registry.example.com/kind/node:v1.30.4"},
+			{Role: RoleControlPlane, Labels: map[string]string{"tier": "control"}},
+			{Role: RoleWorker, ExtraPortMappings: []PortMapping{{ContainerPort: 80, HostPort: 80}}},
+			{Role: RoleWorker, Image: "kindest/node:v1.29.8"},
+		},
+		Networking: &Networking{DNSSearch: &[]string{}},
+	}
+	cluster, warnings, err := ToCluster(config, "")
+	if err != nil {
+		t.Fatalf("Failed to convert kind config: %v", err)
+	}
+	if cluster.Spec.KubernetesVersion != "v1.30.4" || cluster.Spec.NodeImage == nil ||
+		cluster.Spec.NodeImage.Repository != "registry.example.com/kind/node" {
+		t.Errorf("Expected the image of the first node, got %s %+v", cluster.Spec.KubernetesVersion, cluster.Spec.NodeImage)
+	}
+	if cluster.Spec.Networking != nil {
+		t.Errorf("Expected no networking, got %+v", cluster.Spec.Networking)
+	}
+
+	want := []string{
+		"labels of node 1",
+		"extraPortMappings and extraMounts of node 3",
+		"node 3 runs image kindest/node:v1.29.8",
+		"host port 80",
+		"odd number of nodes",
+		"dnsSearch",
+		"featureGates",
+	}
+	for _, w := range want {
+		found := false
+		for _, warning := range warnings {
+			found = found || strings.Contains(warning, w)
+		}
+		if !found {
+			t.Errorf("Expected a warning about %q, got %q", w, warnings)
+		}
+	}
+	if len(warnings) != len(want) {
+		t.Errorf("Expected %d warnings, got %q", len(want), warnings)
+	}
+
+	// The version given replaces the one of the image, and its digest
+	config.Nodes[0].Image = "kindest/node:v1.30.4@" + digest
+	cluster, _, err = ToCluster(config, "v1.31.0")
+	if err != nil || cluster.Spec.KubernetesVersion != "v1.31.0" || cluster.Spec.NodeImage != nil {
+		t.Errorf("Expected Kubernetes v1.31.0 from the default image, got %+v, %v", cluster.Spec, err)
+	}
+}
+
+func TestToClusterErrors(t *testing.T) {
+	if _, _, err := ToCluster(&Config{}, ""); err == nil {
+		t.Error("Expected a Kubernetes version to be required without node images")
+	}
+	cluster, _, err := ToCluster(&Config{}, "v1.31.0")
+	if err != nil || cluster.Spec.ControlPlane.Count != 1 || cluster.Spec.Workers.Count != 0 {
+		t.Errorf("Expected a single control plane node by default, got %+v, %v", cluster, err)
+	}
+	if _, _, err := ToCluster(&Config{Nodes: []Node{{Role: "etcd"}}}, "v1.31.0"); err == nil {
+		t.Error("Expected unknown roles to be rejected")
+	}
+
+	for _, data := range []string{
+		"kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha3\n",
+		"kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n- role: worker\n  replicas: 3\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected %q to be rejected", data)
+		}
+	}
+}
+
+func TestFromCluster(t *testing.T) {
+	config, err := Parse([]byte(kindConfig))
+	if err != nil {
+		t.Fatalf("Failed to parse kind config: %v", err)
+	}
+	cluster, _, err := ToCluster(config, "")
+	if err != nil {
+		t.Fatalf("Failed to convert kind config: %v", err)
+	}
+
+	exported, warnings, err := FromCluster(cluster)
+	if err != nil {
+		t.Fatalf("Failed to convert cluster: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %q", warnings)
+	}
+	if len(exported.Nodes) != 3 || exported.Nodes[0].Role != RoleControlPlane || exported.Nodes[2].Role != RoleWorker {
+		t.Fatalf("Expected a control plane node and 2 workers, got %+v", exported.Nodes)
+	}
+	for _, node := range exported.Nodes {
+		if node.Image != "kindest/node:v1.31.0@"+digest {
+			t.Errorf("Expected the pinned node image, got %s", node.Image)
+		}
+	}
+	if !reflect.DeepEqual(exported.Nodes[0].ExtraPortMappings, config.Nodes[0].ExtraPortMappings) ||
+		!reflect.DeepEqual(exported.Nodes[1].ExtraMounts, config.Nodes[1].ExtraMounts) {
+		t.Errorf("Expected the port mappings and mounts of the kind config, got %+v", exported.Nodes)
+	}
+
+	// The exported config converts back to the same cluster
+	data, err := Marshal(exported)
+	if err != nil {
+		t.Fatalf("Failed to marshal kind config: %v", err)
+	}
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse exported kind config: %v\n%s", err, data)
+	}
+	again, _, err := ToCluster(reparsed, "")
+	if err != nil {
+		t.Fatalf("Failed to convert exported kind config: %v", err)
+	}
+	if !reflect.DeepEqual(again.Spec, cluster.Spec) {
+		t.Errorf("Expected the round trip to keep the spec\n got %+v\nwant %+v", again.Spec, cluster.Spec)
+	}
+}
+
+func TestFromClusterWarnings(t *testing.T) {
+	cluster := &v1alpha1.Cluster{Spec: v1alpha1.ClusterSpec{
+		KubernetesVersion: "v1.31.0",
+		ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1, MachineConfig: v1alpha1.MachineConfig{Memory: "2Gi"}},
+		ImagePullPolicy:   corev1.PullAlways,
+		Suspended:         true,
+		NodeImage: &v1alpha1.NodeImageSpec{
+			Repository:    "registry.example.com/kind/node",
+			PullSecretRef: &corev1.LocalObjectReference{Name: "registry"},
+		},
+	}}
+	config, warnings, err := FromCluster(cluster)
+	if err != nil {
+		t.Fatalf("Failed to convert cluster: %v", err)
+	}
+	if len(config.Nodes) != 1 || config.Nodes[0].Image != "registry.example.com/kind/node:v1.31.0" {
+		t.Errorf("Expected a control plane node of the registry image, got %+v", config.Nodes)
+	}
+	if len(warnings) != 4 {
+		t.Errorf("Expected warnings about machineConfig, imagePullPolicy, suspended and pullSecretRef, got %q", warnings)
+	}
+
+	if _, _, err := FromCluster(&v1alpha1.Cluster{}); err == nil {
+		t.Error("Expected a Kubernetes version to be required")
+	}
+}
@@ -0,0 +1,95 @@
+// Package kindconfig converts between kind cluster configuration files
+// (kind.x-k8s.io/v1alpha4 Cluster) and Cluster resources. Conversions
+// report what they cannot carry over as warnings instead of failing.
+package kindconfig
+
+// APIVersion and Kind identify kind cluster configuration files
+const (
+	APIVersion = "kind.x-k8s.io/v1alpha4"
+	Kind       = "Cluster"
+)
+
+// DefaultName is the name kind gives clusters whose config names none
+const DefaultName = "kind"
+
+// Node roles of kind configs
+const (
+	RoleControlPlane = "control-plane"
+	RoleWorker       = "worker"
+)
+
+// Config is a kind cluster configuration. It mirrors the v1alpha4 Cluster
+// type of kind, so that kind need not be a dependency.
+type Config struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+
+	// Name is the name of the kind cluster
+	Name string `json:"name,omitempty"`
+
+	FeatureGates  map[string]bool   `json:"featureGates,omitempty"`
+	RuntimeConfig map[string]string `json:"runtimeConfig,omitempty"`
+
+	// Nodes default to a single control plane node
+	Nodes []Node `json:"nodes,omitempty"`
+
+	Networking *Networking `json:"networking,omitempty"`
+
+	KubeadmConfigPatches            []string        `json:"kubeadmConfigPatches,omitempty"`
+	KubeadmConfigPatchesJSON6902    []PatchJSON6902 `json:"kubeadmConfigPatchesJSON6902,omitempty"`
+	ContainerdConfigPatches         []string        `json:"containerdConfigPatches,omitempty"`
+	ContainerdConfigPatchesJSON6902 []string        `json:"containerdConfigPatchesJSON6902,omitempty"`
+}
+
+// Node is a node of a kind cluster
+type Node struct {
+	Role string `json:"role,omitempty"`
+
+	// Image is the node image, e.g. kindest/node:v1.31.0@sha256:...
+	Image string `json:"image,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+
+	ExtraMounts       []Mount       `json:"extraMounts,omitempty"`
+	ExtraPortMappings []PortMapping `json:"extraPortMappings,omitempty"`
+
+	KubeadmConfigPatches         []string        `json:"kubeadmConfigPatches,omitempty"`
+	KubeadmConfigPatchesJSON6902 []PatchJSON6902 `json:"kubeadmConfigPatchesJSON6902,omitempty"`
+}
+
+// Networking is the networking section of a kind config
+type Networking struct {
+	IPFamily          string    `json:"ipFamily,omitempty"`
+	APIServerPort     int32     `json:"apiServerPort,omitempty"`
+	APIServerAddress  string    `json:"apiServerAddress,omitempty"`
+	PodSubnet         string    `json:"podSubnet,omitempty"`
+	ServiceSubnet     string    `json:"serviceSubnet,omitempty"`
+	DisableDefaultCNI bool      `json:"disableDefaultCNI,omitempty"`
+	KubeProxyMode     string    `json:"kubeProxyMode,omitempty"`
+	DNSSearch         *[]string `json:"dnsSearch,omitempty"`
+}
+
+// Mount is an extra mount of a kind node
+type Mount struct {
+	ContainerPath  string `json:"containerPath,omitempty"`
+	HostPath       string `json:"hostPath,omitempty"`
+	ReadOnly       bool   `json:"readOnly,omitempty"`
+	SelinuxRelabel bool   `json:"selinuxRelabel,omitempty"`
+	Propagation    string `json:"propagation,omitempty"`
+}
+
+// PortMapping is an extra port mapping of a kind node
+type PortMapping struct {
+	ContainerPort int32  `json:"containerPort,omitempty"`
+	HostPort      int32  `json:"hostPort,omitempty"`
+	ListenAddress string `json:"listenAddress,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// PatchJSON6902 is a JSON 6902 patch of a kubeadm configuration object
+type PatchJSON6902 struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	Patch   string `json:"patch"`
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// collectTimeout bounds how long a scrape may spend listing clusters
+const collectTimeout = 5 * time.Second
+
+var (
+	clustersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "clusters"),
+		"Number of clusters by phase.",
+		[]string{"phase"}, nil,
+	)
+	clusterNodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "cluster_nodes"),
+		"Number of nodes per cluster by role, desired and ready.",
+		[]string{"namespace", "cluster", "role", "state"}, nil,
+	)
+)
+
+// ClusterCollector reports cluster phases and node counts, read from the
+// Cluster resources at scrape time
+type ClusterCollector struct {
+	reader client.Reader
+}
+
+// NewClusterCollector creates a collector that lists clusters through reader
+func NewClusterCollector(reader client.Reader) *ClusterCollector {
+	return &ClusterCollector{reader: reader}
+}
+
+// Describe implements prometheus.Collector
+func (c *ClusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clustersDesc
+	ch <- clusterNodesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *ClusterCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	var clusters v1alpha1.ClusterList
+	if err := c.reader.List(ctx, &clusters); err != nil {
+		logf.Log.WithName("metrics").Error(err, "Failed to list clusters")
+		return
+	}
+
+	phases := map[v1alpha1.ClusterPhase]int{
+		v1alpha1.ClusterPhasePending:      0,
+		v1alpha1.ClusterPhaseProvisioning: 0,
+		v1alpha1.ClusterPhaseRunning:      0,
+		v1alpha1.ClusterPhaseUpdating:     0,
+		v1alpha1.ClusterPhaseSuspended:    0,
+		v1alpha1.ClusterPhaseFailed:       0,
+		v1alpha1.ClusterPhaseDeleting:     0,
+	}
+	for _, cluster := range clusters.Items {
+		phase := cluster.Status.Phase
+		if phase == "" {
+			phase = v1alpha1.ClusterPhasePending
+		}
+		phases[phase]++
+
+		var controlPlaneReady int32
+		if cluster.Status.ControlPlaneReady {
+			controlPlaneReady = cluster.Spec.ControlPlane.Count
+		}
+		nodes := []struct {
+			role, state string
+			count       int32
+		}{
+			{"control-plane", "desired", cluster.Spec.ControlPlane.Count},
+			{"control-plane", "ready", controlPlaneReady},
+			{"worker", "desired", cluster.Spec.Workers.Count},
+			{"worker", "ready", cluster.Status.WorkersReady},
+		}
+		for _, n := range nodes {
+			ch <- prometheus.MustNewConstMetric(clusterNodesDesc, prometheus.GaugeValue, float64(n.count),
+				cluster.Namespace, cluster.Name, n.role, n.state)
+		}
+	}
+
+	for phase, count := range phases {
+		ch <- prometheus.MustNewConstMetric(clustersDesc, prometheus.GaugeValue, float64(count), string(phase))
+	}
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+func TestClusterCollector(t *testing.T) {
+	s := runtime.NewScheme()
+	v1alpha1.AddToScheme(s)
+
+	client := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		&v1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"},
+			Spec: v1alpha1.ClusterSpec{
+				ControlPlane: v1alpha1.ControlPlaneConfig{Count: 1},
+				Workers:      v1alpha1.WorkerConfig{Count: 3},
+			},
+			Status: v1alpha1.ClusterStatus{
+				Phase:             v1alpha1.ClusterPhaseRunning,
+				ControlPlaneReady: true,
+				WorkersReady:      2,
+			},
+		},
+		&v1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default"},
+			Spec: v1alpha1.ClusterSpec{
+				ControlPlane: v1alpha1.ControlPlaneConfig{Count: 1},
+			},
+			Status: v1alpha1.ClusterStatus{Phase: v1alpha1.ClusterPhaseFailed},
+		},
+	).Build()
+
+	expected := `
+# HELP mkm_clusters Number of clusters by phase.
+# TYPE mkm_clusters gauge
+mkm_clusters{phase="Deleting"} 0
+mkm_clusters{phase="Failed"} 1
+mkm_clusters{phase="Pending"} 0
+mkm_clusters{phase="Provisioning"} 0
+mkm_clusters{phase="Running"} 1
+mkm_clusters{phase="Suspended"} 0
+mkm_clusters{phase="Updating"} 0
+# HELP mkm_cluster_nodes Number of nodes per cluster by role, desired and ready.
+# TYPE mkm_cluster_nodes gauge
+mkm_cluster_nodes{cluster="a",namespace="default",role="control-plane",state="desired"} 1
+mkm_cluster_nodes{cluster="a",namespace="default",role="control-plane",state="ready"} 1
+mkm_cluster_nodes{cluster="a",namespace="default",role="worker",state="desired"} 3
+mkm_cluster_nodes{cluster="a",namespace="default",role="worker",state="ready"} 2
+mkm_cluster_nodes{cluster="b",namespace="default",role="control-plane",state="desired"} 1
+mkm_cluster_nodes{cluster="b",namespace="default",role="control-plane",state="ready"} 0
+mkm_cluster_nodes{cluster="b",namespace="default",role="worker",state="desired"} 0
+mkm_cluster_nodes{cluster="b",namespace="default",role="worker",state="ready"} 0
+`
+	if err := testutil.CollectAndCompare(NewClusterCollector(client), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestObserveDockerCall(t *testing.T) {
+	before := testutil.ToFloat64(DockerAPIErrors.WithLabelValues("TestMethod"))
+
+	ObserveDockerCall("TestMethod", time.Now(), nil)
+	ObserveDockerCall("TestMethod", time.Now(), errors.New("connection refused"))
+
+	if got := testutil.ToFloat64(DockerAPICalls.WithLabelValues("TestMethod")); got != 2 {
+		t.Errorf("Expected 2 calls, got %v", got)
+	}
+	if got := testutil.ToFloat64(DockerAPIErrors.WithLabelValues("TestMethod")) - before; got != 1 {
+		t.Errorf("Expected 1 error, got %v", got)
+	}
+}
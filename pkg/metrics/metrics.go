@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "mkm"
+
+var (
+	// ProvisioningDuration records how long the provider took to create a cluster
+	ProvisioningDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cluster_provisioning_duration_seconds",
+		Help:      "Time taken to provision a cluster.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 9),
+	})
+
+	// UpgradeDuration records how long the provider took to upgrade the
+	// Kubernetes version of a cluster
+	UpgradeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cluster_upgrade_duration_seconds",
+		Help:      "Time taken to upgrade a cluster to a new Kubernetes version.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 9),
+	})
+
+	// DeletionDuration records how long the provider took to delete a cluster
+	DeletionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cluster_deletion_duration_seconds",
+		Help:      "Time taken to delete a cluster.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 9),
+	})
+
+	// DockerAPICalls counts Docker API calls by method
+	DockerAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "docker_api_calls_total",
+		Help:      "Number of Docker API calls made by the Docker provider.",
+	}, []string{"method"})
+
+	// DockerAPIErrors counts failed Docker API calls by method
+	DockerAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "docker_api_errors_total",
+		Help:      "Number of Docker API calls made by the Docker provider that returned an error.",
+	}, []string{"method"})
+
+	// DockerAPILatency records the latency of Docker API calls by method
+	DockerAPILatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "docker_api_call_duration_seconds",
+		Help:      "Latency of Docker API calls made by the Docker provider.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"method"})
+
+	// ImagePullDuration records how long node image pulls took
+	ImagePullDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "image_pull_duration_seconds",
+		Help:      "Time taken to pull a node image.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	}, []string{"image"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		ProvisioningDuration,
+		UpgradeDuration,
+		DeletionDuration,
+		DockerAPICalls,
+		DockerAPIErrors,
+		DockerAPILatency,
+		ImagePullDuration,
+	)
+}
+
+// ObserveDockerCall records a Docker API call that started at start and returned err
+func ObserveDockerCall(method string, start time.Time, err error) {
+	DockerAPICalls.WithLabelValues(method).Inc()
+	DockerAPILatency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		DockerAPIErrors.WithLabelValues(method).Inc()
+	}
+}
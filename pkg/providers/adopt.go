@@ -0,0 +1,228 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// Labels kind puts on its node containers
+const (
+	kindLabelCluster = "io.x-k8s.kind.cluster"
+	kindLabelRole    = "io.x-k8s.kind.role"
+)
+
+// RoleExternalLoadBalancer is the role of the load balancer kind runs in front
+// of multiple control plane nodes
+const RoleExternalLoadBalancer = "external-load-balancer"
+
+// adoptedNode is a kind node container that is about to be adopted
+type adoptedNode struct {
+	summary container.Summary
+	role    string
+	ordinal int
+}
+
+// AdoptCluster takes over the kind cluster with the given name and returns a
+// ClusterSpec derived from its containers. The containers are left exactly as
+// kind created them, so their names, addresses and the load balancer's
+// backends stay valid: from then on they are found through kind's cluster
+// label, which the cluster keeps in its AdoptAnnotation.
+func (p *DockerProvider) AdoptCluster(ctx context.Context, cluster *v1alpha1.Cluster, source string) (*v1alpha1.ClusterSpec, error) {
+	containers, err := p.listKindContainers(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("%w: no containers found for kind cluster %s", ErrClusterNotFound, source)
+	}
+
+	nodes := make([]adoptedNode, 0, len(containers))
+	for _, cont := range containers {
+		role := cont.Labels[kindLabelRole]
+		if role == "" {
+			return nil, fmt.Errorf("container %s has no %s label", containerName(cont), kindLabelRole)
+		}
+		labels := cont.Labels
+		if _, managed := labels[LabelManagedBy]; managed &&
+			(labels[LabelClusterNamespace] != cluster.Namespace || labels[LabelClusterName] != cluster.Name) {
+			return nil, fmt.Errorf("container %s is already managed by another cluster", containerName(cont))
+		}
+		nodes = append(nodes, adoptedNode{
+			summary: cont,
+			role:    role,
+			ordinal: kindNodeOrdinal(containerName(cont), source, role),
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].role != nodes[j].role {
+			return nodes[i].role < nodes[j].role
+		}
+		return nodes[i].ordinal < nodes[j].ordinal
+	})
+
+	spec, err := p.deriveClusterSpec(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		clusterLogger(ctx, cluster).Info("Adopting node", "step", "adopt", "node", containerName(node.summary), "role", node.role)
+		recordNormal(ctx, cluster, ReasonNodeAdopted, "Adopted %s node %s", node.role, containerName(node.summary))
+	}
+	return spec, nil
+}
+
+// listKindContainers lists the containers of the kind cluster with the given name
+func (p *DockerProvider) listKindContainers(ctx context.Context, source string) ([]container.Summary, error) {
+	containers, err := p.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", kindLabelCluster, source))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers of kind cluster %s: %w", source, err)
+	}
+	return containers, nil
+}
+
+// adoptedKindNode returns the summary of a container of the kind cluster
+// adopted by cluster with the labels of the provider's own nodes added, taken
+// from kind's labels and the container's network and image, so that the rest
+// of the provider handles it like one of them. The container keeps its labels.
+func adoptedKindNode(cluster *v1alpha1.Cluster, cont container.Summary) container.Summary {
+	labels := nodeLabels(cluster, cont.Labels[kindLabelRole], cont.HostConfig.NetworkMode)
+	labels[LabelNodeImage] = cont.Image
+	for k, v := range cont.Labels {
+		labels[k] = v
+	}
+	cont.Labels = labels
+	return cont
+}
+
+// deriveClusterSpec builds the ClusterSpec that describes the adopted nodes
+func (p *DockerProvider) deriveClusterSpec(ctx context.Context, nodes []adoptedNode) (*v1alpha1.ClusterSpec, error) {
+	spec := &v1alpha1.ClusterSpec{}
+	for _, node := range nodes {
+		var machineConfig *v1alpha1.MachineConfig
+		switch node.role {
+		case RoleControlPlane:
+			spec.ControlPlane.Count++
+			machineConfig = &spec.ControlPlane.MachineConfig
+		case RoleWorker:
+			spec.Workers.Count++
+			machineConfig = &spec.Workers.MachineConfig
+		default:
+			continue
+		}
+
+		image := node.summary.Image
+		if label, ok := node.summary.Labels[LabelNodeImage]; ok {
+			image = label
+		}
+		version := imageTag(image)
+		if version == "" {
+			return nil, fmt.Errorf("cannot determine Kubernetes version from image %q of container %s", image, containerName(node.summary))
+		}
+		if spec.KubernetesVersion != "" && spec.KubernetesVersion != version {
+			return nil, fmt.Errorf("nodes run different Kubernetes versions (%s and %s)", spec.KubernetesVersion, version)
+		}
+		spec.KubernetesVersion = version
+
+		// New nodes come from the same repository as the adopted ones. The
+		// digest of kind's image is not pinned: it would hold the cluster
+		// to that image through later upgrades.
+		if repository := imageRepository(image); spec.NodeImage == nil && repository != DefaultNodeImageRepository {
+			spec.NodeImage = &v1alpha1.NodeImageSpec{Repository: repository}
+		}
+
+		info, err := p.client.ContainerInspect(ctx, node.summary.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %s: %w", containerName(node.summary), err)
+		}
+		*machineConfig = v1alpha1.MachineConfig{
+			Memory:   formatMemory(info.HostConfig.Memory),
+			CPUCount: int32(nanoCPUs(info.HostConfig.Resources) / 1e9),
+		}
+	}
+
+	if spec.ControlPlane.Count == 0 {
+		return nil, fmt.Errorf("kind cluster has no control plane node")
+	}
+	return spec, nil
+}
+
+// containerName returns the primary name of a container without the leading slash
+func containerName(cont container.Summary) string {
+	if len(cont.Names) == 0 {
+		return cont.ID
+	}
+	return strings.TrimPrefix(cont.Names[0], "/")
+}
+
+// nodeNames returns the comma-separated names of node containers
+func nodeNames(nodes []container.Summary) string {
+	names := make([]string, 0, len(nodes))
+	for _, cont := range nodes {
+		names = append(names, containerName(cont))
+	}
+	return strings.Join(names, ", ")
+}
+
+// kindNodeOrdinal returns the position of a kind node within its role, following
+// kind's naming: <cluster>-worker, <cluster>-worker2, <cluster>-worker3, ...
+func kindNodeOrdinal(name, source, role string) int {
+	suffix := strings.TrimPrefix(name, fmt.Sprintf("%s-%s", source, role))
+	if suffix == "" {
+		return 1
+	}
+	ordinal, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0
+	}
+	return ordinal
+}
+
+// imageTag returns the tag of an image reference such as
+// kindest/node:v1.27.3@sha256:..., or "" if the reference has no tag
+func imageTag(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return ""
+	}
+	return ref[i+1:]
+}
+
+// imageRepository returns the repository of an image reference such as
+// kindest/node:v1.27.3@sha256:..., i.e. the reference without tag and digest
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// formatMemory converts a byte count to the notation accepted by parseMemory,
+// returning "" for an unlimited (zero) value
+func formatMemory(bytes int64) string {
+	switch {
+	case bytes <= 0:
+		return ""
+	case bytes%(1024*1024*1024) == 0:
+		return fmt.Sprintf("%dGi", bytes/(1024*1024*1024))
+	case bytes%(1024*1024) == 0:
+		return fmt.Sprintf("%dMi", bytes/(1024*1024))
+	default:
+		return fmt.Sprintf("%dKi", bytes/1024)
+	}
+}
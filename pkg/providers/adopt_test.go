@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+func TestImageTag(t *testing.T) {
+	tests := map[string]string{
+		"kindest/node:v1.27.3":                        "v1.27.3",
+		"kindest/node:v1.27.3@sha256:3966ac761ae0136": "v1.27.3",
+		"localhost:5000/kindest/node:v1.29.0":         "v1.29.0",
+		"localhost:5000/kindest/node":                 "",
+		"kindest/node@sha256:3966ac761ae0136":         "",
+	}
+	for ref, want := range tests {
+		if got := imageTag(ref); got != want {
+			t.Errorf("imageTag(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"kindest/node:v1.27.3":                        "kindest/node",
+		"kindest/node:v1.27.3@sha256:3966ac761ae0136": "kindest/node",
+		"localhost:5000/kindest/node:v1.29.0":         "localhost:5000/kindest/node",
+		"localhost:5000/kindest/node":                 "localhost:5000/kindest/node",
+	}
+	for ref, want := range tests {
+		if got := imageRepository(ref); got != want {
+			t.Errorf("imageRepository(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestKindNodeOrdinal(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		want int
+	}{
+		{"dev-control-plane", RoleControlPlane, 1},
+		{"dev-control-plane2", RoleControlPlane, 2},
+		{"dev-worker", RoleWorker, 1},
+		{"dev-worker10", RoleWorker, 10},
+	}
+	for _, tt := range tests {
+		if got := kindNodeOrdinal(tt.name, "dev", tt.role); got != tt.want {
+			t.Errorf("kindNodeOrdinal(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMemoryRoundTrip(t *testing.T) {
+	provider := &DockerProvider{BaseProvider: &BaseProvider{}}
+
+	if got := formatMemory(0); got != "" {
+		t.Errorf("Expected unlimited memory to format as empty, got %q", got)
+	}
+	for _, memory := range []string{"2Gi", "512Mi", "64Ki"} {
+		if got := formatMemory(provider.parseMemory(memory)); got != memory {
+			t.Errorf("formatMemory(parseMemory(%q)) = %q", memory, got)
+		}
+	}
+}
+
+func TestAdoptClusterLeavesContainers(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	engine.AddImage("kindest/node:v1.31.0")
+	engine.AddImage("kindest/haproxy:v20230606-42a2262b")
+	if _, err := engine.NetworkCreate(ctx, "kind", network.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create network: %v", err)
+	}
+	ids := map[string]string{}
+	for name, role := range map[string]string{
+		"dev-control-plane":          RoleControlPlane,
+		"dev-worker":                 RoleWorker,
+		"dev-external-load-balancer": RoleExternalLoadBalancer,
+	} {
+		image := "kindest/node:v1.31.0"
+		if role == RoleExternalLoadBalancer {
+			image = "kindest/haproxy:v20230606-42a2262b"
+		}
+		resp, err := engine.ContainerCreate(ctx,
+			&container.Config{Image: image, Labels: map[string]string{kindLabelCluster: "dev", kindLabelRole: role}},
+			&container.HostConfig{NetworkMode: "kind"}, nil, nil, name)
+		if err != nil {
+			t.Fatalf("Failed to create container %s: %v", name, err)
+		}
+		if err := engine.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+			t.Fatalf("Failed to start container %s: %v", name, err)
+		}
+		ids[name] = resp.ID
+	}
+
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Name:        "dev",
+		Namespace:   "default",
+		Annotations: map[string]string{v1alpha1.AdoptAnnotation: "dev"},
+	}}
+	created := engine.Calls("ContainerCreate")
+	spec, err := provider.AdoptCluster(ctx, cluster, "dev")
+	if err != nil {
+		t.Fatalf("Failed to adopt cluster: %v", err)
+	}
+	if spec.KubernetesVersion != "v1.31.0" || spec.ControlPlane.Count != 1 || spec.Workers.Count != 1 || spec.NodeImage != nil {
+		t.Errorf("Expected Kubernetes v1.31.0 on 1 control plane node and 1 worker from the default image, got %+v", spec)
+	}
+	if engine.Calls("ContainerCreate") != created {
+		t.Error("Expected adoption to create no containers")
+	}
+	for _, method := range []string{"ContainerRemove", "ContainerCommit", "ContainerRename"} {
+		if calls := engine.Calls(method); calls != 0 {
+			t.Errorf("Expected adoption to leave the containers alone, got %d %s calls", calls, method)
+		}
+	}
+
+	// The provider finds the adopted nodes through kind's labels
+	containers, err := provider.listClusterContainers(ctx, cluster)
+	if err != nil {
+		t.Fatalf("Failed to list cluster containers: %v", err)
+	}
+	if len(containers) != 3 {
+		t.Fatalf("Expected the 3 kind containers, got %d", len(containers))
+	}
+	for _, cont := range containers {
+		name := containerName(cont)
+		if cont.ID != ids[name] || nodeRole(cont.Labels) != cont.Labels[kindLabelRole] || cont.Labels[LabelNetwork] != "kind" {
+			t.Errorf("Expected %s to keep its ID and take role and network from kind, got labels %v", name, cont.Labels)
+		}
+	}
+	net, err := provider.getClusterNetwork(ctx, cluster)
+	if err != nil || net == nil || net.Name != "kind" {
+		t.Errorf("Expected the kind network, got %+v, %v", net, err)
+	}
+}
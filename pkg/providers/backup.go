@@ -0,0 +1,364 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+const (
+	// backupVolumeMount is where backup volumes are mounted in the helper container
+	backupVolumeMount = "/backup"
+
+	// etcdRestoreDir is where a snapshot is restored on the node before it
+	// replaces etcd's data
+	etcdRestoreDir = etcdDataDir + "/mini-k8s-restore"
+
+	// staticPodManifests is the kubelet's static pod directory on kubeadm nodes
+	staticPodManifests = "/etc/kubernetes/manifests"
+
+	// stoppedManifests is where the etcd and API server manifests are kept
+	// while the data is replaced, which makes the kubelet stop their pods
+	stoppedManifests = "/etc/kubernetes/mini-k8s-restore-manifests"
+
+	// etcdStopTimeout bounds the wait for etcd to stop or start during a restore
+	etcdStopTimeout = 2 * time.Minute
+)
+
+// BackupEtcd saves a snapshot of the cluster's etcd to location, under the
+// cluster's namespace and name
+func (p *DockerProvider) BackupEtcd(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.BackupEtcd", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateBackupLocation(location); err != nil {
+		return "", err
+	}
+	node, err := p.etcdNode(ctx, cluster)
+	if err != nil {
+		return "", err
+	}
+	name := etcdSnapshotName()
+
+	if location.Volume == "" {
+		path := p.backupPath(cluster, location, name)
+		if err := p.saveEtcdSnapshot(ctx, cluster, node, name, path); err != nil {
+			return "", err
+		}
+		recordNormal(ctx, cluster, ReasonEtcdSnapshotSaved, "Saved etcd snapshot of node %s to %s", containerName(node), path)
+		return name, nil
+	}
+
+	// Volumes are only reachable through a container, so the snapshot goes
+	// through a temporary file on the host
+	tmpDir, err := os.MkdirTemp("", "mini-k8s-backup-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tmp := filepath.Join(tmpDir, name)
+	if err := p.saveEtcdSnapshot(ctx, cluster, node, name, tmp); err != nil {
+		return "", err
+	}
+
+	err = p.withBackupVolume(ctx, cluster, location.Volume, func(helperID string) error {
+		dir := path.Dir(p.volumeBackupPath(cluster, name))
+		if _, err := p.execInContainer(ctx, helperID, "mkdir", "-p", dir); err != nil {
+			return err
+		}
+		// Copy under a temporary name so only complete snapshots carry theirs
+		partial := path.Join(dir, "."+name+".partial")
+		if err := p.copyFileToContainer(ctx, helperID, tmp, partial); err != nil {
+			return err
+		}
+		_, err := p.execInContainer(ctx, helperID, "mv", partial, path.Join(dir, name))
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store etcd snapshot in volume %s: %w", location.Volume, err)
+	}
+	recordNormal(ctx, cluster, ReasonEtcdSnapshotSaved, "Saved etcd snapshot %s of node %s to volume %s", name, containerName(node), location.Volume)
+	return name, nil
+}
+
+// DeleteEtcdBackup removes a snapshot saved by BackupEtcd
+func (p *DockerProvider) DeleteEtcdBackup(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.DeleteEtcdBackup", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateBackupLocation(location); err != nil {
+		return err
+	}
+	if location.Volume == "" {
+		if err := os.Remove(p.backupPath(cluster, location, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete etcd snapshot %s: %w", name, err)
+		}
+		return nil
+	}
+
+	return p.withBackupVolume(ctx, cluster, location.Volume, func(helperID string) error {
+		if _, err := p.execInContainer(ctx, helperID, "rm", "-f", p.volumeBackupPath(cluster, name)); err != nil {
+			return fmt.Errorf("failed to delete etcd snapshot %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// RestoreEtcd replaces the etcd data of the cluster's control plane nodes with
+// a snapshot saved by BackupEtcd. Every member is restored from the same
+// snapshot into a new etcd cluster of the same members. etcd and the API
+// server are stopped on all nodes while the data is swapped and started again
+// afterwards.
+func (p *DockerProvider) RestoreEtcd(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.RestoreEtcd", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateBackupLocation(location); err != nil {
+		return err
+	}
+	nodes, err := p.runningControlPlanes(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	log := clusterLogger(ctx, cluster).WithValues("step", "etcd-restore", "snapshot", name)
+	log.Info("Restoring etcd snapshot", "members", len(nodes))
+
+	// Snapshots kept in a volume are read through a temporary file on the host
+	snapshot := p.backupPath(cluster, location, name)
+	if location.Volume != "" {
+		tmpDir, err := os.MkdirTemp("", "mini-k8s-restore-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		snapshot = filepath.Join(tmpDir, name)
+		err = p.withBackupVolume(ctx, cluster, location.Volume, func(helperID string) error {
+			return p.copyFileFromContainer(ctx, helperID, p.volumeBackupPath(cluster, name), snapshot)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read etcd snapshot %s from volume %s: %w", name, location.Volume, err)
+		}
+	}
+	if err := p.restoreEtcdSnapshot(ctx, cluster, nodes, snapshot); err != nil {
+		return err
+	}
+
+	log.Info("Restored etcd snapshot")
+	recordNormal(ctx, cluster, ReasonEtcdRestored, "Restored etcd snapshot %s from %s on nodes %s", name, location, nodeNames(nodes))
+	return nil
+}
+
+// etcdMemberManifest is the name and peer URL an etcd member has in the
+// static pod manifest on its node
+type etcdMemberManifest struct {
+	name    string
+	peerURL string
+}
+
+// restoreEtcdSnapshot replaces the etcd data on control plane nodes with the
+// snapshot at the host path snapshot. Each member keeps the name and peer URL
+// its manifest gives it; together they form the initial cluster every member
+// is restored with, so the restored members find each other and none of them
+// remembers the members of the cluster the snapshot was taken from.
+func (p *DockerProvider) restoreEtcdSnapshot(ctx context.Context, cluster *v1alpha1.Cluster, nodes []container.Summary, snapshot string) error {
+	log := clusterLogger(ctx, cluster).WithValues("step", "etcd-restore")
+
+	members := make([]etcdMemberManifest, len(nodes))
+	initialCluster := make([]string, len(nodes))
+	for i, node := range nodes {
+		manifest, err := p.execInContainer(ctx, node.ID, "cat", path.Join(staticPodManifests, "etcd.yaml"))
+		if err != nil {
+			return fmt.Errorf("failed to read etcd manifest on node %s: %w", containerName(node), err)
+		}
+		members[i] = etcdMemberManifest{name: manifestFlag(manifest, "name"), peerURL: manifestFlag(manifest, "initial-advertise-peer-urls")}
+		if members[i].name == "" || members[i].peerURL == "" {
+			return fmt.Errorf("etcd manifest on node %s has no member name or peer URL", containerName(node))
+		}
+		initialCluster[i] = members[i].name + "=" + members[i].peerURL
+	}
+
+	// Restore into a new data directory on every node while etcd still runs
+	// to provide etcdutl
+	for i, node := range nodes {
+		if err := p.restoreEtcdMember(ctx, cluster, node, members[i], strings.Join(initialCluster, ","), snapshot); err != nil {
+			return err
+		}
+	}
+
+	// Stop etcd and the API server everywhere, swap the data and start them
+	// again. The manifests are put back on every node they were taken from,
+	// even if the swap fails.
+	log.Info("Stopping etcd and the API server", "nodes", nodeNames(nodes))
+	var stopped []container.Summary
+	var swapErr error
+	stop := fmt.Sprintf("mkdir -p %[1]s && mv %[2]s/etcd.yaml %[2]s/kube-apiserver.yaml %[1]s/", stoppedManifests, staticPodManifests)
+	for _, node := range nodes {
+		if _, err := p.execInContainer(ctx, node.ID, "sh", "-c", stop); err != nil {
+			swapErr = fmt.Errorf("failed to stop etcd on node %s: %w", containerName(node), err)
+			break
+		}
+		stopped = append(stopped, node)
+	}
+	for _, node := range stopped {
+		if swapErr != nil {
+			break
+		}
+		swapErr = p.waitForEtcd(ctx, node.ID, false)
+	}
+	swap := fmt.Sprintf("rm -rf %[1]s/member && mv %[2]s/member %[1]s/member && rm -rf %[2]s", etcdDataDir, etcdRestoreDir)
+	for _, node := range stopped {
+		if swapErr != nil {
+			break
+		}
+		if _, err := p.execInContainer(ctx, node.ID, "sh", "-c", swap); err != nil {
+			swapErr = fmt.Errorf("failed to replace etcd data on node %s: %w", containerName(node), err)
+		}
+	}
+	start := fmt.Sprintf("mv %[1]s/etcd.yaml %[1]s/kube-apiserver.yaml %[2]s/ && rmdir %[1]s", stoppedManifests, staticPodManifests)
+	var startErrs []error
+	for _, node := range stopped {
+		if _, err := p.execInContainer(ctx, node.ID, "sh", "-c", start); err != nil {
+			startErrs = append(startErrs, fmt.Errorf("failed to start etcd on node %s: %w", containerName(node), err))
+		}
+	}
+	if err := errors.Join(append([]error{swapErr}, startErrs...)...); err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if err := p.waitForEtcd(ctx, node.ID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreEtcdMember restores the snapshot at the host path snapshot into
+// etcdRestoreDir on node, as member of initialCluster
+func (p *DockerProvider) restoreEtcdMember(ctx context.Context, cluster *v1alpha1.Cluster, node container.Summary, member etcdMemberManifest, initialCluster, snapshot string) error {
+	log := clusterLogger(ctx, cluster).WithValues("step", "etcd-restore", "node", containerName(node))
+
+	// Bring the snapshot onto the node, next to etcd's data
+	nodeSnapshot := path.Join(etcdDataDir, "mini-k8s-restore-"+filepath.Base(snapshot))
+	if err := p.copyFileToContainer(ctx, node.ID, snapshot, nodeSnapshot); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := p.execInContainer(ctx, node.ID, "rm", "-f", nodeSnapshot); err != nil {
+			log.Error(err, "Failed to remove etcd snapshot from node", "path", nodeSnapshot)
+		}
+	}()
+
+	etcdID, err := p.etcdContainerID(ctx, node.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := p.execInContainer(ctx, node.ID, "rm", "-rf", etcdRestoreDir); err != nil {
+		return err
+	}
+	_, err = p.execInContainer(ctx, node.ID, "crictl", "exec", etcdID,
+		"etcdutl", "snapshot", "restore", nodeSnapshot,
+		"--data-dir="+etcdRestoreDir,
+		"--name="+member.name,
+		"--initial-cluster="+initialCluster,
+		"--initial-advertise-peer-urls="+member.peerURL,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore etcd snapshot on node %s: %w", containerName(node), err)
+	}
+	return nil
+}
+
+// waitForEtcd waits until etcd runs on a node, or until it no longer does
+func (p *DockerProvider) waitForEtcd(ctx context.Context, nodeID string, running bool) error {
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, etcdStopTimeout, true, func(ctx context.Context) (bool, error) {
+		out, err := p.execInContainer(ctx, nodeID, "crictl", "ps", "--quiet", "--state", "running", "--name", "^etcd$")
+		if err != nil {
+			return false, err
+		}
+		return (strings.TrimSpace(out) != "") == running, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if running {
+		return fmt.Errorf("etcd did not start on node %s: %w", shortID(nodeID), err)
+	}
+	return fmt.Errorf("etcd did not stop on node %s: %w", shortID(nodeID), err)
+}
+
+// withBackupVolume runs fn with a helper container that has volume mounted at
+// backupVolumeMount. The helper runs the cluster's node image and is removed
+// once fn returns.
+func (p *DockerProvider) withBackupVolume(ctx context.Context, cluster *v1alpha1.Cluster, volume string, fn func(helperID string) error) error {
+	image := p.getNodeImage(cluster)
+	if err := p.images.ensureImage(ctx, cluster, image, corev1.PullIfNotPresent); err != nil {
+		return err
+	}
+
+	config := &container.Config{
+		Image:      image,
+		Entrypoint: []string{"sleep", "infinity"},
+	}
+	hostConfig := &container.HostConfig{
+		Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: volume, Target: backupVolumeMount}},
+	}
+	resp, err := p.client.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create backup helper container: %w", err)
+	}
+	defer func() {
+		if err := p.client.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true}); err != nil {
+			clusterLogger(ctx, cluster).Error(err, "Failed to remove backup helper container", "containerID", shortID(resp.ID))
+		}
+	}()
+	if err := p.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start backup helper container: %w", err)
+	}
+	return fn(resp.ID)
+}
+
+// backupPath returns the host path of a snapshot in a host directory location
+func (p *DockerProvider) backupPath(cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) string {
+	dir := location.HostPath
+	if dir == "" {
+		dir = p.etcdSnapshotDirectory()
+	}
+	return filepath.Join(dir, cluster.Namespace, cluster.Name, name)
+}
+
+// volumeBackupPath returns the path of a snapshot in the backup helper container
+func (p *DockerProvider) volumeBackupPath(cluster *v1alpha1.Cluster, name string) string {
+	return path.Join(backupVolumeMount, cluster.Namespace, cluster.Name, name)
+}
+
+// validateBackupLocation rejects locations naming both a directory and a volume
+func validateBackupLocation(location v1alpha1.BackupLocation) error {
+	if location.HostPath != "" && location.Volume != "" {
+		return fmt.Errorf("%w: a backup location is either a host path or a volume", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// manifestFlag returns the value of a --flag=value argument in a static pod manifest
+func manifestFlag(manifest, flag string) string {
+	for _, line := range strings.Split(manifest, "\n") {
+		arg := strings.TrimPrefix(strings.TrimSpace(line), "- ")
+		if value, ok := strings.CutPrefix(arg, "--"+flag+"="); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers/dockerfake"
+)
+
+func TestManifestFlag(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - command:
+    - etcd
+    - --data-dir=/var/lib/etcd
+    - --initial-advertise-peer-urls=https://172.18.0.2:2380
+    - --name=dev-control-plane
+`
+	if got := manifestFlag(manifest, "name"); got != "dev-control-plane" {
+		t.Errorf("Expected member name dev-control-plane, got %q", got)
+	}
+	if got := manifestFlag(manifest, "initial-advertise-peer-urls"); got != "https://172.18.0.2:2380" {
+		t.Errorf("Expected the peer URL, got %q", got)
+	}
+	if got := manifestFlag(manifest, "listen-client-urls"); got != "" {
+		t.Errorf("Expected no value for a missing flag, got %q", got)
+	}
+}
+
+func TestBackupPath(t *testing.T) {
+	provider := &DockerProvider{config: &v1alpha1.DockerProviderConfig{
+		Spec: v1alpha1.DockerProviderConfigSpec{EtcdSnapshotDirectory: "/snapshots"},
+	}}
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "team-a"}}
+
+	if got := provider.backupPath(cluster, v1alpha1.BackupLocation{}, "etcd.db"); got != filepath.Join("/snapshots", "team-a", "dev", "etcd.db") {
+		t.Errorf("Expected the snapshot directory by default, got %s", got)
+	}
+	if got := provider.backupPath(cluster, v1alpha1.BackupLocation{HostPath: "/backups"}, "etcd.db"); got != filepath.Join("/backups", "team-a", "dev", "etcd.db") {
+		t.Errorf("Expected the host path, got %s", got)
+	}
+	if got := provider.volumeBackupPath(cluster, "etcd.db"); got != "/backup/team-a/dev/etcd.db" {
+		t.Errorf("Expected the path in the volume, got %s", got)
+	}
+
+	err := validateBackupLocation(v1alpha1.BackupLocation{HostPath: "/backups", Volume: "backups"})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig for a location with a path and a volume, got %v", err)
+	}
+}
+
+func TestArchiveFile(t *testing.T) {
+	content := "snapshot"
+	var archive bytes.Buffer
+	if err := archiveFile(&archive, strings.NewReader(content), "etcd.db", int64(len(content))); err != nil {
+		t.Fatalf("Failed to archive file: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "restored.db")
+	if err := extractFile(&archive, dest); err != nil {
+		t.Fatalf("Failed to extract file: %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil || string(data) != content {
+		t.Errorf("Expected the archived contents, got %q, %v", data, err)
+	}
+}
+
+func TestRestoreEtcdOnEveryControlPlaneNode(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	cluster := newFakeCluster("restore", 0)
+	cluster.Spec.ControlPlane.Count = 3
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+
+	var names, initialCluster []string
+	for i := 0; i < 3; i++ {
+		name := provider.getNodeName(cluster, RoleControlPlane, i)
+		peerURL := fmt.Sprintf("https://172.30.0.%d:2380", i+2)
+		manifest := fmt.Sprintf("    - --name=%s\n    - --initial-advertise-peer-urls=%s\n", name, peerURL)
+		if err := engine.WriteFile(name, staticPodManifests+"/etcd.yaml", []byte(manifest)); err != nil {
+			t.Fatalf("Failed to write etcd manifest on %s: %v", name, err)
+		}
+		names = append(names, name)
+		initialCluster = append(initialCluster, name+"="+peerURL)
+	}
+
+	var mu sync.Mutex
+	var steps []string
+	stopped := map[string]bool{}
+	engine.HandleExec(func(name string, cmd []string) (dockerfake.ExecResult, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		line := strings.Join(cmd, " ")
+		switch {
+		case cmd[0] == "crictl" && cmd[1] == "ps":
+			if stopped[name] {
+				return dockerfake.ExecResult{}, true
+			}
+			return dockerfake.ExecResult{Stdout: "etcd0\n"}, true
+		case strings.Contains(line, "etcdutl snapshot restore"):
+			for _, arg := range cmd {
+				if value, ok := strings.CutPrefix(arg, "--initial-cluster="); ok {
+					steps = append(steps, "restore "+name+" "+value)
+				}
+			}
+		case cmd[0] == "sh" && strings.Contains(line, "mv "+staticPodManifests+"/etcd.yaml"):
+			stopped[name] = true
+			steps = append(steps, "stop "+name)
+		case cmd[0] == "sh" && strings.Contains(line, "mv "+etcdRestoreDir+"/member"):
+			steps = append(steps, "swap "+name)
+		case cmd[0] == "sh" && strings.Contains(line, "mv "+stoppedManifests+"/etcd.yaml"):
+			stopped[name] = false
+			steps = append(steps, "start "+name)
+		case cmd[0] == "cat", cmd[0] == "rm", cmd[0] == "test":
+			return dockerfake.ExecResult{}, false
+		}
+		return dockerfake.ExecResult{}, true
+	})
+
+	location := v1alpha1.BackupLocation{HostPath: t.TempDir()}
+	snapshot := provider.backupPath(cluster, location, "etcd.db")
+	if err := os.MkdirAll(filepath.Dir(snapshot), 0o755); err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+	if err := os.WriteFile(snapshot, []byte("snapshot"), 0o644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	if err := provider.RestoreEtcd(ctx, cluster, location, "etcd.db"); err != nil {
+		t.Fatalf("Failed to restore etcd: %v", err)
+	}
+
+	// Every member is restored into the same new cluster, and etcd is stopped
+	// everywhere before any data is swapped
+	var want []string
+	for _, step := range []string{"restore", "stop", "swap", "start"} {
+		for _, name := range names {
+			if step == "restore" {
+				want = append(want, "restore "+name+" "+strings.Join(initialCluster, ","))
+			} else {
+				want = append(want, step+" "+name)
+			}
+		}
+	}
+	if strings.Join(steps, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected restore steps\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(steps, "\n"))
+	}
+}
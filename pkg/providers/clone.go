@@ -0,0 +1,469 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+const (
+	// cloneSourceMount is where the helper container filling a cloned node's
+	// volumes mounts the volumes of the source node
+	cloneSourceMount = "/mini-k8s-clone-source"
+
+	// kindKubeadmConfig is the kubeadm configuration kind leaves on its nodes
+	kindKubeadmConfig = "/kind/kubeadm.conf"
+
+	// kubeletKubeconfig is the kubeconfig the kubelet of kubeadm nodes uses
+	kubeletKubeconfig = "/etc/kubernetes/kubelet.conf"
+
+	// kubeletPKIDir holds the kubelet's client and serving certificates
+	kubeletPKIDir = "/var/lib/kubelet/pki"
+
+	// kubeadmPKIDir holds the cluster's certificates on control plane nodes
+	kubeadmPKIDir = "/etc/kubernetes/pki"
+)
+
+// renamedConfigDirs hold the configuration files of kubeadm nodes that name
+// nodes or their addresses
+var renamedConfigDirs = []string{"/etc/kubernetes", "/var/lib/kubelet", "/kind"}
+
+// renamedObjects are the kinds of API objects of kubeadm clusters that name
+// the control plane endpoint, e.g. kube-proxy's kubeconfig and kindnet's
+// environment
+var renamedObjects = []struct{ namespace, resource string }{
+	{"kube-system", "configmaps"},
+	{"kube-public", "configmaps"},
+	{"kube-system", "daemonsets"},
+}
+
+// reissuedCerts are the kubeadm certificates that name the control plane
+// node or carry its address, by kubeadm init phase and file name
+var reissuedCerts = []struct{ phase, file string }{
+	{"apiserver", "apiserver"},
+	{"etcd-server", "etcd/server"},
+	{"etcd-peer", "etcd/peer"},
+}
+
+// nodeClone is a node of a source cluster and the copy made of it
+type nodeClone struct {
+	source        container.Summary
+	sourceAddress string
+	role          string
+	name          string
+
+	// node and address are set once the copy has been created
+	node    container.Summary
+	address string
+}
+
+// rename is a string naming a node of the source cluster and what names the
+// corresponding node of the clone instead
+type rename struct {
+	from, to string
+}
+
+// CloneCluster creates cluster as a copy of source. Every source node is
+// paused while its filesystem is committed to an image and its volumes are
+// copied, and the copy is created from both on a network of its own. On
+// kubeadm clusters the copies are then given their new names and addresses:
+// configuration naming the source nodes is rewritten, the certificates that
+// carry names are issued again from the copied CA, etcd is restored from a
+// snapshot of the source under the new member name, and the Node objects of
+// the source are deleted. Only single control plane kubeadm clusters can be cloned.
+func (p *DockerProvider) CloneCluster(ctx context.Context, cluster, source *v1alpha1.Cluster) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.CloneCluster", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	sourceNodes, err := p.listClusterContainers(ctx, source)
+	if err != nil {
+		return err
+	}
+	if len(sourceNodes) == 0 {
+		return ErrClusterNotFound
+	}
+	exists, err := p.clusterExists(ctx, cluster)
+	if err != nil {
+		return fmt.Errorf("failed to check if cluster exists: %w", err)
+	}
+	if exists {
+		return ErrClusterExists
+	}
+
+	controlPlanes, workers := nodesByRole(sourceNodes, "")
+	if len(controlPlanes) == 0 {
+		return fmt.Errorf("%w: cluster %s has no control plane node", ErrInvalidConfig, source.Name)
+	}
+	// Control planes come first, they are set up before the workers
+	var clones []*nodeClone
+	for _, group := range []struct {
+		role  string
+		nodes []container.Summary
+	}{{RoleControlPlane, controlPlanes}, {RoleWorker, workers}} {
+		for i, node := range group.nodes {
+			if node.State != "running" {
+				return fmt.Errorf("node %s of cluster %s is %s, only running clusters can be cloned", containerName(node), source.Name, node.State)
+			}
+			clones = append(clones, &nodeClone{source: node, role: group.role, name: p.getNodeName(cluster, group.role, i)})
+		}
+	}
+	log := clusterLogger(ctx, cluster).WithValues("source", source.Name)
+	log.Info("Cloning cluster", "controlPlanes", len(controlPlanes), "workers", len(workers))
+
+	// etcd is snapshotted before anything is paused: the copied etcd data
+	// is replaced with the snapshot, which is consistent
+	bootstrapped := p.bootstrapped(ctx, controlPlanes[0].ID)
+	var snapshot string
+	if bootstrapped {
+		if len(controlPlanes) > 1 {
+			return fmt.Errorf("%w: cloning is only supported for clusters with one control plane node", ErrInvalidConfig)
+		}
+		tmpDir, err := os.MkdirTemp("", "mini-k8s-clone-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		name := etcdSnapshotName()
+		snapshot = filepath.Join(tmpDir, name)
+		if err := p.saveEtcdSnapshot(ctx, source, controlPlanes[0], name, snapshot); err != nil {
+			return err
+		}
+	}
+
+	clusterNetwork, err := p.createNetwork(ctx, cluster)
+	if err != nil {
+		return fmt.Errorf("failed to create network: %w", err)
+	}
+	log.V(1).Info("Using network", "step", "network", "network", clusterNetwork.Name, "networkID", shortID(clusterNetwork.ID))
+	defer func() {
+		if err != nil {
+			p.cleanupFailedCluster(ctx, cluster)
+		}
+	}()
+
+	byID := make(map[string]*nodeClone, len(clones))
+	for _, clone := range clones {
+		byID[clone.source.ID] = clone
+	}
+	err = p.forEachNode(ctx, "clone", sourceNodes, func(ctx context.Context, node container.Summary) error {
+		clone := byID[node.ID]
+		if err := p.cloneNode(ctx, cluster, clone, clusterNetwork); err != nil {
+			recordWarning(ctx, cluster, ReasonNodeCloneFailed, "Failed to clone node %s to %s: %v", containerName(node), clone.name, err)
+			return err
+		}
+		recordNormal(ctx, cluster, ReasonNodeCloned, "Cloned node %s of cluster %s to %s", containerName(node), source.Name, clone.name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if bootstrapped {
+		if err := p.updateClonedIdentities(ctx, cluster, clones, snapshot); err != nil {
+			return err
+		}
+	}
+	log.Info("Cloned cluster")
+	return nil
+}
+
+// cloneNode creates the copy of a source node from its committed filesystem
+// and copies of its volumes, and starts it
+func (p *DockerProvider) cloneNode(ctx context.Context, cluster *v1alpha1.Cluster, clone *nodeClone, clusterNetwork *network.Summary) error {
+	log := clusterLogger(ctx, cluster).WithValues("node", clone.name, "source", containerName(clone.source))
+	info, err := p.client.ContainerInspect(ctx, clone.source.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", clone.source.ID, err)
+	}
+	clone.sourceAddress = nodeAddress(info, clone.source.Labels[LabelNetwork])
+
+	// The filesystem and the volumes are copied as of the same moment
+	log.V(2).Info("Pausing source node", "step", "pause")
+	if err := p.client.ContainerPause(ctx, clone.source.ID); err != nil {
+		return fmt.Errorf("failed to pause container %s: %w", clone.source.ID, err)
+	}
+	defer func() {
+		if err := p.client.ContainerUnpause(context.WithoutCancel(ctx), clone.source.ID); err != nil {
+			log.Error(err, "Failed to unpause source node")
+		}
+	}()
+
+	log.V(2).Info("Committing container", "step", "commit")
+	// The committed image belongs to the copy's cluster and goes when it is deleted
+	imageID, err := p.commitNode(ctx, cluster, clone.source.ID, container.CommitOptions{
+		Reference: fmt.Sprintf("mini-k8s-manager/clone:%s", clone.name),
+		Comment:   "created by mini-k8s-manager while cloning " + containerName(clone.source),
+	})
+	if err != nil {
+		return err
+	}
+
+	config := *info.Config
+	config.Image = imageID
+	config.Hostname = clone.name
+	config.Labels = nodeLabels(cluster, clone.role, clusterNetwork.Name)
+	config.Labels[LabelNodeImage] = info.Config.Labels[LabelNodeImage]
+	if config.Labels[LabelNodeImage] == "" {
+		config.Labels[LabelNodeImage] = info.Config.Image
+	}
+
+	// Every volume of the source becomes an anonymous volume of the copy, so
+	// it goes away with the node
+	config.Volumes = maps.Clone(info.Config.Volumes)
+	if config.Volumes == nil {
+		config.Volumes = map[string]struct{}{}
+	}
+	volumes := map[string]string{}
+	for _, m := range info.Mounts {
+		if m.Type == mount.TypeVolume {
+			volumes[m.Destination] = m.Name
+			config.Volumes[m.Destination] = struct{}{}
+		}
+	}
+
+	hostConfig := *info.HostConfig
+	hostConfig.NetworkMode = container.NetworkMode(clusterNetwork.Name)
+	hostConfig.Mounts = nil
+	for _, m := range info.HostConfig.Mounts {
+		if m.Type != mount.TypeVolume {
+			hostConfig.Mounts = append(hostConfig.Mounts, m)
+		}
+	}
+	// Published ports get new host ports rather than clash with the source's
+	hostConfig.PortBindings = maps.Clone(info.HostConfig.PortBindings)
+	for port, bindings := range hostConfig.PortBindings {
+		bindings = slices.Clone(bindings)
+		for i := range bindings {
+			bindings[i].HostPort = ""
+		}
+		hostConfig.PortBindings[port] = bindings
+	}
+
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			clusterNetwork.Name: {
+				NetworkID: clusterNetwork.ID,
+			},
+		},
+	}
+
+	log.V(2).Info("Creating container", "step", "create", "image", shortID(imageID))
+	resp, err := p.client.ContainerCreate(ctx, &config, &hostConfig, networkingConfig, nil, clone.name)
+	if err != nil {
+		p.removeUnusedImage(ctx, cluster, imageID)
+		return fmt.Errorf("failed to create container %s from image %s: %w", clone.name, imageID, err)
+	}
+	if err := p.copyVolumes(ctx, cluster, resp.ID, imageID, volumes); err != nil {
+		return err
+	}
+
+	log.V(2).Info("Starting container", "step", "start")
+	if err := p.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container %s: %w", clone.name, err)
+	}
+	started, err := p.client.ContainerInspect(ctx, resp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", resp.ID, err)
+	}
+	clone.node = container.Summary{ID: resp.ID, Names: []string{"/" + clone.name}, Labels: config.Labels, State: "running"}
+	clone.address = nodeAddress(started, clusterNetwork.Name)
+	log.Info("Cloned node")
+	return nil
+}
+
+// copyVolumes replaces the content of the volumes of the container cloneID
+// with that of the source volumes, by mount point. The copy is made by a
+// helper container running image, which is removed once it is done.
+func (p *DockerProvider) copyVolumes(ctx context.Context, cluster *v1alpha1.Cluster, cloneID, image string, volumes map[string]string) error {
+	if len(volumes) == 0 {
+		return nil
+	}
+	targets := slices.Sorted(maps.Keys(volumes))
+
+	config := &container.Config{
+		Image:      image,
+		Entrypoint: []string{"sleep", "infinity"},
+	}
+	hostConfig := &container.HostConfig{VolumesFrom: []string{cloneID}}
+	for _, target := range targets {
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   volumes[target],
+			Target:   path.Join(cloneSourceMount, target),
+			ReadOnly: true,
+		})
+	}
+	resp, err := p.client.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create volume copy helper container: %w", err)
+	}
+	defer func() {
+		if err := p.client.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true}); err != nil {
+			clusterLogger(ctx, cluster).Error(err, "Failed to remove volume copy helper container", "containerID", shortID(resp.ID))
+		}
+	}()
+	if err := p.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start volume copy helper container: %w", err)
+	}
+
+	for _, target := range targets {
+		// New volumes start out with the image's content, which goes first
+		script := fmt.Sprintf("find %[1]s -mindepth 1 -delete && cp -a %[2]s/. %[1]s/", target, path.Join(cloneSourceMount, target))
+		if _, err := p.execInContainer(ctx, resp.ID, "sh", "-c", script); err != nil {
+			return fmt.Errorf("failed to copy volume %s: %w", volumes[target], err)
+		}
+	}
+	return nil
+}
+
+// updateClonedIdentities gives the kubeadm nodes of a cloned cluster their new
+// names and addresses. The kubelets are stopped until each node has a client
+// certificate for its new name, and the control plane comes up before the workers.
+func (p *DockerProvider) updateClonedIdentities(ctx context.Context, cluster *v1alpha1.Cluster, clones []*nodeClone, snapshot string) error {
+	log := clusterLogger(ctx, cluster).WithValues("step", "clone-identities")
+	renames := cloneRenames(clones)
+	expressions := renameExpressions(renames)
+	controlPlane := clones[0].node
+
+	for _, clone := range clones {
+		log.V(1).Info("Renaming node configuration", "node", clone.name)
+		if _, err := p.execInContainer(ctx, clone.node.ID, "systemctl", "stop", "kubelet"); err != nil {
+			return fmt.Errorf("failed to stop kubelet on node %s: %w", clone.name, err)
+		}
+		find := append([]string{"find"}, renamedConfigDirs...)
+		find = append(find, "-maxdepth", "2", "-type", "f",
+			"(", "-name", "*.conf", "-o", "-name", "*.yaml", "-o", "-name", "*.env", ")",
+			"-exec", "sed", "-i")
+		for _, expression := range expressions {
+			find = append(find, "-e", expression)
+		}
+		if _, err := p.execInContainer(ctx, clone.node.ID, append(find, "{}", "+")...); err != nil {
+			return fmt.Errorf("failed to rename configuration on node %s: %w", clone.name, err)
+		}
+		if _, err := p.execInContainer(ctx, clone.node.ID, "find", kubeletPKIDir, "-name", "kubelet*", "-delete"); err != nil {
+			return fmt.Errorf("failed to remove kubelet certificates on node %s: %w", clone.name, err)
+		}
+	}
+
+	log.Info("Issuing certificates for the new names")
+	for _, cert := range reissuedCerts {
+		base := path.Join(kubeadmPKIDir, cert.file)
+		if _, err := p.execInContainer(ctx, controlPlane.ID, "rm", "-f", base+".crt", base+".key"); err != nil {
+			return err
+		}
+		if _, err := p.execInContainer(ctx, controlPlane.ID, "kubeadm", "init", "phase", "certs", cert.phase, "--config="+kindKubeadmConfig); err != nil {
+			return fmt.Errorf("failed to issue %s certificate: %w", cert.phase, err)
+		}
+	}
+	for _, clone := range clones {
+		kubeconfig, err := p.execInContainer(ctx, controlPlane.ID, "kubeadm", "kubeconfig", "user",
+			"--config="+kindKubeadmConfig, "--client-name=system:node:"+clone.name, "--org=system:nodes")
+		if err != nil {
+			return fmt.Errorf("failed to issue kubelet credentials for node %s: %w", clone.name, err)
+		}
+		if err := p.writeFileToContainer(ctx, clone.node.ID, []byte(kubeconfig), kubeletKubeconfig); err != nil {
+			return err
+		}
+	}
+
+	// etcd comes up with the source's membership; restoring the snapshot
+	// recreates it under the member name and peer URL of the copy
+	if _, err := p.execInContainer(ctx, controlPlane.ID, "systemctl", "start", "kubelet"); err != nil {
+		return fmt.Errorf("failed to start kubelet on node %s: %w", containerName(controlPlane), err)
+	}
+	if err := p.waitForEtcd(ctx, controlPlane.ID, true); err != nil {
+		return err
+	}
+	if err := p.restoreEtcdSnapshot(ctx, cluster, []container.Summary{controlPlane}, snapshot); err != nil {
+		return err
+	}
+	if err := p.waitForAPIServer(ctx, cluster, controlPlane); err != nil {
+		return err
+	}
+
+	log.Info("Renaming nodes in the API")
+	kubectl := []string{"kubectl", "--kubeconfig=" + adminKubeconfig}
+	deleteNodes := append(slices.Clone(kubectl), "delete", "node", "--ignore-not-found", "--wait=false")
+	for _, clone := range clones {
+		deleteNodes = append(deleteNodes, containerName(clone.source))
+	}
+	if _, err := p.execInContainer(ctx, controlPlane.ID, deleteNodes...); err != nil {
+		return fmt.Errorf("failed to delete the source cluster's nodes: %w", err)
+	}
+	quoted := make([]string, len(expressions))
+	for i, expression := range expressions {
+		quoted[i] = "-e '" + expression + "'"
+	}
+	for _, objects := range renamedObjects {
+		get := fmt.Sprintf("%s -n %s get %s -o yaml", strings.Join(kubectl, " "), objects.namespace, objects.resource)
+		replace := fmt.Sprintf("%s replace -f -", strings.Join(kubectl, " "))
+		if _, err := p.execInContainer(ctx, controlPlane.ID, "sh", "-c", get+" | sed "+strings.Join(quoted, " ")+" | "+replace); err != nil {
+			return fmt.Errorf("failed to rename %s in namespace %s: %w", objects.resource, objects.namespace, err)
+		}
+	}
+	if _, err := p.execInContainer(ctx, controlPlane.ID, append(kubectl, "-n", "kube-system", "rollout", "restart", "daemonset/kube-proxy")...); err != nil {
+		return fmt.Errorf("failed to restart kube-proxy: %w", err)
+	}
+
+	for _, clone := range clones[1:] {
+		if _, err := p.execInContainer(ctx, clone.node.ID, "systemctl", "start", "kubelet"); err != nil {
+			return fmt.Errorf("failed to start kubelet on node %s: %w", clone.name, err)
+		}
+	}
+	return nil
+}
+
+// cloneRenames returns how the names and addresses of source nodes change in
+// their copies, longest first so no rename applies to part of a longer one
+func cloneRenames(clones []*nodeClone) []rename {
+	var renames []rename
+	for _, clone := range clones {
+		renames = append(renames, rename{from: containerName(clone.source), to: clone.name})
+		if clone.sourceAddress != "" && clone.address != "" {
+			renames = append(renames, rename{from: clone.sourceAddress, to: clone.address})
+		}
+	}
+	sort.SliceStable(renames, func(i, j int) bool { return len(renames[i].from) > len(renames[j].from) })
+	return renames
+}
+
+// renameExpressions returns sed expressions applying renames to whole words
+func renameExpressions(renames []rename) []string {
+	pattern := strings.NewReplacer(`\`, `\\`, `/`, `\/`, `.`, `\.`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `^`, `\^`, `$`, `\$`)
+	replacement := strings.NewReplacer(`\`, `\\`, `/`, `\/`, `&`, `\&`)
+	expressions := make([]string, 0, len(renames))
+	for _, r := range renames {
+		expressions = append(expressions, fmt.Sprintf(`s/\b%s\b/%s/g`, pattern.Replace(r.from), replacement.Replace(r.to)))
+	}
+	return expressions
+}
+
+// nodeAddress returns the IPv4 address of a container on the named network,
+// or on the first network it is attached to if it is not on that one
+func nodeAddress(info container.InspectResponse, networkName string) string {
+	if info.NetworkSettings == nil {
+		return ""
+	}
+	if endpoint := info.NetworkSettings.Networks[networkName]; endpoint != nil {
+		return endpoint.IPAddress
+	}
+	for _, name := range slices.Sorted(maps.Keys(info.NetworkSettings.Networks)) {
+		if endpoint := info.NetworkSettings.Networks[name]; endpoint != nil && endpoint.IPAddress != "" {
+			return endpoint.IPAddress
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestCloneRenames(t *testing.T) {
+	clones := []*nodeClone{
+		{
+			source:        container.Summary{Names: []string{"/kind-control-plane"}},
+			sourceAddress: "172.18.0.2",
+			name:          "cluster-fork-1a2b3c4d-control-plane-0",
+			address:       "10.10.1.2",
+		},
+		{
+			source:        container.Summary{Names: []string{"/kind-worker"}},
+			sourceAddress: "172.18.0.3",
+			name:          "cluster-fork-1a2b3c4d-worker-0",
+			address:       "10.10.1.3",
+		},
+	}
+
+	expected := []string{
+		`s/\bkind-control-plane\b/cluster-fork-1a2b3c4d-control-plane-0/g`,
+		`s/\bkind-worker\b/cluster-fork-1a2b3c4d-worker-0/g`,
+		`s/\b172\.18\.0\.2\b/10.10.1.2/g`,
+		`s/\b172\.18\.0\.3\b/10.10.1.3/g`,
+	}
+	if got := renameExpressions(cloneRenames(clones)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected expressions %v, got %v", expected, got)
+	}
+}
+
+func TestNodeAddress(t *testing.T) {
+	info := container.InspectResponse{
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"kind":   {IPAddress: "172.18.0.2"},
+				"bridge": {IPAddress: "172.17.0.5"},
+			},
+		},
+	}
+	if address := nodeAddress(info, "kind"); address != "172.18.0.2" {
+		t.Errorf("Expected the address on the named network, got %s", address)
+	}
+	if address := nodeAddress(info, "missing"); address != "172.17.0.5" {
+		t.Errorf("Expected the address on the first network by name, got %s", address)
+	}
+}
+
+func TestCloneNodeImageGoesWithCluster(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	sourceID := createRelabelNode(t, engine, "kind-control-plane")
+	cluster := newFakeCluster("fork", 0)
+
+	created, err := engine.NetworkCreate(ctx, "fork-net", network.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create network: %v", err)
+	}
+	clusterNetwork, err := engine.NetworkInspect(ctx, created.ID, network.InspectOptions{})
+	if err != nil {
+		t.Fatalf("Failed to inspect network: %v", err)
+	}
+	clone := &nodeClone{
+		source: container.Summary{ID: sourceID, Names: []string{"/kind-control-plane"}},
+		role:   "control-plane",
+		name:   "cluster-fork-control-plane-0",
+	}
+	if err := provider.cloneNode(ctx, cluster, clone, &clusterNetwork); err != nil {
+		t.Fatalf("Failed to clone node: %v", err)
+	}
+
+	images := committedImages(t, engine)
+	if len(images) != 1 || images[0].Labels[LabelClusterName] != "fork" {
+		t.Fatalf("Expected the image the node was cloned from to be labelled for the cluster, got %+v", images)
+	}
+	if err := provider.DeleteCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+	if images := committedImages(t, engine); len(images) != 0 {
+		t.Errorf("Expected the committed image to be removed with the cluster, got %+v", images)
+	}
+	if _, err := engine.ContainerInspect(ctx, sourceID); err != nil {
+		t.Errorf("Expected the source node to be kept, got %v", err)
+	}
+}
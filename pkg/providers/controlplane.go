@@ -0,0 +1,545 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+const (
+	// haproxyConfig is where kind's load balancer image reads its configuration
+	haproxyConfig = "/usr/local/etc/haproxy/haproxy.cfg"
+
+	// apiServerPort is the port the API server listens on on control plane nodes
+	apiServerPort = 6443
+)
+
+// controlPlaneNode is a control plane node container together with what its
+// cluster knows about it
+type controlPlaneNode struct {
+	summary container.Summary
+
+	// hostname is the name of the node in Kubernetes and etcd
+	hostname string
+
+	// address is the node's address on the cluster network
+	address string
+
+	// joined reports whether kubeadm set the node up as part of the cluster
+	joined bool
+
+	// member reports whether the node has an etcd member
+	member bool
+}
+
+// controlPlanePlan is what it takes to bring the control plane of a cluster
+// to the desired count. Its steps are derived from the nodes' containers and
+// etcd membership alone, so a scaling interrupted by a manager restart is
+// finished in the same order when it is planned again.
+type controlPlanePlan struct {
+	// abandoned holds nodes whose etcd member an interrupted scale-down has
+	// removed already; their removal is finished before anything else
+	abandoned []controlPlaneNode
+
+	// remove holds the surplus nodes, removed one at a time in this order
+	remove []controlPlaneNode
+
+	// join holds kept nodes that have not joined the cluster yet
+	join []controlPlaneNode
+
+	// create holds the names of the nodes to create and join
+	create []string
+}
+
+// empty reports whether the control plane is as desired already
+func (s controlPlanePlan) empty() bool {
+	return len(s.abandoned) == 0 && len(s.remove) == 0 && len(s.join) == 0 && len(s.create) == 0
+}
+
+// etcdMember is a member of a cluster's etcd
+type etcdMember struct {
+	id         uint64
+	name       string
+	peerURLs   []string
+	clientURLs []string
+	healthy    bool
+}
+
+// validateControlPlaneCount rejects control planes whose etcd would lose its
+// majority as soon as one member fails, or that have no member at all
+func validateControlPlaneCount(count int32) error {
+	if count < 1 || count%2 == 0 {
+		return fmt.Errorf("%w: the control plane needs an odd number of nodes for etcd to keep a majority, not %d", ErrInvalidConfig, count)
+	}
+	return nil
+}
+
+// scaleControlPlane brings the cluster's control plane to the desired count.
+// Nodes of clusters bootstrapped with kubeadm join with kubeadm join
+// --control-plane and leave one at a time: they are drained, their etcd
+// member is removed as long as etcd keeps a majority of healthy members, and
+// their Node object and container are deleted. The load balancer in front
+// of the control plane, if there is one, follows every change.
+func (p *DockerProvider) scaleControlPlane(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	desired := cluster.Spec.ControlPlane.Count
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	nodes, admin, err := p.inspectControlPlane(ctx, cluster, containers)
+	if err != nil {
+		return err
+	}
+	plan := p.planControlPlaneScaling(cluster, nodes, int(desired), admin != nil)
+	if plan.empty() {
+		return nil
+	}
+	if err := validateControlPlaneCount(desired); err != nil {
+		return err
+	}
+	log := clusterLogger(ctx, cluster)
+	log.Info("Scaling control plane", "to", desired, "create", len(plan.create), "remove", len(plan.remove)+len(plan.abandoned), "join", len(plan.join))
+
+	if len(plan.create) > 0 {
+		if err := p.images.ensureImage(ctx, cluster, p.getNodeImage(cluster), nodeImagePolicy(cluster)); err != nil {
+			return err
+		}
+	}
+
+	// Nodes that were never bootstrapped only need their containers created
+	// or removed
+	if admin == nil {
+		for _, name := range plan.create {
+			if err := p.createControlPlaneContainer(ctx, cluster, name); err != nil {
+				return err
+			}
+		}
+		for _, node := range plan.remove {
+			if err := p.removeNode(ctx, cluster, node.summary, 60); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, node := range plan.abandoned {
+		log.Info("Finishing removal of control plane node", "node", containerName(node.summary))
+		if err := p.deleteControlPlaneNode(ctx, cluster, *admin, node); err != nil {
+			return err
+		}
+	}
+	for _, node := range plan.remove {
+		if err := p.removeControlPlaneNode(ctx, cluster, *admin, node); err != nil {
+			return err
+		}
+	}
+	for _, node := range plan.join {
+		if err := p.joinControlPlaneNode(ctx, cluster, *admin, node.summary.ID, containerName(node.summary)); err != nil {
+			return err
+		}
+	}
+	for _, name := range plan.create {
+		if err := p.createControlPlaneContainer(ctx, cluster, name); err != nil {
+			return err
+		}
+		if err := p.joinControlPlaneNode(ctx, cluster, *admin, name, name); err != nil {
+			return err
+		}
+	}
+	return p.updateLoadBalancer(ctx, cluster)
+}
+
+// inspectControlPlane gathers what the cluster knows about its control plane
+// nodes. For clusters bootstrapped with kubeadm it also returns the running
+// control plane node with an etcd member the cluster is managed through, and
+// fails if there is none; for other clusters that node is nil.
+func (p *DockerProvider) inspectControlPlane(ctx context.Context, cluster *v1alpha1.Cluster, containers []container.Summary) ([]controlPlaneNode, *container.Summary, error) {
+	controlPlanes, _ := nodesByRole(containers, "")
+	nodes := make([]controlPlaneNode, 0, len(controlPlanes))
+	anyJoined := false
+	for _, cont := range controlPlanes {
+		info, err := p.client.ContainerInspect(ctx, cont.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to inspect container %s: %w", cont.ID, err)
+		}
+		node := controlPlaneNode{
+			summary:  cont,
+			hostname: nodeHostname(info, containerName(cont)),
+			address:  nodeAddress(info, cont.Labels[LabelNetwork]),
+			joined:   p.joinedNode(ctx, cont.ID),
+		}
+		anyJoined = anyJoined || node.joined
+		nodes = append(nodes, node)
+	}
+	if !anyJoined {
+		return nodes, nil, nil
+	}
+
+	for _, candidate := range nodes {
+		if candidate.summary.State != "running" || !candidate.joined || !p.bootstrapped(ctx, candidate.summary.ID) {
+			continue
+		}
+		members, err := p.etcdMembers(ctx, candidate.summary)
+		if err != nil {
+			clusterLogger(ctx, cluster).V(1).Info("Cannot list etcd members", "node", containerName(candidate.summary), "error", err.Error())
+			continue
+		}
+		if findEtcdMember(members, candidate) == nil {
+			continue
+		}
+		for i := range nodes {
+			nodes[i].member = findEtcdMember(members, nodes[i]) != nil
+		}
+		admin := candidate.summary
+		return nodes, &admin, nil
+	}
+	return nil, nil, fmt.Errorf("no running control plane node with an etcd member in cluster %s", cluster.Name)
+}
+
+// planControlPlaneScaling works out how to get from the cluster's control
+// plane nodes to desired ones. In a bootstrapped cluster nodes that joined
+// but have no etcd member are being removed, and nodes that have not joined
+// are joined. Surplus nodes are removed in removal order: nodes that have not
+// joined, then stopped ones, then the highest index first, so the node the
+// cluster was initialised on goes last. New nodes take the lowest free indices.
+func (p *DockerProvider) planControlPlaneScaling(cluster *v1alpha1.Cluster, nodes []controlPlaneNode, desired int, bootstrapped bool) controlPlanePlan {
+	var plan controlPlanePlan
+	var kept []controlPlaneNode
+	used := map[int]bool{}
+	for _, node := range nodes {
+		if index, ok := p.nodeIndex(cluster, RoleControlPlane, containerName(node.summary)); ok {
+			used[index] = true
+		}
+		if bootstrapped && node.joined && !node.member {
+			plan.abandoned = append(plan.abandoned, node)
+			continue
+		}
+		kept = append(kept, node)
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		a, b := kept[i], kept[j]
+		if bootstrapped && a.joined != b.joined {
+			return !a.joined
+		}
+		if running := a.summary.State == "running"; running != (b.summary.State == "running") {
+			return !running
+		}
+		ai, aok := p.nodeIndex(cluster, RoleControlPlane, containerName(a.summary))
+		bi, bok := p.nodeIndex(cluster, RoleControlPlane, containerName(b.summary))
+		if aok && bok && ai != bi {
+			return ai > bi
+		}
+		return containerName(a.summary) > containerName(b.summary)
+	})
+	if surplus := len(kept) - desired; surplus > 0 {
+		plan.remove = kept[:surplus]
+		kept = kept[surplus:]
+	}
+	for index := 0; len(kept)+len(plan.create) < desired; index++ {
+		if !used[index] {
+			plan.create = append(plan.create, p.getNodeName(cluster, RoleControlPlane, index))
+		}
+	}
+	if bootstrapped {
+		for i := len(kept) - 1; i >= 0; i-- {
+			if !kept[i].joined {
+				plan.join = append(plan.join, kept[i])
+			}
+		}
+	}
+	return plan
+}
+
+// createControlPlaneContainer creates the container of a new control plane node
+func (p *DockerProvider) createControlPlaneContainer(ctx context.Context, cluster *v1alpha1.Cluster, name string) error {
+	clusterNetwork, err := p.requireClusterNetwork(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if err := p.createNode(ctx, cluster, name, RoleControlPlane, clusterNetwork, cluster.Spec.ControlPlane.MachineConfig); err != nil {
+		return fmt.Errorf("failed to create control plane node %s: %w", name, err)
+	}
+	return nil
+}
+
+// joinControlPlaneNode joins the node container nodeID as a control plane
+// node through admin. An etcd member left behind by an interrupted join of
+// the node is removed first, as it would keep the node from joining again.
+func (p *DockerProvider) joinControlPlaneNode(ctx context.Context, cluster *v1alpha1.Cluster, admin container.Summary, nodeID, name string) error {
+	info, err := p.client.ContainerInspect(ctx, nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", nodeID, err)
+	}
+	node := controlPlaneNode{hostname: nodeHostname(info, name)}
+	if info.Config != nil {
+		node.address = nodeAddress(info, info.Config.Labels[LabelNetwork])
+	}
+	members, err := p.etcdMembers(ctx, admin)
+	if err != nil {
+		return err
+	}
+	if stale := findEtcdMember(members, node); stale != nil {
+		if err := p.removeEtcdMember(ctx, cluster, admin, members, *stale); err != nil {
+			return err
+		}
+	}
+
+	join, err := p.kubeadmJoinCommand(ctx, admin.ID)
+	if err != nil {
+		return fmt.Errorf("cannot join control plane node %s: %w", name, err)
+	}
+	key, err := p.uploadCertificates(ctx, admin.ID)
+	if err != nil {
+		return fmt.Errorf("cannot join control plane node %s: %w", name, err)
+	}
+	return p.joinNode(ctx, cluster, name, append(join, "--control-plane", "--certificate-key", key))
+}
+
+// uploadCertificates stores the control plane certificates in the cluster,
+// encrypted, for a joining control plane node, and returns the key to
+// decrypt them with
+func (p *DockerProvider) uploadCertificates(ctx context.Context, adminID string) (string, error) {
+	out, err := p.execInContainer(ctx, adminID, "kubeadm", "init", "phase", "upload-certs", "--upload-certs")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload control plane certificates: %w", err)
+	}
+	lines := strings.Fields(out)
+	if len(lines) == 0 {
+		return "", fmt.Errorf("kubeadm did not print a certificate key")
+	}
+	return lines[len(lines)-1], nil
+}
+
+// removeControlPlaneNode drains a surplus control plane node and deletes its
+// Node object, then removes its etcd member and its container. The member is
+// only removed if etcd keeps a majority of healthy members without it.
+func (p *DockerProvider) removeControlPlaneNode(ctx context.Context, cluster *v1alpha1.Cluster, admin container.Summary, node controlPlaneNode) error {
+	name := containerName(node.summary)
+	members, err := p.etcdMembers(ctx, admin)
+	if err != nil {
+		return err
+	}
+	member := findEtcdMember(members, node)
+	if member != nil && !keepsQuorum(members, member.id) {
+		return fmt.Errorf("removing the etcd member of control plane node %s would leave etcd without a majority of healthy members", name)
+	}
+
+	clusterLogger(ctx, cluster).Info("Removing control plane node", "node", name)
+	if err := p.drainNode(ctx, cluster, admin, node.summary); err != nil {
+		return err
+	}
+	if member != nil {
+		if err := p.removeEtcdMember(ctx, cluster, admin, members, *member); err != nil {
+			return err
+		}
+	}
+	return p.deleteControlPlaneNode(ctx, cluster, admin, node)
+}
+
+// deleteControlPlaneNode deletes the Node object and the container of a
+// control plane node that no longer has an etcd member
+func (p *DockerProvider) deleteControlPlaneNode(ctx context.Context, cluster *v1alpha1.Cluster, admin container.Summary, node controlPlaneNode) error {
+	if _, err := p.execInContainer(ctx, admin.ID, "kubectl", "--kubeconfig="+adminKubeconfig, "delete", "node", node.hostname, "--ignore-not-found"); err != nil {
+		return fmt.Errorf("failed to delete node %s: %w", containerName(node.summary), err)
+	}
+	return p.removeNode(ctx, cluster, node.summary, 60)
+}
+
+// etcdMembers lists the etcd members of the cluster through the etcd member
+// of node and checks the health of each of them
+func (p *DockerProvider) etcdMembers(ctx context.Context, node container.Summary) ([]etcdMember, error) {
+	etcdID, err := p.etcdContainerID(ctx, node.ID)
+	if err != nil {
+		return nil, err
+	}
+	out, err := p.execInContainer(ctx, node.ID, etcdctlCommand(etcdID, "", "member", "list", "--write-out=json")...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etcd members: %w", err)
+	}
+	members, err := parseEtcdMembers([]byte(out))
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		if len(members[i].clientURLs) == 0 {
+			continue
+		}
+		_, err := p.execInContainer(ctx, node.ID, etcdctlCommand(etcdID, strings.Join(members[i].clientURLs, ","), "endpoint", "health")...)
+		members[i].healthy = err == nil
+	}
+	return members, nil
+}
+
+// removeEtcdMember removes member from etcd through the etcd member of admin
+func (p *DockerProvider) removeEtcdMember(ctx context.Context, cluster *v1alpha1.Cluster, admin container.Summary, members []etcdMember, member etcdMember) error {
+	if !keepsQuorum(members, member.id) {
+		return fmt.Errorf("removing etcd member %s would leave etcd without a majority of healthy members", member.name)
+	}
+	etcdID, err := p.etcdContainerID(ctx, admin.ID)
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatUint(member.id, 16)
+	if _, err := p.execInContainer(ctx, admin.ID, etcdctlCommand(etcdID, "", "member", "remove", id)...); err != nil {
+		return fmt.Errorf("failed to remove etcd member %s: %w", id, err)
+	}
+	clusterLogger(ctx, cluster).Info("Removed etcd member", "member", member.name, "id", id)
+	recordNormal(ctx, cluster, ReasonEtcdMemberRemoved, "Removed etcd member %s (%s)", member.name, id)
+	return nil
+}
+
+// updateLoadBalancer points the load balancer kind runs in front of multiple
+// control plane nodes at the cluster's running control plane nodes and makes
+// it reload its configuration. Clusters without one are left alone.
+func (p *DockerProvider) updateLoadBalancer(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	var loadBalancer *container.Summary
+	backends := map[string]string{}
+	for _, cont := range containers {
+		switch nodeRole(cont.Labels) {
+		case RoleExternalLoadBalancer:
+			loadBalancer = &cont
+		case RoleControlPlane:
+			if cont.State != "running" {
+				continue
+			}
+			info, err := p.client.ContainerInspect(ctx, cont.ID)
+			if err != nil {
+				return fmt.Errorf("failed to inspect container %s: %w", cont.ID, err)
+			}
+			if address := nodeAddress(info, cont.Labels[LabelNetwork]); address != "" {
+				backends[containerName(cont)] = address
+			}
+		}
+	}
+	if loadBalancer == nil {
+		return nil
+	}
+
+	name := containerName(*loadBalancer)
+	if err := p.writeFileToContainer(ctx, loadBalancer.ID, []byte(haproxyConfiguration(backends)), haproxyConfig); err != nil {
+		return fmt.Errorf("failed to configure load balancer %s: %w", name, err)
+	}
+	if loadBalancer.State == "running" {
+		if _, err := p.execInContainer(ctx, loadBalancer.ID, "kill", "-s", "HUP", "1"); err != nil {
+			return fmt.Errorf("failed to reload load balancer %s: %w", name, err)
+		}
+	}
+	clusterLogger(ctx, cluster).Info("Updated load balancer", "node", name, "backends", len(backends))
+	recordNormal(ctx, cluster, ReasonLoadBalancerUpdated, "Load balancer %s now sends API traffic to %d control plane nodes", name, len(backends))
+	return nil
+}
+
+// haproxyConfiguration returns the load balancer configuration for the API
+// servers at backends, keyed by node name. It follows the one kind writes.
+func haproxyConfiguration(backends map[string]string) string {
+	var b strings.Builder
+	b.WriteString(`global
+  log /dev/log local0
+  log /dev/log local1 notice
+  daemon
+
+defaults
+  log global
+  mode tcp
+  option dontlognull
+  timeout connect 5000
+  timeout client 50000
+  timeout server 50000
+  default-server init-addr none
+
+frontend control-plane
+  bind *:6443
+  default_backend kube-apiservers
+
+backend kube-apiservers
+  option httpchk GET /healthz
+`)
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  server %s %s check check-ssl verify none\n", name, net.JoinHostPort(backends[name], strconv.Itoa(apiServerPort)))
+	}
+	return b.String()
+}
+
+// etcdctlCommand returns the command that runs etcdctl with args in the etcd
+// container etcdID, talking to endpoints or, if empty, to the local member
+func etcdctlCommand(etcdID, endpoints string, args ...string) []string {
+	cmd := []string{"crictl", "exec", etcdID}
+	for _, arg := range etcdctlArgs {
+		if endpoints != "" && strings.HasPrefix(arg, "--endpoints=") {
+			arg = "--endpoints=" + endpoints
+		}
+		cmd = append(cmd, arg)
+	}
+	return append(cmd, args...)
+}
+
+// parseEtcdMembers parses the output of etcdctl member list --write-out=json
+func parseEtcdMembers(data []byte) ([]etcdMember, error) {
+	var list struct {
+		Members []struct {
+			ID         uint64   `json:"ID"`
+			Name       string   `json:"name"`
+			PeerURLs   []string `json:"peerURLs"`
+			ClientURLs []string `json:"clientURLs"`
+		} `json:"members"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse etcd member list: %w", err)
+	}
+	members := make([]etcdMember, 0, len(list.Members))
+	for _, m := range list.Members {
+		members = append(members, etcdMember{id: m.ID, name: m.Name, peerURLs: m.PeerURLs, clientURLs: m.ClientURLs})
+	}
+	return members, nil
+}
+
+// findEtcdMember returns the etcd member of node. Members that have not
+// started yet have no name and are found by their peer address.
+func findEtcdMember(members []etcdMember, node controlPlaneNode) *etcdMember {
+	for i, member := range members {
+		if member.name != "" && member.name == node.hostname {
+			return &members[i]
+		}
+		for _, peer := range member.peerURLs {
+			if u, err := url.Parse(peer); err == nil && node.address != "" && u.Hostname() == node.address {
+				return &members[i]
+			}
+		}
+	}
+	return nil
+}
+
+// keepsQuorum reports whether etcd keeps a majority of healthy members once
+// the member with the given ID is removed
+func keepsQuorum(members []etcdMember, id uint64) bool {
+	remaining, healthy := 0, 0
+	for _, member := range members {
+		if member.id == id {
+			continue
+		}
+		remaining++
+		if member.healthy {
+			healthy++
+		}
+	}
+	return remaining > 0 && healthy > remaining/2
+}
@@ -0,0 +1,232 @@
+package providers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+func TestPlanControlPlaneScaling(t *testing.T) {
+	p := &DockerProvider{}
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "scale", Namespace: "default"}}
+	name := func(index int) string { return p.getNodeName(cluster, RoleControlPlane, index) }
+	node := func(index int, state string, joined, member bool) controlPlaneNode {
+		return controlPlaneNode{
+			summary: container.Summary{
+				Names:  []string{"/" + name(index)},
+				Labels: map[string]string{LabelRole: RoleControlPlane},
+				State:  state,
+			},
+			hostname: name(index),
+			joined:   joined,
+			member:   member,
+		}
+	}
+	names := func(nodes []controlPlaneNode) []string {
+		var names []string
+		for _, node := range nodes {
+			names = append(names, containerName(node.summary))
+		}
+		return names
+	}
+
+	tests := []struct {
+		name         string
+		nodes        []controlPlaneNode
+		desired      int
+		bootstrapped bool
+		abandoned    []string
+		remove       []string
+		join         []string
+		create       []string
+	}{
+		{
+			name:         "nothing to do",
+			nodes:        []controlPlaneNode{node(0, "running", true, true), node(1, "running", true, true), node(2, "running", true, true)},
+			desired:      3,
+			bootstrapped: true,
+		},
+		{
+			name:         "scale up joins new nodes",
+			nodes:        []controlPlaneNode{node(0, "running", true, true)},
+			desired:      3,
+			bootstrapped: true,
+			create:       []string{name(1), name(2)},
+		},
+		{
+			name:         "scale down removes the highest index first",
+			nodes:        []controlPlaneNode{node(0, "running", true, true), node(1, "running", true, true), node(2, "running", true, true)},
+			desired:      1,
+			bootstrapped: true,
+			remove:       []string{name(2), name(1)},
+		},
+		{
+			name:         "stopped nodes are removed before running ones",
+			nodes:        []controlPlaneNode{node(0, "running", true, true), node(1, "exited", true, true), node(2, "running", true, true)},
+			desired:      1,
+			bootstrapped: true,
+			remove:       []string{name(1), name(2)},
+		},
+		{
+			name:         "an interrupted removal is finished",
+			nodes:        []controlPlaneNode{node(0, "running", true, true), node(1, "running", true, true), node(2, "running", true, true), node(3, "running", true, false)},
+			desired:      3,
+			bootstrapped: true,
+			abandoned:    []string{name(3)},
+		},
+		{
+			name:         "an interrupted join is finished",
+			nodes:        []controlPlaneNode{node(0, "running", true, true), node(1, "running", true, true), node(2, "running", false, false)},
+			desired:      3,
+			bootstrapped: true,
+			join:         []string{name(2)},
+		},
+		{
+			name:         "nodes that have not joined are removed first",
+			nodes:        []controlPlaneNode{node(0, "running", true, true), node(1, "running", false, true), node(2, "running", true, true)},
+			desired:      1,
+			bootstrapped: true,
+			remove:       []string{name(1), name(2)},
+		},
+		{
+			name:    "clusters without kubeadm only create containers",
+			nodes:   []controlPlaneNode{node(0, "running", false, false)},
+			desired: 3,
+			create:  []string{name(1), name(2)},
+		},
+		{
+			name:    "clusters without kubeadm only remove containers",
+			nodes:   []controlPlaneNode{node(0, "running", false, false), node(1, "running", false, false), node(2, "running", false, false)},
+			desired: 1,
+			remove:  []string{name(2), name(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := p.planControlPlaneScaling(cluster, tt.nodes, tt.desired, tt.bootstrapped)
+			if !reflect.DeepEqual(names(plan.abandoned), tt.abandoned) {
+				t.Errorf("Expected to finish removing %v, got %v", tt.abandoned, names(plan.abandoned))
+			}
+			if !reflect.DeepEqual(names(plan.remove), tt.remove) {
+				t.Errorf("Expected to remove %v, got %v", tt.remove, names(plan.remove))
+			}
+			if !reflect.DeepEqual(names(plan.join), tt.join) {
+				t.Errorf("Expected to join %v, got %v", tt.join, names(plan.join))
+			}
+			if !reflect.DeepEqual(plan.create, tt.create) {
+				t.Errorf("Expected to create %v, got %v", tt.create, plan.create)
+			}
+		})
+	}
+}
+
+func TestValidateControlPlaneCount(t *testing.T) {
+	for _, count := range []int32{1, 3, 5} {
+		if err := validateControlPlaneCount(count); err != nil {
+			t.Errorf("Expected %d control plane nodes to be valid, got %v", count, err)
+		}
+	}
+	for _, count := range []int32{0, 2, 4} {
+		if err := validateControlPlaneCount(count); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Expected %d control plane nodes to be invalid, got %v", count, err)
+		}
+	}
+}
+
+func TestKeepsQuorum(t *testing.T) {
+	members := func(healthy ...bool) []etcdMember {
+		var members []etcdMember
+		for i, h := range healthy {
+			members = append(members, etcdMember{id: uint64(i + 1), healthy: h})
+		}
+		return members
+	}
+
+	tests := []struct {
+		name     string
+		members  []etcdMember
+		remove   uint64
+		expected bool
+	}{
+		{"healthy member of three", members(true, true, true), 3, true},
+		{"last member", members(true), 1, false},
+		{"healthy member while another is down", members(true, true, false), 1, false},
+		{"the member that is down", members(true, true, false), 3, true},
+		{"unstarted member of two", members(true, false), 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keepsQuorum(tt.members, tt.remove); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEtcdMembers(t *testing.T) {
+	list := `{"header":{"cluster_id":1},"members":[
+		{"ID":12345678901234567890,"name":"dev-control-plane-0","peerURLs":["https://172.18.0.2:2380"],"clientURLs":["https://172.18.0.2:2379"]},
+		{"ID":42,"peerURLs":["https://172.18.0.4:2380"],"isLearner":true}
+	]}`
+	members, err := parseEtcdMembers([]byte(list))
+	if err != nil {
+		t.Fatalf("Failed to parse member list: %v", err)
+	}
+	if len(members) != 2 || members[0].id != 12345678901234567890 || members[0].name != "dev-control-plane-0" {
+		t.Fatalf("Unexpected members %+v", members)
+	}
+
+	if member := findEtcdMember(members, controlPlaneNode{hostname: "dev-control-plane-0"}); member == nil || member.id != members[0].id {
+		t.Errorf("Expected the member to be found by name, got %+v", member)
+	}
+	if member := findEtcdMember(members, controlPlaneNode{hostname: "dev-control-plane-1", address: "172.18.0.4"}); member == nil || member.id != 42 {
+		t.Errorf("Expected the unstarted member to be found by address, got %+v", member)
+	}
+	if member := findEtcdMember(members, controlPlaneNode{hostname: "dev-control-plane-2", address: "172.18.0.5"}); member != nil {
+		t.Errorf("Expected no member, got %+v", member)
+	}
+}
+
+func TestEtcdctlCommand(t *testing.T) {
+	local := strings.Join(etcdctlCommand("abc", "", "member", "list"), " ")
+	if !strings.HasPrefix(local, "crictl exec abc etcdctl --endpoints=https://127.0.0.1:2379 ") || !strings.HasSuffix(local, " member list") {
+		t.Errorf("Unexpected command %q", local)
+	}
+
+	remote := etcdctlCommand("abc", "https://172.18.0.3:2379", "endpoint", "health")
+	endpoints := 0
+	for _, arg := range remote {
+		if strings.HasPrefix(arg, "--endpoints=") {
+			endpoints++
+			if arg != "--endpoints=https://172.18.0.3:2379" {
+				t.Errorf("Expected the given endpoint, got %s", arg)
+			}
+		}
+	}
+	if endpoints != 1 {
+		t.Errorf("Expected one endpoints flag, got %v", remote)
+	}
+}
+
+func TestHaproxyConfiguration(t *testing.T) {
+	config := haproxyConfiguration(map[string]string{
+		"dev-control-plane-1": "172.18.0.3",
+		"dev-control-plane-0": "172.18.0.2",
+	})
+	first := strings.Index(config, "  server dev-control-plane-0 172.18.0.2:6443 check check-ssl verify none\n")
+	second := strings.Index(config, "  server dev-control-plane-1 172.18.0.3:6443 check check-ssl verify none\n")
+	if first < 0 || second < first {
+		t.Errorf("Expected a backend per control plane node in name order, got:\n%s", config)
+	}
+	if !strings.Contains(config, "bind *:6443") {
+		t.Errorf("Expected the load balancer to listen on the API server port, got:\n%s", config)
+	}
+}
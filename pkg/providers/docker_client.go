@@ -0,0 +1,300 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/metrics"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+// DockerClient is the part of the Docker Engine API the provider depends on:
+// containers, exec, networks, images, volumes and events. The Docker SDK's
+// *client.Client implements it, and so does the in-memory engine of package
+// dockerfake that tests run the provider against.
+type DockerClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.UpdateResponse, error)
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+	ContainerRename(ctx context.Context, containerID, newContainerName string) error
+	ContainerCommit(ctx context.Context, containerID string, options container.CommitOptions) (container.CommitResponse, error)
+	ContainerPause(ctx context.Context, containerID string) error
+	ContainerUnpause(ctx context.Context, containerID string) error
+
+	ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (container.ExecCreateResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error)
+	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, container.PathStat, error)
+	CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options container.CopyToContainerOptions) error
+
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+	NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+	NetworkRemove(ctx context.Context, networkID string) error
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+	NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error
+
+	ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+	ImageInspect(ctx context.Context, imageID string, opts ...client.ImageInspectOption) (image.InspectResponse, error)
+	ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error)
+	ImageRemove(ctx context.Context, imageID string, options image.RemoveOptions) ([]image.DeleteResponse, error)
+
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+	VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+}
+
+var _ DockerClient = &client.Client{}
+
+// instrumentedClient decorates a Docker client, recording call counts, errors
+// and latencies for every Docker API method the provider uses, each call in
+// its own trace span
+type instrumentedClient struct {
+	next DockerClient
+}
+
+// observe starts the span and timer for a Docker API call; the returned
+// function ends both with the call's outcome
+func observe(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "docker."+method)
+	return ctx, func(err error) {
+		metrics.ObserveDockerCall(method, start, err)
+		tracing.End(span, err)
+	}
+}
+
+func (c *instrumentedClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	ctx, done := observe(ctx, "ContainerList")
+	containers, err := c.next.ContainerList(ctx, options)
+	done(err)
+	return containers, err
+}
+
+func (c *instrumentedClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	ctx, done := observe(ctx, "ContainerInspect")
+	info, err := c.next.ContainerInspect(ctx, containerID)
+	done(err)
+	return info, err
+}
+
+func (c *instrumentedClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	ctx, done := observe(ctx, "ContainerCreate")
+	resp, err := c.next.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, containerName)
+	done(err)
+	return resp, err
+}
+
+func (c *instrumentedClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	ctx, done := observe(ctx, "ContainerStart")
+	err := c.next.ContainerStart(ctx, containerID, options)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	ctx, done := observe(ctx, "ContainerStop")
+	err := c.next.ContainerStop(ctx, containerID, options)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error {
+	ctx, done := observe(ctx, "ContainerRestart")
+	err := c.next.ContainerRestart(ctx, containerID, options)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.UpdateResponse, error) {
+	ctx, done := observe(ctx, "ContainerUpdate")
+	resp, err := c.next.ContainerUpdate(ctx, containerID, updateConfig)
+	done(err)
+	return resp, err
+}
+
+func (c *instrumentedClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	ctx, done := observe(ctx, "ContainerRemove")
+	err := c.next.ContainerRemove(ctx, containerID, options)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ContainerRename(ctx context.Context, containerID, newContainerName string) error {
+	ctx, done := observe(ctx, "ContainerRename")
+	err := c.next.ContainerRename(ctx, containerID, newContainerName)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ContainerCommit(ctx context.Context, containerID string, options container.CommitOptions) (container.CommitResponse, error) {
+	ctx, done := observe(ctx, "ContainerCommit")
+	resp, err := c.next.ContainerCommit(ctx, containerID, options)
+	done(err)
+	return resp, err
+}
+
+func (c *instrumentedClient) ContainerPause(ctx context.Context, containerID string) error {
+	ctx, done := observe(ctx, "ContainerPause")
+	err := c.next.ContainerPause(ctx, containerID)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ContainerUnpause(ctx context.Context, containerID string) error {
+	ctx, done := observe(ctx, "ContainerUnpause")
+	err := c.next.ContainerUnpause(ctx, containerID)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (container.ExecCreateResponse, error) {
+	ctx, done := observe(ctx, "ContainerExecCreate")
+	resp, err := c.next.ContainerExecCreate(ctx, containerID, options)
+	done(err)
+	return resp, err
+}
+
+func (c *instrumentedClient) ContainerExecAttach(ctx context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error) {
+	ctx, done := observe(ctx, "ContainerExecAttach")
+	resp, err := c.next.ContainerExecAttach(ctx, execID, options)
+	done(err)
+	return resp, err
+}
+
+func (c *instrumentedClient) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	ctx, done := observe(ctx, "ContainerExecInspect")
+	info, err := c.next.ContainerExecInspect(ctx, execID)
+	done(err)
+	return info, err
+}
+
+func (c *instrumentedClient) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, container.PathStat, error) {
+	ctx, done := observe(ctx, "CopyFromContainer")
+	reader, stat, err := c.next.CopyFromContainer(ctx, containerID, srcPath)
+	done(err)
+	return reader, stat, err
+}
+
+func (c *instrumentedClient) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options container.CopyToContainerOptions) error {
+	ctx, done := observe(ctx, "CopyToContainer")
+	err := c.next.CopyToContainer(ctx, containerID, dstPath, content, options)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error) {
+	ctx, done := observe(ctx, "NetworkList")
+	networks, err := c.next.NetworkList(ctx, options)
+	done(err)
+	return networks, err
+}
+
+func (c *instrumentedClient) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	ctx, done := observe(ctx, "NetworkInspect")
+	info, err := c.next.NetworkInspect(ctx, networkID, options)
+	done(err)
+	return info, err
+}
+
+func (c *instrumentedClient) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	ctx, done := observe(ctx, "NetworkCreate")
+	resp, err := c.next.NetworkCreate(ctx, name, options)
+	done(err)
+	return resp, err
+}
+
+func (c *instrumentedClient) NetworkRemove(ctx context.Context, networkID string) error {
+	ctx, done := observe(ctx, "NetworkRemove")
+	err := c.next.NetworkRemove(ctx, networkID)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	ctx, done := observe(ctx, "NetworkConnect")
+	err := c.next.NetworkConnect(ctx, networkID, containerID, config)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
+	ctx, done := observe(ctx, "NetworkDisconnect")
+	err := c.next.NetworkDisconnect(ctx, networkID, containerID, force)
+	done(err)
+	return err
+}
+
+func (c *instrumentedClient) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+	ctx, done := observe(ctx, "ImagePull")
+	reader, err := c.next.ImagePull(ctx, refStr, options)
+	done(err)
+	return reader, err
+}
+
+func (c *instrumentedClient) ImageInspect(ctx context.Context, imageID string, opts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	ctx, done := observe(ctx, "ImageInspect")
+	info, err := c.next.ImageInspect(ctx, imageID, opts...)
+	done(err)
+	return info, err
+}
+
+func (c *instrumentedClient) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	ctx, done := observe(ctx, "ImageList")
+	images, err := c.next.ImageList(ctx, options)
+	done(err)
+	return images, err
+}
+
+func (c *instrumentedClient) ImageRemove(ctx context.Context, imageID string, options image.RemoveOptions) ([]image.DeleteResponse, error) {
+	ctx, done := observe(ctx, "ImageRemove")
+	resp, err := c.next.ImageRemove(ctx, imageID, options)
+	done(err)
+	return resp, err
+}
+
+func (c *instrumentedClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	ctx, done := observe(ctx, "VolumeCreate")
+	vol, err := c.next.VolumeCreate(ctx, options)
+	done(err)
+	return vol, err
+}
+
+func (c *instrumentedClient) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	ctx, done := observe(ctx, "VolumeList")
+	volumes, err := c.next.VolumeList(ctx, options)
+	done(err)
+	return volumes, err
+}
+
+func (c *instrumentedClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	ctx, done := observe(ctx, "VolumeRemove")
+	err := c.next.VolumeRemove(ctx, volumeID, force)
+	done(err)
+	return err
+}
+
+// Events only records the subscription; the stream runs until ctx ends
+func (c *instrumentedClient) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	_, done := observe(ctx, "Events")
+	messages, errs := c.next.Events(ctx, options)
+	done(nil)
+	return messages, errs
+}
@@ -3,22 +3,47 @@ package providers
 import (
 	"context"
 	"fmt"
-	"github.com/docker/docker/api/types/image"
 	"net"
+	"sort"
 	"strings"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
 )
 
+// DefaultNodeImageRepository is where node images come from unless the provider
+// configuration or the cluster names another repository
+const DefaultNodeImageRepository = "kindest/node"
+
+// DockerAPIVersion is the Docker Engine API version the provider speaks
+const DockerAPIVersion = "1.45"
+
 // DockerProvider implements the Provider interface for Docker
 type DockerProvider struct {
 	*BaseProvider
-	config *v1alpha1.DockerProviderConfig
-	client *client.Client
+	config   *v1alpha1.DockerProviderConfig
+	client   DockerClient
+	images   *imageManager
+	versions *versions.Catalog
+}
+
+// DockerProviderOption configures optional parts of a DockerProvider
+type DockerProviderOption func(*DockerProvider)
+
+// WithVersionCatalog makes the provider accept the Kubernetes versions of
+// catalog and pin node images to its digests. Defaults to versions.Builtin().
+func WithVersionCatalog(catalog *versions.Catalog) DockerProviderOption {
+	return func(p *DockerProvider) {
+		p.versions = catalog
+	}
 }
 
 // containerInfo represents container information for testing
@@ -29,35 +54,174 @@ type containerInfo struct {
 	State  string
 }
 
-// NewDockerProvider creates a new Docker provider instance
-func NewDockerProvider(config *v1alpha1.DockerProviderConfig) (*DockerProvider, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithVersion("1.45"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+// WithDockerClient makes the provider talk to client instead of the Docker
+// daemon the environment points at, e.g. to an in-memory engine in tests
+func WithDockerClient(client DockerClient) DockerProviderOption {
+	return func(p *DockerProvider) {
+		p.client = client
 	}
+}
 
-	return &DockerProvider{
+// NewDockerProvider creates a new Docker provider instance
+func NewDockerProvider(config *v1alpha1.DockerProviderConfig, opts ...DockerProviderOption) (*DockerProvider, error) {
+	provider := &DockerProvider{
 		BaseProvider: &BaseProvider{},
 		config:       config,
-		client:       cli,
-	}, nil
+		versions:     versions.Builtin(),
+	}
+	for _, opt := range opts {
+		opt(provider)
+	}
+	if provider.client == nil {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithVersion(DockerAPIVersion))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Docker client: %w", err)
+		}
+		provider.client = cli
+	}
+
+	provider.client = &instrumentedClient{next: provider.client}
+	provider.images = newImageManager(provider.client)
+	return provider, nil
+}
+
+// getNodeImage returns the node image reference for the cluster: the
+// repository tagged with the Kubernetes version, pinned to the digest the
+// cluster gives or else to the one the version catalog has for the version
+func (p *DockerProvider) getNodeImage(cluster *v1alpha1.Cluster) string {
+	repository := DefaultNodeImageRepository
+	if p.config != nil && p.config.Spec.NodeImageRepository != "" {
+		repository = p.config.Spec.NodeImageRepository
+	}
+
+	nodeImage := cluster.Spec.NodeImage
+	if nodeImage != nil && nodeImage.Repository != "" {
+		repository = nodeImage.Repository
+	}
+	ref := fmt.Sprintf("%s:%s", repository, cluster.Spec.KubernetesVersion)
+	switch {
+	case nodeImage != nil && nodeImage.Digest != "":
+		ref += "@" + nodeImage.Digest
+	case p.versions != nil:
+		if version, ok := p.versions.Lookup(cluster.Spec.KubernetesVersion); ok && version.Digest != "" {
+			ref += "@" + version.Digest
+		}
+	}
+	return ref
+}
+
+// validateVersion checks the cluster's Kubernetes version against the version
+// catalog and warns about deprecated versions
+func (p *DockerProvider) validateVersion(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	if p.versions == nil {
+		return nil
+	}
+	warning, err := p.versions.Validate(cluster.Spec.KubernetesVersion)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	if warning != "" {
+		clusterLogger(ctx, cluster).Info(warning)
+		recordWarning(ctx, cluster, ReasonVersionDeprecated, "%s", warning)
+	}
+	return nil
 }
 
 // getClusterNetworkName returns the Docker network name for the cluster
 func (p *DockerProvider) getClusterNetworkName(cluster *v1alpha1.Cluster) string {
-	return fmt.Sprintf("cluster-%s-net", cluster.Name)
+	return fmt.Sprintf("%s-net", clusterResourcePrefix(cluster))
 }
 
 // getNodeName returns the Docker container name for a node
 func (p *DockerProvider) getNodeName(cluster *v1alpha1.Cluster, role string, index int) string {
-	return fmt.Sprintf("cluster-%s-%s-%d", cluster.Name, role, index)
+	return fmt.Sprintf("%s-%s-%d", clusterResourcePrefix(cluster), role, index)
 }
 
 // getClusterFilters returns Docker filters for the cluster resources
 func (p *DockerProvider) getClusterFilters(cluster *v1alpha1.Cluster) filters.Args {
-	filters := filters.NewArgs()
-	filters.Add("label", fmt.Sprintf("cluster=%s", cluster.Name))
-	return filters
+	return clusterFilters(cluster)
+}
+
+// listClusterContainers returns the containers of the cluster, including nodes
+// created by older releases that have not been migrated yet and the nodes of
+// the kind cluster it adopted
+func (p *DockerProvider) listClusterContainers(ctx context.Context, cluster *v1alpha1.Cluster) ([]container.Summary, error) {
+	containers, err := p.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: p.getClusterFilters(cluster),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	legacy, err := p.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: legacyClusterFilters(cluster),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	for _, cont := range legacy {
+		if isLegacyNode(cluster, cont.Names, cont.Labels) {
+			containers = append(containers, cont)
+		}
+	}
+
+	// Adopted kind clusters keep kind's labels on their containers
+	if source, ok := cluster.Annotations[v1alpha1.AdoptAnnotation]; ok {
+		kind, err := p.listKindContainers(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		for _, cont := range kind {
+			if _, managed := cont.Labels[LabelManagedBy]; !managed {
+				containers = append(containers, adoptedKindNode(cluster, cont))
+			}
+		}
+	}
+	return containers, nil
+}
+
+// getClusterNetwork returns the Docker network of the cluster. Networks created
+// by the manager carry the cluster labels; migrated and adopted clusters keep
+// their original network, which is recorded on the node containers.
+func (p *DockerProvider) getClusterNetwork(ctx context.Context, cluster *v1alpha1.Cluster) (*network.Summary, error) {
+	networks, err := p.client.NetworkList(ctx, network.ListOptions{Filters: p.getClusterFilters(cluster)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	if len(networks) > 0 {
+		return &networks[0], nil
+	}
+
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	for _, cont := range containers {
+		name := cont.Labels[LabelNetwork]
+		if name == "" {
+			continue
+		}
+		networks, err := p.client.NetworkList(ctx, network.ListOptions{Filters: filters.NewArgs(filters.Arg("name", name))})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list networks: %w", err)
+		}
+		for i := range networks {
+			if networks[i].Name == name {
+				return &networks[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// clusterAttributes returns the span attributes identifying cluster
+func clusterAttributes(cluster *v1alpha1.Cluster) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		tracing.ClusterNamespaceKey.String(cluster.Namespace),
+		tracing.ClusterNameKey.String(cluster.Name),
+	}
 }
 
 // parseMemory converts memory string (e.g., "2Gi") to bytes
@@ -83,26 +247,30 @@ func (p *DockerProvider) parseMemory(memory string) int64 {
 }
 
 // createNetwork creates a Docker network for the cluster
-func (p *DockerProvider) createNetwork(ctx context.Context, cluster *v1alpha1.Cluster) (string, error) {
+func (p *DockerProvider) createNetwork(ctx context.Context, cluster *v1alpha1.Cluster) (_ *network.Summary, err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.createNetwork", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
 	networkName := p.getClusterNetworkName(cluster)
 
 	// Check if network already exists.
-	filterArgs := filters.NewArgs()
-	filterArgs.Add("name", networkName)
-	networks, err := p.client.NetworkList(ctx, network.ListOptions{Filters: filterArgs})
+	existing, err := p.getClusterNetwork(ctx, cluster)
 	if err != nil {
-		return "", fmt.Errorf("failed to list networks: %w", err)
+		return nil, err
 	}
-
-	if len(networks) > 0 {
-		return networks[0].ID, nil
+	if existing != nil {
+		return existing, nil
 	}
 
-	// Prepare IPAM configuration.
+	// Prepare IPAM configuration with a subnet no other network uses.
+	subnet, err := p.allocateSubnet(ctx)
+	if err != nil {
+		return nil, err
+	}
 	ipamConfig := []network.IPAMConfig{
 		{
-			Subnet:  p.config.Spec.Network.CIDR,
-			Gateway: p.getNetworkGateway(p.config.Spec.Network.CIDR),
+			Subnet:  subnet,
+			Gateway: p.getNetworkGateway(subnet),
 		},
 	}
 	ipam := &network.IPAM{
@@ -118,16 +286,18 @@ func (p *DockerProvider) createNetwork(ctx context.Context, cluster *v1alpha1.Cl
 		Internal:   false,
 		Attachable: false,
 		Ingress:    false,
+		Labels:     clusterLabels(cluster),
 		// Note: "Scope" is no longer a field in CreateOptions.
 	}
 
 	// Create the network.
 	netResp, err := p.client.NetworkCreate(ctx, networkName, networkCreateOpts)
 	if err != nil {
-		return "", fmt.Errorf("failed to create network: %w", err)
+		return nil, fmt.Errorf("failed to create network: %w", err)
 	}
+	recordNormal(ctx, cluster, ReasonNetworkCreated, "Created network %s with subnet %s", networkName, subnet)
 
-	return netResp.ID, nil
+	return &network.Summary{ID: netResp.ID, Name: networkName}, nil
 }
 
 // getNetworkGateway returns the gateway IP for a given CIDR
@@ -143,183 +313,279 @@ func (p *DockerProvider) getNetworkGateway(cidr string) string {
 	return ip.String()
 }
 
-// createNode creates a Docker container for a Kubernetes node
-func (p *DockerProvider) createNode(ctx context.Context, cluster *v1alpha1.Cluster, nodeName, role, networkID string, machineConfig v1alpha1.MachineConfig) error {
-	fmt.Printf("Creating node %s with role %s\n", nodeName, role)
+// createNode creates a Docker container for a Kubernetes node from the cluster's node image
+func (p *DockerProvider) createNode(ctx context.Context, cluster *v1alpha1.Cluster, nodeName, role string, clusterNetwork *network.Summary, machineConfig v1alpha1.MachineConfig) error {
+	return p.createNodeFromImage(ctx, cluster, nodeName, role, clusterNetwork, machineConfig, p.getNodeImage(cluster))
+}
 
-	// Pull the node image first
-	imageRef := fmt.Sprintf("kindest/node:%s", cluster.Spec.KubernetesVersion)
-	fmt.Printf("Pulling image %s...\n", imageRef)
-	reader, err := p.client.ImagePull(ctx, imageRef, image.PullOptions{})
-	if err != nil {
-		fmt.Printf("Failed to pull image: %v\n", err)
-		return fmt.Errorf("failed to pull node image: %w", err)
+// createNodeFromImage creates a Docker container for a Kubernetes node from imageRef
+func (p *DockerProvider) createNodeFromImage(ctx context.Context, cluster *v1alpha1.Cluster, nodeName, role string, clusterNetwork *network.Summary, machineConfig v1alpha1.MachineConfig, imageRef string) error {
+	return p.createNodeReplacing(ctx, cluster, nodeName, role, clusterNetwork, machineConfig, imageRef, nil)
+}
+
+// createNodeReplacing creates a Docker container for a Kubernetes node from
+// imageRef. If predecessor, a removed container of the node, is given, the new
+// one takes over the volumes it got from its image and its address on the
+// cluster network.
+func (p *DockerProvider) createNodeReplacing(ctx context.Context, cluster *v1alpha1.Cluster, nodeName, role string, clusterNetwork *network.Summary, machineConfig v1alpha1.MachineConfig, imageRef string, predecessor *container.InspectResponse) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.createNode", append(clusterAttributes(cluster),
+		tracing.NodeNameKey.String(nodeName),
+		tracing.NodeRoleKey.String(role),
+	)...)
+	defer func() { tracing.End(span, err) }()
+
+	log := clusterLogger(ctx, cluster).WithValues("node", nodeName, "role", role)
+	log.V(1).Info("Creating node")
+
+	// Make sure the node image is there. Cluster operations pull it once up
+	// front, so an Always policy must not pull it again for every node.
+	policy := nodeImagePolicy(cluster)
+	if policy == corev1.PullAlways {
+		policy = corev1.PullIfNotPresent
+	}
+	if err := p.images.ensureImage(ctx, cluster, imageRef, policy); err != nil {
+		return err
 	}
-	fmt.Printf("Successfully pulled image %s\n", imageRef)
-	defer reader.Close()
 
 	// Create container configuration
+	labels := nodeLabels(cluster, role, clusterNetwork.Name)
+	labels[LabelNodeImage] = imageRef
 	config := &container.Config{
-		Image:    fmt.Sprintf("kindest/node:%s", cluster.Spec.KubernetesVersion),
+		Image:    imageRef,
 		Hostname: nodeName,
-		Labels: map[string]string{
-			"cluster": cluster.Name,
-			"role":    role,
-		},
+		Labels:   labels,
 	}
 
 	// Create host configuration
 	hostConfig := &container.HostConfig{
 		Privileged: true,
-		Resources: container.Resources{
-			Memory:   p.parseMemory(machineConfig.Memory),
-			NanoCPUs: int64(machineConfig.CPUCount * 1e9), // Convert CPU count to nanoCPUs
-		},
+		Resources:  p.nodeResources(machineConfig),
 	}
+	applyNodeExtras(nodeExtras(cluster, role), config, hostConfig)
 
 	// Create network configuration
+	endpoint := &network.EndpointSettings{NetworkID: clusterNetwork.ID}
+	if predecessor != nil {
+		hostConfig.Mounts = append(hostConfig.Mounts, anonymousVolumeMounts(*predecessor)...)
+		if address := nodeAddress(*predecessor, clusterNetwork.Name); address != "" {
+			endpoint.IPAMConfig = &network.EndpointIPAMConfig{IPv4Address: address}
+		}
+	}
 	networkingConfig := &network.NetworkingConfig{
 		EndpointsConfig: map[string]*network.EndpointSettings{
-			p.getClusterNetworkName(cluster): {
-				NetworkID: networkID,
-			},
+			clusterNetwork.Name: endpoint,
 		},
 	}
 
 	// Create container
-	fmt.Printf("Creating container with name %s...\n", nodeName)
+	log.V(2).Info("Creating container", "step", "create")
 	contResp, err := p.client.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, nodeName)
 	if err != nil {
-		fmt.Printf("Failed to create container: %v\n", err)
+		recordWarning(ctx, cluster, ReasonNodeCreationFailed, "Failed to create %s node %s: %v", role, nodeName, err)
 		return fmt.Errorf("failed to create container: %w", err)
 	}
-	fmt.Printf("Successfully created container %s with ID %s\n", nodeName, contResp.ID)
+	log = log.WithValues("containerID", shortID(contResp.ID))
 
 	// Start container
-	fmt.Printf("Starting container %s...\n", contResp.ID)
+	log.V(2).Info("Starting container", "step", "start")
 	if err := p.client.ContainerStart(ctx, contResp.ID, container.StartOptions{}); err != nil {
-		fmt.Printf("Failed to start container: %v\n", err)
+		recordWarning(ctx, cluster, ReasonNodeCreationFailed, "Failed to start %s node %s: %v", role, nodeName, err)
+		// Don't leave a container behind that holds the node's name
+		if removeErr := p.client.ContainerRemove(ctx, contResp.ID, container.RemoveOptions{RemoveVolumes: true, Force: true}); removeErr != nil {
+			log.Error(removeErr, "Failed to remove container that did not start")
+		}
 		return fmt.Errorf("failed to start container: %w", err)
 	}
-	fmt.Printf("Successfully started container %s\n", contResp.ID)
+	log.Info("Created node")
+	recordNormal(ctx, cluster, ReasonNodeCreated, "Created %s node %s", role, nodeName)
 
 	return nil
 }
 
 // CreateCluster creates a new Kubernetes cluster using Docker containers
 func (p *DockerProvider) CreateCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
-	fmt.Printf("Creating cluster %s...\n", cluster.Name)
+	log := clusterLogger(ctx, cluster)
+	log.Info("Creating cluster", "controlPlanes", cluster.Spec.ControlPlane.Count, "workers", cluster.Spec.Workers.Count)
+
+	if err := p.validateVersion(ctx, cluster); err != nil {
+		return err
+	}
 
 	// Check if cluster already exists
-	fmt.Printf("Checking if cluster already exists...\n")
 	exists, err := p.clusterExists(ctx, cluster)
 	if err != nil {
-		fmt.Printf("Failed to check if cluster exists: %v\n", err)
 		return fmt.Errorf("failed to check if cluster exists: %w", err)
 	}
 	if exists {
 		return ErrClusterExists
 	}
 
+	// Pull the node image once for all nodes
+	if err := p.images.ensureImage(ctx, cluster, p.getNodeImage(cluster), nodeImagePolicy(cluster)); err != nil {
+		return err
+	}
+
 	// Create network
-	fmt.Printf("Creating network for cluster %s...\n", cluster.Name)
-	networkID, err := p.createNetwork(ctx, cluster)
+	clusterNetwork, err := p.createNetwork(ctx, cluster)
 	if err != nil {
-		fmt.Printf("Failed to create network: %v\n", err)
 		return fmt.Errorf("failed to create network: %w", err)
 	}
-	fmt.Printf("Successfully created network with ID %s\n", networkID)
+	log.V(1).Info("Using network", "step", "network", "network", clusterNetwork.Name, "networkID", shortID(clusterNetwork.ID))
 
-	// Create control plane nodes
-	fmt.Printf("Creating %d control plane nodes...\n", cluster.Spec.ControlPlane.Count)
+	// Create control plane nodes one at a time; the first one has to be up
+	// before anything joins it
 	for i := 0; i < int(cluster.Spec.ControlPlane.Count); i++ {
-		nodeName := p.getNodeName(cluster, "control-plane", i)
-		fmt.Printf("Creating control plane node %s (%d/%d)...\n", nodeName, i+1, cluster.Spec.ControlPlane.Count)
-		if err := p.createNode(ctx, cluster, nodeName, "control-plane", networkID, cluster.Spec.ControlPlane.MachineConfig); err != nil {
-			fmt.Printf("Failed to create control plane node %s: %v\n", nodeName, err)
+		nodeName := p.getNodeName(cluster, RoleControlPlane, i)
+		if err := p.createNode(ctx, cluster, nodeName, RoleControlPlane, clusterNetwork, cluster.Spec.ControlPlane.MachineConfig); err != nil {
 			// Cleanup on failure
-			p.DeleteCluster(ctx, cluster)
+			p.cleanupFailedCluster(ctx, cluster)
 			return fmt.Errorf("failed to create control plane node %s: %w", nodeName, err)
 		}
-		fmt.Printf("Successfully created control plane node %s\n", nodeName)
 	}
 
-	// Create worker nodes
-	fmt.Printf("Creating %d worker nodes...\n", cluster.Spec.Workers.Count)
+	// Create worker nodes in parallel. Workers that were created are kept if
+	// others fail, so a retry through UpdateCluster only creates the missing ones.
+	workers := make([]string, 0, cluster.Spec.Workers.Count)
 	for i := 0; i < int(cluster.Spec.Workers.Count); i++ {
-		nodeName := p.getNodeName(cluster, "worker", i)
-		fmt.Printf("Creating worker node %s (%d/%d)...\n", nodeName, i+1, cluster.Spec.Workers.Count)
-		if err := p.createNode(ctx, cluster, nodeName, "worker", networkID, cluster.Spec.Workers.MachineConfig); err != nil {
-			fmt.Printf("Failed to create worker node %s: %v\n", nodeName, err)
-			// Cleanup on failure
-			p.DeleteCluster(ctx, cluster)
-			return fmt.Errorf("failed to create worker node %s: %w", nodeName, err)
-		}
-		fmt.Printf("Successfully created worker node %s\n", nodeName)
+		workers = append(workers, p.getNodeName(cluster, RoleWorker, i))
+	}
+	if err := p.createWorkers(ctx, cluster, clusterNetwork, workers); err != nil {
+		return err
 	}
 
+	log.Info("Created cluster")
 	return nil
 }
 
+// createWorkers creates the named worker nodes in parallel
+func (p *DockerProvider) createWorkers(ctx context.Context, cluster *v1alpha1.Cluster, clusterNetwork *network.Summary, names []string) error {
+	return p.runNodeOperations(ctx, "create", names, func(ctx context.Context, nodeName string) error {
+		return p.createNode(ctx, cluster, nodeName, RoleWorker, clusterNetwork, cluster.Spec.Workers.MachineConfig)
+	})
+}
+
+// removeNode stops and removes a node container together with its volumes
+func (p *DockerProvider) removeNode(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary, stopTimeout int) error {
+	return p.removeNodeContainer(ctx, cluster, cont, stopTimeout, true)
+}
+
+// removeNodeContainer stops and removes a node container, with its volumes if
+// removeVolumes is set
+func (p *DockerProvider) removeNodeContainer(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary, stopTimeout int, removeVolumes bool) error {
+	log := clusterLogger(ctx, cluster).WithValues("node", containerName(cont), "containerID", shortID(cont.ID))
+
+	// Stop container with a timeout
+	log.V(2).Info("Stopping container", "step", "stop")
+	if err := p.client.ContainerStop(ctx, cont.ID, container.StopOptions{Timeout: &stopTimeout}); err != nil {
+		recordWarning(ctx, cluster, ReasonNodeRemovalFailed, "Failed to stop node %s: %v", containerName(cont), err)
+		return fmt.Errorf("failed to stop container %s: %w", cont.ID, err)
+	}
+
+	// Remove container
+	log.V(2).Info("Removing container", "step", "remove")
+	if err := p.client.ContainerRemove(ctx, cont.ID, container.RemoveOptions{
+		RemoveVolumes: removeVolumes,
+		Force:         true,
+	}); err != nil {
+		recordWarning(ctx, cluster, ReasonNodeRemovalFailed, "Failed to remove node %s: %v", containerName(cont), err)
+		return fmt.Errorf("failed to remove container %s: %w", cont.ID, err)
+	}
+	log.V(1).Info("Removed node")
+	recordNormal(ctx, cluster, ReasonNodeRemoved, "Removed %s node %s", nodeRole(cont.Labels), containerName(cont))
+	return nil
+}
+
+// removeNodes removes node containers in parallel
+func (p *DockerProvider) removeNodes(ctx context.Context, cluster *v1alpha1.Cluster, containers []container.Summary, stopTimeout int) error {
+	return p.forEachNode(ctx, "remove", containers, func(ctx context.Context, cont container.Summary) error {
+		return p.removeNode(ctx, cluster, cont, stopTimeout)
+	})
+}
+
+// cleanupFailedCluster removes whatever a failed CreateCluster left behind. The
+// creation error is what gets reported, so cleanup errors are only logged.
+func (p *DockerProvider) cleanupFailedCluster(ctx context.Context, cluster *v1alpha1.Cluster) {
+	if err := p.DeleteCluster(ctx, cluster); err != nil {
+		clusterLogger(ctx, cluster).Error(err, "Failed to clean up after failed cluster creation")
+	}
+}
+
 // DeleteCluster deletes an existing Kubernetes cluster
 func (p *DockerProvider) DeleteCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
-	fmt.Printf("Deleting cluster %s...\n", cluster.Name)
+	log := clusterLogger(ctx, cluster)
 
 	// List all containers for this cluster
-	fmt.Printf("Listing containers in cluster %s...\n", cluster.Name)
-	containers, err := p.client.ContainerList(ctx, container.ListOptions{
-		All:     true,
-		Filters: p.getClusterFilters(cluster),
-	})
+	containers, err := p.listClusterContainers(ctx, cluster)
 	if err != nil {
-		fmt.Printf("Failed to list containers: %v\n", err)
-		return fmt.Errorf("failed to list containers: %w", err)
+		return err
 	}
-	fmt.Printf("Found %d containers to delete\n", len(containers))
+	log.Info("Deleting cluster", "containers", len(containers))
 
-	// Stop and remove all containers
+	// Networks the nodes were attached to but that the manager did not create
+	// (legacy or adopted clusters) are only removed once nothing else uses them
+	sharedNetworks := map[string]bool{}
 	for _, cont := range containers {
-		// Stop container with a timeout
-		fmt.Printf("Stopping container %s...\n", cont.ID[:12])
-		timeoutSeconds := int(30)
-		if err := p.client.ContainerStop(ctx, cont.ID, container.StopOptions{Timeout: &timeoutSeconds}); err != nil {
-			fmt.Printf("Failed to stop container %s: %v\n", cont.ID[:12], err)
-			return fmt.Errorf("failed to stop container %s: %w", cont.ID, err)
+		if name := cont.Labels[LabelNetwork]; name != "" && name != p.getClusterNetworkName(cluster) {
+			sharedNetworks[name] = true
 		}
-		fmt.Printf("Successfully stopped container %s\n", cont.ID[:12])
-
-		// Remove container
-		fmt.Printf("Removing container %s...\n", cont.ID[:12])
-		if err := p.client.ContainerRemove(ctx, cont.ID, container.RemoveOptions{
-			RemoveVolumes: true,
-			Force:         true,
-		}); err != nil {
-			fmt.Printf("Failed to remove container %s: %v\n", cont.ID[:12], err)
-			return fmt.Errorf("failed to remove container %s: %w", cont.ID, err)
+		if _, managed := cont.Labels[LabelManagedBy]; !managed {
+			sharedNetworks[fmt.Sprintf("cluster-%s-net", cluster.Name)] = true
 		}
-		fmt.Printf("Successfully removed container %s\n", cont.ID[:12])
+	}
+
+	// Stop and remove all containers; the network can only go once they are gone
+	if err := p.removeNodes(ctx, cluster, containers, 30); err != nil {
+		return err
+	}
+	if err := p.removeCommittedImages(ctx, cluster); err != nil {
+		return err
 	}
 
 	// Remove network
-	fmt.Printf("Listing networks for cluster %s...\n", cluster.Name)
 	networks, err := p.client.NetworkList(ctx, network.ListOptions{
 		Filters: p.getClusterFilters(cluster),
 	})
 	if err != nil {
-		fmt.Printf("Failed to list networks: %v\n", err)
 		return fmt.Errorf("failed to list networks: %w", err)
 	}
-	fmt.Printf("Found %d networks to delete\n", len(networks))
 
 	for _, net := range networks {
-		fmt.Printf("Removing network %s...\n", net.Name)
+		log.V(1).Info("Removing network", "step", "network", "network", net.Name)
 		if err := p.client.NetworkRemove(ctx, net.ID); err != nil {
-			fmt.Printf("Failed to remove network %s: %v\n", net.Name, err)
 			return fmt.Errorf("failed to remove network %s: %w", net.ID, err)
 		}
-		fmt.Printf("Successfully removed network %s\n", net.Name)
+		recordNormal(ctx, cluster, ReasonNetworkRemoved, "Removed network %s", net.Name)
 	}
 
+	for name := range sharedNetworks {
+		if err := p.removeNetworkIfUnused(ctx, cluster, name); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Deleted cluster")
+	return nil
+}
+
+// removeNetworkIfUnused removes a network that the cluster used but did not
+// create, provided no containers are attached to it any more
+func (p *DockerProvider) removeNetworkIfUnused(ctx context.Context, cluster *v1alpha1.Cluster, name string) error {
+	info, err := p.client.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to inspect network %s: %w", name, err)
+	}
+	if len(info.Containers) > 0 {
+		clusterLogger(ctx, cluster).V(1).Info("Keeping shared network", "step", "network", "network", name, "containers", len(info.Containers))
+		return nil
+	}
+	clusterLogger(ctx, cluster).V(1).Info("Removing shared network", "step", "network", "network", name)
+	if err := p.client.NetworkRemove(ctx, info.ID); err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", name, err)
+	}
+	recordNormal(ctx, cluster, ReasonNetworkRemoved, "Removed network %s", name)
 	return nil
 }
 
@@ -328,28 +594,40 @@ func (p *DockerProvider) GetClusterStatus(ctx context.Context, cluster *v1alpha1
 	status := &v1alpha1.ClusterStatus{}
 
 	// List all containers for this cluster
-	containers, err := p.client.ContainerList(ctx, container.ListOptions{
-		All:     true,
-		Filters: p.getClusterFilters(cluster),
-	})
+	containers, err := p.listClusterContainers(ctx, cluster)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list containers: %w", err)
+		return nil, err
+	}
+
+	// Stopped nodes are expected while the cluster is suspended
+	if cluster.Spec.Suspended && len(containers) > 0 {
+		status.Phase = v1alpha1.ClusterPhaseSuspended
+		running := 0
+		for _, cont := range containers {
+			if cont.State == "running" {
+				running++
+			}
+		}
+		if running > 0 {
+			status.Message = fmt.Sprintf("%d of %d nodes are still running", running, len(containers))
+		}
+		return status, nil
 	}
 
 	// Count control plane and worker nodes
 	var controlPlaneCount, workerCount int32
-	allRunning := true
+	var notRunning []string
 
 	for _, cont := range containers {
 		if cont.State != "running" {
-			allRunning = false
+			notRunning = append(notRunning, containerName(cont))
 			continue
 		}
 
-		switch cont.Labels["role"] {
-		case "control-plane":
+		switch nodeRole(cont.Labels) {
+		case RoleControlPlane:
 			controlPlaneCount++
-		case "worker":
+		case RoleWorker:
 			workerCount++
 		}
 	}
@@ -357,8 +635,11 @@ func (p *DockerProvider) GetClusterStatus(ctx context.Context, cluster *v1alpha1
 	// Set status fields
 	if len(containers) == 0 {
 		status.Phase = "NotFound"
-	} else if !allRunning {
-		status.Phase = "Starting"
+	} else if len(notRunning) > 0 {
+		// The cluster stays Running so its health check can repair the nodes
+		sort.Strings(notRunning)
+		status.Phase = v1alpha1.ClusterPhaseRunning
+		status.Message = fmt.Sprintf("Nodes not running: %s", strings.Join(notRunning, ", "))
 	} else if controlPlaneCount == cluster.Spec.ControlPlane.Count &&
 		workerCount == cluster.Spec.Workers.Count {
 		status.Phase = "Running"
@@ -368,18 +649,16 @@ func (p *DockerProvider) GetClusterStatus(ctx context.Context, cluster *v1alpha1
 
 	status.ControlPlaneReady = (controlPlaneCount == cluster.Spec.ControlPlane.Count)
 	status.WorkersReady = workerCount
+	status.KubernetesVersion = controlPlaneVersion(containers)
 
 	return status, nil
 }
 
 // clusterExists checks if a cluster with the given name already exists
 func (p *DockerProvider) clusterExists(ctx context.Context, cluster *v1alpha1.Cluster) (bool, error) {
-	containers, err := p.client.ContainerList(ctx, container.ListOptions{
-		All:     true,
-		Filters: p.getClusterFilters(cluster),
-	})
+	containers, err := p.listClusterContainers(ctx, cluster)
 	if err != nil {
-		return false, fmt.Errorf("failed to list containers: %w", err)
+		return false, err
 	}
 	return len(containers) > 0, nil
 }
@@ -406,16 +685,6 @@ func (p *DockerProvider) getNetworkInfo(networkID string) *network.NetworkingCon
 	}
 }
 
-// getResourceAllocation returns container resource allocation configuration
-func (p *DockerProvider) getResourceAllocation(config v1alpha1.MachineConfig) *container.Resources {
-	memoryBytes := p.parseMemory(config.Memory)
-	return &container.Resources{
-		Memory:    memoryBytes,
-		CPUQuota:  int64(config.CPUCount * 100000),
-		CPUPeriod: 100000,
-	}
-}
-
 // UpdateCluster updates an existing cluster's configuration
 func (p *DockerProvider) UpdateCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
 	// Check if cluster exists
@@ -427,64 +696,72 @@ func (p *DockerProvider) UpdateCluster(ctx context.Context, cluster *v1alpha1.Cl
 		return ErrClusterNotFound
 	}
 
-	// For now, we'll implement a simple update that only supports scaling workers
-	// Get current cluster status
-	status, err := p.GetClusterStatus(ctx, cluster)
-	if err != nil {
-		return fmt.Errorf("failed to get cluster status: %w", err)
+	// Clusters on versions that have since left the catalog can still be
+	// scaled and resized; only a change of version is checked
+	if cluster.Spec.KubernetesVersion != cluster.Status.KubernetesVersion {
+		if err := p.validateVersion(ctx, cluster); err != nil {
+			return err
+		}
 	}
 
-	// Calculate the difference in worker count
-	currentWorkers := status.WorkersReady
-	desiredWorkers := cluster.Spec.Workers.Count
+	// Upgrade the nodes before scaling so new workers join an upgraded control plane
+	if err := p.upgradeNodes(ctx, cluster); err != nil {
+		return err
+	}
+
+	// Nodes are resized or replaced before scaling, which leaves an
+	// interrupted rollout's surplus nodes alone
+	if err := p.updateMachineConfig(ctx, cluster); err != nil {
+		return err
+	}
 
-	if currentWorkers == desiredWorkers {
+	// The control plane is scaled first, so new workers have all of it to
+	// join and removed workers do not wait on control plane nodes going away
+	if err := p.scaleControlPlane(ctx, cluster); err != nil {
+		return err
+	}
+
+	// Workers are scaled from the worker containers that exist rather than
+	// from their names alone
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	desiredWorkers := int(cluster.Spec.Workers.Count)
+	plan := p.planWorkerScaling(cluster, containers, desiredWorkers)
+	if plan.empty() {
 		// No changes needed
 		return nil
 	}
+	log := clusterLogger(ctx, cluster)
+	log.Info("Scaling workers", "to", desiredWorkers, "create", len(plan.create), "remove", len(plan.remove), "start", len(plan.start))
 
-	// Get network ID
-	networkName := p.getClusterNetworkName(cluster)
-	networks, err := p.client.NetworkList(ctx, network.ListOptions{Filters: filters.NewArgs(filters.Arg("name", networkName))})
-	if err != nil || len(networks) == 0 {
-		return fmt.Errorf("failed to get cluster network: %w", err)
+	// Workers that are kept but were stopped are started again
+	if err := p.forEachNode(ctx, "start", plan.start, func(ctx context.Context, cont container.Summary) error {
+		return p.startNode(ctx, cluster, cont)
+	}); err != nil {
+		return err
 	}
-	networkID := networks[0].ID
 
-	if desiredWorkers > currentWorkers {
-		// Scale up: Create new worker nodes
-		for i := currentWorkers; i < desiredWorkers; i++ {
-			nodeName := p.getNodeName(cluster, "worker", int(i))
-			if err := p.createNode(ctx, cluster, nodeName, "worker", networkID, cluster.Spec.Workers.MachineConfig); err != nil {
-				return fmt.Errorf("failed to create worker node %s: %w", nodeName, err)
-			}
+	if len(plan.create) > 0 {
+		// Get the cluster network
+		clusterNetwork, err := p.requireClusterNetwork(ctx, cluster)
+		if err != nil {
+			return err
 		}
-	} else {
-		// Scale down: Remove excess worker nodes
-		for i := currentWorkers - 1; i >= desiredWorkers; i-- {
-			nodeName := p.getNodeName(cluster, "worker", int(i))
-
-			// Find container by name
-			containers, err := p.client.ContainerList(ctx, container.ListOptions{All: true, Filters: filters.NewArgs(filters.Arg("name", nodeName))})
-			if err != nil {
-				return fmt.Errorf("failed to list containers: %w", err)
-			}
-
-			if len(containers) == 0 {
-				continue // Container already gone
-			}
-
-			// Stop and remove the container
-			timeout := 60 // seconds
-			if err := p.client.ContainerStop(ctx, containers[0].ID, container.StopOptions{Timeout: &timeout}); err != nil {
-				return fmt.Errorf("failed to stop container %s: %w", nodeName, err)
-			}
 
-			if err := p.client.ContainerRemove(ctx, containers[0].ID, container.RemoveOptions{Force: true}); err != nil {
-				return fmt.Errorf("failed to remove container %s: %w", nodeName, err)
-			}
+		// Scale up: Create new worker nodes
+		if err := p.images.ensureImage(ctx, cluster, p.getNodeImage(cluster), nodeImagePolicy(cluster)); err != nil {
+			return err
+		}
+		if err := p.createWorkers(ctx, cluster, clusterNetwork, plan.create); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	// Scale down: Drain surplus worker nodes, then remove them
+	if err := p.drainNodes(ctx, cluster, plan.remove); err != nil {
+		return err
+	}
+	return p.removeNodes(ctx, cluster, plan.remove, 60)
 }
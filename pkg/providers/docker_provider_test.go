@@ -6,9 +6,51 @@ import (
 	"time"
 
 	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers/dockerfake"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+var _ DockerClient = &dockerfake.Engine{}
+
+// newFakeProvider returns a provider running against an in-memory engine
+func newFakeProvider(t *testing.T) (*DockerProvider, *dockerfake.Engine) {
+	t.Helper()
+	engine := dockerfake.NewEngine()
+	provider, err := NewDockerProvider(newTestProviderConfig(), WithDockerClient(engine))
+	if err != nil {
+		t.Fatalf("Failed to create Docker provider: %v", err)
+	}
+	return provider, engine
+}
+
+// newTestProviderConfig returns the provider configuration of tests that do
+// not test the configuration itself
+func newTestProviderConfig() *v1alpha1.DockerProviderConfig {
+	return &v1alpha1.DockerProviderConfig{
+		Spec: v1alpha1.DockerProviderConfigSpec{
+			Network: v1alpha1.NetworkConfig{CIDR: "172.30.0.0/16", SubnetMask: 24},
+		},
+	}
+}
+
+// newFakeCluster returns a cluster with a control plane node and workers
+func newFakeCluster(name string, workers int32) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: v1alpha1.TestKubernetesVersion,
+			ControlPlane: v1alpha1.ControlPlaneConfig{
+				Count:         1,
+				MachineConfig: v1alpha1.MachineConfig{Memory: "2Gi", CPUCount: 2},
+			},
+			Workers: v1alpha1.WorkerConfig{
+				Count:         workers,
+				MachineConfig: v1alpha1.MachineConfig{Memory: "2Gi", CPUCount: 2},
+			},
+		},
+	}
+}
+
 func TestDockerProvider(t *testing.T) {
 	ctx := context.Background()
 	provider, err := NewDockerProvider(&v1alpha1.DockerProviderConfig{
@@ -38,7 +80,7 @@ func TestDockerProvider(t *testing.T) {
 				},
 			},
 		},
-	})
+	}, WithDockerClient(dockerfake.NewEngine()))
 	if err != nil {
 		t.Fatalf("Failed to create Docker provider: %v", err)
 	}
@@ -146,17 +188,14 @@ func TestDockerProvider(t *testing.T) {
 		}
 
 		// Verify resource allocation
-		resources := provider.getResourceAllocation(cluster.Spec.ControlPlane.MachineConfig)
-		if resources == nil {
-			t.Fatal("Failed to get resource allocation")
-		}
+		resources := provider.nodeResources(cluster.Spec.ControlPlane.MachineConfig)
 
 		// Check control plane resources
-		expectedCPUQuota := int64(cluster.Spec.ControlPlane.MachineConfig.CPUCount * 100000)
-		if resources.CPUQuota != expectedCPUQuota {
-			t.Errorf("Expected CPU quota %d, got %d",
-				expectedCPUQuota,
-				resources.CPUQuota)
+		expectedNanoCPUs := int64(cluster.Spec.ControlPlane.MachineConfig.CPUCount) * 1e9
+		if resources.NanoCPUs != expectedNanoCPUs {
+			t.Errorf("Expected %d nanoCPUs, got %d",
+				expectedNanoCPUs,
+				resources.NanoCPUs)
 		}
 
 		expectedMemory := provider.parseMemory(cluster.Spec.ControlPlane.MachineConfig.Memory)
@@ -220,7 +259,7 @@ func TestCreateSingleNode(t *testing.T) {
 				DNSNameserver: "8.8.8.8",
 			},
 		},
-	})
+	}, WithDockerClient(dockerfake.NewEngine()))
 	if err != nil {
 		t.Fatalf("Failed to create Docker provider: %v", err)
 	}
@@ -0,0 +1,792 @@
+package dockerfake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// containerFilters are the filters ContainerList accepts
+var containerFilters = map[string]bool{
+	"label":    true,
+	"name":     true,
+	"id":       true,
+	"status":   true,
+	"network":  true,
+	"ancestor": true,
+}
+
+// fakeContainer is a container of the engine
+type fakeContainer struct {
+	id         string
+	name       string
+	created    time.Time
+	imageID    string
+	config     container.Config
+	hostConfig container.HostConfig
+	state      container.State
+
+	// networks holds the container's endpoints by network name
+	networks map[string]*network.EndpointSettings
+	mounts   []container.MountPoint
+
+	// anonymous holds the names of the volumes created for the container,
+	// which go away with it when its volumes are removed
+	anonymous []string
+
+	// files holds the content of the container's files by absolute path and
+	// dirs the directories created in it
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+// running reports whether the container's processes exist, paused or not
+func (c *fakeContainer) running() bool {
+	return c.state.Running
+}
+
+// attributes returns the attributes of the container's events
+func (c *fakeContainer) attributes() map[string]string {
+	attributes := copyLabels(c.config.Labels)
+	attributes["name"] = c.name
+	attributes["image"] = c.config.Image
+	return attributes
+}
+
+// container finds a container by ID, name or ID prefix. The caller holds e.mu.
+func (e *Engine) container(ref string) (*fakeContainer, error) {
+	if c, ok := e.containers[ref]; ok {
+		return c, nil
+	}
+	name := strings.TrimPrefix(ref, "/")
+	for _, c := range e.containers {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	var found *fakeContainer
+	for _, c := range e.containers {
+		if matchesID(c.id, ref) {
+			if found != nil {
+				return nil, errdefs.InvalidParameter(fmt.Errorf("multiple IDs found with provided prefix: %s", ref))
+			}
+			found = c
+		}
+	}
+	if found == nil {
+		return nil, errdefs.NotFound(fmt.Errorf("No such container: %s", ref))
+	}
+	return found, nil
+}
+
+// ContainerCreate creates a container from an image that is present. Its
+// endpoints are given addresses right away and take part in their networks
+// while the container runs.
+func (e *Engine) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	if err := e.call("ContainerCreate"); err != nil {
+		return container.CreateResponse{}, err
+	}
+	if config == nil {
+		return container.CreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("config cannot be empty in order to create a container"))
+	}
+	if hostConfig == nil {
+		hostConfig = &container.HostConfig{}
+	}
+	resources := hostConfig.Resources
+	if resources.NanoCPUs != 0 && (resources.CPUQuota != 0 || resources.CPUPeriod != 0) {
+		return container.CreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("conflicting options: Nano CPUs and CPU Period/Quota cannot both be set"))
+	}
+	if resources.Memory > 0 && resources.MemorySwap > 0 && resources.MemorySwap < resources.Memory {
+		return container.CreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("minimum memoryswap limit should be larger than memory limit, see usage"))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	img, err := e.image(config.Image)
+	if err != nil {
+		return container.CreateResponse{}, err
+	}
+	if containerName != "" {
+		if existing, err := e.container("/" + containerName); err == nil && existing.name == containerName {
+			return container.CreateResponse{}, errdefs.Conflict(fmt.Errorf("Conflict. The container name %q is already in use by container %q. You have to remove (or rename) that container to be able to reuse that name.", "/"+containerName, existing.id))
+		}
+	}
+
+	c := &fakeContainer{
+		id:         e.newID(),
+		name:       containerName,
+		created:    e.Now(),
+		imageID:    img.id,
+		config:     *config,
+		hostConfig: *hostConfig,
+		state:      container.State{Status: "created", StartedAt: zeroTime, FinishedAt: zeroTime},
+		networks:   map[string]*network.EndpointSettings{},
+		files:      map[string][]byte{},
+		dirs:       map[string]bool{},
+	}
+	if c.name == "" {
+		c.name = fmt.Sprintf("container_%s", c.id[len(c.id)-8:])
+	}
+	if c.config.Hostname == "" {
+		c.config.Hostname = c.id[:12]
+	}
+	c.config.Labels = copyLabels(config.Labels)
+	if c.hostConfig.Memory > 0 && c.hostConfig.MemorySwap == 0 {
+		c.hostConfig.MemorySwap = 2 * c.hostConfig.Memory
+	}
+	for path, content := range img.files {
+		c.files[path] = append([]byte(nil), content...)
+	}
+	for dir := range img.dirs {
+		c.dirs[dir] = true
+	}
+
+	if err := e.attachNetworks(c, networkingConfig); err != nil {
+		return container.CreateResponse{}, err
+	}
+	if err := e.mountVolumes(c, img); err != nil {
+		return container.CreateResponse{}, err
+	}
+
+	e.containers[c.id] = c
+	e.publish(events.ContainerEventType, events.ActionCreate, c.id, c.attributes())
+	return container.CreateResponse{ID: c.id}, nil
+}
+
+// attachNetworks gives a new container its endpoints, those of the
+// networking config or otherwise the one of its network mode
+func (e *Engine) attachNetworks(c *fakeContainer, networkingConfig *network.NetworkingConfig) error {
+	endpoints := map[string]*network.EndpointSettings{}
+	if networkingConfig != nil {
+		for name, endpoint := range networkingConfig.EndpointsConfig {
+			endpoints[name] = endpoint
+		}
+	}
+	if len(endpoints) == 0 {
+		switch mode := c.hostConfig.NetworkMode; {
+		case mode.IsHost() || mode.IsNone() || mode.IsContainer():
+		case mode == "" || mode.IsDefault():
+			endpoints["bridge"] = nil
+		default:
+			endpoints[string(mode)] = nil
+		}
+	}
+
+	for ref, config := range endpoints {
+		net, err := e.network(ref)
+		if err != nil {
+			return err
+		}
+		endpoint := &network.EndpointSettings{}
+		if config != nil {
+			endpoint = config.Copy()
+		}
+		var requested string
+		if endpoint.IPAMConfig != nil {
+			requested = endpoint.IPAMConfig.IPv4Address
+		}
+		address, gateway, prefix, err := e.allocateAddress(net, requested)
+		if err != nil {
+			return err
+		}
+		endpoint.NetworkID = net.ID
+		endpoint.EndpointID = e.newID()
+		endpoint.IPAddress = address
+		endpoint.Gateway = gateway
+		endpoint.IPPrefixLen = prefix
+		endpoint.MacAddress = macAddress(address)
+		endpoint.DNSNames = append([]string{c.name, c.id[:12]}, endpoint.Aliases...)
+		c.networks[net.Name] = endpoint
+	}
+	return nil
+}
+
+// mountVolumes mounts a new container's binds, its mounts and the volumes
+// of its config and image, creating the volumes that do not exist yet
+func (e *Engine) mountVolumes(c *fakeContainer, img *fakeImage) error {
+	mounted := map[string]bool{}
+	mountVolume := func(name, target string, readOnly bool) {
+		if name == "" {
+			name = e.newID()
+			c.anonymous = append(c.anonymous, name)
+		}
+		vol := e.ensureVolume(name, "local", nil)
+		c.mounts = append(c.mounts, container.MountPoint{
+			Type:        mount.TypeVolume,
+			Name:        vol.Name,
+			Source:      vol.Mountpoint,
+			Destination: target,
+			Driver:      vol.Driver,
+			RW:          !readOnly,
+		})
+		mounted[target] = true
+	}
+
+	for _, bind := range c.hostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 2 {
+			return errdefs.InvalidParameter(fmt.Errorf("invalid volume specification: '%s'", bind))
+		}
+		readOnly := len(parts) > 2 && strings.Contains(parts[2], "ro")
+		if strings.HasPrefix(parts[0], "/") {
+			c.mounts = append(c.mounts, container.MountPoint{Type: mount.TypeBind, Source: parts[0], Destination: parts[1], RW: !readOnly})
+			mounted[parts[1]] = true
+			continue
+		}
+		mountVolume(parts[0], parts[1], readOnly)
+	}
+	for _, m := range c.hostConfig.Mounts {
+		if mounted[m.Target] {
+			return errdefs.InvalidParameter(fmt.Errorf("Duplicate mount point: %s", m.Target))
+		}
+		switch m.Type {
+		case mount.TypeVolume:
+			mountVolume(m.Source, m.Target, m.ReadOnly)
+		default:
+			c.mounts = append(c.mounts, container.MountPoint{Type: m.Type, Source: m.Source, Destination: m.Target, RW: !m.ReadOnly})
+			mounted[m.Target] = true
+		}
+	}
+
+	var declared []string
+	for target := range img.volumes {
+		declared = append(declared, target)
+	}
+	for target := range c.config.Volumes {
+		declared = append(declared, target)
+	}
+	sort.Strings(declared)
+	for _, target := range declared {
+		if !mounted[target] {
+			mountVolume("", target, false)
+		}
+	}
+	return nil
+}
+
+// ContainerStart starts a created or exited container; starting a running
+// container does nothing
+func (e *Engine) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	if err := e.call("ContainerStart"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if c.state.Paused {
+		return errdefs.Conflict(fmt.Errorf("cannot start a paused container, try unpause instead"))
+	}
+	if c.running() {
+		return nil
+	}
+	e.start(c)
+	return nil
+}
+
+// start moves c to running and joins it to its networks. The caller holds e.mu.
+func (e *Engine) start(c *fakeContainer) {
+	c.state = container.State{
+		Status:     "running",
+		Running:    true,
+		Pid:        1000 + e.nextID,
+		StartedAt:  timestamp(e.Now()),
+		FinishedAt: c.state.FinishedAt,
+	}
+	for name, endpoint := range c.networks {
+		if net, err := e.network(name); err == nil {
+			net.Containers[c.id] = network.EndpointResource{
+				Name:        c.name,
+				EndpointID:  endpoint.EndpointID,
+				MacAddress:  endpoint.MacAddress,
+				IPv4Address: fmt.Sprintf("%s/%d", endpoint.IPAddress, endpoint.IPPrefixLen),
+			}
+		}
+	}
+	e.publish(events.ContainerEventType, events.ActionStart, c.id, c.attributes())
+}
+
+// stop moves c to exited with exitCode and takes it off its networks. The
+// caller holds e.mu.
+func (e *Engine) stop(c *fakeContainer, exitCode int) {
+	c.state = container.State{
+		Status:     "exited",
+		ExitCode:   exitCode,
+		StartedAt:  c.state.StartedAt,
+		FinishedAt: timestamp(e.Now()),
+	}
+	for name := range c.networks {
+		if net, err := e.network(name); err == nil {
+			delete(net.Containers, c.id)
+		}
+	}
+	attributes := c.attributes()
+	attributes["exitCode"] = fmt.Sprint(exitCode)
+	e.publish(events.ContainerEventType, events.ActionDie, c.id, attributes)
+}
+
+// ContainerStop stops a running or paused container; stopping a container
+// that is not running does nothing
+func (e *Engine) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	if err := e.call("ContainerStop"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if !c.running() {
+		return nil
+	}
+	e.stop(c, 0)
+	e.publish(events.ContainerEventType, events.ActionStop, c.id, c.attributes())
+	return nil
+}
+
+// ContainerRestart stops the container if it runs and starts it again
+func (e *Engine) ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error {
+	if err := e.call("ContainerRestart"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if c.running() {
+		e.stop(c, 0)
+	}
+	e.start(c)
+	c.state.Restarting = false
+	e.publish(events.ContainerEventType, events.ActionRestart, c.id, c.attributes())
+	return nil
+}
+
+// Kill makes a running container exit with exitCode, as if its main process
+// had died
+func (e *Engine) Kill(containerID string, exitCode int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if !c.running() {
+		return errdefs.Conflict(fmt.Errorf("Container %s is not running", c.id))
+	}
+	e.stop(c, exitCode)
+	return nil
+}
+
+// ContainerPause pauses a running container
+func (e *Engine) ContainerPause(ctx context.Context, containerID string) error {
+	if err := e.call("ContainerPause"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if !c.running() {
+		return errdefs.Conflict(fmt.Errorf("Container %s is not running", c.id))
+	}
+	if c.state.Paused {
+		return errdefs.Conflict(fmt.Errorf("Container %s is already paused", c.id))
+	}
+	c.state.Paused = true
+	c.state.Status = "paused"
+	e.publish(events.ContainerEventType, events.ActionPause, c.id, c.attributes())
+	return nil
+}
+
+// ContainerUnpause resumes a paused container
+func (e *Engine) ContainerUnpause(ctx context.Context, containerID string) error {
+	if err := e.call("ContainerUnpause"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if !c.state.Paused {
+		return errdefs.Conflict(fmt.Errorf("Container %s is not paused", c.id))
+	}
+	c.state.Paused = false
+	c.state.Status = "running"
+	e.publish(events.ContainerEventType, events.ActionUnPause, c.id, c.attributes())
+	return nil
+}
+
+// ContainerRemove removes a container that is not running, or any container
+// when forced, with its anonymous volumes if asked to
+func (e *Engine) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	if err := e.call("ContainerRemove"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if c.running() {
+		if !options.Force {
+			return errdefs.Conflict(fmt.Errorf("cannot remove container %q: container is running: stop the container before removing or force remove", "/"+c.name))
+		}
+		e.stop(c, 137)
+		e.publish(events.ContainerEventType, events.ActionKill, c.id, c.attributes())
+	}
+	delete(e.containers, c.id)
+	for id, exec := range e.execs {
+		if exec.containerID == c.id {
+			delete(e.execs, id)
+		}
+	}
+	if options.RemoveVolumes {
+		for _, name := range c.anonymous {
+			delete(e.volumes, name)
+		}
+	}
+	e.publish(events.ContainerEventType, events.ActionDestroy, c.id, c.attributes())
+	return nil
+}
+
+// ContainerRename gives a container a name no other container has
+func (e *Engine) ContainerRename(ctx context.Context, containerID, newContainerName string) error {
+	if err := e.call("ContainerRename"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimPrefix(newContainerName, "/")
+	if name == "" {
+		return errdefs.InvalidParameter(fmt.Errorf("Neither old nor new names may be empty"))
+	}
+	for _, other := range e.containers {
+		if other.name == name && other.id != c.id {
+			return errdefs.Conflict(fmt.Errorf("Conflict. The container name %q is already in use by container %q. You have to remove (or rename) that container to be able to reuse that name.", "/"+name, other.id))
+		}
+	}
+	oldName := c.name
+	c.name = name
+	for _, endpoint := range c.networks {
+		endpoint.DNSNames = append([]string{c.name, c.id[:12]}, endpoint.Aliases...)
+	}
+	attributes := c.attributes()
+	attributes["oldName"] = "/" + oldName
+	e.publish(events.ContainerEventType, events.ActionRename, c.id, attributes)
+	return nil
+}
+
+// ContainerUpdate changes a container's resources. Like the daemon it leaves
+// zero values unchanged, so limits cannot be lifted, and it refuses memory
+// limits above the memory and swap limit.
+func (e *Engine) ContainerUpdate(ctx context.Context, containerID string, updateConfig container.UpdateConfig) (container.UpdateResponse, error) {
+	if err := e.call("ContainerUpdate"); err != nil {
+		return container.UpdateResponse{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return container.UpdateResponse{}, err
+	}
+
+	update := updateConfig.Resources
+	resources := c.hostConfig.Resources
+	if update.NanoCPUs != 0 {
+		if resources.CPUQuota != 0 || resources.CPUPeriod != 0 {
+			return container.UpdateResponse{}, errdefs.InvalidParameter(fmt.Errorf("conflicting options: Nano CPUs cannot be updated as CPU Period/Quota has already been set"))
+		}
+		resources.NanoCPUs = update.NanoCPUs
+	}
+	if update.CPUQuota != 0 || update.CPUPeriod != 0 {
+		if resources.NanoCPUs != 0 {
+			return container.UpdateResponse{}, errdefs.InvalidParameter(fmt.Errorf("conflicting options: CPU Period/Quota cannot be updated as NanoCPUs has already been set"))
+		}
+		if update.CPUQuota != 0 {
+			resources.CPUQuota = update.CPUQuota
+		}
+		if update.CPUPeriod != 0 {
+			resources.CPUPeriod = update.CPUPeriod
+		}
+	}
+	if update.CPUShares != 0 {
+		resources.CPUShares = update.CPUShares
+	}
+	if update.MemorySwap != 0 {
+		resources.MemorySwap = update.MemorySwap
+	}
+	if update.Memory != 0 {
+		if resources.MemorySwap > 0 && update.Memory > resources.MemorySwap {
+			if update.MemorySwap == 0 {
+				return container.UpdateResponse{}, errdefs.InvalidParameter(fmt.Errorf("memory limit should be smaller than already set memoryswap limit, update the memoryswap at the same time"))
+			}
+			return container.UpdateResponse{}, errdefs.InvalidParameter(fmt.Errorf("minimum memoryswap limit should be larger than memory limit, see usage"))
+		}
+		resources.Memory = update.Memory
+	}
+	if update.MemoryReservation != 0 {
+		resources.MemoryReservation = update.MemoryReservation
+	}
+	if update.PidsLimit != nil {
+		limit := *update.PidsLimit
+		resources.PidsLimit = &limit
+	}
+	c.hostConfig.Resources = resources
+	if updateConfig.RestartPolicy.Name != "" {
+		c.hostConfig.RestartPolicy = updateConfig.RestartPolicy
+	}
+	e.publish(events.ContainerEventType, events.ActionUpdate, c.id, c.attributes())
+	return container.UpdateResponse{}, nil
+}
+
+// ContainerCommit creates an image holding the container's files and config
+func (e *Engine) ContainerCommit(ctx context.Context, containerID string, options container.CommitOptions) (container.CommitResponse, error) {
+	if err := e.call("ContainerCommit"); err != nil {
+		return container.CommitResponse{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return container.CommitResponse{}, err
+	}
+
+	img := e.newImage()
+	img.comment = options.Comment
+	img.labels = copyLabels(c.config.Labels)
+	if options.Config != nil {
+		for k, v := range options.Config.Labels {
+			img.labels[k] = v
+		}
+	}
+	for target := range c.config.Volumes {
+		img.volumes[target] = true
+	}
+	for path, content := range c.files {
+		img.files[path] = append([]byte(nil), content...)
+	}
+	for dir := range c.dirs {
+		img.dirs[dir] = true
+	}
+	if options.Reference != "" {
+		img.refs = append(img.refs, normalizeRef(options.Reference))
+	}
+	e.publish(events.ContainerEventType, events.ActionCommit, c.id, c.attributes())
+	return container.CommitResponse{ID: img.id}, nil
+}
+
+// ContainerList lists running containers, or all of them, newest first,
+// that match the options' filters
+func (e *Engine) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	if err := e.call("ContainerList"); err != nil {
+		return nil, err
+	}
+	if err := options.Filters.Validate(containerFilters); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	all := options.All || options.Filters.Contains("status")
+	var matches []*fakeContainer
+	for _, c := range e.containers {
+		if !all && !c.running() {
+			continue
+		}
+		if e.containerMatches(options.Filters, c) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].created.Equal(matches[j].created) {
+			return matches[i].created.After(matches[j].created)
+		}
+		return matches[i].id > matches[j].id
+	})
+	if options.Latest && len(matches) > 1 {
+		matches = matches[:1]
+	}
+	if options.Limit > 0 && len(matches) > options.Limit {
+		matches = matches[:options.Limit]
+	}
+
+	summaries := make([]container.Summary, 0, len(matches))
+	for _, c := range matches {
+		summaries = append(summaries, e.summary(c))
+	}
+	return summaries, nil
+}
+
+// containerMatches applies the daemon's container filters to c. The caller
+// holds e.mu.
+func (e *Engine) containerMatches(args filters.Args, c *fakeContainer) bool {
+	if !args.MatchKVList("label", c.config.Labels) {
+		return false
+	}
+	if args.Contains("name") && !args.Match("name", "/"+c.name) && !args.Match("name", c.name) {
+		return false
+	}
+	if !args.FuzzyMatch("id", c.id) || !args.ExactMatch("status", c.state.Status) {
+		return false
+	}
+	if args.Contains("ancestor") && !args.ExactMatch("ancestor", c.config.Image) && !args.ExactMatch("ancestor", c.imageID) {
+		return false
+	}
+	if args.Contains("network") {
+		found := false
+		for name, endpoint := range c.networks {
+			if args.ExactMatch("network", name) || args.ExactMatch("network", endpoint.NetworkID) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// summary describes c as ContainerList does. The caller holds e.mu.
+func (e *Engine) summary(c *fakeContainer) container.Summary {
+	summary := container.Summary{
+		ID:              c.id,
+		Names:           []string{"/" + c.name},
+		Image:           c.config.Image,
+		ImageID:         c.imageID,
+		Command:         strings.Join(append(append([]string(nil), c.config.Entrypoint...), c.config.Cmd...), " "),
+		Created:         c.created.Unix(),
+		Labels:          copyLabels(c.config.Labels),
+		State:           c.state.Status,
+		Status:          e.status(c),
+		NetworkSettings: &container.NetworkSettingsSummary{Networks: c.endpoints()},
+		Mounts:          append([]container.MountPoint(nil), c.mounts...),
+	}
+	summary.HostConfig.NetworkMode = string(c.hostConfig.NetworkMode)
+	return summary
+}
+
+// status describes c's state the way docker ps does
+func (e *Engine) status(c *fakeContainer) string {
+	switch c.state.Status {
+	case "running":
+		return "Up " + e.since(c.state.StartedAt)
+	case "paused":
+		return "Up " + e.since(c.state.StartedAt) + " (Paused)"
+	case "exited":
+		return fmt.Sprintf("Exited (%d) %s ago", c.state.ExitCode, e.since(c.state.FinishedAt))
+	default:
+		return "Created"
+	}
+}
+
+// since returns how long ago the timestamp was
+func (e *Engine) since(at string) string {
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return "Less than a second"
+	}
+	if d := e.Now().Sub(t).Round(time.Second); d >= time.Second {
+		return d.String()
+	}
+	return "Less than a second"
+}
+
+// endpoints returns copies of c's endpoints
+func (c *fakeContainer) endpoints() map[string]*network.EndpointSettings {
+	endpoints := make(map[string]*network.EndpointSettings, len(c.networks))
+	for name, endpoint := range c.networks {
+		endpoints[name] = endpoint.Copy()
+	}
+	return endpoints
+}
+
+// ContainerInspect describes a container
+func (e *Engine) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
+	if err := e.call("ContainerInspect"); err != nil {
+		return container.InspectResponse{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return container.InspectResponse{}, err
+	}
+
+	config := c.config
+	config.Labels = copyLabels(c.config.Labels)
+	config.Env = append([]string(nil), c.config.Env...)
+	config.Cmd = append([]string(nil), c.config.Cmd...)
+	config.Entrypoint = append([]string(nil), c.config.Entrypoint...)
+	if c.config.Volumes != nil {
+		config.Volumes = make(map[string]struct{}, len(c.config.Volumes))
+		for target := range c.config.Volumes {
+			config.Volumes[target] = struct{}{}
+		}
+	}
+	hostConfig := c.hostConfig
+	hostConfig.Binds = append([]string(nil), c.hostConfig.Binds...)
+	hostConfig.Mounts = append([]mount.Mount(nil), c.hostConfig.Mounts...)
+	state := c.state
+
+	var execIDs []string
+	for id, exec := range e.execs {
+		if exec.containerID == c.id {
+			execIDs = append(execIDs, id)
+		}
+	}
+	sort.Strings(execIDs)
+
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         c.id,
+			Created:    timestamp(c.created),
+			Path:       firstOf(c.config.Entrypoint, c.config.Cmd),
+			State:      &state,
+			Image:      c.imageID,
+			Name:       "/" + c.name,
+			Driver:     "overlay2",
+			Platform:   "linux",
+			ExecIDs:    execIDs,
+			HostConfig: &hostConfig,
+		},
+		Mounts:          append([]container.MountPoint(nil), c.mounts...),
+		Config:          &config,
+		NetworkSettings: &container.NetworkSettings{Networks: c.endpoints()},
+	}, nil
+}
+
+// firstOf returns the first element of the first non-empty slice
+func firstOf(slices ...[]string) string {
+	for _, s := range slices {
+		if len(s) > 0 {
+			return s[0]
+		}
+	}
+	return ""
+}
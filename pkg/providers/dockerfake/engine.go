@@ -0,0 +1,244 @@
+// Package dockerfake is an in-memory Docker engine for testing code that
+// talks to Docker through providers.DockerClient without a daemon. It keeps
+// containers, networks, images and volumes with their names, labels and
+// states, filters lists the way the daemon does, runs exec commands through
+// handlers and fails calls on demand.
+package dockerfake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// eventBuffer is how many events a subscriber can fall behind by before
+// further events are dropped for it
+const eventBuffer = 256
+
+// ExecResult is the outcome of a command run in a container
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ExecHandler runs cmd in the named container. It returns false for commands
+// it does not handle, which are passed on to the handlers registered before
+// it and finally to the engine's built-in commands.
+type ExecHandler func(container string, cmd []string) (ExecResult, bool)
+
+// Engine is an in-memory Docker engine. It is safe for concurrent use.
+type Engine struct {
+	// Now returns the time the engine records for state changes; tests may
+	// replace it before using the engine
+	Now func() time.Time
+
+	mu          sync.Mutex
+	nextID      int
+	containers  map[string]*fakeContainer
+	networks    map[string]*network.Inspect
+	images      map[string]*fakeImage
+	volumes     map[string]*volume.Volume
+	execs       map[string]*execution
+	handlers    []ExecHandler
+	failures    map[string]*failure
+	calls       map[string]int
+	subscribers map[int]*subscriber
+}
+
+// failure is an error injected into calls of an API method
+type failure struct {
+	err error
+
+	// remaining is how many more calls fail; negative means all of them
+	remaining int
+}
+
+// subscriber receives the events that match its filters
+type subscriber struct {
+	filters  filters.Args
+	messages chan events.Message
+}
+
+// NewEngine returns an engine that has nothing but Docker's default bridge
+// network
+func NewEngine() *Engine {
+	e := &Engine{
+		Now:         time.Now,
+		containers:  map[string]*fakeContainer{},
+		networks:    map[string]*network.Inspect{},
+		images:      map[string]*fakeImage{},
+		volumes:     map[string]*volume.Volume{},
+		execs:       map[string]*execution{},
+		failures:    map[string]*failure{},
+		calls:       map[string]int{},
+		subscribers: map[int]*subscriber{},
+	}
+	bridge := &network.Inspect{
+		Name:       "bridge",
+		ID:         e.newID(),
+		Created:    e.Now(),
+		Scope:      "local",
+		Driver:     "bridge",
+		IPAM:       network.IPAM{Driver: "default", Config: []network.IPAMConfig{{Subnet: "172.17.0.0/16", Gateway: "172.17.0.1"}}},
+		Containers: map[string]network.EndpointResource{},
+		Options:    map[string]string{},
+		Labels:     map[string]string{},
+	}
+	e.networks[bridge.ID] = bridge
+	return e
+}
+
+// InjectError makes the next times calls of the API method, e.g.
+// "ContainerStart", fail with err; times < 1 fails every call until the error
+// is injected again. A nil err stops the failures.
+func (e *Engine) InjectError(method string, err error, times int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		delete(e.failures, method)
+		return
+	}
+	if times < 1 {
+		times = -1
+	}
+	e.failures[method] = &failure{err: err, remaining: times}
+}
+
+// Calls returns how many times the API method was called, failed calls included
+func (e *Engine) Calls(method string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls[method]
+}
+
+// HandleExec registers handler for commands run in containers. Handlers
+// registered later are asked first.
+func (e *Engine) HandleExec(handler ExecHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers = append(e.handlers, handler)
+}
+
+// call counts a call of method and returns the error injected into it, if any
+func (e *Engine) call(method string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls[method]++
+	f, ok := e.failures[method]
+	if !ok {
+		return nil
+	}
+	if f.remaining > 0 {
+		f.remaining--
+		if f.remaining == 0 {
+			delete(e.failures, method)
+		}
+	}
+	return f.err
+}
+
+// newID returns a new 64 character hex ID, as the daemon hands out
+func (e *Engine) newID() string {
+	e.nextID++
+	return fmt.Sprintf("%064x", e.nextID)
+}
+
+// timestamp formats t the way the daemon reports state changes
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// zeroTime is what the daemon reports for state changes that never happened
+const zeroTime = "0001-01-01T00:00:00Z"
+
+// Events streams the engine's events that match the options' filters until
+// ctx ends, when ctx's error is sent on the error channel. Since and Until
+// are not supported.
+func (e *Engine) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	errs := make(chan error, 1)
+	if err := e.call("Events"); err != nil {
+		errs <- err
+		return make(chan events.Message), errs
+	}
+
+	sub := &subscriber{filters: options.Filters, messages: make(chan events.Message, eventBuffer)}
+	e.mu.Lock()
+	id := e.nextID
+	e.nextID++
+	e.subscribers[id] = sub
+	e.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		e.mu.Lock()
+		delete(e.subscribers, id)
+		e.mu.Unlock()
+		errs <- ctx.Err()
+	}()
+	return sub.messages, errs
+}
+
+// publish sends an event to the subscribers whose filters it matches. The
+// caller holds e.mu. Subscribers that fall behind miss events.
+func (e *Engine) publish(eventType events.Type, action events.Action, id string, attributes map[string]string) {
+	now := e.Now()
+	msg := events.Message{
+		Type:     eventType,
+		Action:   action,
+		Actor:    events.Actor{ID: id, Attributes: attributes},
+		Scope:    "local",
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	}
+	for _, sub := range e.subscribers {
+		if !eventMatches(sub.filters, msg) {
+			continue
+		}
+		select {
+		case sub.messages <- msg:
+		default:
+		}
+	}
+}
+
+// eventMatches applies the daemon's event filters to msg
+func eventMatches(args filters.Args, msg events.Message) bool {
+	if !args.ExactMatch("type", string(msg.Type)) || !args.ExactMatch("event", string(msg.Action)) {
+		return false
+	}
+	if !args.MatchKVList("label", msg.Actor.Attributes) {
+		return false
+	}
+	for _, key := range []events.Type{events.ContainerEventType, events.NetworkEventType, events.VolumeEventType, events.ImageEventType} {
+		if msg.Type != key || !args.Contains(string(key)) {
+			continue
+		}
+		if !args.ExactMatch(string(key), msg.Actor.ID) && !args.ExactMatch(string(key), msg.Actor.Attributes["name"]) {
+			return false
+		}
+	}
+	return true
+}
+
+// copyLabels returns a copy of labels that is never nil
+func copyLabels(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
+// matchesID reports whether ref is id or an unambiguous prefix of it, as
+// the daemon accepts
+func matchesID(id, ref string) bool {
+	return ref != "" && strings.HasPrefix(id, ref)
+}
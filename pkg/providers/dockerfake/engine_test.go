@@ -0,0 +1,390 @@
+package dockerfake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const testImage = "kindest/node:v1.31.0"
+
+// create creates a container named name from testImage
+func create(t *testing.T, e *Engine, name string, labels map[string]string) string {
+	t.Helper()
+	resp, err := e.ContainerCreate(context.Background(), &container.Config{Image: testImage, Labels: labels}, nil, nil, nil, name)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", name, err)
+	}
+	return resp.ID
+}
+
+// run runs cmd in a container and returns its output and exit code
+func run(t *testing.T, e *Engine, containerID string, cmd ...string) (string, string, int) {
+	t.Helper()
+	ctx := context.Background()
+	exec, err := e.ContainerExecCreate(ctx, containerID, container.ExecOptions{Cmd: cmd, AttachStdout: true, AttachStderr: true})
+	if err != nil {
+		t.Fatalf("Failed to create exec of %v: %v", cmd, err)
+	}
+	resp, err := e.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		t.Fatalf("Failed to attach to %v: %v", cmd, err)
+	}
+	defer resp.Close()
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		t.Fatalf("Failed to read output of %v: %v", cmd, err)
+	}
+	info, err := e.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		t.Fatalf("Failed to inspect exec of %v: %v", cmd, err)
+	}
+	return stdout.String(), stderr.String(), info.ExitCode
+}
+
+func TestContainerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+
+	if _, err := e.ContainerCreate(ctx, &container.Config{Image: testImage}, nil, nil, nil, "node"); !errdefs.IsNotFound(err) {
+		t.Fatalf("Expected creating from a missing image to be not found, got %v", err)
+	}
+	e.AddImage(testImage)
+	id := create(t, e, "node", nil)
+	if _, err := e.ContainerCreate(ctx, &container.Config{Image: testImage}, nil, nil, nil, "node"); !errdefs.IsConflict(err) {
+		t.Errorf("Expected a second container of the same name to conflict, got %v", err)
+	}
+
+	info, err := e.ContainerInspect(ctx, "node")
+	if err != nil {
+		t.Fatalf("Failed to inspect by name: %v", err)
+	}
+	if info.ID != id || info.Name != "/node" || info.State.Status != "created" {
+		t.Errorf("Expected created /node %s, got %s %s %s", id, info.Name, info.ID, info.State.Status)
+	}
+	if _, err := e.ContainerInspect(ctx, id[:12]); err != nil {
+		t.Errorf("Expected inspecting by ID prefix to work, got %v", err)
+	}
+
+	if err := e.ContainerStart(ctx, "node", container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	if err := e.ContainerRemove(ctx, "node", container.RemoveOptions{}); !errdefs.IsConflict(err) {
+		t.Errorf("Expected removing a running container to conflict, got %v", err)
+	}
+	if err := e.ContainerPause(ctx, "node"); err != nil {
+		t.Fatalf("Failed to pause: %v", err)
+	}
+	if err := e.ContainerStart(ctx, "node", container.StartOptions{}); !errdefs.IsConflict(err) {
+		t.Errorf("Expected starting a paused container to conflict, got %v", err)
+	}
+	if err := e.ContainerUnpause(ctx, "node"); err != nil {
+		t.Fatalf("Failed to unpause: %v", err)
+	}
+	if err := e.Kill("node", 137); err != nil {
+		t.Fatalf("Failed to kill: %v", err)
+	}
+	info, _ = e.ContainerInspect(ctx, "node")
+	if info.State.Status != "exited" || info.State.ExitCode != 137 || info.State.Running {
+		t.Errorf("Expected the container to have exited with 137, got %+v", info.State)
+	}
+	if err := e.ContainerRemove(ctx, "node", container.RemoveOptions{}); err != nil {
+		t.Fatalf("Failed to remove a stopped container: %v", err)
+	}
+	if _, err := e.ContainerInspect(ctx, "node"); !errdefs.IsNotFound(err) {
+		t.Errorf("Expected a removed container to be not found, got %v", err)
+	}
+}
+
+func TestContainerList(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+	e.AddImage(testImage)
+	create(t, e, "a-cp", map[string]string{"cluster": "a", "role": "control-plane"})
+	create(t, e, "a-worker", map[string]string{"cluster": "a", "role": "worker"})
+	create(t, e, "b-cp", map[string]string{"cluster": "b", "role": "control-plane"})
+	if err := e.ContainerStart(ctx, "a-cp", container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options container.ListOptions
+		want    []string
+	}{
+		{"running only", container.ListOptions{}, []string{"a-cp"}},
+		{"all newest first", container.ListOptions{All: true}, []string{"b-cp", "a-worker", "a-cp"}},
+		{"label", container.ListOptions{All: true, Filters: filters.NewArgs(filters.Arg("label", "cluster=a"))}, []string{"a-worker", "a-cp"}},
+		{"labels", container.ListOptions{All: true, Filters: filters.NewArgs(filters.Arg("label", "cluster=a"), filters.Arg("label", "role=worker"))}, []string{"a-worker"}},
+		{"label key", container.ListOptions{All: true, Filters: filters.NewArgs(filters.Arg("label", "role"))}, []string{"b-cp", "a-worker", "a-cp"}},
+		{"name", container.ListOptions{All: true, Filters: filters.NewArgs(filters.Arg("name", "^/b-"))}, []string{"b-cp"}},
+		{"status", container.ListOptions{Filters: filters.NewArgs(filters.Arg("status", "created"))}, []string{"b-cp", "a-worker"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containers, err := e.ContainerList(ctx, tt.options)
+			if err != nil {
+				t.Fatalf("Failed to list: %v", err)
+			}
+			var names []string
+			for _, c := range containers {
+				names = append(names, strings.TrimPrefix(c.Names[0], "/"))
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, names)
+			}
+		})
+	}
+
+	if _, err := e.ContainerList(ctx, container.ListOptions{Filters: filters.NewArgs(filters.Arg("colour", "red"))}); !errdefs.IsInvalidParameter(err) {
+		t.Errorf("Expected an unknown filter to be invalid, got %v", err)
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+	e.AddImage(testImage)
+
+	resp, err := e.NetworkCreate(ctx, "kind", network.CreateOptions{Labels: map[string]string{"cluster": "a"}})
+	if err != nil {
+		t.Fatalf("Failed to create network: %v", err)
+	}
+	if _, err := e.NetworkCreate(ctx, "kind", network.CreateOptions{}); !errdefs.IsConflict(err) {
+		t.Errorf("Expected a second network of the same name to conflict, got %v", err)
+	}
+	net, err := e.NetworkInspect(ctx, "kind", network.InspectOptions{})
+	if err != nil {
+		t.Fatalf("Failed to inspect network: %v", err)
+	}
+	if net.ID != resp.ID || net.IPAM.Config[0].Subnet != "172.18.0.0/16" || net.IPAM.Config[0].Gateway != "172.18.0.1" {
+		t.Errorf("Expected the first free default pool, got %+v", net.IPAM)
+	}
+
+	for _, name := range []string{"n0", "n1"} {
+		if _, err := e.ContainerCreate(ctx, &container.Config{Image: testImage}, &container.HostConfig{NetworkMode: "kind"}, nil, nil, name); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	info, _ := e.ContainerInspect(ctx, "n1")
+	if endpoint := info.NetworkSettings.Networks["kind"]; endpoint == nil || endpoint.IPAddress != "172.18.0.3" || endpoint.NetworkID != resp.ID {
+		t.Errorf("Expected n1 to get the second address on kind, got %+v", endpoint)
+	}
+
+	if err := e.ContainerStart(ctx, "n0", container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	if err := e.NetworkRemove(ctx, "kind"); !errdefs.IsForbidden(err) {
+		t.Errorf("Expected removing a network with running containers to be forbidden, got %v", err)
+	}
+	if err := e.ContainerStop(ctx, "n0", container.StopOptions{}); err != nil {
+		t.Fatalf("Failed to stop: %v", err)
+	}
+	if err := e.NetworkRemove(ctx, "kind"); err != nil {
+		t.Errorf("Expected a network without running containers to be removed, got %v", err)
+	}
+
+	networks, err := e.NetworkList(ctx, network.ListOptions{Filters: filters.NewArgs(filters.Arg("label", "cluster=a"))})
+	if err != nil || len(networks) != 0 {
+		t.Errorf("Expected no labelled networks left, got %v (%v)", networks, err)
+	}
+}
+
+func TestContainerUpdate(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+	e.AddImage(testImage)
+	hostConfig := &container.HostConfig{Resources: container.Resources{Memory: 1 << 30, NanoCPUs: 1e9}}
+	if _, err := e.ContainerCreate(ctx, &container.Config{Image: testImage}, hostConfig, nil, nil, "node"); err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+
+	if _, err := e.ContainerUpdate(ctx, "node", container.UpdateConfig{Resources: container.Resources{Memory: 4 << 30}}); !errdefs.IsInvalidParameter(err) {
+		t.Errorf("Expected raising memory above the swap limit alone to be refused, got %v", err)
+	}
+	if _, err := e.ContainerUpdate(ctx, "node", container.UpdateConfig{Resources: container.Resources{CPUQuota: 50000}}); !errdefs.IsInvalidParameter(err) {
+		t.Errorf("Expected a CPU quota next to nanoCPUs to be refused, got %v", err)
+	}
+	if _, err := e.ContainerUpdate(ctx, "node", container.UpdateConfig{Resources: container.Resources{Memory: 4 << 30, MemorySwap: 8 << 30, NanoCPUs: 2e9}}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	info, _ := e.ContainerInspect(ctx, "node")
+	if info.HostConfig.Memory != 4<<30 || info.HostConfig.MemorySwap != 8<<30 || info.HostConfig.NanoCPUs != 2e9 {
+		t.Errorf("Expected the new limits, got %+v", info.HostConfig.Resources)
+	}
+}
+
+func TestExec(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+	e.AddImage(testImage)
+	id := create(t, e, "node", nil)
+
+	if _, err := e.ContainerExecCreate(ctx, id, container.ExecOptions{Cmd: []string{"true"}}); !errdefs.IsConflict(err) {
+		t.Errorf("Expected exec in a stopped container to conflict, got %v", err)
+	}
+	if err := e.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	if err := e.WriteFile("node", "/etc/kubernetes/admin.conf", []byte("config")); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, _, code := run(t, e, id, "test", "-f", "/etc/kubernetes/admin.conf"); code != 0 {
+		t.Errorf("Expected the written file to exist, got exit code %d", code)
+	}
+	if _, _, code := run(t, e, id, "test", "-f", "/etc/kubernetes"); code != 1 {
+		t.Errorf("Expected a directory not to be a file, got exit code %d", code)
+	}
+	if stdout, _, _ := run(t, e, id, "cat", "/etc/kubernetes/admin.conf"); stdout != "config" {
+		t.Errorf("Expected cat to print the file, got %q", stdout)
+	}
+	if _, stderr, code := run(t, e, id, "kubeadm", "init"); code != 127 || !strings.Contains(stderr, "not found") {
+		t.Errorf("Expected an unhandled command not to be found, got %d %q", code, stderr)
+	}
+
+	e.HandleExec(func(name string, cmd []string) (ExecResult, bool) {
+		if cmd[0] != "kubeadm" {
+			return ExecResult{}, false
+		}
+		return ExecResult{Stdout: "initialized on " + name, Stderr: "warning", ExitCode: 0}, true
+	})
+	if stdout, stderr, code := run(t, e, id, "kubeadm", "init"); stdout != "initialized on node" || stderr != "warning" || code != 0 {
+		t.Errorf("Expected the handler to run kubeadm, got %d %q %q", code, stdout, stderr)
+	}
+	if _, _, code := run(t, e, id, "false"); code != 1 {
+		t.Errorf("Expected commands the handler declines to run as before, got exit code %d", code)
+	}
+}
+
+func TestCopyFiles(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+	e.AddImage(testImage)
+	create(t, e, "node", nil)
+	if err := e.WriteFile("node", "/kind/manifests/cni.yaml", []byte("cni")); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	reader, stat, err := e.CopyFromContainer(ctx, "node", "/kind/manifests/cni.yaml")
+	if err != nil {
+		t.Fatalf("Failed to copy from container: %v", err)
+	}
+	if stat.Name != "cni.yaml" || stat.Size != 3 {
+		t.Errorf("Expected the file's stat, got %+v", stat)
+	}
+	if err := e.CopyToContainer(ctx, "node", "/tmp", reader, container.CopyToContainerOptions{}); err != nil {
+		t.Fatalf("Failed to copy to container: %v", err)
+	}
+	if content, err := e.ReadFile("node", "/tmp/cni.yaml"); err != nil || string(content) != "cni" {
+		t.Errorf("Expected the copied file, got %q (%v)", content, err)
+	}
+	if _, _, err := e.CopyFromContainer(ctx, "node", "/missing"); !errdefs.IsNotFound(err) {
+		t.Errorf("Expected copying a missing file to be not found, got %v", err)
+	}
+}
+
+func TestImages(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+
+	if _, err := e.ImageInspect(ctx, testImage); !errdefs.IsNotFound(err) {
+		t.Fatalf("Expected a missing image to be not found, got %v", err)
+	}
+	stream, err := e.ImagePull(ctx, testImage, image.PullOptions{})
+	if err != nil {
+		t.Fatalf("Failed to pull: %v", err)
+	}
+	var out bytes.Buffer
+	out.ReadFrom(stream)
+	if !strings.Contains(out.String(), "Downloaded newer image for "+testImage) {
+		t.Errorf("Expected the pull to report a download, got %s", out.String())
+	}
+	if _, err := e.ImageInspect(ctx, testImage); err != nil {
+		t.Errorf("Expected the pulled image to be present, got %v", err)
+	}
+	if _, err := e.ImageInspect(ctx, "busybox"); !errdefs.IsNotFound(err) {
+		t.Errorf("Expected an image that was not pulled to be not found, got %v", err)
+	}
+	e.AddImage("busybox")
+	if _, err := e.ImageInspect(ctx, "busybox:latest"); err != nil {
+		t.Errorf("Expected untagged references to mean latest, got %v", err)
+	}
+}
+
+func TestInjectError(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+	e.AddImage(testImage)
+	injected := errors.New("daemon unavailable")
+
+	e.InjectError("ContainerCreate", injected, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := e.ContainerCreate(ctx, &container.Config{Image: testImage}, nil, nil, nil, "node"); !errors.Is(err, injected) {
+			t.Errorf("Expected call %d to fail with the injected error, got %v", i, err)
+		}
+	}
+	create(t, e, "node", nil)
+	if calls := e.Calls("ContainerCreate"); calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+
+	e.InjectError("ContainerList", injected, 0)
+	for i := 0; i < 3; i++ {
+		if _, err := e.ContainerList(ctx, container.ListOptions{}); !errors.Is(err, injected) {
+			t.Errorf("Expected every call to fail, got %v", err)
+		}
+	}
+	e.InjectError("ContainerList", nil, 0)
+	if _, err := e.ContainerList(ctx, container.ListOptions{}); err != nil {
+		t.Errorf("Expected calls to succeed once the error is cleared, got %v", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	e := NewEngine()
+	e.AddImage(testImage)
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, errs := e.Events(ctx, events.ListOptions{Filters: filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("label", "cluster=a"),
+	)})
+
+	create(t, e, "other", nil)
+	id := create(t, e, "node", map[string]string{"cluster": "a"})
+	if err := e.ContainerStart(context.Background(), id, container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	for _, action := range []events.Action{events.ActionCreate, events.ActionStart} {
+		select {
+		case msg := <-messages:
+			if msg.Action != action || msg.Actor.ID != id || msg.Actor.Attributes["name"] != "node" {
+				t.Errorf("Expected %s of node, got %s of %s", action, msg.Action, msg.Actor.Attributes["name"])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s", action)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the stream to end with the context, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the stream to end")
+	}
+}
@@ -0,0 +1,404 @@
+package dockerfake
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// execution is a command created to run in a container
+type execution struct {
+	id          string
+	containerID string
+	cmd         []string
+	started     bool
+	exitCode    int
+}
+
+// ContainerExecCreate creates a command to run in a running container
+func (e *Engine) ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (container.ExecCreateResponse, error) {
+	if err := e.call("ContainerExecCreate"); err != nil {
+		return container.ExecCreateResponse{}, err
+	}
+	if len(options.Cmd) == 0 {
+		return container.ExecCreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("No exec command specified"))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return container.ExecCreateResponse{}, err
+	}
+	if !c.running() {
+		return container.ExecCreateResponse{}, errdefs.Conflict(fmt.Errorf("container %s is not running", c.id))
+	}
+	if c.state.Paused {
+		return container.ExecCreateResponse{}, errdefs.Conflict(fmt.Errorf("container %s is paused, unpause the container before exec", c.id))
+	}
+
+	exec := &execution{id: e.newID(), containerID: c.id, cmd: append([]string(nil), options.Cmd...)}
+	e.execs[exec.id] = exec
+	attributes := c.attributes()
+	attributes["execID"] = exec.id
+	e.publish(events.ContainerEventType, events.Action("exec_create: "+strings.Join(exec.cmd, " ")), c.id, attributes)
+	return container.ExecCreateResponse{ID: exec.id}, nil
+}
+
+// ContainerExecAttach runs a created command and returns its output as the
+// daemon's multiplexed stream. The command has finished by the time it
+// returns, so its exit code can be inspected right away.
+func (e *Engine) ContainerExecAttach(ctx context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error) {
+	if err := e.call("ContainerExecAttach"); err != nil {
+		return types.HijackedResponse{}, err
+	}
+	e.mu.Lock()
+	exec, ok := e.execs[execID]
+	if !ok {
+		e.mu.Unlock()
+		return types.HijackedResponse{}, errdefs.NotFound(fmt.Errorf("No such exec instance: %s", execID))
+	}
+	if exec.started {
+		e.mu.Unlock()
+		return types.HijackedResponse{}, errdefs.Conflict(fmt.Errorf("Error: Exec command %s is already running", execID))
+	}
+	exec.started = true
+	c, err := e.container(exec.containerID)
+	if err != nil {
+		e.mu.Unlock()
+		return types.HijackedResponse{}, err
+	}
+	name := c.name
+	handlers := append([]ExecHandler(nil), e.handlers...)
+	e.mu.Unlock()
+
+	// Handlers run without the lock so that they may call the engine
+	result, handled := ExecResult{}, false
+	for i := len(handlers) - 1; i >= 0 && !handled; i-- {
+		result, handled = handlers[i](name, exec.cmd)
+	}
+	if !handled {
+		result = e.builtin(exec.containerID, exec.cmd)
+	}
+
+	e.mu.Lock()
+	exec.exitCode = result.ExitCode
+	e.mu.Unlock()
+
+	server, client := net.Pipe()
+	go func() {
+		defer server.Close()
+		if result.Stdout != "" {
+			if _, err := stdcopy.NewStdWriter(server, stdcopy.Stdout).Write([]byte(result.Stdout)); err != nil {
+				return
+			}
+		}
+		if result.Stderr != "" {
+			stdcopy.NewStdWriter(server, stdcopy.Stderr).Write([]byte(result.Stderr))
+		}
+	}()
+	return types.NewHijackedResponse(client, types.MediaTypeMultiplexedStream), nil
+}
+
+// ContainerExecInspect describes a command; it has finished once attached to
+func (e *Engine) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	if err := e.call("ContainerExecInspect"); err != nil {
+		return container.ExecInspect{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	exec, ok := e.execs[execID]
+	if !ok {
+		return container.ExecInspect{}, errdefs.NotFound(fmt.Errorf("No such exec instance: %s", execID))
+	}
+	return container.ExecInspect{ExecID: exec.id, ContainerID: exec.containerID, ExitCode: exec.exitCode}, nil
+}
+
+// builtin runs the commands every container has that no handler took:
+// true, false, test -e/-f/-d, cat, mkdir, rm and mv on the container's files.
+// Anything else is not found in the container.
+func (e *Engine) builtin(containerID string, cmd []string) ExecResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return ExecResult{Stderr: err.Error(), ExitCode: 126}
+	}
+	args := cmd[1:]
+	flags := map[string]bool{}
+	var paths []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && len(paths) == 0 {
+			for _, flag := range arg[1:] {
+				flags[string(flag)] = true
+			}
+			continue
+		}
+		paths = append(paths, arg)
+	}
+
+	switch cmd[0] {
+	case "true":
+		return ExecResult{}
+	case "false":
+		return ExecResult{ExitCode: 1}
+	case "test", "[":
+		if len(paths) != 1 {
+			return ExecResult{Stderr: "test: unsupported expression", ExitCode: 2}
+		}
+		_, isFile := c.files[paths[0]]
+		isDir := c.isDir(paths[0])
+		if (flags["f"] && isFile) || (flags["d"] && isDir) || (flags["e"] && (isFile || isDir)) {
+			return ExecResult{}
+		}
+		return ExecResult{ExitCode: 1}
+	case "cat":
+		var out, errs strings.Builder
+		for _, p := range paths {
+			content, ok := c.files[p]
+			if !ok {
+				fmt.Fprintf(&errs, "cat: %s: No such file or directory\n", p)
+				continue
+			}
+			out.Write(content)
+		}
+		if errs.Len() > 0 {
+			return ExecResult{Stdout: out.String(), Stderr: errs.String(), ExitCode: 1}
+		}
+		return ExecResult{Stdout: out.String()}
+	case "mkdir":
+		for _, p := range paths {
+			c.mkdirAll(p)
+		}
+		return ExecResult{}
+	case "rm":
+		var errs strings.Builder
+		for _, p := range paths {
+			if _, ok := c.files[p]; ok {
+				delete(c.files, p)
+				continue
+			}
+			if c.isDir(p) && (flags["r"] || flags["R"]) {
+				c.removeAll(p)
+				continue
+			}
+			if !flags["f"] {
+				fmt.Fprintf(&errs, "rm: cannot remove '%s': No such file or directory\n", p)
+			}
+		}
+		if errs.Len() > 0 {
+			return ExecResult{Stderr: errs.String(), ExitCode: 1}
+		}
+		return ExecResult{}
+	case "mv":
+		if len(paths) != 2 {
+			return ExecResult{Stderr: "mv: missing destination file operand", ExitCode: 1}
+		}
+		content, ok := c.files[paths[0]]
+		if !ok {
+			return ExecResult{Stderr: fmt.Sprintf("mv: cannot stat '%s': No such file or directory", paths[0]), ExitCode: 1}
+		}
+		dst := paths[1]
+		if c.isDir(dst) {
+			dst = path.Join(dst, path.Base(paths[0]))
+		}
+		delete(c.files, paths[0])
+		c.files[dst] = content
+		return ExecResult{}
+	}
+	return ExecResult{
+		Stderr:   fmt.Sprintf("OCI runtime exec failed: exec failed: unable to start container process: exec: %q: executable file not found in $PATH", cmd[0]),
+		ExitCode: 127,
+	}
+}
+
+// isDir reports whether p is a directory of the container: one created in
+// it or one holding a file
+func (c *fakeContainer) isDir(p string) bool {
+	p = path.Clean(p)
+	if p == "/" || c.dirs[p] {
+		return true
+	}
+	for file := range c.files {
+		if strings.HasPrefix(file, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mkdirAll creates the directory p and its parents
+func (c *fakeContainer) mkdirAll(p string) {
+	for p = path.Clean(p); p != "/" && p != "."; p = path.Dir(p) {
+		c.dirs[p] = true
+	}
+}
+
+// removeAll removes the directory p and everything below it
+func (c *fakeContainer) removeAll(p string) {
+	p = path.Clean(p)
+	for file := range c.files {
+		if strings.HasPrefix(file, p+"/") {
+			delete(c.files, file)
+		}
+	}
+	for dir := range c.dirs {
+		if dir == p || strings.HasPrefix(dir, p+"/") {
+			delete(c.dirs, dir)
+		}
+	}
+}
+
+// CopyToContainer extracts a tar archive into the directory dstPath of a
+// container. The containers of the engine's images start out empty, so
+// unlike the daemon it creates dstPath when it does not exist yet.
+func (e *Engine) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options container.CopyToContainerOptions) error {
+	if err := e.call("CopyToContainer"); err != nil {
+		return err
+	}
+	files := map[string][]byte{}
+	var dirs []string
+	archive := tar.NewReader(content)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errdefs.InvalidParameter(fmt.Errorf("failed to read archive: %w", err))
+		}
+		target := path.Join(dstPath, header.Name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			dirs = append(dirs, target)
+		case tar.TypeReg:
+			data, err := io.ReadAll(archive)
+			if err != nil {
+				return errdefs.InvalidParameter(fmt.Errorf("failed to read %s from archive: %w", header.Name, err))
+			}
+			files[target] = data
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.files[path.Clean(dstPath)]; ok {
+		return errdefs.InvalidParameter(fmt.Errorf("extraction point is not a directory"))
+	}
+	c.mkdirAll(dstPath)
+	for _, dir := range dirs {
+		c.mkdirAll(dir)
+	}
+	for target, data := range files {
+		c.mkdirAll(path.Dir(target))
+		c.files[target] = data
+	}
+	return nil
+}
+
+// CopyFromContainer returns a tar archive of the file or directory srcPath
+// of a container
+func (e *Engine) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, container.PathStat, error) {
+	if err := e.call("CopyFromContainer"); err != nil {
+		return nil, container.PathStat{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return nil, container.PathStat{}, err
+	}
+
+	src := path.Clean(srcPath)
+	base := path.Base(src)
+	mtime := e.Now()
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	var stat container.PathStat
+	if content, ok := c.files[src]; ok {
+		stat = container.PathStat{Name: base, Size: int64(len(content)), Mode: 0o644, Mtime: mtime}
+		if err := writeArchiveFile(archive, base, content); err != nil {
+			return nil, container.PathStat{}, err
+		}
+	} else if c.isDir(src) {
+		stat = container.PathStat{Name: base, Mode: os.ModeDir | 0o755, Mtime: mtime}
+		if err := archive.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: base + "/", Mode: 0o755, ModTime: mtime}); err != nil {
+			return nil, container.PathStat{}, err
+		}
+		var names []string
+		for file := range c.files {
+			if strings.HasPrefix(file, src+"/") {
+				names = append(names, file)
+			}
+		}
+		sort.Strings(names)
+		for _, file := range names {
+			if err := writeArchiveFile(archive, path.Join(base, strings.TrimPrefix(file, src+"/")), c.files[file]); err != nil {
+				return nil, container.PathStat{}, err
+			}
+		}
+	} else {
+		return nil, container.PathStat{}, errdefs.NotFound(fmt.Errorf("Could not find the file %s in container %s", srcPath, containerID))
+	}
+	if err := archive.Close(); err != nil {
+		return nil, container.PathStat{}, err
+	}
+	return io.NopCloser(&buf), stat, nil
+}
+
+// writeArchiveFile adds a regular file to a tar archive
+func writeArchiveFile(archive *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(content))}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := archive.Write(content)
+	return err
+}
+
+// WriteFile sets the content of a file of a container, creating its
+// directories, as a test setting up what a node would hold
+func (e *Engine) WriteFile(containerID, filePath string, content []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	filePath = path.Clean(filePath)
+	c.mkdirAll(path.Dir(filePath))
+	c.files[filePath] = append([]byte(nil), content...)
+	return nil
+}
+
+// ReadFile returns the content of a file of a container
+func (e *Engine) ReadFile(containerID, filePath string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := e.container(containerID)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := c.files[path.Clean(filePath)]
+	if !ok {
+		return nil, errdefs.NotFound(fmt.Errorf("Could not find the file %s in container %s", filePath, containerID))
+	}
+	return append([]byte(nil), content...), nil
+}
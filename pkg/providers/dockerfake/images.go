@@ -0,0 +1,272 @@
+package dockerfake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// pullLayerSize is the size a pull reports for the single layer of an image
+const pullLayerSize = 1 << 20
+
+// fakeImage is an image of the engine
+type fakeImage struct {
+	id      string
+	refs    []string
+	created time.Time
+	comment string
+	labels  map[string]string
+
+	// volumes holds the mount points the image declares; containers get an
+	// anonymous volume at each of them
+	volumes map[string]bool
+
+	// files and dirs are what containers created from the image start with
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+// newImage adds an image without references. The caller holds e.mu.
+func (e *Engine) newImage() *fakeImage {
+	img := &fakeImage{
+		id:      "sha256:" + e.newID(),
+		created: e.Now(),
+		labels:  map[string]string{},
+		volumes: map[string]bool{},
+		files:   map[string][]byte{},
+		dirs:    map[string]bool{},
+	}
+	e.images[img.id] = img
+	return img
+}
+
+// normalizeRef adds the latest tag to references without a tag or digest
+func normalizeRef(ref string) string {
+	if strings.Contains(ref, "@") {
+		return ref
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref
+	}
+	return ref + ":latest"
+}
+
+// image finds an image by reference, ID or ID prefix. The caller holds e.mu.
+func (e *Engine) image(ref string) (*fakeImage, error) {
+	normalized := normalizeRef(ref)
+	for _, img := range e.images {
+		for _, r := range img.refs {
+			if r == normalized {
+				return img, nil
+			}
+		}
+	}
+	id := strings.TrimPrefix(ref, "sha256:")
+	for _, img := range e.images {
+		if matchesID(strings.TrimPrefix(img.id, "sha256:"), id) {
+			return img, nil
+		}
+	}
+	return nil, errdefs.NotFound(fmt.Errorf("No such image: %s", ref))
+}
+
+// AddImage makes ref present, as if it had been pulled, and returns its ID.
+// Adding a present image does nothing.
+func (e *Engine) AddImage(ref string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.addImage(ref).id
+}
+
+// addImage returns the image ref, adding it if it is missing. The caller
+// holds e.mu.
+func (e *Engine) addImage(ref string) *fakeImage {
+	if img, err := e.image(ref); err == nil {
+		return img
+	}
+	img := e.newImage()
+	img.refs = []string{normalizeRef(ref)}
+	return img
+}
+
+// ImagePull adds the image and reports the pull as the daemon's progress
+// stream, which also tells when the image was already present
+func (e *Engine) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
+	if err := e.call("ImagePull"); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ref := normalizeRef(refStr)
+	tag := ref[strings.LastIndexAny(ref, ":@")+1:]
+	var messages []jsonmessage.JSONMessage
+	if img, err := e.image(ref); err == nil {
+		messages = append(messages,
+			jsonmessage.JSONMessage{Status: "Digest: " + img.id},
+			jsonmessage.JSONMessage{Status: "Status: Image is up to date for " + ref},
+		)
+	} else {
+		img := e.addImage(ref)
+		layer := strings.TrimPrefix(img.id, "sha256:")[52:]
+		messages = append(messages,
+			jsonmessage.JSONMessage{Status: "Pulling from " + strings.TrimSuffix(ref, ":"+tag), ID: tag},
+			jsonmessage.JSONMessage{Status: "Pulling fs layer", ID: layer},
+			jsonmessage.JSONMessage{Status: "Downloading", ID: layer, Progress: &jsonmessage.JSONProgress{Current: pullLayerSize / 2, Total: pullLayerSize}},
+			jsonmessage.JSONMessage{Status: "Downloading", ID: layer, Progress: &jsonmessage.JSONProgress{Current: pullLayerSize, Total: pullLayerSize}},
+			jsonmessage.JSONMessage{Status: "Download complete", ID: layer},
+			jsonmessage.JSONMessage{Status: "Pull complete", ID: layer},
+			jsonmessage.JSONMessage{Status: "Digest: " + img.id},
+			jsonmessage.JSONMessage{Status: "Status: Downloaded newer image for " + ref},
+		)
+		e.publish(events.ImageEventType, events.ActionPull, ref, map[string]string{"name": ref})
+	}
+
+	var stream bytes.Buffer
+	encoder := json.NewEncoder(&stream)
+	for _, msg := range messages {
+		if err := encoder.Encode(msg); err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(&stream), nil
+}
+
+// ImageInspect describes a present image
+func (e *Engine) ImageInspect(ctx context.Context, imageID string, opts ...client.ImageInspectOption) (image.InspectResponse, error) {
+	if err := e.call("ImageInspect"); err != nil {
+		return image.InspectResponse{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	img, err := e.image(imageID)
+	if err != nil {
+		return image.InspectResponse{}, err
+	}
+
+	config := &container.Config{Labels: copyLabels(img.labels)}
+	if len(img.volumes) > 0 {
+		config.Volumes = map[string]struct{}{}
+		for target := range img.volumes {
+			config.Volumes[target] = struct{}{}
+		}
+	}
+	refs := append([]string(nil), img.refs...)
+	sort.Strings(refs)
+	return image.InspectResponse{
+		ID:           img.id,
+		RepoTags:     refs,
+		Created:      timestamp(img.created),
+		Comment:      img.comment,
+		Config:       config,
+		Architecture: "amd64",
+		Os:           "linux",
+	}, nil
+}
+
+// imageFilters are the ImageList filters the engine supports
+var imageFilters = map[string]bool{
+	"label":     true,
+	"reference": true,
+}
+
+// ImageList lists the present images that match the options' filters,
+// newest first
+func (e *Engine) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	if err := e.call("ImageList"); err != nil {
+		return nil, err
+	}
+	if err := options.Filters.Validate(imageFilters); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	args := options.Filters
+	var matches []*fakeImage
+	for _, img := range e.images {
+		if !args.MatchKVList("label", img.labels) {
+			continue
+		}
+		if args.Contains("reference") {
+			found := false
+			for _, ref := range img.refs {
+				if args.ExactMatch("reference", ref) {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		matches = append(matches, img)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].created.After(matches[j].created) })
+
+	summaries := make([]image.Summary, 0, len(matches))
+	for _, img := range matches {
+		refs := append([]string(nil), img.refs...)
+		sort.Strings(refs)
+		summaries = append(summaries, image.Summary{
+			ID:         img.id,
+			RepoTags:   refs,
+			Created:    img.created.Unix(),
+			Labels:     copyLabels(img.labels),
+			Containers: int64(len(e.imageUsers(img.id))),
+		})
+	}
+	return summaries, nil
+}
+
+// ImageRemove removes an image by reference or ID with all its references.
+// Like the daemon it refuses images that containers were created from,
+// unless forced, and those of running containers even then.
+func (e *Engine) ImageRemove(ctx context.Context, imageID string, options image.RemoveOptions) ([]image.DeleteResponse, error) {
+	if err := e.call("ImageRemove"); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	img, err := e.image(imageID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range e.imageUsers(img.id) {
+		if !options.Force || c.running() {
+			return nil, errdefs.Conflict(fmt.Errorf("conflict: unable to delete %s - image is being used by container %s", imageID, c.id[:12]))
+		}
+	}
+
+	var resp []image.DeleteResponse
+	for _, ref := range img.refs {
+		resp = append(resp, image.DeleteResponse{Untagged: ref})
+	}
+	resp = append(resp, image.DeleteResponse{Deleted: img.id})
+	delete(e.images, img.id)
+	e.publish(events.ImageEventType, events.ActionDelete, img.id, map[string]string{"name": imageID})
+	return resp, nil
+}
+
+// imageUsers returns the containers created from the image with the given
+// ID. The caller holds e.mu.
+func (e *Engine) imageUsers(id string) []*fakeContainer {
+	var users []*fakeContainer
+	for _, c := range e.containers {
+		if c.imageID == id {
+			users = append(users, c)
+		}
+	}
+	return users
+}
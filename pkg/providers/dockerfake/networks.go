@@ -0,0 +1,342 @@
+package dockerfake
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+// networkFilters are the filters NetworkList accepts
+var networkFilters = map[string]bool{
+	"name":   true,
+	"id":     true,
+	"label":  true,
+	"driver": true,
+	"scope":  true,
+	"type":   true,
+}
+
+// builtinNetworks are the networks the daemon creates and never removes
+var builtinNetworks = map[string]bool{"bridge": true, "host": true, "none": true}
+
+// network finds a network by ID, name or ID prefix. The caller holds e.mu.
+func (e *Engine) network(ref string) (*network.Inspect, error) {
+	if net, ok := e.networks[ref]; ok {
+		return net, nil
+	}
+	for _, net := range e.networks {
+		if net.Name == ref {
+			return net, nil
+		}
+	}
+	for _, net := range e.networks {
+		if matchesID(net.ID, ref) {
+			return net, nil
+		}
+	}
+	return nil, errdefs.NotFound(fmt.Errorf("network %s not found", ref))
+}
+
+// allocateAddress returns the requested address on net, or the lowest free
+// one after the gateway, with the gateway and prefix length of the subnet.
+// The caller holds e.mu.
+func (e *Engine) allocateAddress(net *network.Inspect, requested string) (string, string, int, error) {
+	if len(net.IPAM.Config) == 0 {
+		return "", "", 0, nil
+	}
+	config := net.IPAM.Config[0]
+	subnet, err := netip.ParsePrefix(config.Subnet)
+	if err != nil {
+		return "", "", 0, errdefs.System(fmt.Errorf("network %s has an invalid subnet %q: %w", net.Name, config.Subnet, err))
+	}
+	subnet = subnet.Masked()
+	gateway := config.Gateway
+	if gateway == "" {
+		gateway = subnet.Addr().Next().String()
+	}
+
+	used := map[string]bool{gateway: true}
+	for _, c := range e.containers {
+		for _, endpoint := range c.networks {
+			if endpoint.NetworkID == net.ID {
+				used[endpoint.IPAddress] = true
+			}
+		}
+	}
+
+	if requested != "" {
+		addr, err := netip.ParseAddr(requested)
+		if err != nil || !subnet.Contains(addr) {
+			return "", "", 0, errdefs.InvalidParameter(fmt.Errorf("no configured subnet or ip-range contain the IP address %s", requested))
+		}
+		if used[addr.String()] {
+			return "", "", 0, errdefs.Conflict(fmt.Errorf("Address already in use"))
+		}
+		return addr.String(), gateway, subnet.Bits(), nil
+	}
+	for addr := subnet.Addr().Next(); subnet.Contains(addr.Next()); addr = addr.Next() {
+		if !used[addr.String()] {
+			return addr.String(), gateway, subnet.Bits(), nil
+		}
+	}
+	return "", "", 0, errdefs.Unavailable(fmt.Errorf("no available IPv4 addresses on network %s", net.Name))
+}
+
+// macAddress derives an endpoint's MAC address from its IPv4 address, as
+// the bridge driver does
+func macAddress(address string) string {
+	addr, err := netip.ParseAddr(address)
+	if err != nil || !addr.Is4() {
+		return ""
+	}
+	ip := addr.As4()
+	return fmt.Sprintf("02:42:%02x:%02x:%02x:%02x", ip[0], ip[1], ip[2], ip[3])
+}
+
+// NetworkCreate creates a network. Without an IPAM config it is given the
+// next free 172.x.0.0/16 subnet, as the daemon's default pools do.
+func (e *Engine) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	if err := e.call("NetworkCreate"); err != nil {
+		return network.CreateResponse{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, existing := range e.networks {
+		if existing.Name == name {
+			return network.CreateResponse{}, errdefs.Conflict(fmt.Errorf("network with name %s already exists", name))
+		}
+	}
+
+	net := &network.Inspect{
+		Name:       name,
+		ID:         e.newID(),
+		Created:    e.Now(),
+		Scope:      options.Scope,
+		Driver:     options.Driver,
+		EnableIPv4: true,
+		Internal:   options.Internal,
+		Attachable: options.Attachable,
+		Ingress:    options.Ingress,
+		ConfigOnly: options.ConfigOnly,
+		IPAM:       network.IPAM{Driver: "default"},
+		Containers: map[string]network.EndpointResource{},
+		Options:    copyLabels(options.Options),
+		Labels:     copyLabels(options.Labels),
+	}
+	if net.Scope == "" {
+		net.Scope = "local"
+	}
+	if net.Driver == "" {
+		net.Driver = "bridge"
+	}
+	if options.EnableIPv6 != nil {
+		net.EnableIPv6 = *options.EnableIPv6
+	}
+
+	if options.IPAM != nil && len(options.IPAM.Config) > 0 {
+		if options.IPAM.Driver != "" {
+			net.IPAM.Driver = options.IPAM.Driver
+		}
+		for _, config := range options.IPAM.Config {
+			subnet, err := netip.ParsePrefix(config.Subnet)
+			if err != nil {
+				return network.CreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("invalid subnet %s: %w", config.Subnet, err))
+			}
+			if e.subnetInUse(subnet) {
+				return network.CreateResponse{}, errdefs.Forbidden(fmt.Errorf("Pool overlaps with other one on this address space"))
+			}
+			if config.Gateway == "" {
+				config.Gateway = subnet.Masked().Addr().Next().String()
+			}
+			net.IPAM.Config = append(net.IPAM.Config, config)
+		}
+	} else {
+		subnet, err := e.freeSubnet()
+		if err != nil {
+			return network.CreateResponse{}, err
+		}
+		net.IPAM.Config = []network.IPAMConfig{{Subnet: subnet.String(), Gateway: subnet.Addr().Next().String()}}
+	}
+
+	e.networks[net.ID] = net
+	e.publish(events.NetworkEventType, events.ActionCreate, net.ID, map[string]string{"name": net.Name, "type": net.Driver})
+	return network.CreateResponse{ID: net.ID}, nil
+}
+
+// freeSubnet returns the first 172.x.0.0/16 subnet no network uses. The
+// caller holds e.mu.
+func (e *Engine) freeSubnet() (netip.Prefix, error) {
+	for second := 18; second < 32; second++ {
+		subnet := netip.PrefixFrom(netip.AddrFrom4([4]byte{172, byte(second), 0, 0}), 16)
+		if !e.subnetInUse(subnet) {
+			return subnet, nil
+		}
+	}
+	return netip.Prefix{}, errdefs.Unavailable(fmt.Errorf("all predefined address pools have been fully subnetted"))
+}
+
+// subnetInUse reports whether subnet overlaps a network's. The caller holds e.mu.
+func (e *Engine) subnetInUse(subnet netip.Prefix) bool {
+	for _, net := range e.networks {
+		for _, config := range net.IPAM.Config {
+			if existing, err := netip.ParsePrefix(config.Subnet); err == nil && existing.Overlaps(subnet) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NetworkInspect describes a network, with the endpoints of its running
+// containers
+func (e *Engine) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	if err := e.call("NetworkInspect"); err != nil {
+		return network.Inspect{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	net, err := e.network(networkID)
+	if err != nil {
+		return network.Inspect{}, err
+	}
+	return copyNetwork(net), nil
+}
+
+// NetworkList lists the networks that match the options' filters by name
+func (e *Engine) NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error) {
+	if err := e.call("NetworkList"); err != nil {
+		return nil, err
+	}
+	if err := options.Filters.Validate(networkFilters); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	args := options.Filters
+	networks := []network.Summary{}
+	for _, net := range e.networks {
+		kind := "custom"
+		if builtinNetworks[net.Name] {
+			kind = "builtin"
+		}
+		if args.Contains("name") && !args.Match("name", net.Name) {
+			continue
+		}
+		if args.Contains("id") && !args.Match("id", net.ID) {
+			continue
+		}
+		if !args.MatchKVList("label", net.Labels) || !args.ExactMatch("driver", net.Driver) || !args.ExactMatch("scope", net.Scope) || !args.ExactMatch("type", kind) {
+			continue
+		}
+		networks = append(networks, copyNetwork(net))
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	return networks, nil
+}
+
+// NetworkRemove removes a network that no running container is connected to
+func (e *Engine) NetworkRemove(ctx context.Context, networkID string) error {
+	if err := e.call("NetworkRemove"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	net, err := e.network(networkID)
+	if err != nil {
+		return err
+	}
+	if builtinNetworks[net.Name] {
+		return errdefs.Forbidden(fmt.Errorf("%s is a pre-defined network and cannot be removed", net.Name))
+	}
+	if len(net.Containers) > 0 {
+		var names []string
+		for _, endpoint := range net.Containers {
+			names = append(names, endpoint.Name)
+		}
+		sort.Strings(names)
+		return errdefs.Forbidden(fmt.Errorf("error while removing network: network %s id %s has active endpoints (%s)", net.Name, net.ID, strings.Join(names, ", ")))
+	}
+	delete(e.networks, net.ID)
+	e.publish(events.NetworkEventType, events.ActionDestroy, net.ID, map[string]string{"name": net.Name, "type": net.Driver})
+	return nil
+}
+
+// NetworkConnect gives a container an endpoint on a network, with the
+// address of config if it asks for one
+func (e *Engine) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	if err := e.call("NetworkConnect"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	net, err := e.network(networkID)
+	if err != nil {
+		return err
+	}
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.networks[net.Name]; ok {
+		return errdefs.Forbidden(fmt.Errorf("endpoint with name %s already exists in network %s", c.name, net.Name))
+	}
+	if err := e.attachNetworks(c, &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{net.Name: config}}); err != nil {
+		return err
+	}
+	if c.running() {
+		endpoint := c.networks[net.Name]
+		net.Containers[c.id] = network.EndpointResource{
+			Name:        c.name,
+			EndpointID:  endpoint.EndpointID,
+			MacAddress:  endpoint.MacAddress,
+			IPv4Address: fmt.Sprintf("%s/%d", endpoint.IPAddress, endpoint.IPPrefixLen),
+		}
+	}
+	e.publish(events.NetworkEventType, events.ActionConnect, net.ID, map[string]string{"name": net.Name, "type": net.Driver, "container": c.id})
+	return nil
+}
+
+// NetworkDisconnect takes a container off a network, releasing its address
+func (e *Engine) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
+	if err := e.call("NetworkDisconnect"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	net, err := e.network(networkID)
+	if err != nil {
+		return err
+	}
+	c, err := e.container(containerID)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.networks[net.Name]; !ok {
+		return errdefs.Forbidden(fmt.Errorf("container %s is not connected to network %s", c.id, net.Name))
+	}
+	delete(c.networks, net.Name)
+	delete(net.Containers, c.id)
+	e.publish(events.NetworkEventType, events.ActionDisconnect, net.ID, map[string]string{"name": net.Name, "type": net.Driver, "container": c.id})
+	return nil
+}
+
+// copyNetwork returns a copy of net that shares nothing with it
+func copyNetwork(net *network.Inspect) network.Inspect {
+	out := *net
+	out.IPAM.Config = append([]network.IPAMConfig(nil), net.IPAM.Config...)
+	out.Options = copyLabels(net.Options)
+	out.Labels = copyLabels(net.Labels)
+	out.Containers = make(map[string]network.EndpointResource, len(net.Containers))
+	for id, endpoint := range net.Containers {
+		out.Containers[id] = endpoint
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package dockerfake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// volumeFilters are the filters VolumeList accepts
+var volumeFilters = map[string]bool{
+	"name":     true,
+	"label":    true,
+	"driver":   true,
+	"dangling": true,
+}
+
+// ensureVolume returns the volume name, creating it if it does not exist.
+// The caller holds e.mu.
+func (e *Engine) ensureVolume(name, driver string, labels map[string]string) *volume.Volume {
+	if vol, ok := e.volumes[name]; ok {
+		return vol
+	}
+	if driver == "" {
+		driver = "local"
+	}
+	vol := &volume.Volume{
+		Name:       name,
+		Driver:     driver,
+		Mountpoint: "/var/lib/docker/volumes/" + name + "/_data",
+		CreatedAt:  timestamp(e.Now()),
+		Labels:     copyLabels(labels),
+		Options:    map[string]string{},
+		Scope:      "local",
+	}
+	e.volumes[name] = vol
+	e.publish(events.VolumeEventType, events.ActionCreate, name, map[string]string{"driver": driver})
+	return vol
+}
+
+// volumeUsers returns the names of the containers that mount the volume.
+// The caller holds e.mu.
+func (e *Engine) volumeUsers(name string) []string {
+	var users []string
+	for _, c := range e.containers {
+		for _, m := range c.mounts {
+			if m.Name == name {
+				users = append(users, c.name)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// VolumeCreate creates a volume; creating one that exists returns it as it is
+func (e *Engine) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	if err := e.call("VolumeCreate"); err != nil {
+		return volume.Volume{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	name := options.Name
+	if name == "" {
+		name = e.newID()
+	}
+	vol := e.ensureVolume(name, options.Driver, options.Labels)
+	for k, v := range options.DriverOpts {
+		if _, ok := vol.Options[k]; !ok {
+			vol.Options[k] = v
+		}
+	}
+	return copyVolume(vol), nil
+}
+
+// VolumeList lists the volumes that match the options' filters by name
+func (e *Engine) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	if err := e.call("VolumeList"); err != nil {
+		return volume.ListResponse{}, err
+	}
+	if err := options.Filters.Validate(volumeFilters); err != nil {
+		return volume.ListResponse{}, errdefs.InvalidParameter(err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	args := options.Filters
+	dangling, err := args.GetBoolOrDefault("dangling", false)
+	if err != nil {
+		return volume.ListResponse{}, errdefs.InvalidParameter(err)
+	}
+	resp := volume.ListResponse{Volumes: []*volume.Volume{}}
+	for _, vol := range e.volumes {
+		if args.Contains("name") && !args.Match("name", vol.Name) {
+			continue
+		}
+		if !args.MatchKVList("label", vol.Labels) || !args.ExactMatch("driver", vol.Driver) {
+			continue
+		}
+		if args.Contains("dangling") && dangling != (len(e.volumeUsers(vol.Name)) == 0) {
+			continue
+		}
+		out := copyVolume(vol)
+		resp.Volumes = append(resp.Volumes, &out)
+	}
+	sort.Slice(resp.Volumes, func(i, j int) bool { return resp.Volumes[i].Name < resp.Volumes[j].Name })
+	return resp, nil
+}
+
+// VolumeRemove removes a volume no container mounts. Forcing it only
+// ignores volumes that do not exist, as the daemon does.
+func (e *Engine) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	if err := e.call("VolumeRemove"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.volumes[volumeID]; !ok {
+		if force {
+			return nil
+		}
+		return errdefs.NotFound(fmt.Errorf("get %s: no such volume", volumeID))
+	}
+	if users := e.volumeUsers(volumeID); len(users) > 0 {
+		return errdefs.Conflict(fmt.Errorf("remove %s: volume is in use - %v", volumeID, users))
+	}
+	delete(e.volumes, volumeID)
+	e.publish(events.VolumeEventType, events.ActionDestroy, volumeID, map[string]string{"driver": "local"})
+	return nil
+}
+
+// copyVolume returns a copy of vol that shares nothing with it
+func copyVolume(vol *volume.Volume) volume.Volume {
+	out := *vol
+	out.Labels = copyLabels(vol.Labels)
+	out.Options = copyLabels(vol.Options)
+	return out
+}
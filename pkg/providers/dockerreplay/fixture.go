@@ -0,0 +1,112 @@
+// Package dockerreplay records the Docker Engine API traffic of a run against
+// a real daemon to a fixture file and serves it back from an httptest server,
+// so that tests can pin code using the Docker client to real engine responses
+// without a daemon.
+package dockerreplay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// Fixture is the recorded traffic of a run, in the order the requests were made
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the daemon's response to it
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded API request. The path keeps the API version prefix.
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   Body   `json:"body,omitempty"`
+}
+
+// Response is a recorded API response. Hijacked responses, which exec
+// streams are, have status 101 and hold the whole stream as their body.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Body is a request or response body. It is kept as text when it is UTF-8,
+// which JSON bodies and pull progress are, and as base64 otherwise, which
+// archives and exec streams are.
+type Body []byte
+
+// bodyJSON is how a Body appears in a fixture file
+type bodyJSON struct {
+	Text   string `json:"text,omitempty"`
+	Base64 []byte `json:"base64,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(bodyJSON{Text: string(b)})
+	}
+	return json.Marshal(bodyJSON{Base64: b})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var body bodyJSON
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	if body.Base64 != nil {
+		*b = body.Base64
+	} else {
+		*b = []byte(body.Text)
+	}
+	return nil
+}
+
+// skippedHeaders are response headers that describe one particular
+// connection and are not recorded
+var skippedHeaders = []string{"Date", "Content-Length", "Transfer-Encoding", "Connection"}
+
+// recordedHeader returns the part of a response header worth replaying
+func recordedHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, key := range skippedHeaders {
+		header.Del(key)
+	}
+	return header
+}
+
+// Load reads a fixture file
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Save writes the fixture to path, creating its directory
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
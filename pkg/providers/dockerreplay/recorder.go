@@ -0,0 +1,244 @@
+package dockerreplay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/docker/docker/client"
+)
+
+// Recorder records the requests made through its transports and the
+// responses to them
+type Recorder struct {
+	mu           sync.Mutex
+	interactions []*Interaction
+	errs         []error
+}
+
+// NewRecorder returns a recorder that has recorded nothing yet
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Fixture returns what has been recorded so far, in the order the requests
+// were made
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fixture := &Fixture{Interactions: make([]Interaction, 0, len(r.interactions))}
+	for _, interaction := range r.interactions {
+		fixture.Interactions = append(fixture.Interactions, *interaction)
+	}
+	return fixture
+}
+
+// Err returns the errors met while recording, which leave the fixture
+// incomplete
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Join(r.errs...)
+}
+
+// start reserves the place of an interaction when its request is made, so
+// that concurrent requests are recorded in the order they were made
+func (r *Recorder) start(req Request) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interaction := &Interaction{Request: req}
+	r.interactions = append(r.interactions, interaction)
+	return interaction
+}
+
+// finish stores a response body once it has been read
+func (r *Recorder) finish(interaction *Interaction, body []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interaction.Response.Body = body
+}
+
+// fail notes an error that keeps an interaction from being recorded
+func (r *Recorder) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+// Transport returns a transport that sends requests through next and records
+// them with their responses. Response bodies are recorded as they are read,
+// so streams end up with what the client read of them.
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	return &recordingTransport{recorder: r, next: next}
+}
+
+type recordingTransport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	interaction := t.recorder.start(Request{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery, Body: body})
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.recorder.fail(fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err))
+		return nil, err
+	}
+	t.recorder.mu.Lock()
+	interaction.Response.Status = resp.StatusCode
+	interaction.Response.Header = recordedHeader(resp.Header)
+	t.recorder.mu.Unlock()
+	resp.Body = &recordingBody{ReadCloser: resp.Body, done: func(body []byte) { t.recorder.finish(interaction, body) }}
+	return resp, nil
+}
+
+// recordingBody keeps what is read of a response body and hands it over
+// when the body is closed
+type recordingBody struct {
+	io.ReadCloser
+	read bytes.Buffer
+	once sync.Once
+	done func([]byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read.Write(p[:n])
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() { b.done(b.read.Bytes()) })
+	return b.ReadCloser.Close()
+}
+
+// Client returns a Docker client for the daemon that opts, after the
+// environment, select, recording all its traffic. The daemon must be reached
+// without TLS.
+func (r *Recorder) Client(opts ...client.Opt) (*client.Client, error) {
+	opts = append([]client.Opt{client.FromEnv}, opts...)
+	probe, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	hostURL, err := client.ParseHostURL(probe.DaemonHost())
+	if err != nil {
+		return nil, err
+	}
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, hostURL.Scheme, hostURL.Host)
+	}
+
+	// The client makes plain requests through the transport, where they are
+	// handed to the recording transport, but dials hijacked requests such as
+	// exec starts itself with the transport's dialer, which records those
+	transport := &http.Transport{DialContext: r.hijackDialer(dial)}
+	transport.RegisterProtocol("http", r.Transport(&http.Transport{DialContext: dial}))
+	return client.NewClientWithOpts(append(opts, client.WithHTTPClient(&http.Client{Transport: transport}))...)
+}
+
+// hijackDialer returns a dialer whose connections each record the one
+// hijacked request made over them
+func (r *Recorder) hijackDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &hijackedConn{Conn: conn, recorder: r}, nil
+	}
+}
+
+// hijackedConn records the request written to it and the response and
+// stream read from it, which it parses when it is closed
+type hijackedConn struct {
+	net.Conn
+	recorder *Recorder
+	mu       sync.Mutex
+	written  bytes.Buffer
+	read     bytes.Buffer
+	once     sync.Once
+}
+
+func (c *hijackedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.read.Write(p[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *hijackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.mu.Lock()
+	c.written.Write(p[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *hijackedConn) Close() error {
+	c.once.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err := c.record(); err != nil {
+			c.recorder.fail(fmt.Errorf("failed to record hijacked request: %w", err))
+		}
+	})
+	return c.Conn.Close()
+}
+
+// CloseWrite lets the client close its side of the stream when the
+// connection supports it
+func (c *hijackedConn) CloseWrite() error {
+	if conn, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return conn.CloseWrite()
+	}
+	return nil
+}
+
+// record parses the exchange on the connection into an interaction. The
+// caller holds c.mu.
+func (c *hijackedConn) record() error {
+	req, err := http.ReadRequest(bufio.NewReader(&c.written))
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	interaction := c.recorder.start(Request{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery, Body: body})
+
+	reader := bufio.NewReader(&c.read)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return err
+	}
+	stream, err := io.ReadAll(io.MultiReader(resp.Body, reader))
+	if err != nil {
+		return err
+	}
+	c.recorder.mu.Lock()
+	interaction.Response = Response{Status: resp.StatusCode, Header: recordedHeader(resp.Header), Body: stream}
+	c.recorder.mu.Unlock()
+	return nil
+}
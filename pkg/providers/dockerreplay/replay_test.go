@@ -0,0 +1,188 @@
+package dockerreplay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// newDaemon starts a server answering the few API calls the tests make the
+// way the daemon does
+func newDaemon(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Api-Version", "1.45")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	lists := 0
+	mux.HandleFunc("GET /v1.45/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		lists++
+		reply(w, http.StatusOK, []container.Summary{{ID: "c1", Names: []string{"/node"}, State: fmt.Sprintf("poll-%d", lists)}})
+	})
+	mux.HandleFunc("POST /v1.45/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusCreated, container.CreateResponse{ID: "c1"})
+	})
+	mux.HandleFunc("GET /v1.45/containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusNotFound, map[string]string{"message": "No such container: " + r.PathValue("id")})
+	})
+	mux.HandleFunc("POST /v1.45/containers/c1/exec", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusCreated, container.ExecCreateResponse{ID: "e1"})
+	})
+	mux.HandleFunc("POST /v1.45/exec/e1/start", func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Failed to hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.multiplexed-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		stdcopy.NewStdWriter(buf, stdcopy.Stdout).Write([]byte("v1.31.0\n"))
+		stdcopy.NewStdWriter(buf, stdcopy.Stderr).Write([]byte{0xff, 0xfe})
+		buf.Flush()
+	})
+	mux.HandleFunc("GET /v1.45/exec/e1/json", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusOK, container.ExecInspect{ExecID: "e1", ContainerID: "c1", ExitCode: 3})
+	})
+	return httptest.NewServer(mux)
+}
+
+// session is what a run of exercise saw
+type session struct {
+	states   []string
+	created  string
+	stdout   string
+	stderr   []byte
+	exitCode int
+	notFound bool
+}
+
+// exercise makes the calls the tests record and replay
+func exercise(t *testing.T, cli *client.Client) session {
+	t.Helper()
+	ctx := context.Background()
+	var s session
+	for i := 0; i < 2; i++ {
+		containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+		if err != nil {
+			t.Fatalf("Failed to list containers: %v", err)
+		}
+		s.states = append(s.states, containers[0].State)
+	}
+	created, err := cli.ContainerCreate(ctx, &container.Config{Image: "kindest/node:v1.31.0"}, nil, nil, nil, "node")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	s.created = created.ID
+
+	exec, err := cli.ContainerExecCreate(ctx, created.ID, container.ExecOptions{Cmd: []string{"kubelet", "--version"}, AttachStdout: true, AttachStderr: true})
+	if err != nil {
+		t.Fatalf("Failed to create exec: %v", err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		t.Fatalf("Failed to attach to exec: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		t.Fatalf("Failed to read exec output: %v", err)
+	}
+	resp.Close()
+	s.stdout, s.stderr = stdout.String(), stderr.Bytes()
+	info, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		t.Fatalf("Failed to inspect exec: %v", err)
+	}
+	s.exitCode = info.ExitCode
+
+	_, err = cli.ContainerInspect(ctx, "missing")
+	s.notFound = errdefs.IsNotFound(err)
+	return s
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	daemon := newDaemon(t)
+	recorder := NewRecorder()
+	cli, err := recorder.Client(client.WithHost("tcp://"+daemon.Listener.Addr().String()), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("Failed to create recording client: %v", err)
+	}
+	recorded := exercise(t, cli)
+	cli.Close()
+	daemon.Close()
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+	if recorded.stdout != "v1.31.0\n" || recorded.exitCode != 3 || !recorded.notFound {
+		t.Fatalf("Unexpected session against the daemon: %+v", recorded)
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := recorder.Fixture().Save(path); err != nil {
+		t.Fatalf("Failed to save fixture: %v", err)
+	}
+	fixture, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	if got := len(fixture.Interactions); got != 7 {
+		t.Errorf("Expected 7 interactions, got %d", got)
+	}
+
+	server := NewServer(fixture)
+	defer server.Close()
+	cli, err = server.Client(client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("Failed to create replay client: %v", err)
+	}
+	defer cli.Close()
+	replayed := exercise(t, cli)
+	if err := server.Err(); err != nil {
+		t.Errorf("Expected every request to be answered, got %v", err)
+	}
+	if unused := server.Unused(); len(unused) != 0 {
+		t.Errorf("Expected every interaction to be used, got %d left", len(unused))
+	}
+	if fmt.Sprint(replayed) != fmt.Sprint(recorded) {
+		t.Errorf("Expected the replay to see %+v, got %+v", recorded, replayed)
+	}
+	if strings.Join(replayed.states, ",") != "poll-1,poll-2" {
+		t.Errorf("Expected repeated requests to get their responses in turn, got %v", replayed.states)
+	}
+}
+
+func TestReplayUnrecordedRequest(t *testing.T) {
+	server := NewServer(&Fixture{Interactions: []Interaction{{
+		Request:  Request{Method: http.MethodPost, Path: "/v1.45/containers/create", Query: "name=node"},
+		Response: Response{Status: http.StatusCreated, Header: http.Header{"Content-Type": {"application/json"}}, Body: Body(`{"Id":"c1"}`)},
+	}}})
+	defer server.Close()
+	cli, err := server.Client(client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("Failed to create replay client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	if _, err := cli.ContainerCreate(ctx, &container.Config{Image: "kindest/node:v1.31.0"}, nil, nil, nil, "other"); err == nil {
+		t.Error("Expected a request with a different query not to be answered")
+	}
+	if _, err := cli.ContainerCreate(ctx, &container.Config{Image: "kindest/node:v1.31.0"}, nil, nil, nil, "node"); err != nil {
+		t.Errorf("Expected the recorded request to be answered, got %v", err)
+	}
+	if err := server.Err(); err == nil || !strings.Contains(err.Error(), "name=other") {
+		t.Errorf("Expected the unrecorded request to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package dockerreplay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+
+	"github.com/docker/docker/client"
+)
+
+// Server is an httptest server answering requests with the responses of a
+// fixture. A request gets the response of the first unused interaction with
+// the same method, path and query, and with an equal body when both are JSON;
+// other bodies, such as archives, which hold modification times, are not
+// compared. Requests made the same way several times, such as polls, get the
+// recorded responses in turn.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	fixture   *Fixture
+	used      []bool
+	unmatched []string
+}
+
+// NewServer starts a server replaying fixture
+func NewServer(fixture *Fixture) *Server {
+	s := &Server{fixture: fixture, used: make([]bool, len(fixture.Interactions))}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a Docker client of the server
+func (s *Server) Client(opts ...client.Opt) (*client.Client, error) {
+	return client.NewClientWithOpts(append([]client.Opt{client.WithHost("tcp://" + s.Listener.Addr().String())}, opts...)...)
+}
+
+// Err returns an error naming the requests the fixture had no response for
+func (s *Server) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, request := range s.unmatched {
+		errs = append(errs, fmt.Errorf("no recorded response for %s", request))
+	}
+	return errors.Join(errs...)
+}
+
+// Unused returns the interactions no request has been answered with, which
+// means the code under test made fewer requests than were recorded
+func (s *Server) Unused() []Interaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var unused []Interaction
+	for i, used := range s.used {
+		if !used {
+			unused = append(unused, s.fixture.Interactions[i])
+		}
+	}
+	return unused
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	interaction, ok := s.match(req, body)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprintf("dockerreplay: no recorded response for %s %s", req.Method, req.URL.RequestURI())})
+		return
+	}
+
+	resp := interaction.Response
+	if resp.Status == http.StatusSwitchingProtocols {
+		s.hijack(w, resp)
+		return
+	}
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// match claims the interaction answering req. Requests without one are noted.
+func (s *Server) match(req *http.Request, body []byte) (Interaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, interaction := range s.fixture.Interactions {
+		recorded := interaction.Request
+		if s.used[i] || recorded.Method != req.Method || recorded.Path != req.URL.Path || recorded.Query != req.URL.RawQuery {
+			continue
+		}
+		if !sameJSON(recorded.Body, body) {
+			continue
+		}
+		s.used[i] = true
+		return interaction, true
+	}
+	s.unmatched = append(s.unmatched, req.Method+" "+req.URL.RequestURI())
+	return Interaction{}, false
+}
+
+// sameJSON reports whether two bodies hold the same JSON value. Bodies that
+// are not both JSON count as the same.
+func sameJSON(a, b []byte) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return true
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// hijack answers an upgrade request the way the daemon does: it switches
+// protocols and writes the recorded stream to the raw connection
+func (s *Server) hijack(w http.ResponseWriter, resp Response) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "dockerreplay: connection cannot be hijacked", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(buf, "HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\n")
+	resp.Header.Write(buf)
+	fmt.Fprintf(buf, "\r\n")
+	buf.Write(resp.Body)
+	buf.Flush()
+}
@@ -0,0 +1,96 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// drainNodes drains the given worker nodes through the workload cluster's API
+// and deletes their Node objects, so they can be removed without killing
+// their pods abruptly. Clusters that were not bootstrapped with kubeadm have
+// no API to drain through and are left alone.
+func (p *DockerProvider) drainNodes(ctx context.Context, cluster *v1alpha1.Cluster, nodes []container.Summary) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	controlPlane, err := p.etcdNode(ctx, cluster)
+	if err != nil {
+		return fmt.Errorf("cannot drain nodes: %w", err)
+	}
+	if !p.bootstrapped(ctx, controlPlane.ID) {
+		clusterLogger(ctx, cluster).V(1).Info("Cluster has no API server, not draining nodes")
+		return nil
+	}
+	return p.forEachNode(ctx, "drain", nodes, func(ctx context.Context, node container.Summary) error {
+		return p.drainNode(ctx, cluster, controlPlane, node)
+	})
+}
+
+// drainNode cordons a running node and evicts its pods within their
+// PodDisruptionBudgets, then deletes its Node object. Pods that cannot be
+// evicted before the cluster's drain timeout are left to go with the node.
+// The Node object is named after the node's hostname, which adopted nodes
+// keep from kind.
+func (p *DockerProvider) drainNode(ctx context.Context, cluster *v1alpha1.Cluster, controlPlane, node container.Summary) error {
+	name := containerName(node)
+	info, err := p.client.ContainerInspect(ctx, node.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", node.ID, err)
+	}
+	hostname := nodeHostname(info, name)
+	log := clusterLogger(ctx, cluster).WithValues("node", name, "hostname", hostname, "step", "drain")
+	kubectl := []string{"kubectl", "--kubeconfig=" + adminKubeconfig}
+
+	out, err := p.execInContainer(ctx, controlPlane.ID, append(kubectl, "get", "node", hostname, "--ignore-not-found", "--output=name")...)
+	if err != nil {
+		return fmt.Errorf("failed to look up node %s: %w", hostname, err)
+	}
+	if strings.TrimSpace(out) == "" {
+		log.V(1).Info("Node is not registered, nothing to drain")
+		return nil
+	}
+
+	// The pods of a stopped node cannot shut down; draining it would only
+	// wait for the timeout
+	if node.State == "running" {
+		timeout := drainTimeout(cluster)
+		log.Info("Draining node", "timeout", timeout)
+		_, err = p.execInContainer(ctx, controlPlane.ID, append(kubectl, "cordon", hostname)...)
+		if err == nil {
+			_, err = p.execInContainer(ctx, controlPlane.ID, append(kubectl, "drain", hostname,
+				"--ignore-daemonsets",
+				"--delete-emptydir-data",
+				"--force",
+				fmt.Sprintf("--timeout=%s", timeout),
+			)...)
+		}
+		if err != nil {
+			log.Error(err, "Failed to drain node, removing it anyway")
+			recordWarning(ctx, cluster, ReasonNodeDrainFailed, "Failed to drain node %s within %s, removing it anyway: %v", name, timeout, err)
+		} else {
+			recordNormal(ctx, cluster, ReasonNodeDrained, "Drained node %s", name)
+		}
+	} else {
+		log.Info("Node is not running, deleting it without draining", "state", node.State)
+	}
+
+	if _, err := p.execInContainer(ctx, controlPlane.ID, append(kubectl, "delete", "node", hostname, "--ignore-not-found")...); err != nil {
+		return fmt.Errorf("failed to delete node %s: %w", hostname, err)
+	}
+	log.V(1).Info("Deleted node object")
+	return nil
+}
+
+// drainTimeout returns how long the cluster's workers are drained for
+func drainTimeout(cluster *v1alpha1.Cluster) time.Duration {
+	if timeout := cluster.Spec.Workers.DrainTimeout; timeout != nil && timeout.Duration > 0 {
+		return timeout.Duration
+	}
+	return v1alpha1.DefaultDrainTimeout
+}
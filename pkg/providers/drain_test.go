@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers/dockerfake"
+)
+
+func TestDrainTimeout(t *testing.T) {
+	cluster := &v1alpha1.Cluster{}
+	if timeout := drainTimeout(cluster); timeout != v1alpha1.DefaultDrainTimeout {
+		t.Errorf("Expected the default drain timeout, got %s", timeout)
+	}
+
+	cluster.Spec.Workers.DrainTimeout = &metav1.Duration{Duration: 30 * time.Second}
+	if timeout := drainTimeout(cluster); timeout != 30*time.Second {
+		t.Errorf("Expected a drain timeout of 30s, got %s", timeout)
+	}
+}
+
+func TestDrainNodeUsesHostname(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	engine.AddImage("kindest/node:v1.31.0")
+	nodes := map[string]container.Summary{}
+	for name, hostname := range map[string]string{"dev-control-plane": "dev-control-plane", "default-dev-worker": "dev-worker"} {
+		resp, err := engine.ContainerCreate(ctx, &container.Config{Image: "kindest/node:v1.31.0", Hostname: hostname}, &container.HostConfig{}, nil, nil, name)
+		if err != nil {
+			t.Fatalf("Failed to create container %s: %v", name, err)
+		}
+		if err := engine.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+			t.Fatalf("Failed to start container %s: %v", name, err)
+		}
+		nodes[name] = container.Summary{ID: resp.ID, Names: []string{"/" + name}, State: "running"}
+	}
+
+	var mu sync.Mutex
+	var execs []string
+	engine.HandleExec(func(name string, cmd []string) (dockerfake.ExecResult, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		execs = append(execs, name+": "+strings.Join(cmd, " "))
+		if len(cmd) > 2 && cmd[2] == "get" {
+			return dockerfake.ExecResult{Stdout: "node/" + cmd[4] + "\n"}, true
+		}
+		return dockerfake.ExecResult{}, true
+	})
+
+	cluster := newFakeCluster("dev", 1)
+	if err := provider.drainNode(ctx, cluster, nodes["dev-control-plane"], nodes["default-dev-worker"]); err != nil {
+		t.Fatalf("Failed to drain node: %v", err)
+	}
+
+	// The commands run on the control plane node and name the Node object
+	// after the worker's hostname, not its container
+	kubectl := "dev-control-plane: kubectl --kubeconfig=" + adminKubeconfig
+	want := []string{
+		kubectl + " get node dev-worker --ignore-not-found --output=name",
+		kubectl + " cordon dev-worker",
+		kubectl + " drain dev-worker --ignore-daemonsets --delete-emptydir-data --force --timeout=" + v1alpha1.DefaultDrainTimeout.String(),
+		kubectl + " delete node dev-worker --ignore-not-found",
+	}
+	if strings.Join(execs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected commands\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(execs, "\n"))
+	}
+}
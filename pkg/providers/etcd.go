@@ -0,0 +1,151 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+// etcdDataDir is etcd's data directory on kubeadm control plane nodes. It is
+// mounted into the etcd container, so snapshots written there by etcdctl can
+// be copied out of the node container.
+const etcdDataDir = "/var/lib/etcd"
+
+// etcdctlArgs are the etcdctl flags for talking to the local etcd member of a
+// kubeadm control plane node
+var etcdctlArgs = []string{
+	"etcdctl",
+	"--endpoints=https://127.0.0.1:2379",
+	"--cacert=/etc/kubernetes/pki/etcd/ca.crt",
+	"--cert=/etc/kubernetes/pki/etcd/server.crt",
+	"--key=/etc/kubernetes/pki/etcd/server.key",
+}
+
+// SnapshotEtcd runs etcdctl snapshot save in the etcd container of the first
+// running control plane node and copies the snapshot to the provider's
+// snapshot directory, under the cluster's namespace and name. Clusters that
+// were not bootstrapped with kubeadm run no etcd; for them no snapshot is
+// taken and "" is returned.
+func (p *DockerProvider) SnapshotEtcd(ctx context.Context, cluster *v1alpha1.Cluster) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.SnapshotEtcd", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	node, err := p.etcdNode(ctx, cluster)
+	if err != nil {
+		return "", err
+	}
+	if !p.bootstrapped(ctx, node.ID) {
+		clusterLogger(ctx, cluster).V(1).Info("Cluster has no etcd, not taking a snapshot")
+		return "", nil
+	}
+	name := etcdSnapshotName()
+	path := filepath.Join(p.etcdSnapshotDirectory(), cluster.Namespace, cluster.Name, name)
+	if err := p.saveEtcdSnapshot(ctx, cluster, node, name, path); err != nil {
+		return "", err
+	}
+	recordNormal(ctx, cluster, ReasonEtcdSnapshotSaved, "Saved etcd snapshot of node %s to %s", containerName(node), path)
+	return path, nil
+}
+
+// etcdSnapshotName returns the file name of a snapshot taken now
+func etcdSnapshotName() string {
+	return fmt.Sprintf("etcd-%s.db", time.Now().UTC().Format("20060102T150405Z"))
+}
+
+// saveEtcdSnapshot saves a snapshot of the etcd member on node and copies it
+// to destPath on the host
+func (p *DockerProvider) saveEtcdSnapshot(ctx context.Context, cluster *v1alpha1.Cluster, node container.Summary, name, destPath string) error {
+	log := clusterLogger(ctx, cluster).WithValues("step", "etcd-snapshot", "node", containerName(node))
+	log.Info("Taking etcd snapshot")
+
+	etcdID, err := p.etcdContainerID(ctx, node.ID)
+	if err != nil {
+		return err
+	}
+
+	nodePath := filepath.Join(etcdDataDir, "mini-k8s-"+name)
+	save := append([]string{"crictl", "exec", etcdID}, etcdctlArgs...)
+	if _, err := p.execInContainer(ctx, node.ID, append(save, "snapshot", "save", nodePath)...); err != nil {
+		return fmt.Errorf("failed to save etcd snapshot: %w", err)
+	}
+	defer func() {
+		if _, err := p.execInContainer(ctx, node.ID, "rm", "-f", nodePath); err != nil {
+			log.Error(err, "Failed to remove etcd snapshot from node", "path", nodePath)
+		}
+	}()
+
+	if err := p.copyFileFromContainer(ctx, node.ID, nodePath, destPath); err != nil {
+		return fmt.Errorf("failed to copy etcd snapshot: %w", err)
+	}
+	log.Info("Took etcd snapshot", "path", destPath)
+	return nil
+}
+
+// etcdNode returns the first running control plane node of the cluster
+func (p *DockerProvider) etcdNode(ctx context.Context, cluster *v1alpha1.Cluster) (container.Summary, error) {
+	nodes, err := p.runningControlPlanes(ctx, cluster)
+	if err != nil {
+		return container.Summary{}, err
+	}
+	return nodes[0], nil
+}
+
+// runningControlPlanes returns the running control plane nodes of the
+// cluster by name. It fails if there are none.
+func (p *DockerProvider) runningControlPlanes(ctx context.Context, cluster *v1alpha1.Cluster) ([]container.Summary, error) {
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []container.Summary
+	for _, cont := range containers {
+		if nodeRole(cont.Labels) == RoleControlPlane && cont.State == "running" {
+			nodes = append(nodes, cont)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no running control plane node in cluster %s", cluster.Name)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return containerName(nodes[i]) < containerName(nodes[j]) })
+	return nodes, nil
+}
+
+// etcdContainerID returns the ID of the etcd container running on a node
+func (p *DockerProvider) etcdContainerID(ctx context.Context, nodeID string) (string, error) {
+	out, err := p.execInContainer(ctx, nodeID, "crictl", "ps", "--quiet", "--state", "running", "--name", "^etcd$")
+	if err != nil {
+		return "", fmt.Errorf("failed to find etcd container: %w", err)
+	}
+	ids := strings.Fields(out)
+	if len(ids) == 0 {
+		return "", fmt.Errorf("etcd is not running on node %s", shortID(nodeID))
+	}
+	return ids[0], nil
+}
+
+// etcdSnapshotDirectory returns the directory etcd snapshots are stored in
+func (p *DockerProvider) etcdSnapshotDirectory() string {
+	if p.config != nil && p.config.Spec.EtcdSnapshotDirectory != "" {
+		return p.config.Spec.EtcdSnapshotDirectory
+	}
+	return DefaultEtcdSnapshotDirectory()
+}
+
+// DefaultEtcdSnapshotDirectory returns where etcd snapshots are stored unless
+// the provider configuration says otherwise
+func DefaultEtcdSnapshotDirectory() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "mini-k8s-manager", "snapshots")
+}
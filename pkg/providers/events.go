@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// Event reasons emitted by providers for individual infrastructure steps
+const (
+	ReasonNetworkCreated      = "NetworkCreated"
+	ReasonNetworkRemoved      = "NetworkRemoved"
+	ReasonImagePulling        = "ImagePulling"
+	ReasonImagePullProgress   = "ImagePullProgress"
+	ReasonImagePulled         = "ImagePulled"
+	ReasonImagePullFailed     = "ImagePullFailed"
+	ReasonNodeCreated         = "NodeCreated"
+	ReasonNodeCreationFailed  = "NodeCreationFailed"
+	ReasonNodeRemoved         = "NodeRemoved"
+	ReasonNodeRemovalFailed   = "NodeRemovalFailed"
+	ReasonNodeDrained         = "NodeDrained"
+	ReasonNodeDrainFailed     = "NodeDrainFailed"
+	ReasonNodeStopped         = "NodeStopped"
+	ReasonNodeStopFailed      = "NodeStopFailed"
+	ReasonNodeStarted         = "NodeStarted"
+	ReasonNodeStartFailed     = "NodeStartFailed"
+	ReasonNodeResized         = "NodeResized"
+	ReasonNodeResizeFailed    = "NodeResizeFailed"
+	ReasonNodeRestarted       = "NodeRestarted"
+	ReasonNodeRestartFailed   = "NodeRestartFailed"
+	ReasonNodeJoined          = "NodeJoined"
+	ReasonNodeJoinFailed      = "NodeJoinFailed"
+	ReasonNodeMigrated        = "NodeMigrated"
+	ReasonNodeAdopted         = "NodeAdopted"
+	ReasonNodeCloned          = "NodeCloned"
+	ReasonNodeCloneFailed     = "NodeCloneFailed"
+	ReasonNodeUpgrading       = "NodeUpgrading"
+	ReasonNodeUpgraded        = "NodeUpgraded"
+	ReasonNodeRolledBack      = "NodeRolledBack"
+	ReasonVersionDeprecated   = "VersionDeprecated"
+	ReasonEtcdSnapshotSaved   = "EtcdSnapshotSaved"
+	ReasonEtcdRestored        = "EtcdRestored"
+	ReasonEtcdMemberRemoved   = "EtcdMemberRemoved"
+	ReasonLoadBalancerUpdated = "LoadBalancerUpdated"
+)
+
+type eventRecorderKey struct{}
+
+// WithEventRecorder returns a context that makes providers report the steps
+// they perform on a cluster as Kubernetes Events through recorder
+func WithEventRecorder(ctx context.Context, recorder record.EventRecorder) context.Context {
+	return context.WithValue(ctx, eventRecorderKey{}, recorder)
+}
+
+// recordEvent emits an event about cluster if the context carries a recorder
+func recordEvent(ctx context.Context, cluster *v1alpha1.Cluster, eventType, reason, messageFmt string, args ...interface{}) {
+	recorder, ok := ctx.Value(eventRecorderKey{}).(record.EventRecorder)
+	if !ok || recorder == nil {
+		return
+	}
+	recorder.Eventf(cluster, eventType, reason, messageFmt, args...)
+}
+
+// recordWarning emits a Warning event about cluster if the context carries a recorder
+func recordWarning(ctx context.Context, cluster *v1alpha1.Cluster, reason, messageFmt string, args ...interface{}) {
+	recordEvent(ctx, cluster, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// recordNormal emits a Normal event about cluster if the context carries a recorder
+func recordNormal(ctx context.Context, cluster *v1alpha1.Cluster, reason, messageFmt string, args ...interface{}) {
+	recordEvent(ctx, cluster, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
@@ -0,0 +1,158 @@
+package providers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// execInContainer runs cmd in a container and returns its standard output.
+// A non-zero exit code is returned as an error carrying the standard error.
+func (p *DockerProvider) execInContainer(ctx context.Context, containerID string, cmd ...string) (string, error) {
+	exec, err := p.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create exec of %s: %w", cmd[0], err)
+	}
+
+	resp, err := p.client.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to start %s: %w", cmd[0], err)
+	}
+	defer resp.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		return "", fmt.Errorf("failed to read output of %s: %w", cmd[0], err)
+	}
+
+	info, err := p.client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect exec of %s: %w", cmd[0], err)
+	}
+	if info.ExitCode != 0 {
+		return "", fmt.Errorf("%s exited with code %d: %s", cmd[0], info.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// copyFileFromContainer copies the regular file at srcPath in a container to
+// destPath on the host. The file only appears at destPath once it is complete.
+func (p *DockerProvider) copyFileFromContainer(ctx context.Context, containerID, srcPath, destPath string) error {
+	reader, _, err := p.client.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s from container: %w", srcPath, err)
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", destPath, err)
+	}
+	return extractFile(reader, destPath)
+}
+
+// copyFileToContainer copies the file at srcPath on the host to destPath in a
+// container. The directory of destPath must exist in the container.
+func (p *DockerProvider) copyFileToContainer(ctx context.Context, containerID, srcPath, destPath string) error {
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", srcPath, err)
+	}
+
+	// The archive is streamed to Docker as it is written
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(archiveFile(writer, file, path.Base(destPath), info.Size()))
+	}()
+	defer reader.Close()
+
+	if err := p.client.CopyToContainer(ctx, containerID, path.Dir(destPath), reader, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy %s to container: %w", srcPath, err)
+	}
+	return nil
+}
+
+// writeFileToContainer writes content to destPath in a container. The
+// directory of destPath must exist in the container.
+func (p *DockerProvider) writeFileToContainer(ctx context.Context, containerID string, content []byte, destPath string) error {
+	var archive bytes.Buffer
+	if err := archiveFile(&archive, bytes.NewReader(content), path.Base(destPath), int64(len(content))); err != nil {
+		return err
+	}
+	if err := p.client.CopyToContainer(ctx, containerID, path.Dir(destPath), &archive, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to write %s to container: %w", destPath, err)
+	}
+	return nil
+}
+
+// archiveFile writes a tar stream holding size bytes of content as the file name
+func archiveFile(w io.Writer, content io.Reader, name string, size int64) error {
+	archive := tar.NewWriter(w)
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o600,
+		Size:     size,
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if _, err := io.Copy(archive, content); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return archive.Close()
+}
+
+// extractFile writes the first regular file of a tar stream to destPath
+func extractFile(reader io.Reader, destPath string) error {
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("no file found to copy to %s", destPath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".*")
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", destPath, err)
+		}
+		if _, err := io.Copy(tmp, archive); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write %s: %w", destPath, err)
+		}
+		if err := tmp.Close(); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write %s: %w", destPath, err)
+		}
+		if err := os.Rename(tmp.Name(), destPath); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write %s: %w", destPath, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractFile(t *testing.T) {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	content := []byte("snapshot")
+	if err := writer.WriteHeader(&tar.Header{Name: "etcd.db", Typeflag: tar.TypeReg, Mode: 0o600, Size: int64(len(content))}); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	writer.Write(content)
+	writer.Close()
+
+	dest := filepath.Join(t.TempDir(), "etcd.db")
+	if err := extractFile(bytes.NewReader(archive.Bytes()), dest); err != nil {
+		t.Fatalf("Failed to extract file: %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil || string(data) != "snapshot" {
+		t.Errorf("Expected the file contents, got %q, %v", data, err)
+	}
+
+	var empty bytes.Buffer
+	tar.NewWriter(&empty).Close()
+	if err := extractFile(&empty, filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("Expected an error for an archive without a file")
+	}
+}
@@ -0,0 +1,312 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+const (
+	// remediationStopTimeout is how many seconds an unhealthy node gets to
+	// shut down when it is restarted or recreated
+	remediationStopTimeout = 10
+
+	// joinTimeout bounds the wait for a recreated node's container runtime
+	// to come up before the node is joined to its cluster
+	joinTimeout = 3 * time.Minute
+
+	// joinTokenTTL is how long the bootstrap token a recreated node joins
+	// with stays valid
+	joinTokenTTL = "15m"
+)
+
+// NodeHealth is what a provider observes about the health of a node
+type NodeHealth struct {
+	// Name is the name of the node
+	Name string
+
+	// Role is the role of the node (control-plane or worker)
+	Role string
+
+	// ContainerRunning reports whether the node's container is running
+	ContainerRunning bool
+
+	// ContainerSince is when the container last started or, if it is not
+	// running, stopped. It is zero if the provider cannot tell.
+	ContainerSince time.Time
+
+	// Ready is the Ready condition of the node in the workload cluster. It is
+	// nil for clusters without an API server to ask and for nodes that have
+	// not registered.
+	Ready *bool
+
+	// ReadySince is when the Ready condition last changed
+	ReadySince time.Time
+}
+
+// NodeHealth reports the state of the cluster's node containers and, for
+// clusters bootstrapped with kubeadm, whether their kubelets report Ready
+func (p *DockerProvider) NodeHealth(ctx context.Context, cluster *v1alpha1.Cluster) (_ []NodeHealth, err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.NodeHealth", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, ErrClusterNotFound
+	}
+
+	readiness := p.nodeReadiness(ctx, cluster, containers)
+	nodes := make([]NodeHealth, 0, len(containers))
+	for _, cont := range containers {
+		info, err := p.client.ContainerInspect(ctx, cont.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %s: %w", cont.ID, err)
+		}
+		health := NodeHealth{
+			Name:             containerName(cont),
+			Role:             nodeRole(cont.Labels),
+			ContainerRunning: cont.State == "running",
+			ContainerSince:   containerStateSince(info),
+		}
+		// Adopted nodes keep the hostname, and so the Node name, kind gave them
+		if condition, ok := readiness[nodeHostname(info, health.Name)]; ok {
+			ready := condition.Status == corev1.ConditionTrue
+			health.Ready = &ready
+			health.ReadySince = condition.LastTransitionTime.Time
+		}
+		nodes = append(nodes, health)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+// nodeReadiness returns the Ready conditions of the cluster's registered
+// nodes by Node name, read through the API server of a running control plane
+// node. Clusters without a reachable API server have none.
+func (p *DockerProvider) nodeReadiness(ctx context.Context, cluster *v1alpha1.Cluster, containers []container.Summary) map[string]corev1.NodeCondition {
+	log := clusterLogger(ctx, cluster)
+	controlPlanes, _ := nodesByRole(containers, "running")
+	for _, node := range controlPlanes {
+		if !p.bootstrapped(ctx, node.ID) {
+			continue
+		}
+		out, err := p.execInContainer(ctx, node.ID, "kubectl", "--kubeconfig="+adminKubeconfig, "get", "nodes", "--output=json")
+		if err != nil {
+			log.V(1).Info("Cannot read node readiness", "node", containerName(node), "error", err.Error())
+			continue
+		}
+		var nodes corev1.NodeList
+		if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+			log.V(1).Info("Cannot parse node list", "node", containerName(node), "error", err.Error())
+			continue
+		}
+
+		readiness := make(map[string]corev1.NodeCondition, len(nodes.Items))
+		for _, n := range nodes.Items {
+			for _, condition := range n.Status.Conditions {
+				if condition.Type == corev1.NodeReady {
+					readiness[n.Name] = condition
+				}
+			}
+		}
+		return readiness
+	}
+	return nil
+}
+
+// RemediateNode repairs an unhealthy node. Restart restarts its container.
+// Recreate replaces the container with a new one from the node's image and,
+// in clusters bootstrapped with kubeadm, joins it to the cluster again.
+// Control plane nodes of such clusters hold etcd data and are restarted instead.
+func (p *DockerProvider) RemediateNode(ctx context.Context, cluster *v1alpha1.Cluster, name string, action v1alpha1.RemediationAction) (_ v1alpha1.RemediationAction, err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.RemediateNode", append(clusterAttributes(cluster),
+		tracing.NodeNameKey.String(name),
+	)...)
+	defer func() { tracing.End(span, err) }()
+
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return action, err
+	}
+	var cont container.Summary
+	found := false
+	for _, c := range containers {
+		if containerName(c) == name {
+			cont, found = c, true
+			break
+		}
+	}
+	if !found {
+		return action, fmt.Errorf("node %s not found in cluster %s", name, cluster.Name)
+	}
+	log := clusterLogger(ctx, cluster).WithValues("node", name, "step", "remediate")
+
+	if action != v1alpha1.RemediationActionRecreate {
+		log.Info("Restarting unhealthy node")
+		return v1alpha1.RemediationActionRestart, p.restartNode(ctx, cluster, cont)
+	}
+	joined := p.joinedNode(ctx, cont.ID)
+	if joined && nodeRole(cont.Labels) == RoleControlPlane {
+		log.Info("Control plane node holds etcd data, restarting it instead of recreating it")
+		return v1alpha1.RemediationActionRestart, p.restartNode(ctx, cluster, cont)
+	}
+	log.Info("Recreating unhealthy node", "rejoin", joined)
+	return action, p.recreateNode(ctx, cluster, cont, joined)
+}
+
+// restartNode restarts a node container, keeping it and its volumes
+func (p *DockerProvider) restartNode(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary) error {
+	timeout := remediationStopTimeout
+	if err := p.client.ContainerRestart(ctx, cont.ID, container.StopOptions{Timeout: &timeout}); err != nil {
+		recordWarning(ctx, cluster, ReasonNodeRestartFailed, "Failed to restart node %s: %v", containerName(cont), err)
+		return fmt.Errorf("failed to restart container %s: %w", cont.ID, err)
+	}
+	clusterLogger(ctx, cluster).V(1).Info("Restarted node", "node", containerName(cont))
+	recordNormal(ctx, cluster, ReasonNodeRestarted, "Restarted %s node %s", nodeRole(cont.Labels), containerName(cont))
+	return nil
+}
+
+// recreateNode replaces a node with a new container of the same name, role
+// and image. A node that had joined the cluster is joined again through a
+// running control plane node, after its old Node object is deleted.
+func (p *DockerProvider) recreateNode(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary, joined bool) error {
+	name, role := containerName(cont), nodeRole(cont.Labels)
+	clusterNetwork, err := p.requireClusterNetwork(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	var joinCommand []string
+	if joined {
+		controlPlane, err := p.etcdNode(ctx, cluster)
+		if err != nil {
+			return fmt.Errorf("cannot rejoin node %s: %w", name, err)
+		}
+		if joinCommand, err = p.kubeadmJoinCommand(ctx, controlPlane.ID); err != nil {
+			return fmt.Errorf("cannot rejoin node %s: %w", name, err)
+		}
+
+		info, err := p.client.ContainerInspect(ctx, cont.ID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container %s: %w", cont.ID, err)
+		}
+		if _, err := p.execInContainer(ctx, controlPlane.ID, "kubectl", "--kubeconfig="+adminKubeconfig, "delete", "node", nodeHostname(info, name), "--ignore-not-found"); err != nil {
+			return fmt.Errorf("failed to delete node %s: %w", name, err)
+		}
+	}
+
+	image := cont.Labels[LabelNodeImage]
+	if image == "" {
+		image = p.getNodeImage(cluster)
+	}
+	if err := p.removeNode(ctx, cluster, cont, remediationStopTimeout); err != nil {
+		return err
+	}
+	if err := p.createNodeFromImage(ctx, cluster, name, role, clusterNetwork, machineConfigFor(&cluster.Spec, role), image); err != nil {
+		return err
+	}
+	if !joined {
+		return nil
+	}
+	return p.joinNode(ctx, cluster, name, joinCommand)
+}
+
+// kubeadmJoinCommand creates a bootstrap token on a control plane node and
+// returns the kubeadm join command that uses it
+func (p *DockerProvider) kubeadmJoinCommand(ctx context.Context, controlPlaneID string) ([]string, error) {
+	out, err := p.execInContainer(ctx, controlPlaneID, "kubeadm", "token", "create", "--print-join-command", "--ttl="+joinTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create join token: %w", err)
+	}
+	command := strings.Fields(out)
+	if len(command) == 0 || command[0] != "kubeadm" {
+		return nil, fmt.Errorf("unexpected join command %q", strings.TrimSpace(out))
+	}
+	return command, nil
+}
+
+// joinNode runs a kubeadm join command on a new node once its container
+// runtime is up
+func (p *DockerProvider) joinNode(ctx context.Context, cluster *v1alpha1.Cluster, name string, joinCommand []string) error {
+	log := clusterLogger(ctx, cluster).WithValues("node", name, "step", "join")
+
+	if err := p.waitForContainerRuntime(ctx, name); err != nil {
+		recordWarning(ctx, cluster, ReasonNodeJoinFailed, "Container runtime of node %s did not start: %v", name, err)
+		return err
+	}
+
+	// Node containers do not pass kubeadm's host checks, such as the one for swap
+	log.V(1).Info("Joining node")
+	if _, err := p.execInContainer(ctx, name, append(joinCommand, "--ignore-preflight-errors=all")...); err != nil {
+		recordWarning(ctx, cluster, ReasonNodeJoinFailed, "Failed to join node %s: %v", name, err)
+		return fmt.Errorf("failed to join node %s: %w", name, err)
+	}
+	log.Info("Joined node")
+	recordNormal(ctx, cluster, ReasonNodeJoined, "Joined node %s to the cluster", name)
+	return nil
+}
+
+// waitForContainerRuntime waits until the container runtime of a new node is up
+func (p *DockerProvider) waitForContainerRuntime(ctx context.Context, name string) error {
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, joinTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := p.execInContainer(ctx, name, "crictl", "info")
+		return err == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("container runtime of node %s did not start: %w", name, err)
+	}
+	return nil
+}
+
+// joinedNode reports whether a node was joined to its cluster by kubeadm,
+// which leaves a kubelet kubeconfig behind. Unlike bootstrapped it works on
+// stopped nodes too.
+func (p *DockerProvider) joinedNode(ctx context.Context, nodeID string) bool {
+	reader, _, err := p.client.CopyFromContainer(ctx, nodeID, kubeletKubeconfig)
+	if err != nil {
+		return false
+	}
+	reader.Close()
+	return true
+}
+
+// containerStateSince returns when a container last started or, if it is not
+// running, stopped. Containers that never ran count from their creation.
+func containerStateSince(info container.InspectResponse) time.Time {
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return time.Time{}
+	}
+	at := info.State.FinishedAt
+	if info.State.Running {
+		at = info.State.StartedAt
+	}
+	if since, err := time.Parse(time.RFC3339Nano, at); err == nil && !since.IsZero() {
+		return since
+	}
+	if created, err := time.Parse(time.RFC3339Nano, info.Created); err == nil {
+		return created
+	}
+	return time.Time{}
+}
+
+// nodeHostname returns the hostname of a node container, which kubeadm
+// registers the node under, falling back to name
+func nodeHostname(info container.InspectResponse, name string) string {
+	if info.Config != nil && info.Config.Hostname != "" {
+		return info.Config.Hostname
+	}
+	return name
+}
@@ -0,0 +1,50 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestContainerStateSince(t *testing.T) {
+	inspect := func(running bool, created, started, finished string) container.InspectResponse {
+		return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{
+			Created: created,
+			State:   &container.State{Running: running, StartedAt: started, FinishedAt: finished},
+		}}
+	}
+	const (
+		created  = "2024-05-01T10:00:00.000000000Z"
+		started  = "2024-05-01T10:00:05.123456789Z"
+		finished = "2024-05-01T11:30:00Z"
+		never    = "0001-01-01T00:00:00Z"
+	)
+
+	tests := []struct {
+		name     string
+		info     container.InspectResponse
+		expected string
+	}{
+		{"running", inspect(true, created, started, never), started},
+		{"exited", inspect(false, created, started, finished), finished},
+		{"never started", inspect(false, created, never, never), created},
+		{"not inspected", container.InspectResponse{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			since := containerStateSince(tt.info)
+			if tt.expected == "" {
+				if !since.IsZero() {
+					t.Errorf("Expected no time, got %s", since)
+				}
+				return
+			}
+			expected, _ := time.Parse(time.RFC3339Nano, tt.expected)
+			if !since.Equal(expected) {
+				t.Errorf("Expected %s, got %s", expected, since)
+			}
+		})
+	}
+}
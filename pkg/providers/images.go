@@ -0,0 +1,298 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/metrics"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+// ErrImageNotPresent is returned when the pull policy is Never and the node
+// image is not available locally
+var ErrImageNotPresent = errors.New("image not present")
+
+// ImagePullReporter receives the progress of image pulls
+type ImagePullReporter func(progress v1alpha1.ImagePullStatus)
+
+type imagePullReporterKey struct{}
+
+// WithImagePullReporter returns a context that makes providers report the
+// progress of the image pulls they perform to reporter
+func WithImagePullReporter(ctx context.Context, reporter ImagePullReporter) context.Context {
+	return context.WithValue(ctx, imagePullReporterKey{}, reporter)
+}
+
+// reportImagePull passes progress to the context's reporter, if any
+func reportImagePull(ctx context.Context, progress v1alpha1.ImagePullStatus) {
+	reporter, ok := ctx.Value(imagePullReporterKey{}).(ImagePullReporter)
+	if !ok || reporter == nil {
+		return
+	}
+	progress.LastUpdateTime = metav1.Now()
+	reporter(progress)
+}
+
+// imageManager makes node images available to the Docker daemon. Concurrent
+// requests for the same image share a single pull.
+type imageManager struct {
+	client DockerClient
+
+	mu       sync.Mutex
+	inflight map[string]*imagePull
+}
+
+// imagePull is a pull in progress; err is set before done is closed
+type imagePull struct {
+	done chan struct{}
+	err  error
+}
+
+func newImageManager(client DockerClient) *imageManager {
+	return &imageManager{client: client, inflight: map[string]*imagePull{}}
+}
+
+// ensureImage makes ref available locally according to policy, pulling it on
+// behalf of cluster if needed. An empty policy means IfNotPresent.
+func (m *imageManager) ensureImage(ctx context.Context, cluster *v1alpha1.Cluster, ref string, policy corev1.PullPolicy) error {
+	if policy != corev1.PullAlways {
+		present, err := m.imagePresent(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if present {
+			return nil
+		}
+		if policy == corev1.PullNever {
+			recordWarning(ctx, cluster, ReasonImagePullFailed, "Image %s is not present and the pull policy is Never", ref)
+			return fmt.Errorf("%w: %s (pull policy Never)", ErrImageNotPresent, ref)
+		}
+	}
+
+	m.mu.Lock()
+	if pull, ok := m.inflight[ref]; ok {
+		m.mu.Unlock()
+		clusterLogger(ctx, cluster).V(1).Info("Waiting for image pull in progress", "step", "pull", "image", ref)
+		select {
+		case <-pull.done:
+			return pull.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	pull := &imagePull{done: make(chan struct{})}
+	m.inflight[ref] = pull
+	m.mu.Unlock()
+
+	pull.err = m.pull(ctx, cluster, ref)
+
+	m.mu.Lock()
+	delete(m.inflight, ref)
+	m.mu.Unlock()
+	close(pull.done)
+	return pull.err
+}
+
+// imagePresent reports whether ref is available locally
+func (m *imageManager) imagePresent(ctx context.Context, ref string) (bool, error) {
+	if _, err := m.client.ImageInspect(ctx, ref); err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+	return true, nil
+}
+
+// pull pulls ref and waits for the pull to finish
+func (m *imageManager) pull(ctx context.Context, cluster *v1alpha1.Cluster, ref string) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.pullImage", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	log := clusterLogger(ctx, cluster).WithValues("step", "pull", "image", ref)
+	log.Info("Pulling node image")
+	recordNormal(ctx, cluster, ReasonImagePulling, "Pulling image %s", ref)
+	reportImagePull(ctx, v1alpha1.ImagePullStatus{Image: ref, State: v1alpha1.ImagePullStatePulling})
+
+	start := time.Now()
+	err = m.doPull(ctx, cluster, ref)
+	if err != nil {
+		recordWarning(ctx, cluster, ReasonImagePullFailed, "Failed to pull image %s: %v", ref, err)
+		reportImagePull(ctx, v1alpha1.ImagePullStatus{Image: ref, State: v1alpha1.ImagePullStateFailed, Message: err.Error()})
+		return fmt.Errorf("failed to pull node image: %w", err)
+	}
+	metrics.ImagePullDuration.WithLabelValues(ref).Observe(time.Since(start).Seconds())
+
+	log.Info("Pulled node image", "duration", time.Since(start).Round(time.Millisecond).String())
+	recordNormal(ctx, cluster, ReasonImagePulled, "Pulled image %s in %s", ref, time.Since(start).Round(time.Second))
+	reportImagePull(ctx, v1alpha1.ImagePullStatus{Image: ref, State: v1alpha1.ImagePullStatePulled})
+	return nil
+}
+
+// doPull starts the pull and consumes its progress stream; the daemon only
+// finishes the pull once the stream has been read to the end
+func (m *imageManager) doPull(ctx context.Context, cluster *v1alpha1.Cluster, ref string) error {
+	auth, err := registryAuthFor(ctx, ref)
+	if err != nil {
+		return err
+	}
+	reader, err := m.client.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	progress := newPullProgress()
+	return readPullStream(reader, func(msg jsonmessage.JSONMessage) {
+		current, total, changed := progress.update(msg)
+		if !changed {
+			return
+		}
+		percent := progress.milestone(current, total)
+		if percent > 0 {
+			recordNormal(ctx, cluster, ReasonImagePullProgress, "Pulled %d%% of image %s", percent, ref)
+		}
+		reportImagePull(ctx, v1alpha1.ImagePullStatus{
+			Image:        ref,
+			State:        v1alpha1.ImagePullStatePulling,
+			CurrentBytes: current,
+			TotalBytes:   total,
+			Message:      msg.Status,
+		})
+	})
+}
+
+// readPullStream decodes the JSON messages of a pull progress stream, calling
+// onMessage for each one, until the stream ends or reports an error
+func readPullStream(reader io.Reader, onMessage func(jsonmessage.JSONMessage)) error {
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read pull progress: %w", err)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if msg.ErrorMessage != "" {
+			return errors.New(msg.ErrorMessage)
+		}
+		onMessage(msg)
+	}
+}
+
+// pullProgress adds up the download progress of the layers of an image
+type pullProgress struct {
+	layers        map[string]jsonmessage.JSONProgress
+	lastMilestone int
+}
+
+// pullProgressMilestone is the step, in percent, at which pull progress events are emitted
+const pullProgressMilestone = 25
+
+func newPullProgress() *pullProgress {
+	return &pullProgress{layers: map[string]jsonmessage.JSONProgress{}}
+}
+
+// update records msg and returns the bytes downloaded so far and the total,
+// and whether msg changed them
+func (p *pullProgress) update(msg jsonmessage.JSONMessage) (current, total int64, changed bool) {
+	switch {
+	case msg.ID == "":
+		// Messages about the image as a whole, e.g. the digest
+	case msg.Status == "Downloading" && msg.Progress != nil && msg.Progress.Total > 0:
+		p.layers[msg.ID] = *msg.Progress
+		changed = true
+	case msg.Status == "Download complete" || msg.Status == "Already exists" || msg.Status == "Pull complete":
+		if layer, ok := p.layers[msg.ID]; ok && layer.Current != layer.Total {
+			layer.Current = layer.Total
+			p.layers[msg.ID] = layer
+			changed = true
+		}
+	}
+	for _, layer := range p.layers {
+		current += layer.Current
+		total += layer.Total
+	}
+	return current, total, changed
+}
+
+// milestone returns the percentage reached if current/total crossed another
+// pullProgressMilestone since the last call that returned one, or 0
+func (p *pullProgress) milestone(current, total int64) int {
+	if total <= 0 {
+		return 0
+	}
+	percent := int(current*100/total) / pullProgressMilestone * pullProgressMilestone
+	if percent <= p.lastMilestone {
+		return 0
+	}
+	p.lastMilestone = percent
+	return percent
+}
+
+// commitNode commits a node container to an image that a node of cluster is
+// then created from. The image carries the cluster's labels, so that
+// DeleteCluster removes it with the cluster's nodes. It returns the image ID.
+func (p *DockerProvider) commitNode(ctx context.Context, cluster *v1alpha1.Cluster, containerID string, options container.CommitOptions) (string, error) {
+	labels := clusterLabels(cluster)
+	labels[LabelCommittedFrom] = containerID
+	options.Config = &container.Config{Labels: labels}
+	commit, err := p.client.ContainerCommit(ctx, containerID, options)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit container %s: %w", containerID, err)
+	}
+	return commit.ID, nil
+}
+
+// removeCommittedImages removes the images commitNode committed for the
+// nodes of cluster. Docker keeps images that containers were created from,
+// so this only succeeds once the cluster's nodes are gone.
+func (p *DockerProvider) removeCommittedImages(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	args := clusterFilters(cluster)
+	args.Add("label", LabelCommittedFrom)
+	images, err := p.client.ImageList(ctx, image.ListOptions{Filters: args})
+	if err != nil {
+		return fmt.Errorf("failed to list committed node images: %w", err)
+	}
+	for _, img := range images {
+		clusterLogger(ctx, cluster).V(1).Info("Removing committed node image", "step", "image", "image", shortID(img.ID))
+		if _, err := p.client.ImageRemove(ctx, img.ID, image.RemoveOptions{PruneChildren: true}); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to remove image %s: %w", shortID(img.ID), err)
+		}
+	}
+	return nil
+}
+
+// removeUnusedImage removes an image committed by commitNode that no node
+// was created from after all. Failing to is only logged; DeleteCluster tries
+// again.
+func (p *DockerProvider) removeUnusedImage(ctx context.Context, cluster *v1alpha1.Cluster, imageID string) {
+	if _, err := p.client.ImageRemove(ctx, imageID, image.RemoveOptions{PruneChildren: true}); err != nil && !errdefs.IsNotFound(err) {
+		clusterLogger(ctx, cluster).Error(err, "Failed to remove committed node image", "image", shortID(imageID))
+	}
+}
+
+// nodeImagePolicy returns the pull policy for the node image of cluster
+func nodeImagePolicy(cluster *v1alpha1.Cluster) corev1.PullPolicy {
+	if cluster.Spec.ImagePullPolicy == "" {
+		return corev1.PullIfNotPresent
+	}
+	return cluster.Spec.ImagePullPolicy
+}
@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+const pullStream = `{"status":"Pulling from kindest/node","id":"v1.27.13"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"b"}
+{"status":"Downloading","progressDetail":{"current":50,"total":100},"id":"a"}
+{"status":"Downloading","progressDetail":{"current":100,"total":300},"id":"b"}
+{"status":"Download complete","progressDetail":{},"id":"a"}
+{"status":"Downloading","progressDetail":{"current":300,"total":300},"id":"b"}
+{"status":"Pull complete","progressDetail":{},"id":"b"}
+{"status":"Digest: sha256:0123"}
+{"status":"Status: Downloaded newer image for kindest/node:v1.27.13"}
+`
+
+func TestReadPullStream(t *testing.T) {
+	progress := newPullProgress()
+	var currents, milestones []int64
+	err := readPullStream(strings.NewReader(pullStream), func(msg jsonmessage.JSONMessage) {
+		current, total, changed := progress.update(msg)
+		if !changed {
+			return
+		}
+		currents = append(currents, current)
+		if percent := progress.milestone(current, total); percent > 0 {
+			milestones = append(milestones, int64(percent))
+		}
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []int64{50, 150, 200, 400}
+	if len(currents) != len(expected) {
+		t.Fatalf("Expected progress %v, got %v", expected, currents)
+	}
+	for i := range expected {
+		if currents[i] != expected[i] {
+			t.Errorf("Expected progress %v, got %v", expected, currents)
+			break
+		}
+	}
+	if len(milestones) != 2 || milestones[0] != 50 || milestones[1] != 100 {
+		t.Errorf("Expected milestones [50 100], got %v", milestones)
+	}
+}
+
+func TestReadPullStreamError(t *testing.T) {
+	stream := `{"status":"Pulling from kindest/node","id":"v9.99.0"}
+{"errorDetail":{"message":"manifest for kindest/node:v9.99.0 not found"},"error":"manifest for kindest/node:v9.99.0 not found"}
+`
+	err := readPullStream(strings.NewReader(stream), func(jsonmessage.JSONMessage) {})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected the error reported in the stream, got %v", err)
+	}
+
+	if err := readPullStream(strings.NewReader(`{"status":`), func(jsonmessage.JSONMessage) {}); err == nil {
+		t.Error("Expected an error for a truncated stream")
+	}
+}
+
+func TestNodeImagePolicy(t *testing.T) {
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "dev"}}
+	if policy := nodeImagePolicy(cluster); policy != corev1.PullIfNotPresent {
+		t.Errorf("Expected IfNotPresent by default, got %s", policy)
+	}
+	cluster.Spec.ImagePullPolicy = corev1.PullNever
+	if policy := nodeImagePolicy(cluster); policy != corev1.PullNever {
+		t.Errorf("Expected Never, got %s", policy)
+	}
+}
@@ -0,0 +1,93 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// Kubeconfig returns the admin kubeconfig kubeadm left on the cluster's first
+// bootstrapped control plane node. Its server is the cluster network address
+// of the load balancer, or of that node for clusters without one, which the
+// manager's host reaches directly. Its cluster, user and context are named
+// after the cluster so that the kubeconfigs of several clusters can be merged.
+func (p *DockerProvider) Kubeconfig(ctx context.Context, cluster *v1alpha1.Cluster) ([]byte, error) {
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	controlPlanes, _ := nodesByRole(containers, "running")
+	var admin *container.Summary
+	for i := range controlPlanes {
+		if p.bootstrapped(ctx, controlPlanes[i].ID) {
+			admin = &controlPlanes[i]
+			break
+		}
+	}
+	if admin == nil {
+		return nil, fmt.Errorf("%w: no running control plane node of cluster %s has been bootstrapped", ErrClusterNotFound, cluster.Name)
+	}
+
+	endpoint := *admin
+	for _, cont := range containers {
+		if nodeRole(cont.Labels) == RoleExternalLoadBalancer && cont.State == "running" {
+			endpoint = cont
+		}
+	}
+	info, err := p.client.ContainerInspect(ctx, endpoint.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", endpoint.ID, err)
+	}
+	address := nodeAddress(info, endpoint.Labels[LabelNetwork])
+	if address == "" {
+		return nil, fmt.Errorf("container %s has no address on the cluster network", containerName(endpoint))
+	}
+
+	out, err := p.execInContainer(ctx, admin.ID, "cat", adminKubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin kubeconfig of node %s: %w", containerName(*admin), err)
+	}
+	config, err := clientcmd.Load([]byte(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse admin kubeconfig of node %s: %w", containerName(*admin), err)
+	}
+	return clientcmd.Write(*rewriteKubeconfig(config, cluster.Name, "https://"+net.JoinHostPort(address, strconv.Itoa(apiServerPort))))
+}
+
+// rewriteKubeconfig returns the current context of config as a kubeconfig of
+// its own, with the cluster, user and context called name and the cluster
+// served at server
+func rewriteKubeconfig(config *clientcmdapi.Config, name, server string) *clientcmdapi.Config {
+	rewritten := clientcmdapi.NewConfig()
+	current := config.Contexts[config.CurrentContext]
+	if current == nil {
+		current = clientcmdapi.NewContext()
+	}
+
+	cluster := clientcmdapi.NewCluster()
+	if c := config.Clusters[current.Cluster]; c != nil {
+		cluster = c.DeepCopy()
+	}
+	cluster.Server = server
+	rewritten.Clusters[name] = cluster
+
+	user := clientcmdapi.NewAuthInfo()
+	if u := config.AuthInfos[current.AuthInfo]; u != nil {
+		user = u.DeepCopy()
+	}
+	rewritten.AuthInfos[name+"-admin"] = user
+
+	kubeContext := clientcmdapi.NewContext()
+	kubeContext.Cluster = name
+	kubeContext.AuthInfo = name + "-admin"
+	rewritten.Contexts[name] = kubeContext
+	rewritten.CurrentContext = name
+	return rewritten
+}
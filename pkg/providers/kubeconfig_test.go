@@ -0,0 +1,85 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const testAdminKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kubernetes
+  cluster:
+    certificate-authority-data: Y2E=
+    server: https://kubeconfig-control-plane:6443
+users:
+- name: kubernetes-admin
+  user:
+    client-certificate-data: Y2VydA==
+    client-key-data: a2V5
+contexts:
+- name: kubernetes-admin@kubernetes
+  context:
+    cluster: kubernetes
+    user: kubernetes-admin
+current-context: kubernetes-admin@kubernetes
+`
+
+func TestKubeconfig(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	cluster := newFakeCluster("kubeconfig", 1)
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+
+	// Nodes kubeadm has not bootstrapped have no kubeconfig to hand out
+	if _, err := provider.Kubeconfig(ctx, cluster); !errors.Is(err, ErrClusterNotFound) {
+		t.Errorf("Expected ErrClusterNotFound before bootstrapping, got %v", err)
+	}
+
+	args := clusterFilters(cluster)
+	args.Add("label", LabelRole+"="+RoleControlPlane)
+	controlPlanes, err := engine.ContainerList(ctx, container.ListOptions{Filters: args})
+	if err != nil || len(controlPlanes) != 1 {
+		t.Fatalf("Failed to find the control plane node: %v", err)
+	}
+	if err := engine.WriteFile(controlPlanes[0].ID, adminKubeconfig, []byte(testAdminKubeconfig)); err != nil {
+		t.Fatalf("Failed to write admin kubeconfig: %v", err)
+	}
+	info, err := engine.ContainerInspect(ctx, controlPlanes[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to inspect control plane node: %v", err)
+	}
+
+	data, err := provider.Kubeconfig(ctx, cluster)
+	if err != nil {
+		t.Fatalf("Failed to get kubeconfig: %v", err)
+	}
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		t.Fatalf("Failed to parse kubeconfig: %v", err)
+	}
+	if config.CurrentContext != cluster.Name {
+		t.Errorf("Expected current context %s, got %s", cluster.Name, config.CurrentContext)
+	}
+	kubeCluster := config.Clusters[cluster.Name]
+	if kubeCluster == nil {
+		t.Fatalf("Expected a cluster named %s, got %v", cluster.Name, config.Clusters)
+	}
+	server := "https://" + net.JoinHostPort(nodeAddress(info, controlPlanes[0].Labels[LabelNetwork]), "6443")
+	if kubeCluster.Server != server {
+		t.Errorf("Expected server %s, got %s", server, kubeCluster.Server)
+	}
+	if string(kubeCluster.CertificateAuthorityData) != "ca" {
+		t.Errorf("Expected the CA of the admin kubeconfig, got %q", kubeCluster.CertificateAuthorityData)
+	}
+	if user := config.AuthInfos[cluster.Name+"-admin"]; user == nil || string(user.ClientKeyData) != "key" {
+		t.Errorf("Expected the admin credentials, got %v", config.AuthInfos)
+	}
+}
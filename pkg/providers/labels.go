@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// Labels applied to every Docker resource owned by the manager
+const (
+	// LabelManagedBy marks a resource as owned by the manager
+	LabelManagedBy = "cluster.mini-k8s.io/managed-by"
+
+	// LabelClusterName holds the name of the owning Cluster
+	LabelClusterName = "cluster.mini-k8s.io/cluster-name"
+
+	// LabelClusterNamespace holds the namespace of the owning Cluster
+	LabelClusterNamespace = "cluster.mini-k8s.io/cluster-namespace"
+
+	// LabelClusterUID holds the UID of the owning Cluster
+	LabelClusterUID = "cluster.mini-k8s.io/cluster-uid"
+
+	// LabelRole holds the node role (control-plane or worker)
+	LabelRole = "cluster.mini-k8s.io/role"
+
+	// LabelNetwork holds the name of the Docker network a node is attached to
+	LabelNetwork = "cluster.mini-k8s.io/network"
+
+	// LabelNodeImage holds the node image a container was created from
+	LabelNodeImage = "cluster.mini-k8s.io/node-image"
+
+	// LabelCommittedFrom marks images committed from a node container to
+	// create another node from, and holds the ID of that container
+	LabelCommittedFrom = "cluster.mini-k8s.io/committed-from"
+
+	// ManagerName is the value of LabelManagedBy
+	ManagerName = "mini-k8s-manager"
+)
+
+// Labels written by releases that predated namespace-qualified labels
+const (
+	legacyLabelCluster = "cluster"
+	legacyLabelRole    = "role"
+)
+
+// Node roles
+const (
+	RoleControlPlane = "control-plane"
+	RoleWorker       = "worker"
+)
+
+// clusterHash returns a short, stable hash of the cluster's namespace and name
+func clusterHash(cluster *v1alpha1.Cluster) string {
+	sum := sha256.Sum256([]byte(cluster.Namespace + "/" + cluster.Name))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// clusterResourcePrefix returns the prefix shared by all Docker resource names of a cluster.
+// The hash keeps clusters with the same name in different namespaces apart.
+func clusterResourcePrefix(cluster *v1alpha1.Cluster) string {
+	return fmt.Sprintf("cluster-%s-%s", cluster.Name, clusterHash(cluster))
+}
+
+// clusterLabels returns the labels identifying resources owned by the cluster
+func clusterLabels(cluster *v1alpha1.Cluster) map[string]string {
+	labels := map[string]string{
+		LabelManagedBy:        ManagerName,
+		LabelClusterName:      cluster.Name,
+		LabelClusterNamespace: cluster.Namespace,
+	}
+	if cluster.UID != "" {
+		labels[LabelClusterUID] = string(cluster.UID)
+	}
+	return labels
+}
+
+// nodeLabels returns the labels for a node container of the cluster
+func nodeLabels(cluster *v1alpha1.Cluster, role, networkName string) map[string]string {
+	labels := clusterLabels(cluster)
+	labels[LabelRole] = role
+	labels[LabelNetwork] = networkName
+	return labels
+}
+
+// clusterFilters returns Docker filters selecting resources owned by the cluster.
+// The UID is recorded but not filtered on, so the resources stay reachable if the
+// Cluster object is recreated under the same name.
+func clusterFilters(cluster *v1alpha1.Cluster) filters.Args {
+	args := filters.NewArgs()
+	args.Add("label", fmt.Sprintf("%s=%s", LabelManagedBy, ManagerName))
+	args.Add("label", fmt.Sprintf("%s=%s", LabelClusterNamespace, cluster.Namespace))
+	args.Add("label", fmt.Sprintf("%s=%s", LabelClusterName, cluster.Name))
+	return args
+}
+
+// legacyClusterFilters returns Docker filters matching containers created before
+// namespace-qualified labels were introduced
+func legacyClusterFilters(cluster *v1alpha1.Cluster) filters.Args {
+	return filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", legacyLabelCluster, cluster.Name)))
+}
+
+// isLegacyNode reports whether a container matched by legacyClusterFilters was
+// really created by an older release for this cluster, rather than being an
+// unrelated container that happens to carry a "cluster" label
+func isLegacyNode(cluster *v1alpha1.Cluster, names []string, labels map[string]string) bool {
+	if _, managed := labels[LabelManagedBy]; managed {
+		return false
+	}
+	if labels[legacyLabelCluster] != cluster.Name {
+		return false
+	}
+	role := labels[legacyLabelRole]
+	if role != RoleControlPlane && role != RoleWorker {
+		return false
+	}
+	prefix := fmt.Sprintf("/cluster-%s-%s-", cluster.Name, role)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeRole returns the role of a node container, understanding both label schemes
+func nodeRole(labels map[string]string) string {
+	if role, ok := labels[LabelRole]; ok {
+		return role
+	}
+	return labels[legacyLabelRole]
+}
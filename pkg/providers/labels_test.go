@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourceNamesAreNamespaceQualified(t *testing.T) {
+	provider := &DockerProvider{BaseProvider: &BaseProvider{}}
+
+	clusterA := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "team-a"}}
+	clusterB := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "team-b"}}
+
+	if provider.getNodeName(clusterA, RoleWorker, 0) == provider.getNodeName(clusterB, RoleWorker, 0) {
+		t.Error("Clusters with the same name in different namespaces should get different node names")
+	}
+	if provider.getClusterNetworkName(clusterA) == provider.getClusterNetworkName(clusterB) {
+		t.Error("Clusters with the same name in different namespaces should get different network names")
+	}
+
+	name := provider.getNodeName(clusterA, RoleControlPlane, 1)
+	if !strings.HasPrefix(name, "cluster-dev-") || !strings.HasSuffix(name, "-control-plane-1") {
+		t.Errorf("Unexpected node name %s", name)
+	}
+	if name != provider.getNodeName(clusterA, RoleControlPlane, 1) {
+		t.Error("Node names should be stable")
+	}
+}
+
+func TestClusterLabelsAndFilters(t *testing.T) {
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "team-a", UID: "1234"}}
+
+	labels := nodeLabels(cluster, RoleWorker, "cluster-dev-net")
+	expected := map[string]string{
+		LabelManagedBy:        ManagerName,
+		LabelClusterName:      "dev",
+		LabelClusterNamespace: "team-a",
+		LabelClusterUID:       "1234",
+		LabelRole:             RoleWorker,
+		LabelNetwork:          "cluster-dev-net",
+	}
+	for k, v := range expected {
+		if labels[k] != v {
+			t.Errorf("Expected label %s=%s, got %q", k, v, labels[k])
+		}
+	}
+
+	args := clusterFilters(cluster)
+	for _, label := range []string{
+		LabelManagedBy + "=" + ManagerName,
+		LabelClusterNamespace + "=team-a",
+		LabelClusterName + "=dev",
+	} {
+		if !args.ExactMatch("label", label) {
+			t.Errorf("Expected filter on label %s", label)
+		}
+	}
+}
+
+func TestIsLegacyNode(t *testing.T) {
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+
+	tests := []struct {
+		name   string
+		names  []string
+		labels map[string]string
+		want   bool
+	}{
+		{
+			name:   "node created by an earlier release",
+			names:  []string{"/cluster-foo-worker-0"},
+			labels: map[string]string{"cluster": "foo", "role": "worker"},
+			want:   true,
+		},
+		{
+			name:   "unrelated container with a cluster label",
+			names:  []string{"/postgres"},
+			labels: map[string]string{"cluster": "foo"},
+			want:   false,
+		},
+		{
+			name:   "unrelated container with a role label",
+			names:  []string{"/web"},
+			labels: map[string]string{"cluster": "foo", "role": "worker"},
+			want:   false,
+		},
+		{
+			name:   "already migrated node",
+			names:  []string{"/cluster-foo-worker-0"},
+			labels: map[string]string{"cluster": "foo", "role": "worker", LabelManagedBy: ManagerName},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLegacyNode(cluster, tt.names, tt.labels); got != tt.want {
+				t.Errorf("isLegacyNode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyNodeIndex(t *testing.T) {
+	index, err := legacyNodeIndex([]string{"/cluster-foo-worker-12"}, "foo", RoleWorker)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if index != 12 {
+		t.Errorf("Expected index 12, got %d", index)
+	}
+
+	if _, err := legacyNodeIndex([]string{"/something-else"}, "foo", RoleWorker); err == nil {
+		t.Error("Expected an error for a name without an index")
+	}
+}
@@ -0,0 +1,36 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// Providers log through the logr.Logger carried by the context, so their output
+// goes wherever the caller's logger goes and, under a controller, is tagged with
+// the reconcile request. Verbosity levels:
+//
+//	0: cluster and node lifecycle milestones
+//	1: individual steps (image pulls, networks, migrations)
+//	2: single Docker operations on a container
+//
+// Failures are returned rather than logged; the caller decides how to report them.
+
+// clusterLogger returns the context's logger annotated with the cluster
+func clusterLogger(ctx context.Context, cluster *v1alpha1.Cluster) logr.Logger {
+	return log.FromContext(ctx).WithName("docker-provider").WithValues(
+		"cluster", cluster.Name,
+		"namespace", cluster.Namespace,
+	)
+}
+
+// shortID abbreviates a Docker object ID the way the docker CLI prints it
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
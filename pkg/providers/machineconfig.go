@@ -0,0 +1,449 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// resourceDrift is a node whose container resources differ from what its
+// MachineConfig asks for
+type resourceDrift struct {
+	summary container.Summary
+	current container.Resources
+	want    container.Resources
+}
+
+// rolloutStep is one round of replacing outdated workers
+type rolloutStep struct {
+	// create holds the names of the up-to-date workers to create
+	create []string
+
+	// remove holds the outdated workers to drain and remove
+	remove []container.Summary
+}
+
+// empty reports whether the step changes nothing
+func (s rolloutStep) empty() bool {
+	return len(s.create) == 0 && len(s.remove) == 0
+}
+
+// nodeResources returns the container resources of a node with machineConfig
+func (p *DockerProvider) nodeResources(machineConfig v1alpha1.MachineConfig) container.Resources {
+	return container.Resources{
+		Memory:   p.parseMemory(machineConfig.Memory),
+		NanoCPUs: int64(machineConfig.CPUCount) * 1e9,
+	}
+}
+
+// updateMachineConfig brings the resources of the cluster's nodes in line
+// with their MachineConfig. Docker changes the limits of existing containers
+// in place where it can; nodes it cannot change are replaced: control plane
+// nodes one at a time, workers as their RolloutStrategy allows. Which nodes
+// are outdated is read off their containers, so a rollout interrupted by a
+// manager restart carries on where it stopped.
+func (p *DockerProvider) updateMachineConfig(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	drifts, err := p.resourceDrifts(ctx, cluster, containers)
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		return nil
+	}
+	clusterLogger(ctx, cluster).Info("Updating node resources", "nodes", len(drifts))
+
+	replace := map[string]bool{}
+	for _, drift := range drifts {
+		if err := p.resizeNode(ctx, cluster, drift); err != nil {
+			replace[nodeRole(drift.summary.Labels)] = true
+		}
+	}
+	if replace[RoleControlPlane] {
+		if err := p.rollControlPlane(ctx, cluster); err != nil {
+			return err
+		}
+	}
+	if replace[RoleWorker] {
+		return p.rollWorkers(ctx, cluster)
+	}
+	return nil
+}
+
+// resourceDrifts returns the control plane and worker nodes whose resources
+// differ from their role's MachineConfig
+func (p *DockerProvider) resourceDrifts(ctx context.Context, cluster *v1alpha1.Cluster, containers []container.Summary) ([]resourceDrift, error) {
+	var drifts []resourceDrift
+	for _, cont := range containers {
+		role := nodeRole(cont.Labels)
+		if role != RoleControlPlane && role != RoleWorker {
+			continue
+		}
+		info, err := p.client.ContainerInspect(ctx, cont.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %s: %w", cont.ID, err)
+		}
+		if info.ContainerJSONBase == nil || info.HostConfig == nil {
+			continue
+		}
+		current := info.HostConfig.Resources
+		want := p.nodeResources(machineConfigFor(&cluster.Spec, role))
+		if current.Memory != want.Memory || nanoCPUs(current) != want.NanoCPUs {
+			drifts = append(drifts, resourceDrift{summary: cont, current: current, want: want})
+		}
+	}
+	return drifts, nil
+}
+
+// resizeNode changes the resource limits of a node container in place. It
+// fails for changes Docker cannot make to an existing container, which leave
+// the node to be replaced.
+func (p *DockerProvider) resizeNode(ctx context.Context, cluster *v1alpha1.Cluster, drift resourceDrift) error {
+	name, role := containerName(drift.summary), nodeRole(drift.summary.Labels)
+	log := clusterLogger(ctx, cluster).WithValues("node", name, "step", "resize")
+	machineConfig := machineConfigFor(&cluster.Spec, role)
+
+	err := resizableInPlace(drift.current, drift.want)
+	if err == nil {
+		update := container.UpdateConfig{Resources: cpuLimit(drift.current, drift.want.NanoCPUs)}
+		update.Resources.Memory = drift.want.Memory
+		// Containers created with a memory limit get as much swap again, and
+		// Docker refuses a memory limit above the swap limit
+		if drift.want.Memory > 0 {
+			update.Resources.MemorySwap = 2 * drift.want.Memory
+		}
+		_, err = p.client.ContainerUpdate(ctx, drift.summary.ID, update)
+	}
+	if err != nil {
+		log.Info("Cannot resize node in place, replacing it", "reason", err.Error())
+		recordWarning(ctx, cluster, ReasonNodeResizeFailed, "Cannot resize %s node %s to %s in place, replacing it: %v", role, name, describeMachineConfig(machineConfig), err)
+		return err
+	}
+	log.Info("Resized node", "memory", drift.want.Memory, "nanoCPUs", drift.want.NanoCPUs)
+	recordNormal(ctx, cluster, ReasonNodeResized, "Resized %s node %s to %s", role, name, describeMachineConfig(machineConfig))
+	return nil
+}
+
+// resizableInPlace reports why Docker cannot change a container's limits from
+// current to want. Docker leaves a limit it is asked to set to 0 alone, so
+// limits can be changed but not lifted.
+func resizableInPlace(current, want container.Resources) error {
+	if want.Memory == 0 && current.Memory != 0 {
+		return fmt.Errorf("the memory limit cannot be lifted from a running container")
+	}
+	if want.NanoCPUs == 0 && nanoCPUs(current) != 0 {
+		return fmt.Errorf("the CPU limit cannot be lifted from a running container")
+	}
+	return nil
+}
+
+// nanoCPUs returns the CPU limit of a container in billionths of a CPU,
+// whether it was set as NanoCPUs or, as by older releases, as a CPU quota per
+// period
+func nanoCPUs(resources container.Resources) int64 {
+	if resources.NanoCPUs != 0 || resources.CPUQuota <= 0 || resources.CPUPeriod <= 0 {
+		return resources.NanoCPUs
+	}
+	return resources.CPUQuota * 1e9 / resources.CPUPeriod
+}
+
+// cpuLimit returns the resources that set the CPU limit of a container with
+// current resources to nano, in billionths of a CPU. Docker does not let a
+// container switch between NanoCPUs and a CPU quota, so the limit is set the
+// way the container's was.
+func cpuLimit(current container.Resources, nano int64) container.Resources {
+	if current.NanoCPUs == 0 && current.CPUQuota > 0 && current.CPUPeriod > 0 {
+		return container.Resources{CPUQuota: nano * current.CPUPeriod / 1e9, CPUPeriod: current.CPUPeriod}
+	}
+	return container.Resources{NanoCPUs: nano}
+}
+
+// rollWorkers replaces the outdated workers in rounds planned by
+// planRolloutStep until none is left. Replacements of a cluster bootstrapped
+// with kubeadm join it; outdated workers are drained before they are removed.
+func (p *DockerProvider) rollWorkers(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	desired := int(cluster.Spec.Workers.Count)
+	maxSurge, maxUnavailable := rolloutLimits(cluster.Spec.Workers.RolloutStrategy, desired)
+	log := clusterLogger(ctx, cluster)
+	log.Info("Replacing outdated workers", "maxSurge", maxSurge, "maxUnavailable", maxUnavailable)
+
+	// Pull the node image once for all replacements
+	if err := p.images.ensureImage(ctx, cluster, p.getNodeImage(cluster), nodeImagePolicy(cluster)); err != nil {
+		return err
+	}
+	for {
+		containers, err := p.listClusterContainers(ctx, cluster)
+		if err != nil {
+			return err
+		}
+		drifts, err := p.resourceDrifts(ctx, cluster, containers)
+		if err != nil {
+			return err
+		}
+		outdated := map[string]bool{}
+		for _, drift := range drifts {
+			if nodeRole(drift.summary.Labels) == RoleWorker {
+				outdated[containerName(drift.summary)] = true
+			}
+		}
+		if len(outdated) == 0 {
+			log.Info("Replaced outdated workers")
+			return nil
+		}
+
+		step := p.planRolloutStep(cluster, containers, outdated, desired, maxSurge, maxUnavailable)
+		if step.empty() {
+			return fmt.Errorf("cannot replace %d outdated workers without going beyond maxSurge %d or maxUnavailable %d", len(outdated), maxSurge, maxUnavailable)
+		}
+		log.V(1).Info("Replacing workers", "outdated", len(outdated), "create", len(step.create), "remove", len(step.remove))
+
+		if len(step.create) > 0 {
+			// Each round gets a new join token, as draining can outlast one
+			joinCommand, err := p.workerJoinCommand(ctx, cluster)
+			if err != nil {
+				return err
+			}
+			clusterNetwork, err := p.requireClusterNetwork(ctx, cluster)
+			if err != nil {
+				return err
+			}
+			if err := p.createWorkers(ctx, cluster, clusterNetwork, step.create); err != nil {
+				return err
+			}
+			if joinCommand != nil {
+				if err := p.runNodeOperations(ctx, "join", step.create, func(ctx context.Context, name string) error {
+					return p.joinNode(ctx, cluster, name, joinCommand)
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := p.drainNodes(ctx, cluster, step.remove); err != nil {
+			return err
+		}
+		if err := p.removeNodes(ctx, cluster, step.remove, 60); err != nil {
+			return err
+		}
+	}
+}
+
+// workerJoinCommand returns the kubeadm join command new workers of a
+// cluster bootstrapped with kubeadm join it with, or nil for other clusters
+func (p *DockerProvider) workerJoinCommand(ctx context.Context, cluster *v1alpha1.Cluster) ([]string, error) {
+	controlPlane, err := p.etcdNode(ctx, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("cannot replace workers: %w", err)
+	}
+	if !p.bootstrapped(ctx, controlPlane.ID) {
+		return nil, nil
+	}
+	return p.kubeadmJoinCommand(ctx, controlPlane.ID)
+}
+
+// planRolloutStep works out the next round of replacing the outdated workers
+// among containers. Up-to-date workers are created as long as there are no
+// more than desired+maxSurge workers in all and no more than desired
+// up-to-date ones. Outdated workers that are not running are removed right
+// away, running ones as long as desired-maxUnavailable workers keep running.
+// New workers take the lowest indices no worker holds.
+func (p *DockerProvider) planRolloutStep(cluster *v1alpha1.Cluster, containers []container.Summary, outdated map[string]bool, desired, maxSurge, maxUnavailable int) rolloutStep {
+	var workers, old []container.Summary
+	used := map[int]bool{}
+	running, upToDate := 0, 0
+	for _, cont := range containers {
+		if nodeRole(cont.Labels) != RoleWorker {
+			continue
+		}
+		workers = append(workers, cont)
+		if index, ok := p.nodeIndex(cluster, RoleWorker, containerName(cont)); ok {
+			used[index] = true
+		}
+		if cont.State == "running" {
+			running++
+		}
+		if outdated[containerName(cont)] {
+			old = append(old, cont)
+		} else {
+			upToDate++
+		}
+	}
+
+	var step rolloutStep
+	create := min(desired+maxSurge-len(workers), desired-upToDate)
+	for index := 0; len(step.create) < create; index++ {
+		if !used[index] {
+			step.create = append(step.create, p.getNodeName(cluster, RoleWorker, index))
+		}
+	}
+
+	removalOrder(old)
+	budget := running - (desired - maxUnavailable)
+	for _, cont := range old {
+		if cont.State != "running" {
+			step.remove = append(step.remove, cont)
+		} else if budget > 0 {
+			step.remove = append(step.remove, cont)
+			budget--
+		}
+	}
+	return step
+}
+
+// rolloutLimits resolves a worker rollout strategy against the desired number
+// of workers. Percentages of surge round up and of unavailability down; if
+// both come to 0 one worker at a time is replaced without surge.
+func rolloutLimits(strategy *v1alpha1.RolloutStrategy, desired int) (maxSurge, maxUnavailable int) {
+	surge := intstr.FromInt32(v1alpha1.DefaultMaxSurge)
+	unavailable := intstr.FromInt32(v1alpha1.DefaultMaxUnavailable)
+	if strategy != nil {
+		if strategy.MaxSurge != nil {
+			surge = *strategy.MaxSurge
+		}
+		if strategy.MaxUnavailable != nil {
+			unavailable = *strategy.MaxUnavailable
+		}
+	}
+	maxSurge, _ = intstr.GetScaledValueFromIntOrPercent(&surge, desired, true)
+	maxUnavailable, _ = intstr.GetScaledValueFromIntOrPercent(&unavailable, desired, false)
+	maxSurge, maxUnavailable = max(maxSurge, 0), max(maxUnavailable, 0)
+	if maxSurge == 0 && maxUnavailable == 0 {
+		maxUnavailable = 1
+	}
+	return maxSurge, maxUnavailable
+}
+
+// rollControlPlane replaces the outdated control plane nodes one at a time.
+// In a cluster bootstrapped with kubeadm a new node joins before an outdated
+// one is removed, so etcd keeps at least the desired number of members
+// throughout; other clusters have their outdated nodes recreated in place.
+func (p *DockerProvider) rollControlPlane(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	desired := int(cluster.Spec.ControlPlane.Count)
+	log := clusterLogger(ctx, cluster)
+	log.Info("Replacing outdated control plane nodes")
+
+	// Pull the node image once for all replacements
+	if err := p.images.ensureImage(ctx, cluster, p.getNodeImage(cluster), nodeImagePolicy(cluster)); err != nil {
+		return err
+	}
+	for {
+		containers, err := p.listClusterContainers(ctx, cluster)
+		if err != nil {
+			return err
+		}
+		drifts, err := p.resourceDrifts(ctx, cluster, containers)
+		if err != nil {
+			return err
+		}
+		outdated := map[string]bool{}
+		for _, drift := range drifts {
+			if nodeRole(drift.summary.Labels) == RoleControlPlane {
+				outdated[containerName(drift.summary)] = true
+			}
+		}
+		if len(outdated) == 0 {
+			log.Info("Replaced outdated control plane nodes")
+			return nil
+		}
+
+		nodes, admin, err := p.inspectControlPlane(ctx, cluster, containers)
+		if err != nil {
+			return err
+		}
+		if admin == nil {
+			for _, node := range nodes {
+				name := containerName(node.summary)
+				if !outdated[name] {
+					continue
+				}
+				if err := p.removeNode(ctx, cluster, node.summary, 60); err != nil {
+					return err
+				}
+				if err := p.createControlPlaneContainer(ctx, cluster, name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if len(nodes) <= desired {
+			name := p.freeControlPlaneName(cluster, nodes)
+			log.V(1).Info("Adding control plane node to replace an outdated one", "node", name)
+			if err := p.createControlPlaneContainer(ctx, cluster, name); err != nil {
+				return err
+			}
+			if err := p.joinControlPlaneNode(ctx, cluster, *admin, name, name); err != nil {
+				return err
+			}
+		} else {
+			// Manage the cluster through an up-to-date node, so that every
+			// outdated one can be removed
+			if outdated[containerName(*admin)] {
+				for _, node := range nodes {
+					if !outdated[containerName(node.summary)] && node.summary.State == "running" && node.joined && node.member && p.bootstrapped(ctx, node.summary.ID) {
+						current := node.summary
+						admin = &current
+						break
+					}
+				}
+			}
+			var replaced *controlPlaneNode
+			for i := len(nodes) - 1; i >= 0; i-- {
+				if outdated[containerName(nodes[i].summary)] && nodes[i].summary.ID != admin.ID {
+					replaced = &nodes[i]
+					break
+				}
+			}
+			if replaced == nil {
+				return fmt.Errorf("no control plane node to manage cluster %s through while %s is replaced", cluster.Name, containerName(*admin))
+			}
+			if err := p.removeControlPlaneNode(ctx, cluster, *admin, *replaced); err != nil {
+				return err
+			}
+		}
+		if err := p.updateLoadBalancer(ctx, cluster); err != nil {
+			return err
+		}
+	}
+}
+
+// freeControlPlaneName returns the name of a new control plane node with the
+// lowest index no node holds
+func (p *DockerProvider) freeControlPlaneName(cluster *v1alpha1.Cluster, nodes []controlPlaneNode) string {
+	used := map[int]bool{}
+	for _, node := range nodes {
+		if index, ok := p.nodeIndex(cluster, RoleControlPlane, containerName(node.summary)); ok {
+			used[index] = true
+		}
+	}
+	index := 0
+	for used[index] {
+		index++
+	}
+	return p.getNodeName(cluster, RoleControlPlane, index)
+}
+
+// describeMachineConfig returns a short description of a machine
+// configuration for events
+func describeMachineConfig(config v1alpha1.MachineConfig) string {
+	var parts []string
+	if config.Memory != "" {
+		parts = append(parts, config.Memory+" memory")
+	} else {
+		parts = append(parts, "unlimited memory")
+	}
+	if config.CPUCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d CPUs", config.CPUCount))
+	} else {
+		parts = append(parts, "unlimited CPUs")
+	}
+	return strings.Join(parts, " and ")
+}
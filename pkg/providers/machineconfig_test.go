@@ -0,0 +1,261 @@
+package providers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+func TestPlanRolloutStep(t *testing.T) {
+	p := &DockerProvider{}
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "roll", Namespace: "default"}}
+	name := func(index int) string { return p.getNodeName(cluster, RoleWorker, index) }
+	worker := func(index int, state string) container.Summary {
+		return container.Summary{
+			Names:   []string{"/" + name(index)},
+			Labels:  map[string]string{LabelRole: RoleWorker},
+			State:   state,
+			Created: int64(index),
+		}
+	}
+	controlPlane := container.Summary{
+		Names:  []string{"/" + p.getNodeName(cluster, RoleControlPlane, 0)},
+		Labels: map[string]string{LabelRole: RoleControlPlane},
+		State:  "running",
+	}
+	outdated := func(indices ...int) map[string]bool {
+		names := map[string]bool{}
+		for _, index := range indices {
+			names[name(index)] = true
+		}
+		return names
+	}
+	names := func(containers []container.Summary) []string {
+		var names []string
+		for _, cont := range containers {
+			names = append(names, containerName(cont))
+		}
+		return names
+	}
+
+	tests := []struct {
+		name           string
+		workers        []container.Summary
+		outdated       map[string]bool
+		desired        int
+		maxSurge       int
+		maxUnavailable int
+		create         []string
+		remove         []string
+	}{
+		{
+			name:     "surge creates a worker first",
+			workers:  []container.Summary{worker(0, "running"), worker(1, "running"), worker(2, "running")},
+			outdated: outdated(0, 1, 2),
+			desired:  3,
+			maxSurge: 1,
+			create:   []string{name(3)},
+		},
+		{
+			name:     "surge removes an outdated worker once its replacement is up",
+			workers:  []container.Summary{worker(0, "running"), worker(1, "running"), worker(2, "running"), worker(3, "running")},
+			outdated: outdated(0, 1, 2),
+			desired:  3,
+			maxSurge: 1,
+			remove:   []string{name(2)},
+		},
+		{
+			name:     "replacements take the index freed by a removed worker",
+			workers:  []container.Summary{worker(0, "running"), worker(1, "running"), worker(3, "running")},
+			outdated: outdated(0, 1),
+			desired:  3,
+			maxSurge: 1,
+			create:   []string{name(2)},
+		},
+		{
+			name:           "unavailability removes before creating",
+			workers:        []container.Summary{worker(0, "running"), worker(1, "running"), worker(2, "running")},
+			outdated:       outdated(0, 1, 2),
+			desired:        3,
+			maxUnavailable: 2,
+			remove:         []string{name(2), name(1)},
+		},
+		{
+			name:           "surge and unavailability together",
+			workers:        []container.Summary{worker(0, "running"), worker(1, "running"), worker(2, "running"), worker(3, "running")},
+			outdated:       outdated(0, 1, 2, 3),
+			desired:        4,
+			maxSurge:       2,
+			maxUnavailable: 1,
+			create:         []string{name(4), name(5)},
+			remove:         []string{name(3)},
+		},
+		{
+			name:     "stopped outdated workers are removed right away",
+			workers:  []container.Summary{worker(0, "running"), worker(1, "exited"), worker(2, "running"), worker(3, "running")},
+			outdated: outdated(0, 1),
+			desired:  3,
+			maxSurge: 1,
+			remove:   []string{name(1)},
+		},
+		{
+			name:     "stuck when a replacement is not running",
+			workers:  []container.Summary{worker(0, "running"), worker(1, "running"), worker(2, "exited")},
+			outdated: outdated(0, 1),
+			desired:  2,
+			maxSurge: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containers := append([]container.Summary{controlPlane}, tt.workers...)
+			step := p.planRolloutStep(cluster, containers, tt.outdated, tt.desired, tt.maxSurge, tt.maxUnavailable)
+			if !reflect.DeepEqual(step.create, tt.create) {
+				t.Errorf("Expected to create %v, got %v", tt.create, step.create)
+			}
+			if !reflect.DeepEqual(names(step.remove), tt.remove) {
+				t.Errorf("Expected to remove %v, got %v", tt.remove, names(step.remove))
+			}
+		})
+	}
+}
+
+func TestRolloutLimits(t *testing.T) {
+	value := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	tests := []struct {
+		name           string
+		strategy       *v1alpha1.RolloutStrategy
+		desired        int
+		maxSurge       int
+		maxUnavailable int
+	}{
+		{"defaults", nil, 3, v1alpha1.DefaultMaxSurge, v1alpha1.DefaultMaxUnavailable},
+		{"numbers", &v1alpha1.RolloutStrategy{MaxSurge: value(intstr.FromInt32(2)), MaxUnavailable: value(intstr.FromInt32(1))}, 3, 2, 1},
+		{"percentages", &v1alpha1.RolloutStrategy{MaxSurge: value(intstr.FromString("25%")), MaxUnavailable: value(intstr.FromString("25%"))}, 10, 3, 2},
+		{"neither", &v1alpha1.RolloutStrategy{MaxSurge: value(intstr.FromInt32(0))}, 3, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSurge, maxUnavailable := rolloutLimits(tt.strategy, tt.desired)
+			if maxSurge != tt.maxSurge || maxUnavailable != tt.maxUnavailable {
+				t.Errorf("Expected surge %d and unavailability %d, got %d and %d", tt.maxSurge, tt.maxUnavailable, maxSurge, maxUnavailable)
+			}
+		})
+	}
+}
+
+func TestResizableInPlace(t *testing.T) {
+	p := &DockerProvider{}
+	small := p.nodeResources(v1alpha1.MachineConfig{Memory: "1Gi", CPUCount: 1})
+	large := p.nodeResources(v1alpha1.MachineConfig{Memory: "4Gi", CPUCount: 4})
+	unlimited := p.nodeResources(v1alpha1.MachineConfig{})
+
+	if large.NanoCPUs != 4e9 {
+		t.Errorf("Expected 4 CPUs to be 4e9 nanoCPUs, got %d", large.NanoCPUs)
+	}
+	if err := resizableInPlace(small, large); err != nil {
+		t.Errorf("Expected limits to be raised in place, got %v", err)
+	}
+	if err := resizableInPlace(large, small); err != nil {
+		t.Errorf("Expected limits to be lowered in place, got %v", err)
+	}
+	if err := resizableInPlace(unlimited, small); err != nil {
+		t.Errorf("Expected limits to be set in place, got %v", err)
+	}
+	if err := resizableInPlace(small, unlimited); err == nil {
+		t.Error("Expected lifting limits to need a new container")
+	}
+}
+
+func TestUpdateClusterResizesNodesInPlace(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	cluster := newFakeCluster("resize", 2)
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	created := engine.Calls("ContainerCreate")
+
+	cluster.Spec.Workers.MachineConfig = v1alpha1.MachineConfig{Memory: "4Gi", CPUCount: 4}
+	if err := provider.UpdateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+
+	if calls := engine.Calls("ContainerCreate"); calls != created {
+		t.Errorf("Expected workers to be resized without new containers, got %d creates", calls-created)
+	}
+	want := provider.nodeResources(cluster.Spec.Workers.MachineConfig)
+	for i := 0; i < 2; i++ {
+		info, err := engine.ContainerInspect(ctx, provider.getNodeName(cluster, RoleWorker, i))
+		if err != nil {
+			t.Fatalf("Failed to inspect worker %d: %v", i, err)
+		}
+		if info.HostConfig.Memory != want.Memory || info.HostConfig.NanoCPUs != want.NanoCPUs {
+			t.Errorf("Expected worker %d to have %d bytes and %d nanoCPUs, got %d and %d", i, want.Memory, want.NanoCPUs, info.HostConfig.Memory, info.HostConfig.NanoCPUs)
+		}
+		if info.HostConfig.MemorySwap != 2*want.Memory {
+			t.Errorf("Expected worker %d swap limit to follow its memory, got %d", i, info.HostConfig.MemorySwap)
+		}
+	}
+	info, err := engine.ContainerInspect(ctx, provider.getNodeName(cluster, RoleControlPlane, 0))
+	if err != nil {
+		t.Fatalf("Failed to inspect control plane: %v", err)
+	}
+	if info.HostConfig.NanoCPUs != 2e9 {
+		t.Errorf("Expected the control plane to keep 2 CPUs, got %d nanoCPUs", info.HostConfig.NanoCPUs)
+	}
+}
+
+func TestUpdateClusterResizesNodesWithCPUQuota(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	cluster := newFakeCluster("quota", 1)
+	cluster.Spec.Workers.MachineConfig.CPUCount = 0
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	// Workers created by older releases have their CPUs as a quota per period
+	worker := provider.getNodeName(cluster, RoleWorker, 0)
+	update := container.UpdateConfig{Resources: container.Resources{CPUQuota: 200000, CPUPeriod: 100000}}
+	if _, err := engine.ContainerUpdate(ctx, worker, update); err != nil {
+		t.Fatalf("Failed to set CPU quota: %v", err)
+	}
+
+	cluster.Spec.Workers.MachineConfig.CPUCount = 2
+	containers, err := provider.listClusterContainers(ctx, cluster)
+	if err != nil {
+		t.Fatalf("Failed to list containers: %v", err)
+	}
+	drifts, err := provider.resourceDrifts(ctx, cluster, containers)
+	if err != nil {
+		t.Fatalf("Failed to find resource drifts: %v", err)
+	}
+	if len(drifts) != 0 {
+		t.Errorf("Expected a quota of 2 CPUs to match 2 CPUs, got %d drifts", len(drifts))
+	}
+
+	cluster.Spec.Workers.MachineConfig.CPUCount = 4
+	created := engine.Calls("ContainerCreate")
+	if err := provider.UpdateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+	if calls := engine.Calls("ContainerCreate"); calls != created {
+		t.Errorf("Expected the worker to be resized without a new container, got %d creates", calls-created)
+	}
+	info, err := engine.ContainerInspect(ctx, worker)
+	if err != nil {
+		t.Fatalf("Failed to inspect worker: %v", err)
+	}
+	if info.HostConfig.CPUQuota != 400000 || info.HostConfig.CPUPeriod != 100000 || info.HostConfig.NanoCPUs != 0 {
+		t.Errorf("Expected a quota of 400000 per 100000, got %d per %d and %d nanoCPUs", info.HostConfig.CPUQuota, info.HostConfig.CPUPeriod, info.HostConfig.NanoCPUs)
+	}
+}
@@ -12,6 +12,18 @@ type MockProvider struct {
 	DeleteClusterFunc    func(ctx context.Context, cluster *v1alpha1.Cluster) error
 	GetClusterStatusFunc func(ctx context.Context, cluster *v1alpha1.Cluster) (*v1alpha1.ClusterStatus, error)
 	UpdateClusterFunc    func(ctx context.Context, cluster *v1alpha1.Cluster) error
+	AdoptClusterFunc     func(ctx context.Context, cluster *v1alpha1.Cluster, source string) (*v1alpha1.ClusterSpec, error)
+	RollbackUpgradeFunc  func(ctx context.Context, cluster *v1alpha1.Cluster, upgrade *v1alpha1.UpgradeStatus) error
+	SnapshotEtcdFunc     func(ctx context.Context, cluster *v1alpha1.Cluster) (string, error)
+	SuspendClusterFunc   func(ctx context.Context, cluster *v1alpha1.Cluster) error
+	ResumeClusterFunc    func(ctx context.Context, cluster *v1alpha1.Cluster) error
+	BackupEtcdFunc       func(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation) (string, error)
+	DeleteEtcdBackupFunc func(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error
+	RestoreEtcdFunc      func(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error
+	CloneClusterFunc     func(ctx context.Context, cluster, source *v1alpha1.Cluster) error
+	NodeHealthFunc       func(ctx context.Context, cluster *v1alpha1.Cluster) ([]NodeHealth, error)
+	RemediateNodeFunc    func(ctx context.Context, cluster *v1alpha1.Cluster, node string, action v1alpha1.RemediationAction) (v1alpha1.RemediationAction, error)
+	KubeconfigFunc       func(ctx context.Context, cluster *v1alpha1.Cluster) ([]byte, error)
 }
 
 func (m *MockProvider) CreateCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
@@ -45,3 +57,89 @@ func (m *MockProvider) UpdateCluster(ctx context.Context, cluster *v1alpha1.Clus
 	}
 	return nil
 }
+
+func (m *MockProvider) AdoptCluster(ctx context.Context, cluster *v1alpha1.Cluster, source string) (*v1alpha1.ClusterSpec, error) {
+	if m.AdoptClusterFunc != nil {
+		return m.AdoptClusterFunc(ctx, cluster, source)
+	}
+	spec := &v1alpha1.ClusterSpec{}
+	cluster.Spec.DeepCopyInto(spec)
+	return spec, nil
+}
+
+func (m *MockProvider) RollbackUpgrade(ctx context.Context, cluster *v1alpha1.Cluster, upgrade *v1alpha1.UpgradeStatus) error {
+	if m.RollbackUpgradeFunc != nil {
+		return m.RollbackUpgradeFunc(ctx, cluster, upgrade)
+	}
+	return nil
+}
+
+func (m *MockProvider) SnapshotEtcd(ctx context.Context, cluster *v1alpha1.Cluster) (string, error) {
+	if m.SnapshotEtcdFunc != nil {
+		return m.SnapshotEtcdFunc(ctx, cluster)
+	}
+	return "", nil
+}
+
+func (m *MockProvider) SuspendCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	if m.SuspendClusterFunc != nil {
+		return m.SuspendClusterFunc(ctx, cluster)
+	}
+	return nil
+}
+
+func (m *MockProvider) ResumeCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	if m.ResumeClusterFunc != nil {
+		return m.ResumeClusterFunc(ctx, cluster)
+	}
+	return nil
+}
+
+func (m *MockProvider) BackupEtcd(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation) (string, error) {
+	if m.BackupEtcdFunc != nil {
+		return m.BackupEtcdFunc(ctx, cluster, location)
+	}
+	return "", nil
+}
+
+func (m *MockProvider) DeleteEtcdBackup(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error {
+	if m.DeleteEtcdBackupFunc != nil {
+		return m.DeleteEtcdBackupFunc(ctx, cluster, location, name)
+	}
+	return nil
+}
+
+func (m *MockProvider) RestoreEtcd(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error {
+	if m.RestoreEtcdFunc != nil {
+		return m.RestoreEtcdFunc(ctx, cluster, location, name)
+	}
+	return nil
+}
+
+func (m *MockProvider) CloneCluster(ctx context.Context, cluster, source *v1alpha1.Cluster) error {
+	if m.CloneClusterFunc != nil {
+		return m.CloneClusterFunc(ctx, cluster, source)
+	}
+	return nil
+}
+
+func (m *MockProvider) NodeHealth(ctx context.Context, cluster *v1alpha1.Cluster) ([]NodeHealth, error) {
+	if m.NodeHealthFunc != nil {
+		return m.NodeHealthFunc(ctx, cluster)
+	}
+	return nil, nil
+}
+
+func (m *MockProvider) RemediateNode(ctx context.Context, cluster *v1alpha1.Cluster, node string, action v1alpha1.RemediationAction) (v1alpha1.RemediationAction, error) {
+	if m.RemediateNodeFunc != nil {
+		return m.RemediateNodeFunc(ctx, cluster, node, action)
+	}
+	return action, nil
+}
+
+func (m *MockProvider) Kubeconfig(ctx context.Context, cluster *v1alpha1.Cluster) ([]byte, error) {
+	if m.KubeconfigFunc != nil {
+		return m.KubeconfigFunc(ctx, cluster)
+	}
+	return []byte("apiVersion: v1\nkind: Config\n"), nil
+}
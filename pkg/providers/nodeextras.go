@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// nodeExtras returns the extra port mappings and mounts of the nodes of role
+func nodeExtras(cluster *v1alpha1.Cluster, role string) v1alpha1.NodeExtras {
+	if role == RoleControlPlane {
+		return cluster.Spec.ControlPlane.NodeExtras
+	}
+	return cluster.Spec.Workers.NodeExtras
+}
+
+// applyNodeExtras publishes the extra ports and binds the extra mounts of
+// extras in the configuration of a node container, the way kind does
+func applyNodeExtras(extras v1alpha1.NodeExtras, config *container.Config, hostConfig *container.HostConfig) {
+	for _, mapping := range extras.ExtraPortMappings {
+		protocol := mapping.Protocol
+		if protocol == "" {
+			protocol = v1alpha1.PortProtocolTCP
+		}
+		port := nat.Port(fmt.Sprintf("%d/%s", mapping.ContainerPort, strings.ToLower(string(protocol))))
+		if config.ExposedPorts == nil {
+			config.ExposedPorts = nat.PortSet{}
+		}
+		config.ExposedPorts[port] = struct{}{}
+
+		binding := nat.PortBinding{HostIP: mapping.ListenAddress}
+		if mapping.HostPort > 0 {
+			binding.HostPort = strconv.Itoa(int(mapping.HostPort))
+		}
+		if hostConfig.PortBindings == nil {
+			hostConfig.PortBindings = nat.PortMap{}
+		}
+		hostConfig.PortBindings[port] = append(hostConfig.PortBindings[port], binding)
+	}
+
+	for _, m := range extras.ExtraMounts {
+		hostConfig.Binds = append(hostConfig.Binds, bindSpec(m))
+	}
+}
+
+// bindSpec returns the Docker bind of a mount, host:container[:options]
+func bindSpec(m v1alpha1.Mount) string {
+	var options []string
+	if m.ReadOnly {
+		options = append(options, "ro")
+	}
+	if m.SelinuxRelabel {
+		options = append(options, "Z")
+	}
+	switch m.Propagation {
+	case v1alpha1.MountPropagationHostToContainer:
+		options = append(options, "rslave")
+	case v1alpha1.MountPropagationBidirectional:
+		options = append(options, "rshared")
+	}
+	bind := m.HostPath + ":" + m.ContainerPath
+	if len(options) > 0 {
+		bind += ":" + strings.Join(options, ",")
+	}
+	return bind
+}
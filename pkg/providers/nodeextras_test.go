@@ -0,0 +1,56 @@
+package providers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+func TestApplyNodeExtras(t *testing.T) {
+	extras := v1alpha1.NodeExtras{
+		ExtraPortMappings: []v1alpha1.PortMapping{
+			{ContainerPort: 80, HostPort: 8080},
+			{ContainerPort: 53, ListenAddress: "127.0.0.1", Protocol: v1alpha1.PortProtocolUDP},
+		},
+		ExtraMounts: []v1alpha1.Mount{
+			{HostPath: "/srv/data", ContainerPath: "/data"},
+			{HostPath: "/srv/certs", ContainerPath: "/certs", ReadOnly: true, SelinuxRelabel: true},
+			{HostPath: "/mnt", ContainerPath: "/mnt", Propagation: v1alpha1.MountPropagationBidirectional},
+		},
+	}
+	config := &container.Config{}
+	hostConfig := &container.HostConfig{Binds: []string{"/lib/modules:/lib/modules:ro"}}
+	applyNodeExtras(extras, config, hostConfig)
+
+	expectedPorts := nat.PortSet{"80/tcp": {}, "53/udp": {}}
+	if !reflect.DeepEqual(config.ExposedPorts, expectedPorts) {
+		t.Errorf("Expected exposed ports %v, got %v", expectedPorts, config.ExposedPorts)
+	}
+	expectedBindings := nat.PortMap{
+		"80/tcp": {{HostPort: "8080"}},
+		"53/udp": {{HostIP: "127.0.0.1"}},
+	}
+	if !reflect.DeepEqual(hostConfig.PortBindings, expectedBindings) {
+		t.Errorf("Expected port bindings %v, got %v", expectedBindings, hostConfig.PortBindings)
+	}
+	expectedBinds := []string{
+		"/lib/modules:/lib/modules:ro",
+		"/srv/data:/data",
+		"/srv/certs:/certs:ro,Z",
+		"/mnt:/mnt:rshared",
+	}
+	if !reflect.DeepEqual(hostConfig.Binds, expectedBinds) {
+		t.Errorf("Expected binds %v, got %v", expectedBinds, hostConfig.Binds)
+	}
+
+	// Nodes without extras keep their configuration as it is
+	config, hostConfig = &container.Config{}, &container.HostConfig{}
+	applyNodeExtras(v1alpha1.NodeExtras{}, config, hostConfig)
+	if config.ExposedPorts != nil || hostConfig.PortBindings != nil || hostConfig.Binds != nil {
+		t.Errorf("Expected no ports or binds, got %v %v %v", config.ExposedPorts, hostConfig.PortBindings, hostConfig.Binds)
+	}
+}
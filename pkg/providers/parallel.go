@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxConcurrentNodeOperations is the number of nodes created or removed
+// at the same time when the provider configuration does not say otherwise
+const DefaultMaxConcurrentNodeOperations = 4
+
+// NodeOperationError is returned when an operation on a set of nodes failed for
+// some of them. The nodes listed in Succeeded are in their desired state.
+type NodeOperationError struct {
+	// Operation is what was done to the nodes, e.g. "create" or "remove"
+	Operation string
+	// Succeeded lists the nodes the operation succeeded for
+	Succeeded []string
+	// Failed maps the nodes the operation failed for to their error
+	Failed map[string]error
+}
+
+func (e *NodeOperationError) Error() string {
+	nodes := make([]string, 0, len(e.Failed))
+	for node := range e.Failed {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	failures := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		failures = append(failures, fmt.Sprintf("%s: %v", node, e.Failed[node]))
+	}
+	return fmt.Sprintf("failed to %s %d of %d nodes: %s", e.Operation, len(e.Failed),
+		len(e.Failed)+len(e.Succeeded), strings.Join(failures, "; "))
+}
+
+// Unwrap returns the individual node errors
+func (e *NodeOperationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// IsPartialFailure reports whether err is a NodeOperationError for which at
+// least one node succeeded
+func IsPartialFailure(err error) bool {
+	var nodeErr *NodeOperationError
+	return errors.As(err, &nodeErr) && len(nodeErr.Succeeded) > 0
+}
+
+// runNodeOperations calls run for every node, at most the configured number at
+// a time. All nodes are attempted even if some fail; the failures are returned
+// together as a *NodeOperationError.
+func (p *DockerProvider) runNodeOperations(ctx context.Context, operation string, nodes []string, run func(ctx context.Context, node string) error) error {
+	limit := DefaultMaxConcurrentNodeOperations
+	if p.config != nil && p.config.Spec.MaxConcurrentNodeOperations > 0 {
+		limit = int(p.config.Spec.MaxConcurrentNodeOperations)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result = &NodeOperationError{Operation: operation, Failed: map[string]error{}}
+		slots  = make(chan struct{}, limit)
+	)
+	for _, node := range nodes {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(node string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			err := run(ctx, node)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed[node] = err
+			} else {
+				result.Succeeded = append(result.Succeeded, node)
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	if len(result.Failed) == 0 {
+		return nil
+	}
+	sort.Strings(result.Succeeded)
+	return result
+}
@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+func TestRunNodeOperationsLimitsConcurrency(t *testing.T) {
+	provider := &DockerProvider{
+		BaseProvider: &BaseProvider{},
+		config:       &v1alpha1.DockerProviderConfig{Spec: v1alpha1.DockerProviderConfigSpec{MaxConcurrentNodeOperations: 2}},
+	}
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	nodes := []string{"worker-0", "worker-1", "worker-2", "worker-3", "worker-4"}
+	err := provider.runNodeOperations(context.Background(), "create", nodes, func(ctx context.Context, node string) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if peak != 2 {
+		t.Errorf("Expected at most 2 concurrent operations, got %d", peak)
+	}
+}
+
+func TestRunNodeOperationsAggregatesErrors(t *testing.T) {
+	provider := &DockerProvider{BaseProvider: &BaseProvider{}, config: &v1alpha1.DockerProviderConfig{}}
+
+	errPull := errors.New("pull failed")
+	nodes := []string{"worker-0", "worker-1", "worker-2"}
+	err := provider.runNodeOperations(context.Background(), "create", nodes, func(ctx context.Context, node string) error {
+		if node == "worker-1" {
+			return fmt.Errorf("failed to create %s: %w", node, errPull)
+		}
+		return nil
+	})
+
+	var nodeErr *NodeOperationError
+	if !errors.As(err, &nodeErr) {
+		t.Fatalf("Expected a NodeOperationError, got %v", err)
+	}
+	if len(nodeErr.Failed) != 1 || nodeErr.Failed["worker-1"] == nil {
+		t.Errorf("Expected only worker-1 to fail, got %v", nodeErr.Failed)
+	}
+	if len(nodeErr.Succeeded) != 2 || nodeErr.Succeeded[0] != "worker-0" || nodeErr.Succeeded[1] != "worker-2" {
+		t.Errorf("Expected worker-0 and worker-2 to succeed, got %v", nodeErr.Succeeded)
+	}
+	if !errors.Is(err, errPull) {
+		t.Error("Expected the node error to be reachable through errors.Is")
+	}
+	if !IsPartialFailure(err) {
+		t.Error("Expected a partial failure")
+	}
+
+	err = provider.runNodeOperations(context.Background(), "create", nodes, func(ctx context.Context, node string) error {
+		return errPull
+	})
+	if IsPartialFailure(err) {
+		t.Error("Expected a complete failure not to be reported as partial")
+	}
+}
@@ -36,6 +36,99 @@ type Provider interface {
 	UpdateCluster(ctx context.Context, cluster *v1alpha1.Cluster) error
 }
 
+// Migrator is implemented by providers whose resources created by earlier
+// releases need to be brought under the current naming and labelling scheme
+type Migrator interface {
+	// MigrateCluster migrates the cluster's legacy resources, if any.
+	// It is a no-op for clusters that have already been migrated.
+	MigrateCluster(ctx context.Context, cluster *v1alpha1.Cluster) error
+}
+
+// Adopter is implemented by providers that can take over clusters they did not create
+type Adopter interface {
+	// AdoptCluster takes over the existing cluster identified by source without
+	// recreating it, and returns the spec that describes it.
+	// Returns ErrClusterNotFound if there is no such cluster.
+	AdoptCluster(ctx context.Context, cluster *v1alpha1.Cluster, source string) (*v1alpha1.ClusterSpec, error)
+}
+
+// Rollbacker is implemented by providers that can undo a failed upgrade
+type Rollbacker interface {
+	// RollbackUpgrade recreates the nodes recorded in upgrade that no longer
+	// run their previous image from that image, using upgrade.PreviousSpec.
+	// Nodes that were already rolled back are left alone.
+	RollbackUpgrade(ctx context.Context, cluster *v1alpha1.Cluster, upgrade *v1alpha1.UpgradeStatus) error
+}
+
+// EtcdSnapshotter is implemented by providers that can save the etcd data of a cluster
+type EtcdSnapshotter interface {
+	// SnapshotEtcd saves a snapshot of the cluster's etcd and returns where it
+	// is stored, or "" if the cluster runs no etcd to save
+	SnapshotEtcd(ctx context.Context, cluster *v1alpha1.Cluster) (string, error)
+}
+
+// Suspender is implemented by providers that can stop a cluster's nodes and
+// start them again without losing their state
+type Suspender interface {
+	// SuspendCluster stops the cluster's nodes. Nodes that are already stopped
+	// are left alone.
+	// Returns ErrClusterNotFound if the cluster does not exist
+	SuspendCluster(ctx context.Context, cluster *v1alpha1.Cluster) error
+
+	// ResumeCluster starts the cluster's stopped nodes, control plane first,
+	// and returns once the cluster is healthy again
+	// Returns ErrClusterNotFound if the cluster does not exist
+	ResumeCluster(ctx context.Context, cluster *v1alpha1.Cluster) error
+}
+
+// EtcdBackupper is implemented by providers that can keep etcd snapshots in a
+// backup location and restore a cluster's etcd from them
+type EtcdBackupper interface {
+	// BackupEtcd saves a snapshot of the cluster's etcd to location and
+	// returns the snapshot's name within it
+	BackupEtcd(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation) (string, error)
+
+	// DeleteEtcdBackup removes the snapshot name from location. Snapshots
+	// that are already gone are not an error.
+	DeleteEtcdBackup(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error
+
+	// RestoreEtcd replaces the etcd data of the cluster with the snapshot
+	// name from location and waits for etcd to run on it
+	RestoreEtcd(ctx context.Context, cluster *v1alpha1.Cluster, location v1alpha1.BackupLocation, name string) error
+}
+
+// Cloner is implemented by providers that can create a cluster as a copy of
+// another one, including the state of its nodes
+type Cloner interface {
+	// CloneCluster creates cluster from the nodes, volumes and etcd data of
+	// source. The copies get the cluster's own node names and network.
+	// Returns ErrClusterNotFound if source does not exist
+	// Returns ErrClusterExists if the cluster already has nodes
+	CloneCluster(ctx context.Context, cluster, source *v1alpha1.Cluster) error
+}
+
+// NodeHealthChecker is implemented by providers that can observe the health of
+// a cluster's nodes and repair unhealthy ones
+type NodeHealthChecker interface {
+	// NodeHealth reports what the provider observes about each of the cluster's nodes
+	// Returns ErrClusterNotFound if the cluster does not exist
+	NodeHealth(ctx context.Context, cluster *v1alpha1.Cluster) ([]NodeHealth, error)
+
+	// RemediateNode repairs the named node with action and returns the action
+	// actually taken, which differs from action where the node cannot be
+	// repaired that way
+	RemediateNode(ctx context.Context, cluster *v1alpha1.Cluster, node string, action v1alpha1.RemediationAction) (v1alpha1.RemediationAction, error)
+}
+
+// KubeconfigReader is implemented by providers that can hand out the admin
+// kubeconfig of the clusters they run
+type KubeconfigReader interface {
+	// Kubeconfig returns the admin kubeconfig of the cluster, pointing at an
+	// API server address reachable from the manager's host
+	// Returns ErrClusterNotFound if the cluster has no bootstrapped control plane
+	Kubeconfig(ctx context.Context, cluster *v1alpha1.Cluster) ([]byte, error)
+}
+
 // BaseProvider provides common functionality for providers
 type BaseProvider struct {
 	Name string
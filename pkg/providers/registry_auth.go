@@ -0,0 +1,87 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// dockerHubRegistry is the registry host of images without an explicit registry
+const dockerHubRegistry = "docker.io"
+
+// RegistryCredentials maps registry hosts to the credentials for them
+type RegistryCredentials map[string]registry.AuthConfig
+
+type registryCredentialsKey struct{}
+
+// WithRegistryCredentials returns a context that makes providers authenticate
+// image pulls from the registries in credentials
+func WithRegistryCredentials(ctx context.Context, credentials RegistryCredentials) context.Context {
+	return context.WithValue(ctx, registryCredentialsKey{}, credentials)
+}
+
+// ParseDockerConfigJSON reads the credentials from the contents of a
+// kubernetes.io/dockerconfigjson Secret (a Docker config.json)
+func ParseDockerConfigJSON(data []byte) (RegistryCredentials, error) {
+	var config struct {
+		Auths map[string]registry.AuthConfig `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse Docker config: %w", err)
+	}
+
+	credentials := RegistryCredentials{}
+	for server, auth := range config.Auths {
+		// Only the combined auth field may be set; split it for the daemon
+		if auth.Username == "" && auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for registry %s: %w", server, err)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid auth for registry %s: expected username:password", server)
+			}
+			auth.Username, auth.Password = username, password
+		}
+		auth.Auth = ""
+		auth.ServerAddress = server
+		credentials[registryHost(server)] = auth
+	}
+	return credentials, nil
+}
+
+// registryHost normalises a registry address as found in Docker configs, such
+// as https://index.docker.io/v1/, to the host name used in image references
+func registryHost(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		return dockerHubRegistry
+	}
+	return host
+}
+
+// registryAuthFor returns the encoded PullOptions.RegistryAuth for pulling ref,
+// or "" if the context carries no credentials for its registry
+func registryAuthFor(ctx context.Context, ref string) (string, error) {
+	credentials, ok := ctx.Value(registryCredentialsKey{}).(RegistryCredentials)
+	if !ok || len(credentials) == 0 {
+		return "", nil
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+	auth, ok := credentials[reference.Domain(named)]
+	if !ok {
+		return "", nil
+	}
+	return registry.EncodeAuthConfig(auth)
+}
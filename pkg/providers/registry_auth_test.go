@@ -0,0 +1,128 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+func TestParseDockerConfigJSON(t *testing.T) {
+	config := `{"auths": {
+		"https://index.docker.io/v1/": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("hub-user:hub-pass")) + `"},
+		"registry.example.com": {"username": "builder", "password": "secret"}
+	}}`
+
+	credentials, err := ParseDockerConfigJSON([]byte(config))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	hub, ok := credentials["docker.io"]
+	if !ok || hub.Username != "hub-user" || hub.Password != "hub-pass" {
+		t.Errorf("Expected Docker Hub credentials from the auth field, got %+v", hub)
+	}
+	internal, ok := credentials["registry.example.com"]
+	if !ok || internal.Username != "builder" || internal.Password != "secret" {
+		t.Errorf("Expected credentials for registry.example.com, got %+v", internal)
+	}
+
+	if _, err := ParseDockerConfigJSON([]byte(`{"auths": {"r.example.com": {"auth": "bm8tY29sb24="}}}`)); err == nil {
+		t.Error("Expected an error for an auth field without a colon")
+	}
+}
+
+func TestRegistryAuthFor(t *testing.T) {
+	ctx := WithRegistryCredentials(context.Background(), RegistryCredentials{
+		"registry.example.com": {Username: "builder", Password: "secret"},
+	})
+
+	encoded, err := registryAuthFor(ctx, "registry.example.com/kind/node:v1.29.0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	decoded, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode registry auth: %v", err)
+	}
+	var auth registry.AuthConfig
+	if err := json.Unmarshal(decoded, &auth); err != nil {
+		t.Fatalf("Failed to unmarshal registry auth: %v", err)
+	}
+	if auth.Username != "builder" || auth.Password != "secret" {
+		t.Errorf("Expected the registry.example.com credentials, got %+v", auth)
+	}
+
+	if encoded, err := registryAuthFor(ctx, "kindest/node:v1.29.0"); err != nil || encoded != "" {
+		t.Errorf("Expected no credentials for Docker Hub, got %q, %v", encoded, err)
+	}
+}
+
+func TestGetNodeImage(t *testing.T) {
+	provider := &DockerProvider{BaseProvider: &BaseProvider{}, config: &v1alpha1.DockerProviderConfig{}}
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       v1alpha1.ClusterSpec{KubernetesVersion: "v1.29.0"},
+	}
+
+	if ref := provider.getNodeImage(cluster); ref != "kindest/node:v1.29.0" {
+		t.Errorf("Expected the default node image, got %s", ref)
+	}
+
+	provider.config.Spec.NodeImageRepository = "registry.example.com/kind/node"
+	if ref := provider.getNodeImage(cluster); ref != "registry.example.com/kind/node:v1.29.0" {
+		t.Errorf("Expected the provider's repository, got %s", ref)
+	}
+
+	cluster.Spec.NodeImage = &v1alpha1.NodeImageSpec{
+		Repository:    "registry.example.com/team/node",
+		Digest:        "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		PullSecretRef: &corev1.LocalObjectReference{Name: "registry"},
+	}
+	want := "registry.example.com/team/node:v1.29.0@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	if ref := provider.getNodeImage(cluster); ref != want {
+		t.Errorf("Expected %s, got %s", want, ref)
+	}
+}
+
+func TestGetNodeImageCatalogDigest(t *testing.T) {
+	catalog, err := versions.New(versions.Version{
+		Version: "v1.29.0",
+		Digest:  "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	provider := &DockerProvider{BaseProvider: &BaseProvider{}, config: &v1alpha1.DockerProviderConfig{}, versions: catalog}
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       v1alpha1.ClusterSpec{KubernetesVersion: "v1.29.0"},
+	}
+
+	want := "kindest/node:v1.29.0@sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	if ref := provider.getNodeImage(cluster); ref != want {
+		t.Errorf("Expected the catalog digest, got %s", ref)
+	}
+
+	cluster.Spec.NodeImage = &v1alpha1.NodeImageSpec{Digest: "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}
+	want = "kindest/node:v1.29.0@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	if ref := provider.getNodeImage(cluster); ref != want {
+		t.Errorf("Expected the cluster's digest to take precedence, got %s", ref)
+	}
+
+	if err := provider.validateVersion(context.Background(), cluster); err != nil {
+		t.Errorf("Expected v1.29.0 to be valid, got %v", err)
+	}
+	cluster.Spec.KubernetesVersion = "v1.29.1"
+	if err := provider.validateVersion(context.Background(), cluster); !errors.Is(err, ErrInvalidConfig) || !errors.Is(err, versions.ErrUnsupportedVersion) {
+		t.Errorf("Expected an unsupported version to be an invalid config, got %v", err)
+	}
+}
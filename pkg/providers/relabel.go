@@ -0,0 +1,245 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// recreateContainer replaces a container with an identical one that carries the
+// given labels and name. Docker cannot change the labels of an existing
+// container, so the container's filesystem is committed to an image and a new
+// container is created from it with the same volumes, networks and addresses.
+// The original is set aside under another name and taken off its networks
+// while its replacement is created, and only removed once the replacement
+// runs; if that fails, the original is put back as it was.
+// The committed image belongs to cluster and goes when it is deleted.
+// It returns the ID of the new container.
+func (p *DockerProvider) recreateContainer(ctx context.Context, cluster *v1alpha1.Cluster, containerID, name string, labels map[string]string) (string, error) {
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", containerID, err)
+	}
+	wasRunning := info.State != nil && info.State.Running
+	log := clusterLogger(ctx, cluster).WithValues("node", name, "containerID", shortID(containerID))
+
+	log.V(2).Info("Committing container", "step", "commit")
+	imageID, err := p.commitNode(ctx, cluster, containerID, container.CommitOptions{
+		Reference: fmt.Sprintf("mini-k8s-manager/relabel:%s", name),
+		Comment:   "created by mini-k8s-manager while relabelling " + strings.TrimPrefix(info.Name, "/"),
+		Pause:     true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	config := *info.Config
+	config.Image = imageID
+	config.Labels = make(map[string]string, len(info.Config.Labels)+len(labels))
+	for k, v := range info.Config.Labels {
+		config.Labels[k] = v
+	}
+	for k, v := range labels {
+		config.Labels[k] = v
+	}
+	if _, ok := config.Labels[LabelNodeImage]; !ok {
+		config.Labels[LabelNodeImage] = info.Config.Image
+	}
+
+	hostConfig := *info.HostConfig
+	hostConfig.Mounts = append([]mount.Mount(nil), info.HostConfig.Mounts...)
+	hostConfig.Mounts = append(hostConfig.Mounts, anonymousVolumeMounts(info)...)
+
+	networkingConfig := &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{}}
+	if info.NetworkSettings != nil {
+		for networkName, endpoint := range info.NetworkSettings.Networks {
+			networkingConfig.EndpointsConfig[networkName] = &network.EndpointSettings{
+				NetworkID: endpoint.NetworkID,
+				Aliases:   endpoint.Aliases,
+				IPAMConfig: &network.EndpointIPAMConfig{
+					IPv4Address: endpoint.IPAddress,
+				},
+			}
+		}
+	}
+
+	// The original holds the name and the addresses the replacement needs
+	log.V(2).Info("Setting container aside", "step", "set-aside")
+	if err := p.setContainerAside(ctx, info, networkingConfig); err != nil {
+		if restoreErr := p.restoreContainer(ctx, info, networkingConfig, wasRunning); restoreErr != nil {
+			log.Error(restoreErr, "Failed to restore container", "step", "restore")
+		}
+		p.removeUnusedImage(ctx, cluster, imageID)
+		return "", err
+	}
+
+	log.V(2).Info("Recreating container", "step", "create", "image", shortID(imageID))
+	newID, err := p.createReplacement(ctx, cluster, &config, &hostConfig, networkingConfig, name, wasRunning)
+	if err != nil {
+		log.V(2).Info("Restoring container", "step", "restore")
+		if restoreErr := p.restoreContainer(ctx, info, networkingConfig, wasRunning); restoreErr != nil {
+			return "", fmt.Errorf("%w; restoring container %s also failed: %v", err, strings.TrimPrefix(info.Name, "/"), restoreErr)
+		}
+		p.removeUnusedImage(ctx, cluster, imageID)
+		return "", err
+	}
+
+	log.V(2).Info("Removing container", "step", "remove")
+	if err := p.client.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil {
+		return "", fmt.Errorf("failed to remove container %s after recreating it as %s: %w", containerID, name, err)
+	}
+	return newID, nil
+}
+
+// asideName is the name a container is renamed to while it is being replaced
+func asideName(info container.InspectResponse) string {
+	return fmt.Sprintf("%s-replaced-%s", strings.TrimPrefix(info.Name, "/"), shortID(info.ID))
+}
+
+// setContainerAside renames a container that is being replaced, stops it and
+// takes it off its networks, freeing its name and addresses
+func (p *DockerProvider) setContainerAside(ctx context.Context, info container.InspectResponse, networkingConfig *network.NetworkingConfig) error {
+	if err := p.client.ContainerRename(ctx, info.ID, asideName(info)); err != nil {
+		return fmt.Errorf("failed to rename container %s: %w", info.ID, err)
+	}
+	if err := p.client.ContainerStop(ctx, info.ID, container.StopOptions{}); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", info.ID, err)
+	}
+	for networkName := range networkingConfig.EndpointsConfig {
+		if err := p.client.NetworkDisconnect(ctx, networkName, info.ID, true); err != nil {
+			return fmt.Errorf("failed to disconnect container %s from network %s: %w", info.ID, networkName, err)
+		}
+	}
+	return nil
+}
+
+// createReplacement creates the container that replaces another and starts
+// it if the other was running. A replacement that fails to start is removed.
+func (p *DockerProvider) createReplacement(ctx context.Context, cluster *v1alpha1.Cluster, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string, start bool) (string, error) {
+	resp, err := p.client.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to recreate container %s from image %s: %w", name, config.Image, err)
+	}
+	if !start {
+		return resp.ID, nil
+	}
+	if err := p.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		// The volumes belong to the original container
+		if removeErr := p.client.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true}); removeErr != nil {
+			clusterLogger(ctx, cluster).Error(removeErr, "Failed to remove container that did not start", "node", name, "containerID", shortID(resp.ID))
+		}
+		return "", fmt.Errorf("failed to start container %s: %w", name, err)
+	}
+	return resp.ID, nil
+}
+
+// restoreContainer undoes setContainerAside: it reconnects the container to
+// the networks it is missing with its addresses, gives it its name back and
+// starts it again if it was running
+func (p *DockerProvider) restoreContainer(ctx context.Context, info container.InspectResponse, networkingConfig *network.NetworkingConfig, wasRunning bool) error {
+	current, err := p.client.ContainerInspect(ctx, info.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", info.ID, err)
+	}
+	for networkName, endpoint := range networkingConfig.EndpointsConfig {
+		if current.NetworkSettings != nil && current.NetworkSettings.Networks[networkName] != nil {
+			continue
+		}
+		if err := p.client.NetworkConnect(ctx, networkName, info.ID, endpoint); err != nil {
+			return fmt.Errorf("failed to reconnect container %s to network %s: %w", info.ID, networkName, err)
+		}
+	}
+	if current.Name != info.Name {
+		if err := p.client.ContainerRename(ctx, info.ID, strings.TrimPrefix(info.Name, "/")); err != nil {
+			return fmt.Errorf("failed to rename container %s back: %w", info.ID, err)
+		}
+	}
+	if wasRunning {
+		if err := p.client.ContainerStart(ctx, info.ID, container.StartOptions{}); err != nil {
+			return fmt.Errorf("failed to restart container %s: %w", info.ID, err)
+		}
+	}
+	return nil
+}
+
+// anonymousVolumeMounts returns mounts for the volumes a container received from
+// its image (for example /var on kind nodes), which are not part of its host
+// configuration and would otherwise be replaced by empty volumes on recreation
+func anonymousVolumeMounts(info container.InspectResponse) []mount.Mount {
+	declared := map[string]bool{}
+	for _, m := range info.HostConfig.Mounts {
+		declared[m.Target] = true
+	}
+	for _, bind := range info.HostConfig.Binds {
+		if parts := strings.Split(bind, ":"); len(parts) > 1 {
+			declared[parts[1]] = true
+		}
+	}
+
+	var mounts []mount.Mount
+	for _, m := range info.Mounts {
+		if m.Type != mount.TypeVolume || declared[m.Destination] {
+			continue
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   m.Name,
+			Target:   m.Destination,
+			ReadOnly: !m.RW,
+		})
+	}
+	return mounts
+}
+
+// MigrateCluster brings containers created by releases that only labelled them
+// with cluster=<name> under the namespace-qualified labels and names. Docker
+// networks cannot be relabelled without detaching every node, so the legacy
+// network is kept and recorded on the migrated nodes instead.
+func (p *DockerProvider) MigrateCluster(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	containers, err := p.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: legacyClusterFilters(cluster),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list legacy containers: %w", err)
+	}
+
+	legacyNetwork := fmt.Sprintf("cluster-%s-net", cluster.Name)
+	for _, cont := range containers {
+		if !isLegacyNode(cluster, cont.Names, cont.Labels) {
+			continue
+		}
+		role := cont.Labels[legacyLabelRole]
+		index, err := legacyNodeIndex(cont.Names, cluster.Name, role)
+		if err != nil {
+			return err
+		}
+		name := p.getNodeName(cluster, role, index)
+		clusterLogger(ctx, cluster).Info("Migrating node", "step", "migrate", "container", containerName(cont), "node", name, "role", role)
+		if _, err := p.recreateContainer(ctx, cluster, cont.ID, name, nodeLabels(cluster, role, legacyNetwork)); err != nil {
+			return fmt.Errorf("failed to migrate container %s: %w", cont.Names[0], err)
+		}
+		recordNormal(ctx, cluster, ReasonNodeMigrated, "Migrated node %s to %s", containerName(cont), name)
+	}
+	return nil
+}
+
+// legacyNodeIndex parses the node index out of a legacy container name
+func legacyNodeIndex(names []string, clusterName, role string) (int, error) {
+	prefix := fmt.Sprintf("/cluster-%s-%s-", clusterName, role)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		var index int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(name, prefix), "%d", &index); err == nil {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot determine node index from container names %v", names)
+}
@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers/dockerfake"
+)
+
+// createRelabelNode creates a running node container with a static address
+// on a network of its own
+func createRelabelNode(t *testing.T, engine *dockerfake.Engine, name string) string {
+	t.Helper()
+	ctx := context.Background()
+	engine.AddImage("kindest/node:v1.31.0")
+	if _, err := engine.NetworkCreate(ctx, "relabel-net", network.CreateOptions{
+		IPAM: &network.IPAM{Config: []network.IPAMConfig{{Subnet: "10.20.0.0/24"}}},
+	}); err != nil {
+		t.Fatalf("Failed to create network: %v", err)
+	}
+	resp, err := engine.ContainerCreate(ctx,
+		&container.Config{Image: "kindest/node:v1.31.0", Labels: map[string]string{"cluster": "dev"}},
+		&container.HostConfig{},
+		&network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{
+			"relabel-net": {IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "10.20.0.5"}},
+		}},
+		nil, name)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	if err := engine.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start container: %v", err)
+	}
+	return resp.ID
+}
+
+func TestRecreateContainer(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	oldID := createRelabelNode(t, engine, "node-0")
+
+	cluster := newFakeCluster("dev", 0)
+	newID, err := provider.recreateContainer(ctx, cluster, oldID, "node-0", clusterLabels(cluster))
+	if err != nil {
+		t.Fatalf("Failed to recreate container: %v", err)
+	}
+	if _, err := engine.ContainerInspect(ctx, oldID); err == nil {
+		t.Error("Expected the original container to be removed")
+	}
+	info, err := engine.ContainerInspect(ctx, newID)
+	if err != nil {
+		t.Fatalf("Failed to inspect recreated container: %v", err)
+	}
+	if info.Name != "/node-0" || !info.State.Running || info.Config.Labels[LabelClusterName] != "dev" {
+		t.Errorf("Expected a running node-0 with the new label, got %s running=%v labels %v", info.Name, info.State.Running, info.Config.Labels)
+	}
+	if address := nodeAddress(info, "relabel-net"); address != "10.20.0.5" {
+		t.Errorf("Expected the recreated container to keep address 10.20.0.5, got %s", address)
+	}
+
+	// The committed image goes with the cluster
+	if images := committedImages(t, engine); len(images) != 1 || images[0].ID != info.Image {
+		t.Errorf("Expected the image the container was recreated from to be labelled for the cluster, got %+v", images)
+	}
+	if err := provider.DeleteCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+	if images := committedImages(t, engine); len(images) != 0 {
+		t.Errorf("Expected the committed image to be removed with the cluster, got %+v", images)
+	}
+}
+
+// committedImages lists the images committed from node containers
+func committedImages(t *testing.T, engine *dockerfake.Engine) []image.Summary {
+	t.Helper()
+	images, err := engine.ImageList(context.Background(), image.ListOptions{Filters: filters.NewArgs(filters.Arg("label", LabelCommittedFrom))})
+	if err != nil {
+		t.Fatalf("Failed to list images: %v", err)
+	}
+	return images
+}
+
+func TestRecreateContainerRestoresOriginal(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	oldID := createRelabelNode(t, engine, "node-0")
+
+	// The replacement fails to start; the original comes back as it was
+	engine.InjectError("ContainerStart", errors.New("no space left on device"), 1)
+	if _, err := provider.recreateContainer(ctx, newFakeCluster("dev", 0), oldID, "node-0", map[string]string{LabelClusterName: "dev"}); err == nil {
+		t.Fatal("Expected recreating the container to fail")
+	}
+
+	containers, err := engine.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		t.Fatalf("Failed to list containers: %v", err)
+	}
+	if len(containers) != 1 || containers[0].ID != oldID {
+		t.Fatalf("Expected only the original container to be left, got %+v", containers)
+	}
+	info, err := engine.ContainerInspect(ctx, oldID)
+	if err != nil {
+		t.Fatalf("Failed to inspect original container: %v", err)
+	}
+	if info.Name != "/node-0" || !info.State.Running {
+		t.Errorf("Expected node-0 to run under its name again, got %s running=%v", info.Name, info.State.Running)
+	}
+	if address := nodeAddress(info, "relabel-net"); address != "10.20.0.5" {
+		t.Errorf("Expected the original container to get address 10.20.0.5 back, got %s", address)
+	}
+	if _, ok := info.Config.Labels[LabelClusterName]; ok {
+		t.Errorf("Expected the original labels, got %v", info.Config.Labels)
+	}
+	if images := committedImages(t, engine); len(images) != 0 {
+		t.Errorf("Expected the committed image to be removed, got %+v", images)
+	}
+}
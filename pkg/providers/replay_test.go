@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/client"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers/dockerreplay"
+)
+
+var recordFixtures = flag.Bool("record", false, "record the Docker API fixtures of the replay tests against the local Docker daemon")
+
+// newReplayProvider returns a provider answered from the Docker API fixture
+// testdata/docker/<name>.json. With -record it talks to the local daemon
+// instead and saves its traffic to the fixture once the test has passed.
+// Tests whose fixture has not been recorded yet are skipped.
+func newReplayProvider(t *testing.T, name string) *DockerProvider {
+	t.Helper()
+	path := filepath.Join("testdata", "docker", name+".json")
+
+	var cli *client.Client
+	if *recordFixtures {
+		recorder := dockerreplay.NewRecorder()
+		var err error
+		if cli, err = recorder.Client(client.WithVersion(DockerAPIVersion)); err != nil {
+			t.Fatalf("Failed to create recording Docker client: %v", err)
+		}
+		t.Cleanup(func() {
+			cli.Close()
+			if t.Failed() {
+				return
+			}
+			if err := recorder.Err(); err != nil {
+				t.Errorf("Failed to record %s: %v", path, err)
+				return
+			}
+			if err := recorder.Fixture().Save(path); err != nil {
+				t.Errorf("Failed to save %s: %v", path, err)
+			}
+		})
+	} else {
+		fixture, err := dockerreplay.Load(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			t.Skipf("No Docker API fixture %s; record it with go test ./pkg/providers -run %s -record against a Docker daemon", path, t.Name())
+		}
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", path, err)
+		}
+		server := dockerreplay.NewServer(fixture)
+		if cli, err = server.Client(client.WithVersion(DockerAPIVersion)); err != nil {
+			t.Fatalf("Failed to create replay Docker client: %v", err)
+		}
+		t.Cleanup(func() {
+			cli.Close()
+			server.Close()
+			if err := server.Err(); err != nil {
+				t.Errorf("Requests differ from %s: %v", path, err)
+			}
+			if unused := server.Unused(); len(unused) > 0 {
+				t.Errorf("%d requests of %s were not made, the first being %s %s", len(unused), path, unused[0].Request.Method, unused[0].Request.Path)
+			}
+		})
+	}
+
+	provider, err := NewDockerProvider(newTestProviderConfig(), WithDockerClient(cli))
+	if err != nil {
+		t.Fatalf("Failed to create Docker provider: %v", err)
+	}
+	return provider
+}
+
+func TestReplayCreateCluster(t *testing.T) {
+	ctx := context.Background()
+	provider := newReplayProvider(t, "create-cluster")
+	cluster := newFakeCluster("replay-create", 1)
+
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	status, err := provider.GetClusterStatus(ctx, cluster)
+	if err != nil {
+		t.Fatalf("Failed to get cluster status: %v", err)
+	}
+	if !status.ControlPlaneReady || status.WorkersReady != 1 {
+		t.Errorf("Expected a ready control plane and 1 ready worker, got %+v", status)
+	}
+	if err := provider.DeleteCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+}
+
+func TestReplayUpdateCluster(t *testing.T) {
+	ctx := context.Background()
+	provider := newReplayProvider(t, "update-cluster")
+	cluster := newFakeCluster("replay-update", 1)
+
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	cluster.Spec.Workers.Count = 2
+	cluster.Spec.Workers.MachineConfig.Memory = "3Gi"
+	if err := provider.UpdateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+	status, err := provider.GetClusterStatus(ctx, cluster)
+	if err != nil {
+		t.Fatalf("Failed to get cluster status: %v", err)
+	}
+	if status.WorkersReady != 2 {
+		t.Errorf("Expected 2 ready workers, got %+v", status)
+	}
+	if err := provider.DeleteCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+}
+
+func TestReplayDeleteCluster(t *testing.T) {
+	ctx := context.Background()
+	provider := newReplayProvider(t, "delete-cluster")
+	cluster := newFakeCluster("replay-delete", 1)
+
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	if err := provider.DeleteCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+	exists, err := provider.clusterExists(ctx, cluster)
+	if err != nil {
+		t.Fatalf("Failed to check cluster existence: %v", err)
+	}
+	if exists {
+		t.Error("Cluster should not exist after deletion")
+	}
+	// Deleting again finds nothing to remove
+	if err := provider.DeleteCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete a deleted cluster: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// scalingPlan is what it takes to bring the worker containers of a cluster to
+// the desired count
+type scalingPlan struct {
+	// create holds the names of the workers to create
+	create []string
+
+	// remove holds the workers to remove
+	remove []container.Summary
+
+	// start holds the stopped workers that are kept and started again
+	start []container.Summary
+}
+
+// empty reports whether the workers are as desired already
+func (s scalingPlan) empty() bool {
+	return len(s.create) == 0 && len(s.remove) == 0 && len(s.start) == 0
+}
+
+// planWorkerScaling works out how to get from the cluster's worker containers
+// to desired workers. Workers keep their names for their whole life: new ones
+// take the lowest indices no worker holds, so gaps left by failed creations or
+// removals are filled, and surplus ones are chosen by health and age rather
+// than index, see removalOrder.
+func (p *DockerProvider) planWorkerScaling(cluster *v1alpha1.Cluster, containers []container.Summary, desired int) scalingPlan {
+	var workers []container.Summary
+	used := map[int]bool{}
+	for _, cont := range containers {
+		if nodeRole(cont.Labels) != RoleWorker {
+			continue
+		}
+		workers = append(workers, cont)
+		if index, ok := p.nodeIndex(cluster, RoleWorker, containerName(cont)); ok {
+			used[index] = true
+		}
+	}
+
+	var plan scalingPlan
+	if surplus := len(workers) - desired; surplus > 0 {
+		removalOrder(workers)
+		plan.remove = workers[:surplus]
+		workers = workers[surplus:]
+	}
+	for index := 0; len(workers)+len(plan.create) < desired; index++ {
+		if !used[index] {
+			plan.create = append(plan.create, p.getNodeName(cluster, RoleWorker, index))
+		}
+	}
+	for _, cont := range workers {
+		if cont.State != "running" {
+			plan.start = append(plan.start, cont)
+		}
+	}
+	return plan
+}
+
+// removalOrder sorts workers by how readily they are removed on scale-down:
+// workers that are not running first, then the most recently created ones,
+// whose pods have had the least time to settle
+func removalOrder(workers []container.Summary) {
+	sort.SliceStable(workers, func(i, j int) bool {
+		a, b := workers[i], workers[j]
+		if running := a.State == "running"; running != (b.State == "running") {
+			return !running
+		}
+		if a.Created != b.Created {
+			return a.Created > b.Created
+		}
+		return containerName(a) > containerName(b)
+	})
+}
+
+// nodeIndex parses the index out of the name of one of the cluster's nodes
+func (p *DockerProvider) nodeIndex(cluster *v1alpha1.Cluster, role, name string) (int, bool) {
+	suffix, ok := strings.CutPrefix(name, fmt.Sprintf("%s-%s-", clusterResourcePrefix(cluster), role))
+	if !ok {
+		return 0, false
+	}
+	index, err := strconv.Atoi(suffix)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+func TestPlanWorkerScaling(t *testing.T) {
+	p := &DockerProvider{}
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "scale", Namespace: "default"}}
+	worker := func(index int, state string, created int64) container.Summary {
+		return container.Summary{
+			Names:   []string{"/" + p.getNodeName(cluster, RoleWorker, index)},
+			Labels:  map[string]string{LabelRole: RoleWorker},
+			State:   state,
+			Created: created,
+		}
+	}
+	controlPlane := container.Summary{
+		Names:  []string{"/" + p.getNodeName(cluster, RoleControlPlane, 0)},
+		Labels: map[string]string{LabelRole: RoleControlPlane},
+		State:  "running",
+	}
+	names := func(containers []container.Summary) []string {
+		var names []string
+		for _, cont := range containers {
+			names = append(names, containerName(cont))
+		}
+		return names
+	}
+	name := func(index int) string { return p.getNodeName(cluster, RoleWorker, index) }
+
+	tests := []struct {
+		name    string
+		workers []container.Summary
+		desired int
+		create  []string
+		remove  []string
+		start   []string
+	}{
+		{
+			name:    "nothing to do",
+			workers: []container.Summary{worker(0, "running", 1), worker(1, "running", 2)},
+			desired: 2,
+		},
+		{
+			name:    "gaps are filled first",
+			workers: []container.Summary{worker(0, "running", 1), worker(2, "running", 2)},
+			desired: 4,
+			create:  []string{name(1), name(3)},
+		},
+		{
+			name:    "stopped workers are kept and started",
+			workers: []container.Summary{worker(0, "running", 1), worker(1, "exited", 2), worker(2, "running", 3)},
+			desired: 4,
+			create:  []string{name(3)},
+			start:   []string{name(1)},
+		},
+		{
+			name:    "stopped workers are removed first",
+			workers: []container.Summary{worker(0, "exited", 1), worker(1, "running", 2), worker(2, "running", 3)},
+			desired: 2,
+			remove:  []string{name(0)},
+		},
+		{
+			name:    "newest workers are removed next",
+			workers: []container.Summary{worker(0, "running", 3), worker(1, "running", 1), worker(2, "running", 2)},
+			desired: 1,
+			remove:  []string{name(0), name(2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := p.planWorkerScaling(cluster, append([]container.Summary{controlPlane}, tt.workers...), tt.desired)
+			if !reflect.DeepEqual(plan.create, tt.create) {
+				t.Errorf("Expected to create %v, got %v", tt.create, plan.create)
+			}
+			if got := names(plan.remove); !reflect.DeepEqual(got, tt.remove) {
+				t.Errorf("Expected to remove %v, got %v", tt.remove, got)
+			}
+			if got := names(plan.start); !reflect.DeepEqual(got, tt.start) {
+				t.Errorf("Expected to start %v, got %v", tt.start, got)
+			}
+		})
+	}
+}
+
+func TestNodeIndex(t *testing.T) {
+	p := &DockerProvider{}
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "scale", Namespace: "default"}}
+
+	if index, ok := p.nodeIndex(cluster, RoleWorker, p.getNodeName(cluster, RoleWorker, 12)); !ok || index != 12 {
+		t.Errorf("Expected index 12, got %d, %v", index, ok)
+	}
+	if _, ok := p.nodeIndex(cluster, RoleWorker, p.getNodeName(cluster, RoleControlPlane, 0)); ok {
+		t.Error("Expected no worker index in a control plane node name")
+	}
+	if _, ok := p.nodeIndex(cluster, RoleWorker, "kind-worker2"); ok {
+		t.Error("Expected no index in a foreign node name")
+	}
+}
+
+func TestUpdateClusterScalesWorkers(t *testing.T) {
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	cluster := newFakeCluster("scale", 1)
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	workers := func() []string {
+		args := clusterFilters(cluster)
+		args.Add("label", LabelRole+"="+RoleWorker)
+		containers, err := engine.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+		if err != nil {
+			t.Fatalf("Failed to list workers: %v", err)
+		}
+		var names []string
+		for _, cont := range containers {
+			names = append(names, containerName(cont))
+		}
+		sort.Strings(names)
+		return names
+	}
+	name := func(index int) string { return provider.getNodeName(cluster, RoleWorker, index) }
+
+	for _, tt := range []struct {
+		count   int32
+		workers []string
+	}{
+		{3, []string{name(0), name(1), name(2)}},
+		{1, []string{name(0)}},
+		{2, []string{name(0), name(1)}},
+	} {
+		cluster.Spec.Workers.Count = tt.count
+		if err := provider.UpdateCluster(ctx, cluster); err != nil {
+			t.Fatalf("Failed to scale to %d workers: %v", tt.count, err)
+		}
+		if got := workers(); !reflect.DeepEqual(got, tt.workers) {
+			t.Errorf("Expected workers %v after scaling to %d, got %v", tt.workers, tt.count, got)
+		}
+	}
+}
+
+func TestUpdateClusterOnRetiredVersion(t *testing.T) {
+	ctx := context.Background()
+	provider, _ := newFakeProvider(t)
+	cluster := newFakeCluster("retired", 1)
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	cluster.Status.KubernetesVersion = cluster.Spec.KubernetesVersion
+
+	// The cluster's version has since left the catalog
+	catalog, err := versions.New(versions.Version{Version: "v1.99.0"})
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	provider.versions = catalog
+
+	cluster.Spec.Workers.Count = 2
+	if err := provider.UpdateCluster(ctx, cluster); err != nil {
+		t.Errorf("Expected a cluster on a retired version to scale, got %v", err)
+	}
+	cluster.Spec.KubernetesVersion = "v1.98.0"
+	if err := provider.UpdateCluster(ctx, cluster); !errors.Is(err, versions.ErrUnsupportedVersion) {
+		t.Errorf("Expected a change to an unsupported version to be rejected, got %v", err)
+	}
+}
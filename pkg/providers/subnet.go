@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+// allocateSubnet returns the first subnet of the configured size within the
+// provider's CIDR that no Docker network uses yet, so every cluster gets a
+// subnet of its own. Without a subnet size the whole CIDR is used.
+func (p *DockerProvider) allocateSubnet(ctx context.Context) (string, error) {
+	cidr := p.config.Spec.Network.CIDR
+	_, pool, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid network CIDR %q", ErrInvalidConfig, cidr)
+	}
+	size := int(p.config.Spec.Network.SubnetMask)
+	if ones, _ := pool.Mask.Size(); size == 0 || size <= ones {
+		return cidr, nil
+	}
+
+	networks, err := p.client.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list networks: %w", err)
+	}
+	var used []*net.IPNet
+	for _, nw := range networks {
+		for _, config := range nw.IPAM.Config {
+			if _, subnet, err := net.ParseCIDR(config.Subnet); err == nil {
+				used = append(used, subnet)
+			}
+		}
+	}
+	return freeSubnet(pool, size, used)
+}
+
+// freeSubnet returns the first /size subnet of the IPv4 pool that overlaps
+// none of used
+func freeSubnet(pool *net.IPNet, size int, used []*net.IPNet) (string, error) {
+	ones, bits := pool.Mask.Size()
+	base := pool.IP.To4()
+	if base == nil || bits != 32 {
+		return "", fmt.Errorf("%w: subnets can only be allocated from IPv4 ranges, not %s", ErrInvalidConfig, pool)
+	}
+	if size < ones || size > bits {
+		return "", fmt.Errorf("%w: a /%d subnet does not fit in %s", ErrInvalidConfig, size, pool)
+	}
+
+	start := binary.BigEndian.Uint32(base)
+	for i := uint32(0); i < 1<<(size-ones); i++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, start+i<<(bits-size))
+		candidate := &net.IPNet{IP: ip, Mask: net.CIDRMask(size, bits)}
+
+		free := true
+		for _, subnet := range used {
+			if candidate.Contains(subnet.IP) || subnet.Contains(candidate.IP) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return candidate.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no free /%d subnet left in %s", size, pool)
+}
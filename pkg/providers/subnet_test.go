@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFreeSubnet(t *testing.T) {
+	_, pool, _ := net.ParseCIDR("10.10.0.0/16")
+	parse := func(cidrs ...string) []*net.IPNet {
+		var subnets []*net.IPNet
+		for _, cidr := range cidrs {
+			_, subnet, _ := net.ParseCIDR(cidr)
+			subnets = append(subnets, subnet)
+		}
+		return subnets
+	}
+
+	tests := []struct {
+		name     string
+		used     []*net.IPNet
+		expected string
+	}{
+		{"empty pool", nil, "10.10.0.0/24"},
+		{"first subnet taken", parse("10.10.0.0/24"), "10.10.1.0/24"},
+		{"overlapping larger network", parse("10.10.0.0/23", "172.17.0.0/16"), "10.10.2.0/24"},
+		{"smaller network inside a subnet", parse("10.10.0.128/25"), "10.10.1.0/24"},
+		{"network around the pool", parse("10.0.0.0/8"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnet, err := freeSubnet(pool, 24, tt.used)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("Expected no free subnet, got %s", subnet)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if subnet != tt.expected {
+				t.Errorf("Expected subnet %s, got %s", tt.expected, subnet)
+			}
+		})
+	}
+}
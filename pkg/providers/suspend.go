@@ -0,0 +1,193 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+const (
+	// suspendStopTimeout is how many seconds a node gets to shut down cleanly
+	// when its cluster is suspended
+	suspendStopTimeout = 30
+
+	// resumeHealthTimeout bounds the wait for a resumed cluster to be healthy
+	resumeHealthTimeout = 3 * time.Minute
+
+	// adminKubeconfig is the kubeconfig of kubeadm control plane nodes
+	adminKubeconfig = "/etc/kubernetes/admin.conf"
+)
+
+// SuspendCluster stops the cluster's running node containers, workers before
+// the control plane so nothing loses its API server while still running. The
+// containers, their volumes and the network are kept.
+func (p *DockerProvider) SuspendCluster(ctx context.Context, cluster *v1alpha1.Cluster) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.SuspendCluster", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return ErrClusterNotFound
+	}
+
+	controlPlanes, workers := nodesByRole(containers, "running")
+	if len(controlPlanes)+len(workers) == 0 {
+		return nil
+	}
+	clusterLogger(ctx, cluster).Info("Suspending cluster", "controlPlanes", len(controlPlanes), "workers", len(workers))
+
+	for _, nodes := range [][]container.Summary{workers, controlPlanes} {
+		if err := p.forEachNode(ctx, "stop", nodes, func(ctx context.Context, cont container.Summary) error {
+			return p.stopNode(ctx, cluster, cont)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResumeCluster starts the cluster's stopped node containers: the control
+// plane first, then the workers once the control plane is healthy. It
+// returns once all nodes run and, on bootstrapped nodes, the API server is ready.
+func (p *DockerProvider) ResumeCluster(ctx context.Context, cluster *v1alpha1.Cluster) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.ResumeCluster", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return ErrClusterNotFound
+	}
+	log := clusterLogger(ctx, cluster)
+
+	controlPlanes, workers := nodesByRole(containers, "")
+	log.Info("Resuming cluster", "controlPlanes", len(controlPlanes), "workers", len(workers))
+	if err := p.forEachNode(ctx, "start", controlPlanes, func(ctx context.Context, cont container.Summary) error {
+		return p.startNode(ctx, cluster, cont)
+	}); err != nil {
+		return err
+	}
+	if len(controlPlanes) > 0 {
+		if err := p.waitForAPIServer(ctx, cluster, controlPlanes[0]); err != nil {
+			return err
+		}
+	}
+	if err := p.forEachNode(ctx, "start", workers, func(ctx context.Context, cont container.Summary) error {
+		return p.startNode(ctx, cluster, cont)
+	}); err != nil {
+		return err
+	}
+
+	// Nodes that exit right after starting are caught here
+	containers, err = p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	for _, cont := range containers {
+		if cont.State != "running" {
+			return fmt.Errorf("node %s is %s after resuming", containerName(cont), cont.State)
+		}
+	}
+	log.Info("Resumed cluster")
+	return nil
+}
+
+// stopNode stops a node container, keeping it and its volumes
+func (p *DockerProvider) stopNode(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary) error {
+	timeout := suspendStopTimeout
+	if err := p.client.ContainerStop(ctx, cont.ID, container.StopOptions{Timeout: &timeout}); err != nil {
+		recordWarning(ctx, cluster, ReasonNodeStopFailed, "Failed to stop node %s: %v", containerName(cont), err)
+		return fmt.Errorf("failed to stop container %s: %w", cont.ID, err)
+	}
+	clusterLogger(ctx, cluster).V(1).Info("Stopped node", "node", containerName(cont))
+	recordNormal(ctx, cluster, ReasonNodeStopped, "Stopped %s node %s", nodeRole(cont.Labels), containerName(cont))
+	return nil
+}
+
+// startNode starts a stopped node container. Running nodes are left alone.
+func (p *DockerProvider) startNode(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary) error {
+	if cont.State == "running" {
+		return nil
+	}
+	if err := p.client.ContainerStart(ctx, cont.ID, container.StartOptions{}); err != nil {
+		recordWarning(ctx, cluster, ReasonNodeStartFailed, "Failed to start node %s: %v", containerName(cont), err)
+		return fmt.Errorf("failed to start container %s: %w", cont.ID, err)
+	}
+	clusterLogger(ctx, cluster).V(1).Info("Started node", "node", containerName(cont))
+	recordNormal(ctx, cluster, ReasonNodeStarted, "Started %s node %s", nodeRole(cont.Labels), containerName(cont))
+	return nil
+}
+
+// forEachNode runs operation on node containers in parallel
+func (p *DockerProvider) forEachNode(ctx context.Context, operation string, nodes []container.Summary, run func(ctx context.Context, cont container.Summary) error) error {
+	byName := make(map[string]container.Summary, len(nodes))
+	names := make([]string, 0, len(nodes))
+	for _, cont := range nodes {
+		byName[containerName(cont)] = cont
+		names = append(names, containerName(cont))
+	}
+	return p.runNodeOperations(ctx, operation, names, func(ctx context.Context, name string) error {
+		return run(ctx, byName[name])
+	})
+}
+
+// waitForAPIServer waits until the API server on a control plane node reports
+// ready. Nodes that were never bootstrapped with kubeadm have no API server to
+// wait for.
+func (p *DockerProvider) waitForAPIServer(ctx context.Context, cluster *v1alpha1.Cluster, node container.Summary) error {
+	if !p.bootstrapped(ctx, node.ID) {
+		clusterLogger(ctx, cluster).V(1).Info("Node has no admin kubeconfig, not waiting for the API server", "node", containerName(node))
+		return nil
+	}
+
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, resumeHealthTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := p.execInContainer(ctx, node.ID, "kubectl", "--kubeconfig="+adminKubeconfig, "get", "--raw=/readyz")
+		return err == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("API server on node %s did not become ready: %w", containerName(node), err)
+	}
+	return nil
+}
+
+// bootstrapped reports whether a running control plane node was set up with
+// kubeadm, which leaves an admin kubeconfig behind
+func (p *DockerProvider) bootstrapped(ctx context.Context, nodeID string) bool {
+	_, err := p.execInContainer(ctx, nodeID, "test", "-f", adminKubeconfig)
+	return err == nil
+}
+
+// nodesByRole splits node containers into control plane and worker nodes,
+// each sorted by name. With a non-empty state only containers in that state
+// are returned.
+func nodesByRole(containers []container.Summary, state string) (controlPlanes, workers []container.Summary) {
+	for _, cont := range containers {
+		if state != "" && cont.State != state {
+			continue
+		}
+		switch nodeRole(cont.Labels) {
+		case RoleControlPlane:
+			controlPlanes = append(controlPlanes, cont)
+		case RoleWorker:
+			workers = append(workers, cont)
+		}
+	}
+	byName := func(nodes []container.Summary) func(i, j int) bool {
+		return func(i, j int) bool { return containerName(nodes[i]) < containerName(nodes[j]) }
+	}
+	sort.Slice(controlPlanes, byName(controlPlanes))
+	sort.Slice(workers, byName(workers))
+	return controlPlanes, workers
+}
@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestNodesByRole(t *testing.T) {
+	node := func(name, role, state string) container.Summary {
+		return container.Summary{
+			Names:  []string{"/" + name},
+			State:  state,
+			Labels: map[string]string{LabelRole: role},
+		}
+	}
+	containers := []container.Summary{
+		node("dev-worker-1", RoleWorker, "exited"),
+		node("dev-control-plane-0", RoleControlPlane, "running"),
+		node("dev-worker-0", RoleWorker, "running"),
+	}
+
+	controlPlanes, workers := nodesByRole(containers, "")
+	if len(controlPlanes) != 1 || len(workers) != 2 {
+		t.Fatalf("Expected 1 control plane and 2 workers, got %d and %d", len(controlPlanes), len(workers))
+	}
+	if containerName(workers[0]) != "dev-worker-0" || containerName(workers[1]) != "dev-worker-1" {
+		t.Errorf("Expected workers sorted by name, got %s and %s", containerName(workers[0]), containerName(workers[1]))
+	}
+
+	controlPlanes, workers = nodesByRole(containers, "running")
+	if len(controlPlanes) != 1 || len(workers) != 1 || containerName(workers[0]) != "dev-worker-0" {
+		t.Errorf("Expected only running nodes, got %d control planes and %d workers", len(controlPlanes), len(workers))
+	}
+}
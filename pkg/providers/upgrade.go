@@ -0,0 +1,457 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/tracing"
+)
+
+// kubernetesConfigDir holds the kubeadm configuration of a node
+const kubernetesConfigDir = "/etc/kubernetes"
+
+// NodeUpgradeReporter receives the state of each node an upgrade or its
+// rollback replaces, before and after the node is replaced
+type NodeUpgradeReporter func(node v1alpha1.NodeUpgradeStatus)
+
+type nodeUpgradeReporterKey struct{}
+
+// WithNodeUpgradeReporter returns a context that makes providers report the
+// nodes they replace during upgrades and rollbacks to reporter
+func WithNodeUpgradeReporter(ctx context.Context, reporter NodeUpgradeReporter) context.Context {
+	return context.WithValue(ctx, nodeUpgradeReporterKey{}, reporter)
+}
+
+// reportNodeUpgrade passes the state of a node to the context's reporter, if any
+func reportNodeUpgrade(ctx context.Context, node v1alpha1.NodeUpgradeStatus) {
+	reporter, ok := ctx.Value(nodeUpgradeReporterKey{}).(NodeUpgradeReporter)
+	if !ok || reporter == nil {
+		return
+	}
+	reporter(node)
+}
+
+// upgradeNodes replaces the nodes that run another Kubernetes version than
+// the cluster asks for, one at a time: control plane nodes first, then
+// workers, so that workers never run a newer version than the control plane.
+// Nodes keep their names; whether an upgrade between the versions is
+// supported is for the caller to check against the version catalog.
+func (p *DockerProvider) upgradeNodes(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	var outdated []container.Summary
+	for _, cont := range containers {
+		role := nodeRole(cont.Labels)
+		if role != RoleControlPlane && role != RoleWorker {
+			continue
+		}
+		// Nodes created from an untagged image cannot be told apart; leave them be
+		if version := imageTag(cont.Labels[LabelNodeImage]); version != "" && version != cluster.Spec.KubernetesVersion {
+			outdated = append(outdated, cont)
+		}
+	}
+	if len(outdated) == 0 {
+		return nil
+	}
+	sort.SliceStable(outdated, func(i, j int) bool {
+		return nodeRole(outdated[i].Labels) == RoleControlPlane && nodeRole(outdated[j].Labels) != RoleControlPlane
+	})
+
+	clusterNetwork, err := p.requireClusterNetwork(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	// Pull the new node image once for all nodes
+	image := p.getNodeImage(cluster)
+	if err := p.images.ensureImage(ctx, cluster, image, nodeImagePolicy(cluster)); err != nil {
+		return err
+	}
+
+	clusterLogger(ctx, cluster).Info("Upgrading nodes", "version", cluster.Spec.KubernetesVersion, "nodes", len(outdated))
+	for _, cont := range outdated {
+		if err := p.upgradeNode(ctx, cluster, cont, clusterNetwork, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upgradeNode replaces a node with one of the same name and role created from image
+func (p *DockerProvider) upgradeNode(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary, clusterNetwork *network.Summary, image string) error {
+	name, role := containerName(cont), nodeRole(cont.Labels)
+	from := imageTag(cont.Labels[LabelNodeImage])
+	state := v1alpha1.NodeUpgradeStatus{
+		Name:          name,
+		Role:          role,
+		PreviousImage: cont.Labels[LabelNodeImage],
+		Image:         image,
+		State:         v1alpha1.NodeUpgradeStateUpgrading,
+	}
+
+	clusterLogger(ctx, cluster).V(1).Info("Upgrading node", "step", "upgrade", "node", name, "role", role, "from", from, "to", cluster.Spec.KubernetesVersion)
+	recordNormal(ctx, cluster, ReasonNodeUpgrading, "Upgrading %s node %s from %s to %s", role, name, from, cluster.Spec.KubernetesVersion)
+	reportNodeUpgrade(ctx, state)
+
+	if err := p.replaceNode(ctx, cluster, cont, clusterNetwork, machineConfigFor(&cluster.Spec, role), image, cluster.Spec.KubernetesVersion); err != nil {
+		state.State = v1alpha1.NodeUpgradeStateFailed
+		state.Message = err.Error()
+		reportNodeUpgrade(ctx, state)
+		return fmt.Errorf("failed to upgrade node %s: %w", name, err)
+	}
+
+	state.State = v1alpha1.NodeUpgradeStateUpgraded
+	reportNodeUpgrade(ctx, state)
+	recordNormal(ctx, cluster, ReasonNodeUpgraded, "Upgraded %s node %s to %s", role, name, cluster.Spec.KubernetesVersion)
+	return nil
+}
+
+// RollbackUpgrade recreates the nodes an upgrade replaced from the images they
+// ran before, in the reverse order of the upgrade: workers first, then the
+// control plane. Nodes that already run their previous image are left alone,
+// so an interrupted rollback can be run again. Once the control plane is back,
+// etcd is restored from the snapshot taken before the upgrade, if there is one.
+func (p *DockerProvider) RollbackUpgrade(ctx context.Context, cluster *v1alpha1.Cluster, upgrade *v1alpha1.UpgradeStatus) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerProvider.RollbackUpgrade", clusterAttributes(cluster)...)
+	defer func() { tracing.End(span, err) }()
+
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]container.Summary, len(containers))
+	for _, cont := range containers {
+		byName[containerName(cont)] = cont
+	}
+
+	nodes := make([]v1alpha1.NodeUpgradeStatus, len(upgrade.Nodes))
+	copy(nodes, upgrade.Nodes)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Role != RoleControlPlane && nodes[j].Role == RoleControlPlane
+	})
+
+	log := clusterLogger(ctx, cluster)
+	log.Info("Rolling back upgrade", "from", upgrade.ToVersion, "to", upgrade.FromVersion, "nodes", len(nodes))
+
+	// Control plane nodes only count as rolled back once etcd is restored
+	var controlPlanes []v1alpha1.NodeUpgradeStatus
+	var clusterNetwork *network.Summary
+	for _, node := range nodes {
+		if node.State == v1alpha1.NodeUpgradeStateRolledBack || node.PreviousImage == "" {
+			continue
+		}
+		cont, exists := byName[node.Name]
+		if !exists || cont.Labels[LabelNodeImage] != node.PreviousImage {
+			if clusterNetwork == nil {
+				if clusterNetwork, err = p.requireClusterNetwork(ctx, cluster); err != nil {
+					return err
+				}
+			}
+			if err := p.rollbackNode(ctx, cluster, node, cont, exists, clusterNetwork, upgrade); err != nil {
+				node.Message = err.Error()
+				reportNodeUpgrade(ctx, node)
+				return fmt.Errorf("failed to roll back node %s: %w", node.Name, err)
+			}
+		}
+
+		node.State = v1alpha1.NodeUpgradeStateRolledBack
+		node.Message = ""
+		if node.Role == RoleControlPlane {
+			controlPlanes = append(controlPlanes, node)
+			continue
+		}
+		reportNodeUpgrade(ctx, node)
+	}
+
+	if len(controlPlanes) > 0 && upgrade.EtcdSnapshot != "" {
+		if err := p.restoreUpgradeSnapshot(ctx, cluster, upgrade.EtcdSnapshot); err != nil {
+			return err
+		}
+	}
+	for _, node := range controlPlanes {
+		reportNodeUpgrade(ctx, node)
+	}
+
+	log.Info("Rolled back upgrade", "version", upgrade.FromVersion)
+	return nil
+}
+
+// rollbackNode recreates a node of an upgrade from the image it ran before.
+// A node whose replacement was never created is created again and, in a
+// cluster bootstrapped with kubeadm, joined to it.
+func (p *DockerProvider) rollbackNode(ctx context.Context, cluster *v1alpha1.Cluster, node v1alpha1.NodeUpgradeStatus, cont container.Summary, exists bool, clusterNetwork *network.Summary, upgrade *v1alpha1.UpgradeStatus) error {
+	clusterLogger(ctx, cluster).V(1).Info("Rolling back node", "step", "rollback", "node", node.Name, "role", node.Role, "image", node.PreviousImage)
+	// The previous image was there before the upgrade; only pull it if it is gone
+	if err := p.images.ensureImage(ctx, cluster, node.PreviousImage, corev1.PullIfNotPresent); err != nil {
+		return err
+	}
+	machineConfig := machineConfigFor(&upgrade.PreviousSpec, node.Role)
+	if exists {
+		if err := p.replaceNode(ctx, cluster, cont, clusterNetwork, machineConfig, node.PreviousImage, upgrade.FromVersion); err != nil {
+			return err
+		}
+	} else {
+		if err := p.createNodeFromImage(ctx, cluster, node.Name, node.Role, clusterNetwork, machineConfig, node.PreviousImage); err != nil {
+			return err
+		}
+		if err := p.joinRecreatedNode(ctx, cluster, node.Name, node.Role, upgrade.FromVersion); err != nil {
+			return err
+		}
+	}
+	recordNormal(ctx, cluster, ReasonNodeRolledBack, "Rolled back %s node %s to %s", node.Role, node.Name, upgrade.FromVersion)
+	return nil
+}
+
+// restoreUpgradeSnapshot restores the etcd snapshot taken before an upgrade
+// on every node of the control plane the upgrade was rolled back on
+func (p *DockerProvider) restoreUpgradeSnapshot(ctx context.Context, cluster *v1alpha1.Cluster, snapshot string) error {
+	nodes, err := p.runningControlPlanes(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if err := p.restoreEtcdSnapshot(ctx, cluster, nodes, snapshot); err != nil {
+		return fmt.Errorf("failed to restore etcd snapshot %s: %w", snapshot, err)
+	}
+	recordNormal(ctx, cluster, ReasonEtcdRestored, "Restored etcd snapshot %s taken before the upgrade on nodes %s", snapshot, nodeNames(nodes))
+	return nil
+}
+
+// replaceNode replaces a node with one of the same name and role created from
+// image, which runs Kubernetes version. The new container takes over the
+// address of the old one and the volumes it got from its image: /var on kind
+// images, which holds the data of the kubelet and of etcd.
+//
+// A node of a cluster bootstrapped with kubeadm leaves the cluster first and
+// joins it again through another control plane node; a control plane node
+// gives up its etcd member too. The only control plane node of a cluster has
+// no node to join through: its replacement gets its kubeadm configuration and
+// comes back up on its etcd data.
+func (p *DockerProvider) replaceNode(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary, clusterNetwork *network.Summary, machineConfig v1alpha1.MachineConfig, image, version string) error {
+	name, role := containerName(cont), nodeRole(cont.Labels)
+	info, err := p.client.ContainerInspect(ctx, cont.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", cont.ID, err)
+	}
+
+	var through *container.Summary
+	var kubernetesConfig []byte
+	if p.joinedNode(ctx, cont.ID) {
+		if through, err = p.leaveCluster(ctx, cluster, cont); err != nil {
+			return err
+		}
+		if through == nil {
+			if kubernetesConfig, err = p.readKubernetesConfig(ctx, cont.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := p.removeNodeContainer(ctx, cluster, cont, 60, false); err != nil {
+		return err
+	}
+	if err := p.createNodeReplacing(ctx, cluster, name, role, clusterNetwork, machineConfig, image, &info); err != nil {
+		return err
+	}
+	switch {
+	case through != nil:
+		return p.rejoinNode(ctx, cluster, *through, name, role, version)
+	case kubernetesConfig != nil:
+		return p.restartControlPlane(ctx, cluster, name, kubernetesConfig, version)
+	}
+	return nil
+}
+
+// leaveCluster takes a node that joined its cluster out of it before the node
+// is replaced, and returns the control plane node to join it again through. A
+// control plane node gives up its etcd member, as long as etcd keeps a
+// majority of healthy members without it; if it holds the only one, nil is
+// returned and the node stays in the cluster.
+func (p *DockerProvider) leaveCluster(ctx context.Context, cluster *v1alpha1.Cluster, cont container.Summary) (*container.Summary, error) {
+	name := containerName(cont)
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	nodes, _, err := p.inspectControlPlane(ctx, cluster, containers)
+	if err != nil {
+		return nil, fmt.Errorf("cannot replace node %s: %w", name, err)
+	}
+	var self *controlPlaneNode
+	var through *container.Summary
+	for i := range nodes {
+		switch {
+		case nodes[i].summary.ID == cont.ID:
+			self = &nodes[i]
+		case through == nil && nodes[i].member && nodes[i].summary.State == "running" && p.bootstrapped(ctx, nodes[i].summary.ID):
+			through = &nodes[i].summary
+		}
+	}
+	switch {
+	case through == nil && self != nil:
+		return nil, nil
+	case through == nil:
+		return nil, fmt.Errorf("cannot replace node %s: no running control plane node to join it to the cluster again", name)
+	case self == nil:
+		return through, p.drainNode(ctx, cluster, *through, cont)
+	}
+
+	members, err := p.etcdMembers(ctx, *through)
+	if err != nil {
+		return nil, err
+	}
+	member := findEtcdMember(members, *self)
+	if member != nil && !keepsQuorum(members, member.id) {
+		return nil, fmt.Errorf("replacing control plane node %s would leave etcd without a majority of healthy members", name)
+	}
+	if err := p.drainNode(ctx, cluster, *through, cont); err != nil {
+		return nil, err
+	}
+	if member != nil {
+		if err := p.removeEtcdMember(ctx, cluster, *through, members, *member); err != nil {
+			return nil, err
+		}
+	}
+	return through, nil
+}
+
+// rejoinNode joins a replaced node to its cluster again through the control
+// plane node through. A control plane node discards the data of the etcd
+// member it gave up, which would keep its new member from starting.
+func (p *DockerProvider) rejoinNode(ctx context.Context, cluster *v1alpha1.Cluster, through container.Summary, name, role, version string) error {
+	if role != RoleControlPlane {
+		join, err := p.kubeadmJoinCommand(ctx, through.ID)
+		if err != nil {
+			return fmt.Errorf("cannot rejoin node %s: %w", name, err)
+		}
+		return p.joinNode(ctx, cluster, name, join)
+	}
+
+	if _, err := p.execInContainer(ctx, name, "rm", "-rf", path.Join(etcdDataDir, "member")); err != nil {
+		return fmt.Errorf("failed to discard etcd data of node %s: %w", name, err)
+	}
+	if err := p.joinControlPlaneNode(ctx, cluster, through, name, name); err != nil {
+		return err
+	}
+	if err := p.upgradeControlPlane(ctx, cluster, name, version); err != nil {
+		return err
+	}
+	return p.updateLoadBalancer(ctx, cluster)
+}
+
+// joinRecreatedNode joins a node that had to be created again, because its
+// replacement was never created, through any control plane node with an
+// etcd member. Clusters that were not bootstrapped with kubeadm have nothing
+// to join.
+func (p *DockerProvider) joinRecreatedNode(ctx context.Context, cluster *v1alpha1.Cluster, name, role, version string) error {
+	containers, err := p.listClusterContainers(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	_, admin, err := p.inspectControlPlane(ctx, cluster, containers)
+	if err != nil {
+		return fmt.Errorf("cannot join node %s: %w", name, err)
+	}
+	if admin == nil {
+		return nil
+	}
+	return p.rejoinNode(ctx, cluster, *admin, name, role, version)
+}
+
+// readKubernetesConfig returns a tar archive of the kubeadm configuration of
+// a node: its kubeconfigs, certificates and static pod manifests
+func (p *DockerProvider) readKubernetesConfig(ctx context.Context, nodeID string) ([]byte, error) {
+	reader, _, err := p.client.CopyFromContainer(ctx, nodeID, kubernetesConfigDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container: %w", kubernetesConfigDir, err)
+	}
+	defer reader.Close()
+	config, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container: %w", kubernetesConfigDir, err)
+	}
+	return config, nil
+}
+
+// restartControlPlane brings the only control plane node of a cluster up
+// again in its replacement: with the kubeadm configuration of the replaced
+// container back in place, the kubelet starts etcd on the data the
+// replacement took over, and the control plane is then moved to version
+func (p *DockerProvider) restartControlPlane(ctx context.Context, cluster *v1alpha1.Cluster, name string, kubernetesConfig []byte, version string) error {
+	if err := p.client.CopyToContainer(ctx, name, path.Dir(kubernetesConfigDir), bytes.NewReader(kubernetesConfig), container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to restore kubeadm configuration of node %s: %w", name, err)
+	}
+	if err := p.waitForContainerRuntime(ctx, name); err != nil {
+		return err
+	}
+	if _, err := p.execInContainer(ctx, name, "systemctl", "restart", "kubelet"); err != nil {
+		return fmt.Errorf("failed to restart kubelet on node %s: %w", name, err)
+	}
+	return p.upgradeControlPlane(ctx, cluster, name, version)
+}
+
+// upgradeControlPlane waits for the API server of a control plane node and
+// moves its control plane components, and the kubeadm configuration that
+// nodes joining later get, to version. Forcing the upgrade lets rollbacks go
+// back to an older version.
+func (p *DockerProvider) upgradeControlPlane(ctx context.Context, cluster *v1alpha1.Cluster, name, version string) error {
+	if err := p.waitForAPIServer(ctx, cluster, container.Summary{ID: name, Names: []string{"/" + name}}); err != nil {
+		return err
+	}
+	if version == "" {
+		return nil
+	}
+	clusterLogger(ctx, cluster).Info("Upgrading control plane", "step", "upgrade", "node", name, "version", version)
+	if _, err := p.execInContainer(ctx, name, "kubeadm", "upgrade", "apply", version, "--yes", "--force", "--ignore-preflight-errors=all"); err != nil {
+		return fmt.Errorf("failed to upgrade control plane of node %s to %s: %w", name, version, err)
+	}
+	return nil
+}
+
+// controlPlaneVersion returns the Kubernetes version the control plane nodes
+// run, going by the tags of their images, or "" if they do not all run the
+// same known version
+func controlPlaneVersion(containers []container.Summary) string {
+	version := ""
+	for _, cont := range containers {
+		if nodeRole(cont.Labels) != RoleControlPlane {
+			continue
+		}
+		tag := imageTag(cont.Labels[LabelNodeImage])
+		if tag == "" || (version != "" && tag != version) {
+			return ""
+		}
+		version = tag
+	}
+	return version
+}
+
+// requireClusterNetwork returns the Docker network of the cluster, which has to exist
+func (p *DockerProvider) requireClusterNetwork(ctx context.Context, cluster *v1alpha1.Cluster) (*network.Summary, error) {
+	clusterNetwork, err := p.getClusterNetwork(ctx, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster network: %w", err)
+	}
+	if clusterNetwork == nil {
+		return nil, fmt.Errorf("failed to get cluster network: no network found for cluster %s", cluster.Name)
+	}
+	return clusterNetwork, nil
+}
+
+// machineConfigFor returns the machine configuration of nodes with role in spec
+func machineConfigFor(spec *v1alpha1.ClusterSpec, role string) v1alpha1.MachineConfig {
+	if role == RoleControlPlane {
+		return spec.ControlPlane.MachineConfig
+	}
+	return spec.Workers.MachineConfig
+}
@@ -0,0 +1,242 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers/dockerfake"
+)
+
+const (
+	testOldNodeImage = "kindest/node:v1.27.13"
+	testNewNodeImage = "kindest/node:v1.28.13"
+)
+
+// kubeadmNodes answers the commands the provider runs on the nodes of a
+// cluster bootstrapped with kubeadm whose control plane node holds the only
+// etcd member, and records them by node
+type kubeadmNodes struct {
+	controlPlane string
+	worker       string
+
+	mu          sync.Mutex
+	execs       []string
+	etcdStopped bool
+}
+
+func (k *kubeadmNodes) handle(name string, cmd []string) (dockerfake.ExecResult, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	line := strings.Join(cmd, " ")
+	switch {
+	case cmd[0] == "crictl" && cmd[1] == "ps":
+		if k.etcdStopped {
+			return dockerfake.ExecResult{}, true
+		}
+		return dockerfake.ExecResult{Stdout: "etcd0\n"}, true
+	case strings.HasSuffix(line, "member list --write-out=json"):
+		return dockerfake.ExecResult{Stdout: fmt.Sprintf(`{"members":[{"ID":1,"name":%q,"clientURLs":["https://127.0.0.1:2379"]}]}`, k.controlPlane)}, true
+	case cmd[0] == "sh" && strings.Contains(line, "mv "+staticPodManifests+"/etcd.yaml"):
+		k.etcdStopped = true
+	case cmd[0] == "sh" && strings.Contains(line, "mv "+stoppedManifests+"/etcd.yaml"):
+		k.etcdStopped = false
+	case cmd[0] == "kubeadm" && cmd[1] == "token":
+		return dockerfake.ExecResult{Stdout: "kubeadm join " + k.controlPlane + ":6443 --token abc.def --discovery-token-ca-cert-hash sha256:00\n"}, true
+	case cmd[0] == "kubectl" && strings.Contains(line, " get node "):
+		return dockerfake.ExecResult{Stdout: "node/" + cmd[3] + "\n"}, true
+	case cmd[0] == "test", cmd[0] == "cat", cmd[0] == "rm":
+		return dockerfake.ExecResult{}, false
+	}
+	k.execs = append(k.execs, name+": "+line)
+	return dockerfake.ExecResult{}, true
+}
+
+// ran returns the recorded commands that start with prefix
+func (k *kubeadmNodes) ran(prefix string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var matches []string
+	for _, exec := range k.execs {
+		if strings.HasPrefix(exec, prefix) {
+			matches = append(matches, exec)
+		}
+	}
+	return matches
+}
+
+// addKindNodeImage adds a node image that, like kind's, declares /var a volume
+func addKindNodeImage(t *testing.T, engine *dockerfake.Engine, ref string) {
+	t.Helper()
+	ctx := context.Background()
+	engine.AddImage("kindest/base:test")
+	resp, err := engine.ContainerCreate(ctx, &container.Config{Image: "kindest/base:test", Volumes: map[string]struct{}{"/var": {}}}, &container.HostConfig{}, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create image container: %v", err)
+	}
+	if _, err := engine.ContainerCommit(ctx, resp.ID, container.CommitOptions{Reference: ref}); err != nil {
+		t.Fatalf("Failed to commit image %s: %v", ref, err)
+	}
+	if err := engine.ContainerRemove(ctx, resp.ID, container.RemoveOptions{RemoveVolumes: true}); err != nil {
+		t.Fatalf("Failed to remove image container: %v", err)
+	}
+}
+
+// newKubeadmCluster creates a cluster with a control plane node and a worker
+// from testOldNodeImage and makes them look bootstrapped with kubeadm
+func newKubeadmCluster(t *testing.T) (*DockerProvider, *dockerfake.Engine, *v1alpha1.Cluster, *kubeadmNodes) {
+	t.Helper()
+	ctx := context.Background()
+	provider, engine := newFakeProvider(t)
+	provider.versions = nil
+	addKindNodeImage(t, engine, testOldNodeImage)
+	addKindNodeImage(t, engine, testNewNodeImage)
+
+	cluster := newFakeCluster("upgrade", 1)
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	nodes := &kubeadmNodes{
+		controlPlane: provider.getNodeName(cluster, RoleControlPlane, 0),
+		worker:       provider.getNodeName(cluster, RoleWorker, 0),
+	}
+	files := map[string][]string{
+		nodes.controlPlane: {adminKubeconfig, kubeletKubeconfig, staticPodManifests + "/etcd.yaml"},
+		nodes.worker:       {kubeletKubeconfig},
+	}
+	manifest := fmt.Sprintf("    - --name=%s\n    - --initial-advertise-peer-urls=https://172.30.0.2:2380\n", nodes.controlPlane)
+	for name, paths := range files {
+		for _, path := range paths {
+			if err := engine.WriteFile(name, path, []byte(manifest)); err != nil {
+				t.Fatalf("Failed to write %s on %s: %v", path, name, err)
+			}
+		}
+	}
+
+	engine.HandleExec(nodes.handle)
+	return provider, engine, cluster, nodes
+}
+
+// varVolume returns the name of the volume a node has at /var
+func varVolume(t *testing.T, engine *dockerfake.Engine, name string) string {
+	t.Helper()
+	info, err := engine.ContainerInspect(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Failed to inspect %s: %v", name, err)
+	}
+	for _, m := range info.Mounts {
+		if m.Type == mount.TypeVolume && m.Destination == "/var" {
+			return m.Name
+		}
+	}
+	t.Fatalf("Expected %s to have a volume at /var, got %+v", name, info.Mounts)
+	return ""
+}
+
+func TestUpgradeNodesRejoinsKubeadmCluster(t *testing.T) {
+	ctx := context.Background()
+	provider, engine, cluster, nodes := newKubeadmCluster(t)
+	volumes := map[string]string{}
+	for _, name := range []string{nodes.controlPlane, nodes.worker} {
+		volumes[name] = varVolume(t, engine, name)
+	}
+
+	cluster.Spec.KubernetesVersion = "v1.28.13"
+	if err := provider.upgradeNodes(ctx, cluster); err != nil {
+		t.Fatalf("Failed to upgrade nodes: %v", err)
+	}
+
+	for name, volume := range volumes {
+		info, err := engine.ContainerInspect(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to inspect %s: %v", name, err)
+		}
+		if info.Config.Labels[LabelNodeImage] != testNewNodeImage {
+			t.Errorf("Expected %s to run %s, got %s", name, testNewNodeImage, info.Config.Labels[LabelNodeImage])
+		}
+		if got := varVolume(t, engine, name); got != volume {
+			t.Errorf("Expected %s to keep volume %s at /var, got %s", name, volume, got)
+		}
+	}
+
+	// The only control plane node comes back with its kubeadm configuration
+	if _, err := engine.ReadFile(nodes.controlPlane, adminKubeconfig); err != nil {
+		t.Errorf("Expected the control plane node to keep its admin kubeconfig: %v", err)
+	}
+	if upgrades := nodes.ran(nodes.controlPlane + ": kubeadm upgrade apply v1.28.13"); len(upgrades) != 1 {
+		t.Errorf("Expected the control plane to be upgraded with kubeadm once, got %v", upgrades)
+	}
+	// The worker is drained through it and joins again
+	if drains := nodes.ran(nodes.controlPlane + ": kubectl --kubeconfig=" + adminKubeconfig + " drain " + nodes.worker); len(drains) != 1 {
+		t.Errorf("Expected the worker to be drained once, got %v", drains)
+	}
+	if joins := nodes.ran(nodes.worker + ": kubeadm join"); len(joins) != 1 {
+		t.Errorf("Expected the worker to join again, got %v", joins)
+	}
+}
+
+func TestRollbackUpgradeRestoresEtcd(t *testing.T) {
+	ctx := context.Background()
+	provider, _, cluster, nodes := newKubeadmCluster(t)
+	cluster.Spec.KubernetesVersion = "v1.28.13"
+	if err := provider.upgradeNodes(ctx, cluster); err != nil {
+		t.Fatalf("Failed to upgrade nodes: %v", err)
+	}
+
+	snapshot := filepath.Join(t.TempDir(), "etcd.db")
+	if err := os.WriteFile(snapshot, []byte("snapshot"), 0o644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	upgrade := &v1alpha1.UpgradeStatus{
+		FromVersion:  "v1.27.13",
+		ToVersion:    "v1.28.13",
+		EtcdSnapshot: snapshot,
+		PreviousSpec: cluster.Spec,
+	}
+	for name, role := range map[string]string{nodes.controlPlane: RoleControlPlane, nodes.worker: RoleWorker} {
+		upgrade.Nodes = append(upgrade.Nodes, v1alpha1.NodeUpgradeStatus{
+			Name: name, Role: role, PreviousImage: testOldNodeImage, Image: testNewNodeImage, State: v1alpha1.NodeUpgradeStateUpgraded,
+		})
+	}
+
+	var reported []string
+	ctx = WithNodeUpgradeReporter(ctx, func(node v1alpha1.NodeUpgradeStatus) {
+		reported = append(reported, fmt.Sprintf("%s %s", node.Name, node.State))
+	})
+	if err := provider.RollbackUpgrade(ctx, cluster, upgrade); err != nil {
+		t.Fatalf("Failed to roll back upgrade: %v", err)
+	}
+
+	if downgrades := nodes.ran(nodes.controlPlane + ": kubeadm upgrade apply v1.27.13"); len(downgrades) != 1 {
+		t.Errorf("Expected the control plane to go back to v1.27.13 with kubeadm, got %v", downgrades)
+	}
+	if restores := nodes.ran(nodes.controlPlane + ": crictl exec etcd0 etcdutl snapshot restore"); len(restores) != 1 {
+		t.Errorf("Expected the snapshot to be restored on the control plane node, got %v", restores)
+	}
+	want := []string{nodes.worker + " RolledBack", nodes.controlPlane + " RolledBack"}
+	if strings.Join(reported, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected nodes reported as %v, got %v", want, reported)
+	}
+}
+
+func TestSnapshotEtcdWithoutKubeadm(t *testing.T) {
+	ctx := context.Background()
+	provider, _ := newFakeProvider(t)
+	cluster := newFakeCluster("plain", 0)
+	if err := provider.CreateCluster(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+
+	path, err := provider.SnapshotEtcd(ctx, cluster)
+	if err != nil || path != "" {
+		t.Errorf("Expected no snapshot of a cluster without etcd, got %q, %v", path, err)
+	}
+}
@@ -0,0 +1,588 @@
+package standalone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// DefaultWatchInterval is how often watches look for changes in the store
+const DefaultWatchInterval = time.Second
+
+// Client is a controller-runtime client over a ClusterStore. It gives
+// objects what the API server gives them: UIDs, creation timestamps,
+// resource versions checked on update, generations that count spec changes,
+// a status subresource, finalizers that hold up deletion, and removal of
+// the objects a removed object owns. Watches poll the store.
+type Client struct {
+	store  ClusterStore
+	scheme *runtime.Scheme
+	mapper meta.RESTMapper
+
+	// Now returns the current time, for creation and deletion timestamps
+	Now func() time.Time
+
+	// WatchInterval is how often watches look for changes in the store
+	WatchInterval time.Duration
+}
+
+var _ client.WithWatch = &Client{}
+
+// NewClient returns a client keeping the objects of the kinds in scheme in store
+func NewClient(store ClusterStore, scheme *runtime.Scheme) *Client {
+	mapper := meta.NewDefaultRESTMapper(scheme.PrioritizedVersionsAllGroups())
+	for gvk := range scheme.AllKnownTypes() {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	return &Client{
+		store:         store,
+		scheme:        scheme,
+		mapper:        mapper,
+		Now:           time.Now,
+		WatchInterval: DefaultWatchInterval,
+	}
+}
+
+// Scheme implements client.Client
+func (c *Client) Scheme() *runtime.Scheme {
+	return c.scheme
+}
+
+// RESTMapper implements client.Client
+func (c *Client) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+// GroupVersionKindFor implements client.Client
+func (c *Client) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	return apiutil.GVKForObject(obj, c.scheme)
+}
+
+// IsObjectNamespaced implements client.Client. The store keeps every
+// object in a namespace.
+func (c *Client) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	return true, nil
+}
+
+// Get implements client.Client
+func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	data, err := c.store.Get(ctx, storeKey(gvk, key.Namespace, key.Name))
+	if errors.Is(err, ErrNotFound) {
+		return apierrors.NewNotFound(groupResource(gvk), key.Name)
+	}
+	if err != nil {
+		return err
+	}
+	return decode(data, obj)
+}
+
+// List implements client.Client. Label selectors and field selectors on
+// metadata.name and metadata.namespace are supported.
+func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	gvk, err := c.GroupVersionKindFor(list)
+	if err != nil {
+		return err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+
+	objects, err := c.list(ctx, gvk, listOpts)
+	if err != nil {
+		return err
+	}
+	items := make([]runtime.Object, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, object)
+	}
+	return meta.SetList(list, items)
+}
+
+// list returns the objects of kind gvk that opts select
+func (c *Client) list(ctx context.Context, gvk schema.GroupVersionKind, opts *client.ListOptions) ([]client.Object, error) {
+	stored, err := c.store.List(ctx, gvk.GroupKind().String(), opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	var objects []client.Object
+	for _, data := range stored {
+		object, err := c.newObject(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if err := decode(data, object); err != nil {
+			return nil, err
+		}
+		if opts.LabelSelector != nil && !opts.LabelSelector.Matches(labels.Set(object.GetLabels())) {
+			continue
+		}
+		if opts.FieldSelector != nil && !opts.FieldSelector.Matches(fields.Set{"metadata.name": object.GetName(), "metadata.namespace": object.GetNamespace()}) {
+			continue
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// Create implements client.Client
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	if obj.GetName() == "" && obj.GetGenerateName() != "" {
+		obj.SetName(obj.GetGenerateName() + rand.String(5))
+	}
+	if obj.GetName() == "" {
+		return apierrors.NewBadRequest("name or generateName is required")
+	}
+
+	object, err := toMap(gvk, obj)
+	if err != nil {
+		return err
+	}
+	// What the server sets is not taken from the request, status included
+	delete(object, "status")
+	metadata := metadataOf(object)
+	delete(metadata, "deletionTimestamp")
+	metadata["uid"] = string(uuid.NewUUID())
+	metadata["creationTimestamp"] = c.Now().UTC().Format(time.RFC3339)
+	metadata["resourceVersion"] = "1"
+	metadata["generation"] = int64(1)
+
+	key := storeKey(gvk, obj.GetNamespace(), obj.GetName())
+	var stored []byte
+	err = c.store.Update(ctx, key, func(current []byte) ([]byte, error) {
+		if current != nil {
+			return nil, apierrors.NewAlreadyExists(groupResource(gvk), obj.GetName())
+		}
+		stored, err = json.Marshal(object)
+		return stored, err
+	})
+	if err != nil {
+		return err
+	}
+	return decode(stored, obj)
+}
+
+// Update implements client.Client. The status of obj is ignored; it is
+// updated through the status subresource.
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.update(ctx, obj, false, func(current map[string]interface{}) (map[string]interface{}, error) {
+		gvk, err := c.GroupVersionKindFor(obj)
+		if err != nil {
+			return nil, err
+		}
+		return toMap(gvk, obj)
+	})
+}
+
+// Patch implements client.Client
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.update(ctx, obj, false, c.patcher(obj, patch))
+}
+
+// Delete implements client.Client. Objects with finalizers are marked for
+// deletion and removed once their finalizers are.
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	return c.delete(ctx, storeKey(gvk, obj.GetNamespace(), obj.GetName()), groupResource(gvk))
+}
+
+// DeleteAllOf implements client.Client
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	deleteOpts := (&client.DeleteAllOfOptions{}).ApplyOptions(opts)
+	objects, err := c.list(ctx, gvk, &deleteOpts.ListOptions)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		err := c.delete(ctx, storeKey(gvk, object.GetNamespace(), object.GetName()), groupResource(gvk))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status implements client.Client
+func (c *Client) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+// SubResource implements client.Client. Only the status subresource exists.
+func (c *Client) SubResource(subResource string) client.SubResourceClient {
+	return &subResourceClient{client: c, name: subResource}
+}
+
+// update replaces the stored obj with what modify makes of it. Updates of
+// the main resource keep the stored status and count spec changes in the
+// generation; status updates change nothing but the status. The stored
+// result is decoded into obj.
+func (c *Client) update(ctx context.Context, obj client.Object, status bool, modify func(current map[string]interface{}) (map[string]interface{}, error)) error {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	gr := groupResource(gvk)
+	key := storeKey(gvk, obj.GetNamespace(), obj.GetName())
+
+	var stored []byte
+	var removed types.UID
+	err = c.store.Update(ctx, key, func(data []byte) ([]byte, error) {
+		if data == nil {
+			return nil, apierrors.NewNotFound(gr, obj.GetName())
+		}
+		current := map[string]interface{}{}
+		if err := json.Unmarshal(data, &current); err != nil {
+			return nil, err
+		}
+		modified, err := modify(runtime.DeepCopyJSON(current))
+		if err != nil {
+			return nil, err
+		}
+		// Compare the modified object with the stored one as JSON
+		if modified, err = normalize(modified); err != nil {
+			return nil, err
+		}
+
+		currentMeta, modifiedMeta := metadataOf(current), metadataOf(modified)
+		version, _ := currentMeta["resourceVersion"].(string)
+		if requested, _ := modifiedMeta["resourceVersion"].(string); requested != "" && requested != version {
+			return nil, apierrors.NewConflict(gr, obj.GetName(),
+				fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+		}
+
+		var updated map[string]interface{}
+		if status {
+			updated = current
+			if s, ok := modified["status"]; ok {
+				updated["status"] = s
+			} else {
+				delete(updated, "status")
+			}
+		} else {
+			updated = modified
+			if s, ok := current["status"]; ok {
+				updated["status"] = s
+			} else {
+				delete(updated, "status")
+			}
+			updatedMeta := metadataOf(updated)
+			for _, field := range []string{"name", "namespace", "uid", "creationTimestamp", "deletionTimestamp", "generation"} {
+				if v, ok := currentMeta[field]; ok {
+					updatedMeta[field] = v
+				} else {
+					delete(updatedMeta, field)
+				}
+			}
+			if !reflect.DeepEqual(withoutMetadataAndStatus(current), withoutMetadataAndStatus(updated)) {
+				updatedMeta["generation"] = generationOf(currentMeta) + 1
+			}
+		}
+		updatedMeta := metadataOf(updated)
+		updatedMeta["resourceVersion"] = nextVersion(version)
+
+		// An object marked for deletion goes once its last finalizer does
+		if updatedMeta["deletionTimestamp"] != nil && len(finalizersOf(updatedMeta)) == 0 {
+			removed = types.UID(fmt.Sprint(updatedMeta["uid"]))
+			stored, err = json.Marshal(updated)
+			return nil, err
+		}
+		stored, err = json.Marshal(updated)
+		return stored, err
+	})
+	if err != nil {
+		return err
+	}
+	if err := decode(stored, obj); err != nil {
+		return err
+	}
+	if removed != "" {
+		return c.collectGarbage(ctx, obj.GetNamespace(), removed)
+	}
+	return nil
+}
+
+// patcher returns the modification of update that applies patch
+func (c *Client) patcher(obj client.Object, patch client.Patch) func(current map[string]interface{}) (map[string]interface{}, error) {
+	return func(current map[string]interface{}) (map[string]interface{}, error) {
+		data, err := patch.Data(obj)
+		if err != nil {
+			return nil, err
+		}
+		original, err := json.Marshal(current)
+		if err != nil {
+			return nil, err
+		}
+		var patched []byte
+		switch patch.Type() {
+		case types.MergePatchType:
+			patched, err = jsonpatch.MergePatch(original, data)
+		case types.JSONPatchType:
+			var p jsonpatch.Patch
+			if p, err = jsonpatch.DecodePatch(data); err == nil {
+				patched, err = p.Apply(original)
+			}
+		case types.StrategicMergePatchType:
+			patched, err = strategicpatch.StrategicMergePatch(original, data, obj)
+		default:
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("patch type %s is not supported", patch.Type()))
+		}
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid patch: %v", err))
+		}
+		modified := map[string]interface{}{}
+		if err := json.Unmarshal(patched, &modified); err != nil {
+			return nil, err
+		}
+		return modified, nil
+	}
+}
+
+// delete removes the object under key, or marks it for deletion if it has
+// finalizers, and then removes the objects a removed object owned
+func (c *Client) delete(ctx context.Context, key Key, gr schema.GroupResource) error {
+	var removed types.UID
+	err := c.store.Update(ctx, key, func(data []byte) ([]byte, error) {
+		if data == nil {
+			return nil, apierrors.NewNotFound(gr, key.Name)
+		}
+		current := map[string]interface{}{}
+		if err := json.Unmarshal(data, &current); err != nil {
+			return nil, err
+		}
+		metadata := metadataOf(current)
+		if len(finalizersOf(metadata)) == 0 {
+			removed = types.UID(fmt.Sprint(metadata["uid"]))
+			return nil, nil
+		}
+		if metadata["deletionTimestamp"] != nil {
+			return data, nil
+		}
+		metadata["deletionTimestamp"] = c.Now().UTC().Format(time.RFC3339)
+		version, _ := metadata["resourceVersion"].(string)
+		metadata["resourceVersion"] = nextVersion(version)
+		return json.Marshal(current)
+	})
+	if err != nil {
+		return err
+	}
+	if removed != "" {
+		return c.collectGarbage(ctx, key.Namespace, removed)
+	}
+	return nil
+}
+
+// collectGarbage deletes the objects in namespace that owner owned
+func (c *Client) collectGarbage(ctx context.Context, namespace string, owner types.UID) error {
+	stored, err := c.store.List(ctx, "", namespace)
+	if err != nil {
+		return err
+	}
+	for _, data := range stored {
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		for _, ref := range object.GetOwnerReferences() {
+			if ref.UID != owner {
+				continue
+			}
+			gvk := object.GroupVersionKind()
+			err := c.delete(ctx, storeKey(gvk, object.GetNamespace(), object.GetName()), groupResource(gvk))
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// newObject returns an empty object of kind gvk
+func (c *Client) newObject(gvk schema.GroupVersionKind) (client.Object, error) {
+	object, err := c.scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := object.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", gvk)
+	}
+	return o, nil
+}
+
+// subResourceClient gives access to a subresource of the store's objects
+type subResourceClient struct {
+	client *Client
+	name   string
+}
+
+// Get implements client.SubResourceClient
+func (s *subResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return fmt.Errorf("subresource %s is not supported", s.name)
+}
+
+// Create implements client.SubResourceClient
+func (s *subResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return fmt.Errorf("subresource %s is not supported", s.name)
+}
+
+// Update implements client.SubResourceClient
+func (s *subResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if s.name != "status" {
+		return fmt.Errorf("subresource %s is not supported", s.name)
+	}
+	return s.client.update(ctx, obj, true, func(current map[string]interface{}) (map[string]interface{}, error) {
+		gvk, err := s.client.GroupVersionKindFor(obj)
+		if err != nil {
+			return nil, err
+		}
+		return toMap(gvk, obj)
+	})
+}
+
+// Patch implements client.SubResourceClient
+func (s *subResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if s.name != "status" {
+		return fmt.Errorf("subresource %s is not supported", s.name)
+	}
+	return s.client.update(ctx, obj, true, s.client.patcher(obj, patch))
+}
+
+// storeKey returns the key objects of kind gvk are stored under
+func storeKey(gvk schema.GroupVersionKind, namespace, name string) Key {
+	return Key{Kind: gvk.GroupKind().String(), Namespace: namespace, Name: name}
+}
+
+// groupResource returns the resource of kind gvk, for errors
+func groupResource(gvk schema.GroupVersionKind) schema.GroupResource {
+	return schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind) + "s"}
+}
+
+// toMap returns obj as the unstructured content it is stored as
+func toMap(gvk schema.GroupVersionKind, obj client.Object) (map[string]interface{}, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	object["apiVersion"], object["kind"] = gvk.GroupVersion().String(), gvk.Kind
+	return object, nil
+}
+
+// decode sets obj to the stored object data
+func decode(data []byte, obj client.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.Object = nil
+		return u.UnmarshalJSON(data)
+	}
+	v := reflect.ValueOf(obj).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return json.Unmarshal(data, obj)
+}
+
+// normalize returns object as it reads back from JSON
+func normalize(object map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	normalized := map[string]interface{}{}
+	return normalized, json.Unmarshal(data, &normalized)
+}
+
+// metadataOf returns the metadata of an unstructured object, adding it if missing
+func metadataOf(object map[string]interface{}) map[string]interface{} {
+	metadata, ok := object["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		object["metadata"] = metadata
+	}
+	return metadata
+}
+
+// withoutMetadataAndStatus returns the content of an object whose changes
+// count in its generation
+func withoutMetadataAndStatus(object map[string]interface{}) map[string]interface{} {
+	content := map[string]interface{}{}
+	for k, v := range object {
+		if k != "metadata" && k != "status" {
+			content[k] = v
+		}
+	}
+	return content
+}
+
+// finalizersOf returns the finalizers in metadata
+func finalizersOf(metadata map[string]interface{}) []interface{} {
+	finalizers, _ := metadata["finalizers"].([]interface{})
+	return finalizers
+}
+
+// generationOf returns the generation in metadata
+func generationOf(metadata map[string]interface{}) int64 {
+	switch g := metadata["generation"].(type) {
+	case int64:
+		return g
+	case float64:
+		return int64(g)
+	default:
+		return 0
+	}
+}
+
+// nextVersion returns the resource version following version
+func nextVersion(version string) string {
+	n, _ := strconv.ParseInt(version, 10, 64)
+	return strconv.FormatInt(n+1, 10)
+}
+
+// Watch implements client.WithWatch by polling the store. The objects there
+// when the watch starts are sent as added first, as the API server does for
+// watches without a resource version.
+func (c *Client) Watch(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	gvk, err := c.GroupVersionKindFor(list)
+	if err != nil {
+		return nil, err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+
+	w := &pollingWatch{result: make(chan watch.Event), stop: make(chan struct{})}
+	go w.run(ctx, c.WatchInterval, func() ([]client.Object, error) {
+		return c.list(ctx, gvk, listOpts)
+	})
+	return w, nil
+}
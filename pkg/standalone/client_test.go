@@ -0,0 +1,259 @@
+package standalone
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+)
+
+// newTestClient returns a client over a file store in a temporary directory
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	s := runtime.NewScheme()
+	clientgoscheme.AddToScheme(s)
+	v1alpha1.AddToScheme(s)
+
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewClient(store, s)
+}
+
+func newCluster(name string) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: v1alpha1.TestKubernetesVersion,
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+			Workers:           v1alpha1.WorkerConfig{Count: 1},
+		},
+	}
+}
+
+func TestClientCreateAndUpdate(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	cluster := newCluster("one")
+	cluster.Status.Phase = v1alpha1.ClusterPhaseRunning
+	if err := c.Create(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	if cluster.UID == "" || cluster.CreationTimestamp.IsZero() || cluster.ResourceVersion != "1" || cluster.Generation != 1 {
+		t.Errorf("Expected the server fields to be set, got %+v", cluster.ObjectMeta)
+	}
+	if cluster.Status.Phase != "" {
+		t.Errorf("Expected the status not to be created, got %q", cluster.Status.Phase)
+	}
+	if err := c.Create(ctx, newCluster("one")); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("Expected an AlreadyExists error, got %v", err)
+	}
+
+	// Status updates change neither the spec nor the generation
+	cluster.Status.Phase = v1alpha1.ClusterPhasePending
+	cluster.Spec.Workers.Count = 5
+	if err := c.Status().Update(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if cluster.Status.Phase != v1alpha1.ClusterPhasePending || cluster.Spec.Workers.Count != 1 || cluster.Generation != 1 {
+		t.Errorf("Expected only the status to change, got phase %q, %d workers, generation %d",
+			cluster.Status.Phase, cluster.Spec.Workers.Count, cluster.Generation)
+	}
+
+	// Updates keep the status and count spec changes in the generation
+	cluster.Labels = map[string]string{"team": "a"}
+	if err := c.Update(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+	if cluster.Generation != 1 {
+		t.Errorf("Expected a metadata change to keep generation 1, got %d", cluster.Generation)
+	}
+	cluster.Spec.Workers.Count = 3
+	cluster.Status.Phase = v1alpha1.ClusterPhaseFailed
+	if err := c.Update(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+	if cluster.Generation != 2 || cluster.Spec.Workers.Count != 3 || cluster.Status.Phase != v1alpha1.ClusterPhasePending {
+		t.Errorf("Expected generation 2 with 3 workers and the stored status, got generation %d, %d workers, phase %q",
+			cluster.Generation, cluster.Spec.Workers.Count, cluster.Status.Phase)
+	}
+
+	// Updates of an older version conflict
+	stale := cluster.DeepCopy()
+	stale.ResourceVersion = "1"
+	if err := c.Update(ctx, stale); !apierrors.IsConflict(err) {
+		t.Errorf("Expected a Conflict error, got %v", err)
+	}
+
+	// Merge patches apply to the stored object
+	patch := client.MergeFrom(cluster.DeepCopy())
+	cluster.Spec.Workers.Count = 4
+	if err := c.Patch(ctx, cluster, patch); err != nil {
+		t.Fatalf("Failed to patch cluster: %v", err)
+	}
+	var got v1alpha1.Cluster
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cluster), &got); err != nil {
+		t.Fatalf("Failed to get cluster: %v", err)
+	}
+	if got.Spec.Workers.Count != 4 || got.Generation != 3 || got.Labels["team"] != "a" {
+		t.Errorf("Expected the patched cluster, got %d workers, generation %d, labels %v", got.Spec.Workers.Count, got.Generation, got.Labels)
+	}
+}
+
+func TestClientList(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	for _, name := range []string{"b", "a", "c"} {
+		cluster := newCluster(name)
+		if name != "c" {
+			cluster.Labels = map[string]string{"env": "dev"}
+		}
+		if err := c.Create(ctx, cluster); err != nil {
+			t.Fatalf("Failed to create cluster: %v", err)
+		}
+	}
+	other := newCluster("d")
+	other.Namespace = "other"
+	if err := c.Create(ctx, other); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []client.ListOption
+		expected []string
+	}{
+		{name: "all", expected: []string{"a", "b", "c", "d"}},
+		{name: "namespace", opts: []client.ListOption{client.InNamespace("default")}, expected: []string{"a", "b", "c"}},
+		{name: "labels", opts: []client.ListOption{client.MatchingLabels{"env": "dev"}}, expected: []string{"a", "b"}},
+		{name: "name", opts: []client.ListOption{client.MatchingFields{"metadata.name": "c"}}, expected: []string{"c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list v1alpha1.ClusterList
+			if err := c.List(ctx, &list, tt.opts...); err != nil {
+				t.Fatalf("Failed to list clusters: %v", err)
+			}
+			var names []string
+			for _, cluster := range list.Items {
+				names = append(names, cluster.Name)
+			}
+			if len(names) != len(tt.expected) {
+				t.Fatalf("Expected clusters %v, got %v", tt.expected, names)
+			}
+			for i := range names {
+				if names[i] != tt.expected[i] {
+					t.Errorf("Expected clusters %v, got %v", tt.expected, names)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestClientDeletion(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	cluster := newCluster("one")
+	cluster.Finalizers = []string{"cluster.mini-k8s.io/finalizer"}
+	if err := c.Create(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:            "one-kubeconfig",
+		Namespace:       "default",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "cluster.mini-k8s.io/v1alpha1", Kind: "Cluster", Name: "one", UID: cluster.UID}},
+	}}
+	if err := c.Create(ctx, secret); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	// A finalizer holds up the deletion
+	if err := c.Delete(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cluster), cluster); err != nil {
+		t.Fatalf("Expected the cluster to be kept for its finalizer, got %v", err)
+	}
+	if cluster.DeletionTimestamp.IsZero() {
+		t.Error("Expected the cluster to be marked for deletion")
+	}
+	cluster.Finalizers = append(cluster.Finalizers, "other")
+	if err := c.Update(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update cluster: %v", err)
+	}
+	if cluster.DeletionTimestamp.IsZero() {
+		t.Error("Expected updates to keep the deletion timestamp")
+	}
+
+	// Removing the last finalizer removes the cluster and what it owns
+	cluster.Finalizers = nil
+	if err := c.Update(ctx, cluster); err != nil {
+		t.Fatalf("Failed to remove finalizers: %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(cluster), &v1alpha1.Cluster{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the cluster to be removed, got %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(secret), &corev1.Secret{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the owned secret to be removed, got %v", err)
+	}
+	if err := c.Delete(ctx, cluster); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected a NotFound error, got %v", err)
+	}
+}
+
+func TestClientWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := newTestClient(t)
+	c.WatchInterval = 10 * time.Millisecond
+
+	cluster := newCluster("one")
+	if err := c.Create(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	w, err := c.Watch(ctx, &v1alpha1.ClusterList{}, client.InNamespace("default"))
+	if err != nil {
+		t.Fatalf("Failed to watch clusters: %v", err)
+	}
+	defer w.Stop()
+
+	next := func(expected watch.EventType) {
+		t.Helper()
+		select {
+		case event := <-w.ResultChan():
+			if event.Type != expected {
+				t.Fatalf("Expected a %s event, got %s", expected, event.Type)
+			}
+			if name := event.Object.(client.Object).GetName(); name != "one" {
+				t.Errorf("Expected an event of cluster one, got %s", name)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for a %s event", expected)
+		}
+	}
+	next(watch.Added)
+	cluster.Status.Phase = v1alpha1.ClusterPhasePending
+	if err := c.Status().Update(ctx, cluster); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	next(watch.Modified)
+	if err := c.Delete(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+	next(watch.Deleted)
+}
@@ -0,0 +1,117 @@
+package standalone
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ref "k8s.io/client-go/tools/reference"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// EventRecorder records events as Event objects in the store. Repeated
+// events are counted in one Event, as the API server's event sink does, and
+// events are removed with the object they are about.
+type EventRecorder struct {
+	client    *Client
+	component string
+}
+
+var _ record.EventRecorder = &EventRecorder{}
+
+// NewEventRecorder returns a recorder of the events of component in the
+// store of c
+func NewEventRecorder(c *Client, component string) *EventRecorder {
+	return &EventRecorder{client: c, component: component}
+}
+
+// Event implements record.EventRecorder
+func (r *EventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.record(object, nil, eventtype, reason, message)
+}
+
+// Eventf implements record.EventRecorder
+func (r *EventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.record(object, nil, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// AnnotatedEventf implements record.EventRecorder
+func (r *EventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.record(object, annotations, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// record stores an event. Like the API server's recorder it only logs
+// failures, which must not fail the reconcile reporting the event.
+func (r *EventRecorder) record(object runtime.Object, annotations map[string]string, eventtype, reason, message string) {
+	ctx := context.Background()
+	logger := log.Log.WithName("events")
+
+	involved, err := ref.GetReference(r.client.Scheme(), object)
+	if err != nil {
+		logger.Error(err, "Failed to reference object of event", "reason", reason)
+		return
+	}
+	// Events with the same subject, type, reason and message are one Event
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s", involved.UID, r.component, eventtype, reason, message)
+	key := client.ObjectKey{Namespace: involved.Namespace, Name: fmt.Sprintf("%s.%x", involved.Name, h.Sum64())}
+	now := metav1.NewTime(r.client.Now())
+
+	var event corev1.Event
+	err = r.client.Get(ctx, key, &event)
+	if err == nil {
+		event.Count++
+		event.LastTimestamp = now
+		err = r.client.Update(ctx, &event)
+	} else if apierrors.IsNotFound(err) {
+		event = corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   key.Namespace,
+				Name:        key.Name,
+				Annotations: annotations,
+			},
+			InvolvedObject: *involved,
+			Type:           eventtype,
+			Reason:         reason,
+			Message:        message,
+			Source:         corev1.EventSource{Component: r.component},
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Count:          1,
+		}
+		if involved.UID != "" {
+			event.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: involved.APIVersion,
+				Kind:       involved.Kind,
+				Name:       involved.Name,
+				UID:        involved.UID,
+			}}
+		}
+		if err = r.client.Create(ctx, &event); err == nil && !r.exists(ctx, involved) {
+			// The object went before its event was stored, and its owned
+			// objects with it
+			err = client.IgnoreNotFound(r.client.Delete(ctx, &event))
+		}
+	}
+	if err != nil {
+		logger.Error(err, "Failed to record event", "object", key.Name, "reason", reason)
+	}
+}
+
+// exists returns whether the object involved in an event is still stored
+func (r *EventRecorder) exists(ctx context.Context, involved *corev1.ObjectReference) bool {
+	if involved.UID == "" {
+		return true
+	}
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(involved.GroupVersionKind())
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: involved.Namespace, Name: involved.Name}, object)
+	return err == nil && object.GetUID() == involved.UID
+}
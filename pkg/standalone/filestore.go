@@ -0,0 +1,187 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// clusterScoped is the directory of objects without a namespace
+const clusterScoped = "_"
+
+// FileStore is a ClusterStore keeping each object in a file of its own,
+// <dir>/<kind>/<namespace>/<name>.json. Files are replaced atomically, so
+// reads need no lock, and a lock file serializes updates across the
+// processes sharing the directory, such as the CLI and a daemon.
+type FileStore struct {
+	dir string
+
+	// mu serializes updates within the process; the lock file does across
+	// processes
+	mu   sync.Mutex
+	lock *os.File
+}
+
+var _ ClusterStore = &FileStore{}
+
+// NewFileStore returns a store in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock: %w", err)
+	}
+	return &FileStore{dir: dir, lock: lock}, nil
+}
+
+// Dir returns the directory of the store
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+// Get implements ClusterStore
+func (s *FileStore) Get(ctx context.Context, key Key) ([]byte, error) {
+	return s.read(key)
+}
+
+// List implements ClusterStore
+func (s *FileStore) List(ctx context.Context, kind, namespace string) ([][]byte, error) {
+	var err error
+	kinds := []string{kind}
+	if kind == "" {
+		if kinds, err = subdirectories(s.dir); err != nil {
+			return nil, err
+		}
+	}
+	var objects [][]byte
+	for _, kind := range kinds {
+		namespaces := []string{namespaceDir(namespace)}
+		if namespace == "" {
+			if namespaces, err = subdirectories(filepath.Join(s.dir, kind)); err != nil {
+				return nil, err
+			}
+		}
+		for _, ns := range namespaces {
+			files, err := filepath.Glob(filepath.Join(s.dir, kind, ns, "*.json"))
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(files)
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to read %s: %w", file, err)
+				}
+				objects = append(objects, data)
+			}
+		}
+	}
+	return objects, nil
+}
+
+// Update implements ClusterStore
+func (s *FileStore) Update(ctx context.Context, key Key, update func(current []byte) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := lockFile(s.lock); err != nil {
+		return fmt.Errorf("failed to lock state directory: %w", err)
+	}
+	defer unlockFile(s.lock)
+
+	current, err := s.read(key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	updated, err := update(current)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(key)
+	if updated == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", key, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	// Write a temporary file and rename it over the object, so that readers
+	// never see a partly written object
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(updated); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+// Close implements ClusterStore
+func (s *FileStore) Close() error {
+	return s.lock.Close()
+}
+
+// read returns the object under key
+func (s *FileStore) read(key Key) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// path returns the file of the object under key
+func (s *FileStore) path(key Key) string {
+	return filepath.Join(s.dir, key.Kind, namespaceDir(key.Namespace), key.Name+".json")
+}
+
+// namespaceDir returns the directory of the objects in namespace
+func namespaceDir(namespace string) string {
+	if namespace == "" {
+		return clusterScoped
+	}
+	return namespace
+}
+
+// subdirectories returns the names of the directories in dir, which need not exist
+func subdirectories(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package standalone
+
+import "os"
+
+// lockFile does nothing where advisory file locks are not available; only
+// one process at a time should then use a state directory
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile releases the lock lockFile took
+func unlockFile(f *os.File) {}
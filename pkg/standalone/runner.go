@@ -0,0 +1,170 @@
+package standalone
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DefaultPollInterval is how often a Runner looks for changed objects
+	DefaultPollInterval = time.Second
+
+	// minRetryDelay and maxRetryDelay bound the delay before a failed
+	// reconcile is retried, which doubles with each failure in a row
+	minRetryDelay = time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// Controller is a reconciler and the kind of object it reconciles
+type Controller struct {
+	// Name identifies the controller in logs
+	Name string
+
+	// NewList returns an empty list of the reconciled objects
+	NewList func() client.ObjectList
+
+	Reconciler reconcile.Reconciler
+}
+
+// Runner drives controllers against the objects in a store, as a controller
+// manager does against the API server: objects are reconciled when they
+// change, when their reconciler asks for a requeue, and again after a
+// growing delay while reconciling them fails. Changes are found by polling
+// the store. Each controller reconciles one object at a time.
+type Runner struct {
+	Client      *Client
+	Controllers []Controller
+
+	// PollInterval is how often the store is polled for changed objects
+	PollInterval time.Duration
+}
+
+// NewRunner returns a runner of controllers against the objects of c
+func NewRunner(c *Client, controllers ...Controller) *Runner {
+	return &Runner{Client: c, Controllers: controllers, PollInterval: DefaultPollInterval}
+}
+
+// Run runs the controllers until ctx is done
+func (r *Runner) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, controller := range r.Controllers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := &queue{objects: map[types.NamespacedName]*queued{}}
+			for {
+				r.poll(ctx, controller, q)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(r.PollInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// queue is what a controller knows of the objects it reconciles
+type queue struct {
+	objects map[types.NamespacedName]*queued
+}
+
+// queued is the state of the reconciles of an object
+type queued struct {
+	// resourceVersion is the version of the object last reconciled
+	resourceVersion string
+
+	// due is when the object is reconciled again even if it does not
+	// change; zero if it is not
+	due time.Time
+
+	// failures counts the failed reconciles in a row
+	failures int
+}
+
+// poll reconciles the objects of controller that are due
+func (r *Runner) poll(ctx context.Context, controller Controller, q *queue) {
+	logger := log.FromContext(ctx).WithValues("controller", controller.Name)
+
+	list := controller.NewList()
+	if err := r.Client.List(ctx, list); err != nil {
+		logger.Error(err, "Failed to list objects")
+		return
+	}
+	objects, err := meta.ExtractList(list)
+	if err != nil {
+		logger.Error(err, "Failed to list objects")
+		return
+	}
+
+	listed := make(map[types.NamespacedName]bool, len(objects))
+	for _, o := range objects {
+		object, ok := o.(client.Object)
+		if !ok {
+			continue
+		}
+		name := client.ObjectKeyFromObject(object)
+		listed[name] = true
+
+		state, ok := q.objects[name]
+		if !ok {
+			state = &queued{}
+			q.objects[name] = state
+		}
+		now := time.Now()
+		changed := state.resourceVersion != object.GetResourceVersion()
+		if !changed && (state.due.IsZero() || now.Before(state.due)) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		state.resourceVersion = object.GetResourceVersion()
+		reconcileCtx := log.IntoContext(ctx, logger.WithValues("namespace", name.Namespace, "name", name.Name))
+		result, err := controller.Reconciler.Reconcile(reconcileCtx, ctrl.Request{NamespacedName: name})
+		switch {
+		case err != nil:
+			state.failures++
+			state.due = time.Now().Add(retryDelay(state.failures))
+			logger.Error(err, "Reconciler error", "namespace", name.Namespace, "name", name.Name)
+		case result.RequeueAfter > 0:
+			state.failures = 0
+			state.due = time.Now().Add(result.RequeueAfter)
+		case result.Requeue:
+			state.failures++
+			state.due = time.Now().Add(retryDelay(state.failures))
+		default:
+			state.failures = 0
+			state.due = time.Time{}
+		}
+	}
+	for name := range q.objects {
+		if !listed[name] {
+			delete(q.objects, name)
+		}
+	}
+}
+
+// retryDelay returns the delay before the next reconcile after failures
+// failed reconciles in a row
+func retryDelay(failures int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < failures && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,101 @@
+package standalone
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/controllers"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/providers"
+)
+
+func TestRunnerReconcilesClusters(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	c := newTestClient(t)
+
+	deleted := make(chan struct{}, 1)
+	provider := &providers.MockProvider{
+		DeleteClusterFunc: func(ctx context.Context, cluster *v1alpha1.Cluster) error {
+			deleted <- struct{}{}
+			return nil
+		},
+	}
+	runner := NewRunner(c, Controller{
+		Name:    "cluster",
+		NewList: func() client.ObjectList { return &v1alpha1.ClusterList{} },
+		Reconciler: &controllers.ClusterReconciler{
+			Client:   c,
+			Scheme:   c.Scheme(),
+			Provider: provider,
+			Recorder: NewEventRecorder(c, "cluster-controller"),
+		},
+	})
+	runner.PollInterval = 10 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- runner.Run(ctx) }()
+
+	cluster := newCluster("one")
+	if err := c.Create(ctx, cluster); err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	key := client.ObjectKeyFromObject(cluster)
+
+	// eventually waits for the cluster to be in the state check looks for
+	eventually := func(what string, check func(err error, cluster *v1alpha1.Cluster) bool) {
+		t.Helper()
+		for {
+			var current v1alpha1.Cluster
+			err := c.Get(ctx, key, &current)
+			if check(err, &current) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for %s, cluster is %q: %v", what, current.Status.Phase, err)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	eventually("the cluster to run", func(err error, cluster *v1alpha1.Cluster) bool {
+		return err == nil && cluster.Status.Phase == v1alpha1.ClusterPhaseRunning &&
+			len(cluster.Finalizers) == 1 && cluster.Status.ObservedGeneration == cluster.Generation
+	})
+
+	eventually("the kubeconfig to be published", func(err error, cluster *v1alpha1.Cluster) bool {
+		var secret corev1.Secret
+		return c.Get(ctx, client.ObjectKey{Namespace: "default", Name: v1alpha1.KubeconfigSecretName("one")}, &secret) == nil
+	})
+	var events corev1.EventList
+	if err := c.List(ctx, &events, client.InNamespace("default")); err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(events.Items) == 0 {
+		t.Error("Expected events to be recorded")
+	}
+
+	if err := c.Delete(ctx, cluster); err != nil {
+		t.Fatalf("Failed to delete cluster: %v", err)
+	}
+	eventually("the cluster to be deleted", func(err error, cluster *v1alpha1.Cluster) bool {
+		return apierrors.IsNotFound(err)
+	})
+	select {
+	case <-deleted:
+	default:
+		t.Error("Expected the provider to delete the cluster")
+	}
+	eventually("the events to be removed with the cluster", func(error, *v1alpha1.Cluster) bool {
+		return c.List(ctx, &events, client.InNamespace("default")) == nil && len(events.Items) == 0
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected the runner to stop, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+// Package standalone runs mini-k8s-manager without a Kubernetes API server.
+// Cluster resources, and the Secrets, events, backups and restores that go
+// with them, are kept in a local ClusterStore. A client over the store gives
+// them the semantics the API server gives them, so that the controllers'
+// reconcilers run against it unchanged, driven by a Runner instead of a
+// controller manager.
+package standalone
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by stores for keys they hold no object under
+var ErrNotFound = errors.New("object not found")
+
+// Key identifies a stored object
+type Key struct {
+	// Kind is the group-qualified kind of the object, e.g.
+	// Cluster.cluster.mini-k8s.io, or just the kind for core resources
+	Kind string
+
+	Namespace string
+	Name      string
+}
+
+// String returns the key as kind/namespace/name
+func (k Key) String() string {
+	return k.Kind + "/" + k.Namespace + "/" + k.Name
+}
+
+// ClusterStore persists the serialized objects of standalone mode. It knows
+// nothing of their content; the semantics of the objects are the client's.
+type ClusterStore interface {
+	// Get returns the object stored under key
+	// Returns ErrNotFound if there is none
+	Get(ctx context.Context, key Key) ([]byte, error)
+
+	// List returns the objects of kind in namespace, ordered by name. An
+	// empty kind lists objects of every kind, an empty namespace objects in
+	// every namespace.
+	List(ctx context.Context, kind, namespace string) ([][]byte, error)
+
+	// Update atomically replaces the object stored under key with what update
+	// returns for it. update is passed nil if there is no object under key,
+	// and removes it by returning nil. An error returned by update leaves the
+	// store unchanged and is returned.
+	Update(ctx context.Context, key Key, update func(current []byte) ([]byte, error)) error
+
+	// Close releases the store's resources
+	Close() error
+}
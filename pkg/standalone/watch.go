@@ -0,0 +1,93 @@
+package standalone
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pollingWatch is a watch that lists the watched objects at an interval and
+// sends the differences to the previous listing as events
+type pollingWatch struct {
+	result chan watch.Event
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+var _ watch.Interface = &pollingWatch{}
+
+// Stop implements watch.Interface
+func (w *pollingWatch) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// ResultChan implements watch.Interface
+func (w *pollingWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// run polls list every interval until the watch is stopped or ctx is done
+func (w *pollingWatch) run(ctx context.Context, interval time.Duration, list func() ([]client.Object, error)) {
+	defer close(w.result)
+
+	// seen holds the last listed version of each object by UID
+	seen := map[types.UID]client.Object{}
+	for {
+		objects, err := list()
+		if err != nil {
+			w.send(ctx, watch.Event{Type: watch.Error, Object: &apierrors.NewInternalError(err).ErrStatus})
+			return
+		}
+		listed := make(map[types.UID]bool, len(objects))
+		for _, object := range objects {
+			listed[object.GetUID()] = true
+			previous, ok := seen[object.GetUID()]
+			seen[object.GetUID()] = object
+			event := watch.Event{Type: watch.Added, Object: object}
+			if ok {
+				if previous.GetResourceVersion() == object.GetResourceVersion() {
+					continue
+				}
+				event.Type = watch.Modified
+			}
+			if !w.send(ctx, event) {
+				return
+			}
+		}
+		for uid, object := range seen {
+			if listed[uid] {
+				continue
+			}
+			delete(seen, uid)
+			if !w.send(ctx, watch.Event{Type: watch.Deleted, Object: object}) {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// send sends event, returning false if the watch was stopped first
+func (w *pollingWatch) send(ctx context.Context, event watch.Event) bool {
+	select {
+	case w.result <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-w.stop:
+		return false
+	}
+}
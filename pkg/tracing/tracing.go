@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the service name traces are reported under
+const ServiceName = "mini-k8s-manager"
+
+// instrumentationName identifies the tracer used throughout the manager
+const instrumentationName = "github.com/unmeshjoshi/mini-k8s-manager"
+
+// Attribute keys shared by the manager's spans
+const (
+	ClusterNamespaceKey = attribute.Key("cluster.namespace")
+	ClusterNameKey      = attribute.Key("cluster.name")
+	NodeNameKey         = attribute.Key("node.name")
+	NodeRoleKey         = attribute.Key("node.role")
+	PhaseKey            = attribute.Key("cluster.phase")
+)
+
+// Supported exporters
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Options configures trace export
+type Options struct {
+	// Exporter selects where spans go: none, otlp or file
+	Exporter string
+	// Endpoint is the host:port of the OTLP/HTTP collector. When empty the
+	// standard OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint string
+	// Insecure disables TLS towards the OTLP collector
+	Insecure bool
+	// FilePath is the file spans are written to by the file exporter
+	FilePath string
+	// SampleRatio is the fraction of traces that are sampled, between 0 and 1
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider described by opts and returns a
+// function that flushes and stops it. With the none exporter spans are still
+// created but never recorded, so instrumented code costs next to nothing.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterFile:
+		if opts.FilePath == "" {
+			return nil, fmt.Errorf("the file exporter requires a file path")
+		}
+		file, err := os.OpenFile(opts.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file %s: %w", opts.FilePath, err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		exporter = exp
+		closeFile = file.Close
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q (want %s, %s or %s)", opts.Exporter, ExporterNone, ExporterOTLP, ExporterFile)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	ratio := opts.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			if closeErr := closeFile(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start starts a span named name as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it as failed if err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
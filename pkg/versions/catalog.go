@@ -0,0 +1,241 @@
+// Package versions holds the catalog of Kubernetes versions clusters can run:
+// which node image each version uses, which upgrades between versions are
+// supported and which versions are deprecated.
+package versions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigMapKey is the ConfigMap key holding a catalog
+const ConfigMapKey = "versions.yaml"
+
+// Errors returned when a version or an upgrade is not in the catalog
+var (
+	ErrUnsupportedVersion = errors.New("unsupported Kubernetes version")
+	ErrUnsupportedUpgrade = errors.New("unsupported Kubernetes upgrade")
+)
+
+// Version describes a supported Kubernetes version
+type Version struct {
+	// Version is the Kubernetes version, e.g. v1.29.8; it is also the node image tag
+	Version string `json:"version"`
+
+	// Digest pins the node image of this version, e.g. sha256:0123...
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// MinUpgradeFrom is the oldest version that can be upgraded to this one.
+	// Defaults to the first patch release of the previous minor version.
+	// +optional
+	MinUpgradeFrom string `json:"minUpgradeFrom,omitempty"`
+
+	// MaxUpgradeTo is the newest version this one can be upgraded to.
+	// Defaults to any patch release of the next minor version.
+	// +optional
+	MaxUpgradeTo string `json:"maxUpgradeTo,omitempty"`
+
+	// Deprecated versions can still be used but new clusters get a warning
+	// +optional
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// DeprecationMessage explains the deprecation, e.g. what to move to
+	// +optional
+	DeprecationMessage string `json:"deprecationMessage,omitempty"`
+}
+
+// catalogFile is the serialized form of a catalog
+type catalogFile struct {
+	Versions []Version `json:"versions"`
+}
+
+// Catalog is a set of supported Kubernetes versions
+type Catalog struct {
+	versions map[string]Version
+}
+
+// builtinVersions are the node images published with kind v0.24.0 and
+// v0.23.0, pinned to the digests in their release notes
+var builtinVersions = []Version{
+	{Version: "v1.31.0", Digest: "sha256:53df588e04085fd41ae12de0c3fe4c72f7013bba32a20e7325357a1ac94ba865"},
+	{Version: "v1.30.4", Digest: "sha256:976ea815844d5fa93be213437e3ff5754cd599b040946b5cca43ca45c2047114"},
+	{Version: "v1.30.0", Digest: "sha256:047357ac0cfea04663786a612ba1eaba9702bef25227a794b52890dd8bcd692e"},
+	{Version: "v1.29.8", Digest: "sha256:d46b7aa29567e93b27f7531d258c372e829d7224b25e3fc6ffdefed12476d3aa"},
+	{Version: "v1.29.4", Digest: "sha256:3abb816a5b1061fb15c6e9e60856ec40d56b7b52bcea5f5f1350bc6e2320b6f8"},
+	{Version: "v1.28.13", Digest: "sha256:45d319897776e11167e4698f6b14938eb4d52eb381d9e3d7a9086c16c69a8110"},
+	{Version: "v1.28.9", Digest: "sha256:dca54bc6a6079dd34699d53d7d4ffa2e853e46a20cd12d619a09207e35300bd0"},
+	{Version: "v1.27.16", Digest: "sha256:3fd82731af34efe19cd54ea5c25e882985bafa2c9baefe14f8deab1737d9fabe"},
+	{Version: "v1.27.13", Digest: "sha256:17439fa5b32290e3ead39ead1250dca1d822d94a10d26f1981756cd51b24b9d8"},
+	{Version: "v1.26.15", Digest: "sha256:1cc15d7b1edd2126ef051e359bf864f37bbcf1568e61be4d2ed1df7a3e87b354", Deprecated: true, DeprecationMessage: "Kubernetes 1.26 is end of life, use v1.27 or newer"},
+	{Version: "v1.25.16", Digest: "sha256:6110314339b3b44d10da7d27881849a87e092124afab5956f2e10ecdb463b025", Deprecated: true, DeprecationMessage: "Kubernetes 1.25 is end of life, use v1.27 or newer"},
+}
+
+// Builtin returns the catalog compiled into the manager
+func Builtin() *Catalog {
+	catalog, err := New(builtinVersions...)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// New returns a catalog of the given versions
+func New(versions ...Version) (*Catalog, error) {
+	catalog := &Catalog{versions: map[string]Version{}}
+	for _, v := range versions {
+		if err := catalog.add(v); err != nil {
+			return nil, err
+		}
+	}
+	return catalog, nil
+}
+
+func (c *Catalog) add(v Version) error {
+	if _, err := utilversion.ParseSemantic(v.Version); err != nil {
+		return fmt.Errorf("invalid version %q: %w", v.Version, err)
+	}
+	for _, bound := range []string{v.MinUpgradeFrom, v.MaxUpgradeTo} {
+		if bound == "" {
+			continue
+		}
+		if _, err := utilversion.ParseSemantic(bound); err != nil {
+			return fmt.Errorf("invalid upgrade bound %q of version %s: %w", bound, v.Version, err)
+		}
+	}
+	c.versions[v.Version] = v
+	return nil
+}
+
+// Parse reads a catalog in YAML or JSON form:
+//
+//	versions:
+//	- version: v1.29.8
+//	  digest: sha256:...
+//	  minUpgradeFrom: v1.28.0
+func Parse(data []byte) (*Catalog, error) {
+	var file catalogFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse version catalog: %w", err)
+	}
+	return New(file.Versions...)
+}
+
+// LoadFile reads a catalog from a file
+func LoadFile(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version catalog: %w", err)
+	}
+	return Parse(data)
+}
+
+// FromConfigMap reads a catalog from the ConfigMapKey of a ConfigMap
+func FromConfigMap(cm *corev1.ConfigMap) (*Catalog, error) {
+	data, ok := cm.Data[ConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s/%s has no %s key", cm.Namespace, cm.Name, ConfigMapKey)
+	}
+	return Parse([]byte(data))
+}
+
+// Merge returns a catalog with the versions of c and override, where the
+// entries of override replace those of c for the same version
+func (c *Catalog) Merge(override *Catalog) *Catalog {
+	merged := &Catalog{versions: make(map[string]Version, len(c.versions)+len(override.versions))}
+	for k, v := range c.versions {
+		merged.versions[k] = v
+	}
+	for k, v := range override.versions {
+		merged.versions[k] = v
+	}
+	return merged
+}
+
+// List returns the versions in the catalog, newest first
+func (c *Catalog) List() []Version {
+	list := make([]Version, 0, len(c.versions))
+	for _, v := range c.versions {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return utilversion.MustParseSemantic(list[i].Version).GreaterThan(utilversion.MustParseSemantic(list[j].Version))
+	})
+	return list
+}
+
+// Lookup returns the catalog entry for version
+func (c *Catalog) Lookup(version string) (Version, bool) {
+	v, ok := c.versions[version]
+	return v, ok
+}
+
+// Validate checks that version is in the catalog. Deprecated versions are
+// valid; their deprecation message is returned as a warning.
+func (c *Catalog) Validate(version string) (warning string, err error) {
+	v, ok := c.versions[version]
+	if !ok {
+		return "", fmt.Errorf("%w %q, supported versions are %v", ErrUnsupportedVersion, version, c.names())
+	}
+	if v.Deprecated {
+		warning = fmt.Sprintf("Kubernetes version %s is deprecated", version)
+		if v.DeprecationMessage != "" {
+			warning += ": " + v.DeprecationMessage
+		}
+	}
+	return warning, nil
+}
+
+// ValidateUpgrade checks that a cluster running from can be upgraded to to.
+// Downgrades are never supported. Unless the catalog entries say otherwise, an
+// upgrade may move at most one minor version.
+func (c *Catalog) ValidateUpgrade(from, to string) error {
+	if from == to {
+		return nil
+	}
+	if _, err := c.Validate(to); err != nil {
+		return err
+	}
+	fromVersion, err := utilversion.ParseSemantic(from)
+	if err != nil {
+		return fmt.Errorf("%w from %q: %v", ErrUnsupportedUpgrade, from, err)
+	}
+	toVersion := utilversion.MustParseSemantic(to)
+
+	if toVersion.LessThan(fromVersion) {
+		return fmt.Errorf("%w: downgrading from %s to %s is not supported", ErrUnsupportedUpgrade, from, to)
+	}
+
+	minFrom := utilversion.MajorMinor(toVersion.Major(), 0)
+	if toVersion.Minor() > 0 {
+		minFrom = utilversion.MajorMinor(toVersion.Major(), toVersion.Minor()-1)
+	}
+	if target := c.versions[to]; target.MinUpgradeFrom != "" {
+		minFrom = utilversion.MustParseSemantic(target.MinUpgradeFrom)
+	}
+	if fromVersion.LessThan(minFrom) {
+		return fmt.Errorf("%w: %s can only be upgraded to from %s or newer, not from %s", ErrUnsupportedUpgrade, to, minFrom, from)
+	}
+
+	if source, ok := c.versions[from]; ok && source.MaxUpgradeTo != "" {
+		if utilversion.MustParseSemantic(source.MaxUpgradeTo).LessThan(toVersion) {
+			return fmt.Errorf("%w: %s can only be upgraded up to %s, not to %s", ErrUnsupportedUpgrade, from, source.MaxUpgradeTo, to)
+		}
+	}
+	return nil
+}
+
+// names returns the versions in the catalog, newest first
+func (c *Catalog) names() []string {
+	names := make([]string, 0, len(c.versions))
+	for _, v := range c.List() {
+		names = append(names, v.Version)
+	}
+	return names
+}
@@ -0,0 +1,125 @@
+package versions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const override = `versions:
+- version: v1.29.8
+  digest: sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+- version: v1.32.0
+  minUpgradeFrom: v1.30.0
+- version: v1.27.13
+  deprecated: true
+  deprecationMessage: use v1.28 or newer
+`
+
+func TestParseAndMerge(t *testing.T) {
+	catalog, err := Parse([]byte(override))
+	if err != nil {
+		t.Fatalf("Failed to parse catalog: %v", err)
+	}
+	merged := Builtin().Merge(catalog)
+
+	v, ok := merged.Lookup("v1.29.8")
+	if !ok || v.Digest == "" {
+		t.Errorf("Expected the override to pin v1.29.8, got %+v", v)
+	}
+	if _, ok := merged.Lookup("v1.31.0"); !ok {
+		t.Error("Expected the built-in versions to be kept")
+	}
+	if list := merged.List(); list[0].Version != "v1.32.0" {
+		t.Errorf("Expected the newest version first, got %s", list[0].Version)
+	}
+
+	warning, err := merged.Validate("v1.27.13")
+	if err != nil || !strings.Contains(warning, "use v1.28 or newer") {
+		t.Errorf("Expected a deprecation warning, got %q, %v", warning, err)
+	}
+	if _, err := merged.Validate("v1.29.9"); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected v1.29.9 to be unsupported, got %v", err)
+	}
+
+	if _, err := Parse([]byte("versions:\n- version: latest\n")); err == nil {
+		t.Error("Expected an error for a version that is not semantic")
+	}
+	if _, err := Parse([]byte("versions:\n- version: v1.29.8\n  digets: sha256:0123\n")); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+func TestValidateUpgrade(t *testing.T) {
+	catalog, err := Parse([]byte(override))
+	if err != nil {
+		t.Fatalf("Failed to parse catalog: %v", err)
+	}
+	catalog = Builtin().Merge(catalog)
+
+	tests := []struct {
+		from, to string
+		ok       bool
+	}{
+		{"v1.29.4", "v1.29.4", true},
+		{"v1.29.4", "v1.29.8", true},
+		{"v1.28.9", "v1.29.8", true},
+		{"v1.27.13", "v1.29.8", false},
+		{"v1.29.8", "v1.28.13", false},
+		{"v1.30.4", "v1.32.0", true},
+		{"v1.29.8", "v1.32.0", false},
+		{"v1.29.8", "v1.29.9", false},
+	}
+	for _, tt := range tests {
+		err := catalog.ValidateUpgrade(tt.from, tt.to)
+		if tt.ok && err != nil {
+			t.Errorf("Expected upgrade from %s to %s to be supported, got %v", tt.from, tt.to, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("Expected upgrade from %s to %s to be rejected", tt.from, tt.to)
+		}
+	}
+
+	limited, err := New(Version{Version: "v1.29.4", MaxUpgradeTo: "v1.29.8"}, Version{Version: "v1.29.8"}, Version{Version: "v1.30.0"})
+	if err != nil {
+		t.Fatalf("Failed to create catalog: %v", err)
+	}
+	if err := limited.ValidateUpgrade("v1.29.4", "v1.30.0"); !errors.Is(err, ErrUnsupportedUpgrade) {
+		t.Errorf("Expected the upgrade past maxUpgradeTo to be rejected, got %v", err)
+	}
+	if err := limited.ValidateUpgrade("v1.29.4", "v1.29.8"); err != nil {
+		t.Errorf("Expected the upgrade up to maxUpgradeTo to be supported, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "versions.yaml")
+	if err := os.WriteFile(path, []byte(override), 0o644); err != nil {
+		t.Fatalf("Failed to write catalog: %v", err)
+	}
+	catalog, err := Load(context.Background(), nil, path, "")
+	if err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+	if _, ok := catalog.Lookup("v1.32.0"); !ok {
+		t.Error("Expected the versions from the file")
+	}
+	if _, err := Load(context.Background(), nil, "", "no-namespace"); err == nil {
+		t.Error("Expected an error for a ConfigMap reference without namespace")
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "versions", Namespace: "system"}}
+	if _, err := FromConfigMap(cm); err == nil {
+		t.Errorf("Expected an error for a ConfigMap without the %s key", ConfigMapKey)
+	}
+	cm.Data = map[string]string{ConfigMapKey: override}
+	if _, err := FromConfigMap(cm); err != nil {
+		t.Errorf("Failed to read catalog from ConfigMap: %v", err)
+	}
+}
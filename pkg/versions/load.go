@@ -0,0 +1,43 @@
+package versions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Load returns the built-in catalog with the overrides from file and from the
+// ConfigMap named by configMap (namespace/name) merged over it. Either may be
+// empty; reader is only used if configMap is given.
+func Load(ctx context.Context, reader client.Reader, file, configMap string) (*Catalog, error) {
+	catalog := Builtin()
+
+	if file != "" {
+		override, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		catalog = catalog.Merge(override)
+	}
+
+	if configMap != "" {
+		namespace, name, ok := strings.Cut(configMap, "/")
+		if !ok || namespace == "" || name == "" {
+			return nil, fmt.Errorf("invalid version catalog ConfigMap %q, expected namespace/name", configMap)
+		}
+		var cm corev1.ConfigMap
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &cm); err != nil {
+			return nil, fmt.Errorf("failed to get version catalog ConfigMap %s: %w", configMap, err)
+		}
+		override, err := FromConfigMap(&cm)
+		if err != nil {
+			return nil, err
+		}
+		catalog = catalog.Merge(override)
+	}
+
+	return catalog, nil
+}
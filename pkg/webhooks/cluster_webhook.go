@@ -0,0 +1,220 @@
+// Package webhooks holds the admission webhooks for the manager's resources
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	clusterv1alpha1 "github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+// +kubebuilder:webhook:path=/validate-cluster-mini-k8s-io-v1alpha1-cluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=cluster.mini-k8s.io,resources=clusters,verbs=create;update,versions=v1alpha1,name=vcluster.mini-k8s.io,admissionReviewVersions=v1
+
+// ClusterValidator rejects Clusters whose Kubernetes version is not in the
+// version catalog and version changes the catalog does not support as upgrades
+type ClusterValidator struct {
+	Versions *versions.Catalog
+}
+
+var _ admission.CustomValidator = &ClusterValidator{}
+
+// SetupWithManager registers the validating webhook with the manager
+func (v *ClusterValidator) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&clusterv1alpha1.Cluster{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate checks the version, control plane size and worker rollout
+// strategy of a new Cluster.
+// Deprecated versions are accepted with a warning. Clones may leave the
+// version to their source, and adopted clusters to the kind cluster.
+func (v *ClusterValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	cluster, ok := obj.(*clusterv1alpha1.Cluster)
+	if !ok {
+		return nil, fmt.Errorf("expected a Cluster, got %T", obj)
+	}
+	// Clones take the version and size of their source, which were checked
+	// already, and adopted clusters those of the kind cluster they take over
+	if cluster.Spec.CloneFrom != nil && cluster.Spec.KubernetesVersion == "" {
+		return nil, nil
+	}
+	if _, adopt := cluster.Annotations[clusterv1alpha1.AdoptAnnotation]; adopt && cluster.Spec.KubernetesVersion == "" {
+		return nil, nil
+	}
+	if err := validateControlPlaneCount(cluster); err != nil {
+		return nil, err
+	}
+	if err := validateRolloutStrategy(cluster); err != nil {
+		return nil, err
+	}
+
+	warning, err := v.Versions.Validate(cluster.Spec.KubernetesVersion)
+	if err != nil {
+		return nil, invalid(cluster, err)
+	}
+	return warnings(warning), nil
+}
+
+// ValidateUpdate checks a change of the Kubernetes version against the upgrade
+// paths in the catalog, and changes of the control plane size and of the
+// worker rollout strategy. The kind cluster an adopted Cluster took over
+// cannot be changed. Updates that
+// leave the version alone are accepted, so clusters on versions that have
+// since left the catalog can still be changed.
+func (v *ClusterValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldCluster, ok := oldObj.(*clusterv1alpha1.Cluster)
+	if !ok {
+		return nil, fmt.Errorf("expected a Cluster, got %T", oldObj)
+	}
+	cluster, ok := newObj.(*clusterv1alpha1.Cluster)
+	if !ok {
+		return nil, fmt.Errorf("expected a Cluster, got %T", newObj)
+	}
+	if err := validateAdoptAnnotation(oldCluster, cluster); err != nil {
+		return nil, err
+	}
+	// The spec of an adopted cluster is filled in from the kind cluster it
+	// took over, whose nodes run what they run
+	if _, adopted := oldCluster.Annotations[clusterv1alpha1.AdoptAnnotation]; adopted && oldCluster.Spec.KubernetesVersion == "" {
+		return nil, nil
+	}
+	if cluster.Spec.ControlPlane.Count != oldCluster.Spec.ControlPlane.Count {
+		if err := validateControlPlaneCount(cluster); err != nil {
+			return nil, err
+		}
+	}
+	if !reflect.DeepEqual(cluster.Spec.Workers.RolloutStrategy, oldCluster.Spec.Workers.RolloutStrategy) {
+		if err := validateRolloutStrategy(cluster); err != nil {
+			return nil, err
+		}
+	}
+	if cluster.Spec.KubernetesVersion == oldCluster.Spec.KubernetesVersion {
+		return nil, nil
+	}
+
+	warning, err := v.Versions.Validate(cluster.Spec.KubernetesVersion)
+	if err != nil {
+		return nil, invalid(cluster, err)
+	}
+
+	// Upgrade from what the nodes run; an earlier rejected change of the spec
+	// never reached them
+	from := oldCluster.Status.KubernetesVersion
+	if from == "" {
+		from = oldCluster.Spec.KubernetesVersion
+	}
+	// The spec of a clone is filled in from its source; nothing is upgraded
+	if from == "" {
+		return warnings(warning), nil
+	}
+	if err := v.Versions.ValidateUpgrade(from, cluster.Spec.KubernetesVersion); err != nil {
+		return nil, invalid(cluster, err)
+	}
+	return warnings(warning), nil
+}
+
+// ValidateDelete accepts every deletion
+func (v *ClusterValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateAdoptAnnotation rejects changes of the kind cluster a Cluster
+// adopted, as its nodes are found through the annotation
+func validateAdoptAnnotation(oldCluster, cluster *clusterv1alpha1.Cluster) error {
+	source, adopted := oldCluster.Annotations[clusterv1alpha1.AdoptAnnotation]
+	if current, ok := cluster.Annotations[clusterv1alpha1.AdoptAnnotation]; !adopted || (ok && current == source) {
+		return nil
+	}
+	path := field.NewPath("metadata", "annotations").Key(clusterv1alpha1.AdoptAnnotation)
+	return apierrors.NewInvalid(
+		clusterv1alpha1.GroupVersion.WithKind("Cluster").GroupKind(),
+		cluster.Name,
+		field.ErrorList{field.Forbidden(path, "cannot be changed or removed, the nodes of the adopted kind cluster are found through it")},
+	)
+}
+
+// validateControlPlaneCount rejects control planes with an even number of
+// nodes, whose etcd would lose its majority as soon as one member fails
+func validateControlPlaneCount(cluster *clusterv1alpha1.Cluster) error {
+	count := cluster.Spec.ControlPlane.Count
+	if count >= 1 && count%2 == 1 {
+		return nil
+	}
+	path := field.NewPath("spec", "controlPlane", "count")
+	return apierrors.NewInvalid(
+		clusterv1alpha1.GroupVersion.WithKind("Cluster").GroupKind(),
+		cluster.Name,
+		field.ErrorList{field.Invalid(path, count, "must be an odd number, so etcd keeps a majority when a member fails")},
+	)
+}
+
+// validateRolloutStrategy rejects worker rollout strategies with negative or
+// malformed limits, and ones that allow neither surge nor unavailability
+func validateRolloutStrategy(cluster *clusterv1alpha1.Cluster) error {
+	strategy := cluster.Spec.Workers.RolloutStrategy
+	if strategy == nil {
+		return nil
+	}
+	path := field.NewPath("spec", "workers", "rolloutStrategy")
+	surge, surgeErr := rolloutLimit(path.Child("maxSurge"), strategy.MaxSurge, clusterv1alpha1.DefaultMaxSurge)
+	unavailable, unavailableErr := rolloutLimit(path.Child("maxUnavailable"), strategy.MaxUnavailable, clusterv1alpha1.DefaultMaxUnavailable)
+
+	var errs field.ErrorList
+	for _, err := range []*field.Error{surgeErr, unavailableErr} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 && surge == 0 && unavailable == 0 {
+		errs = append(errs, field.Invalid(path.Child("maxUnavailable"), unavailable, "must not be 0 when maxSurge is 0"))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(clusterv1alpha1.GroupVersion.WithKind("Cluster").GroupKind(), cluster.Name, errs)
+}
+
+// rolloutLimit resolves a rollout limit against 100 workers, so that any
+// percentage above 0% counts as allowing some
+func rolloutLimit(path *field.Path, value *intstr.IntOrString, defaultValue int) (int, *field.Error) {
+	if value == nil {
+		return defaultValue, nil
+	}
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(value, 100, true)
+	if err != nil {
+		return 0, field.Invalid(path, value.String(), "must be a number or a percentage")
+	}
+	if scaled < 0 {
+		return 0, field.Invalid(path, value.String(), "must not be negative")
+	}
+	return scaled, nil
+}
+
+// invalid returns the Invalid API error for a rejected Kubernetes version
+func invalid(cluster *clusterv1alpha1.Cluster, err error) error {
+	path := field.NewPath("spec", "kubernetesVersion")
+	return apierrors.NewInvalid(
+		clusterv1alpha1.GroupVersion.WithKind("Cluster").GroupKind(),
+		cluster.Name,
+		field.ErrorList{field.Invalid(path, cluster.Spec.KubernetesVersion, err.Error())},
+	)
+}
+
+// warnings turns an optional warning into admission warnings
+func warnings(warning string) admission.Warnings {
+	if warning == "" {
+		return nil
+	}
+	return admission.Warnings{warning}
+}
@@ -0,0 +1,173 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/api/v1alpha1"
+	"github.com/unmeshjoshi/mini-k8s-manager/pkg/versions"
+)
+
+func newCluster(version string) *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec: v1alpha1.ClusterSpec{
+			KubernetesVersion: version,
+			ControlPlane:      v1alpha1.ControlPlaneConfig{Count: 1},
+		},
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	validator := &ClusterValidator{Versions: versions.Builtin()}
+
+	if warnings, err := validator.ValidateCreate(context.Background(), newCluster(v1alpha1.TestKubernetesVersion)); err != nil || len(warnings) != 0 {
+		t.Errorf("Expected %s to be accepted without warnings, got %v, %v", v1alpha1.TestKubernetesVersion, warnings, err)
+	}
+
+	_, err := validator.ValidateCreate(context.Background(), newCluster("v1.27.99"))
+	if !apierrors.IsInvalid(err) {
+		t.Errorf("Expected an unsupported version to be rejected as invalid, got %v", err)
+	}
+
+	warnings, err := validator.ValidateCreate(context.Background(), newCluster("v1.25.16"))
+	if err != nil || len(warnings) != 1 {
+		t.Errorf("Expected a deprecated version to be accepted with a warning, got %v, %v", warnings, err)
+	}
+
+	clone := newCluster("")
+	clone.Spec.CloneFrom = &corev1.LocalObjectReference{Name: "source"}
+	if _, err := validator.ValidateCreate(context.Background(), clone); err != nil {
+		t.Errorf("Expected a clone without a version to be accepted, got %v", err)
+	}
+	if _, err := validator.ValidateUpdate(context.Background(), clone, newCluster(v1alpha1.TestKubernetesVersion)); err != nil {
+		t.Errorf("Expected the version of the source to be accepted for a clone, got %v", err)
+	}
+	adopt := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Name:        "dev",
+		Namespace:   "default",
+		Annotations: map[string]string{v1alpha1.AdoptAnnotation: "kind"},
+	}}
+	if _, err := validator.ValidateCreate(context.Background(), adopt); err != nil {
+		t.Errorf("Expected an adopting cluster without a spec to be accepted, got %v", err)
+	}
+	adopted := adopt.DeepCopy()
+	adopted.Spec = v1alpha1.ClusterSpec{KubernetesVersion: "v1.27.99", ControlPlane: v1alpha1.ControlPlaneConfig{Count: 2}}
+	if _, err := validator.ValidateUpdate(context.Background(), adopt, adopted); err != nil {
+		t.Errorf("Expected the spec derived from the kind cluster to be accepted, got %v", err)
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	validator := &ClusterValidator{Versions: versions.Builtin()}
+
+	old := newCluster("v1.27.13")
+	old.Status.KubernetesVersion = "v1.27.13"
+
+	if _, err := validator.ValidateUpdate(context.Background(), old, newCluster("v1.28.13")); err != nil {
+		t.Errorf("Expected an upgrade by one minor version to be accepted, got %v", err)
+	}
+	if _, err := validator.ValidateUpdate(context.Background(), old, newCluster("v1.29.8")); !apierrors.IsInvalid(err) {
+		t.Errorf("Expected an upgrade by two minor versions to be rejected, got %v", err)
+	}
+
+	// The nodes still run v1.27.13 after a rejected change to v1.30.4
+	old.Spec.KubernetesVersion = "v1.30.4"
+	if _, err := validator.ValidateUpdate(context.Background(), old, newCluster("v1.28.9")); err != nil {
+		t.Errorf("Expected the upgrade to be checked against the running version, got %v", err)
+	}
+
+	// Clusters on versions that left the catalog can still be changed otherwise
+	outdated := newCluster("v1.24.0")
+	scaled := newCluster("v1.24.0")
+	scaled.Spec.Workers.Count = 2
+	if _, err := validator.ValidateUpdate(context.Background(), outdated, scaled); err != nil {
+		t.Errorf("Expected an update that keeps the version to be accepted, got %v", err)
+	}
+}
+
+func TestValidateControlPlaneCount(t *testing.T) {
+	validator := &ClusterValidator{Versions: versions.Builtin()}
+
+	even := newCluster(v1alpha1.TestKubernetesVersion)
+	even.Spec.ControlPlane.Count = 2
+	if _, err := validator.ValidateCreate(context.Background(), even); !apierrors.IsInvalid(err) {
+		t.Errorf("Expected an even control plane to be rejected, got %v", err)
+	}
+
+	old := newCluster(v1alpha1.TestKubernetesVersion)
+	scaled := newCluster(v1alpha1.TestKubernetesVersion)
+	scaled.Spec.ControlPlane.Count = 3
+	if _, err := validator.ValidateUpdate(context.Background(), old, scaled); err != nil {
+		t.Errorf("Expected scaling the control plane to 3 nodes to be accepted, got %v", err)
+	}
+	if _, err := validator.ValidateUpdate(context.Background(), scaled, even); !apierrors.IsInvalid(err) {
+		t.Errorf("Expected scaling the control plane to 2 nodes to be rejected, got %v", err)
+	}
+
+	// Clusters that already have an even control plane can still be changed otherwise
+	resized := even.DeepCopy()
+	resized.Spec.Workers.Count = 2
+	if _, err := validator.ValidateUpdate(context.Background(), even, resized); err != nil {
+		t.Errorf("Expected an update that keeps the control plane size to be accepted, got %v", err)
+	}
+}
+
+func TestValidateRolloutStrategy(t *testing.T) {
+	validator := &ClusterValidator{Versions: versions.Builtin()}
+	withStrategy := func(maxSurge, maxUnavailable *intstr.IntOrString) *v1alpha1.Cluster {
+		cluster := newCluster(v1alpha1.TestKubernetesVersion)
+		cluster.Spec.Workers.RolloutStrategy = &v1alpha1.RolloutStrategy{MaxSurge: maxSurge, MaxUnavailable: maxUnavailable}
+		return cluster
+	}
+	value := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	valid := []*v1alpha1.Cluster{
+		withStrategy(nil, nil),
+		withStrategy(value(intstr.FromInt32(0)), value(intstr.FromString("10%"))),
+		withStrategy(value(intstr.FromString("50%")), value(intstr.FromInt32(0))),
+	}
+	for _, cluster := range valid {
+		if _, err := validator.ValidateCreate(context.Background(), cluster); err != nil {
+			t.Errorf("Expected %+v to be accepted, got %v", cluster.Spec.Workers.RolloutStrategy, err)
+		}
+	}
+
+	invalid := []*v1alpha1.Cluster{
+		withStrategy(value(intstr.FromInt32(0)), nil),
+		withStrategy(value(intstr.FromInt32(-1)), nil),
+		withStrategy(nil, value(intstr.FromString("half"))),
+	}
+	for _, cluster := range invalid {
+		if _, err := validator.ValidateCreate(context.Background(), cluster); !apierrors.IsInvalid(err) {
+			t.Errorf("Expected %+v to be rejected, got %v", cluster.Spec.Workers.RolloutStrategy, err)
+		}
+		if _, err := validator.ValidateUpdate(context.Background(), newCluster(v1alpha1.TestKubernetesVersion), cluster); !apierrors.IsInvalid(err) {
+			t.Errorf("Expected a change to %+v to be rejected, got %v", cluster.Spec.Workers.RolloutStrategy, err)
+		}
+	}
+}
+
+func TestValidateAdoptAnnotation(t *testing.T) {
+	validator := &ClusterValidator{Versions: versions.Builtin()}
+	adopted := newCluster(v1alpha1.TestKubernetesVersion)
+	adopted.Annotations = map[string]string{v1alpha1.AdoptAnnotation: "kind"}
+
+	scaled := adopted.DeepCopy()
+	scaled.Spec.Workers.Count = 2
+	if _, err := validator.ValidateUpdate(context.Background(), adopted, scaled); err != nil {
+		t.Errorf("Expected an update that keeps the adopt annotation to be accepted, got %v", err)
+	}
+	for _, annotations := range []map[string]string{nil, {v1alpha1.AdoptAnnotation: "other"}} {
+		changed := adopted.DeepCopy()
+		changed.Annotations = annotations
+		if _, err := validator.ValidateUpdate(context.Background(), adopted, changed); !apierrors.IsInvalid(err) {
+			t.Errorf("Expected adopt annotations %v to be rejected, got %v", annotations, err)
+		}
+	}
+}